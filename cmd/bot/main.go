@@ -5,9 +5,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sub-cos-counter/internal/api"
 	"sub-cos-counter/internal/bot"
 	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/migrate"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/rates"
 	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/sqlite"
+	"sub-cos-counter/internal/scheduler"
 	"sub-cos-counter/internal/services"
 	"syscall"
 	"time"
@@ -16,6 +22,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -34,47 +45,145 @@ func main() {
 		log.Printf("Personal bot mode - allowed user: %d", cfg.Telegram.AllowedUser)
 	}
 
+	if len(cfg.Telegram.Access) > 0 {
+		log.Printf("Access control enabled - %d allow-list rules", len(cfg.Telegram.Access))
+	}
+
 	// Connect to database
 	ctx := context.Background()
 
-	// Create connection pool configuration
-	poolConfig, err := pgxpool.ParseConfig(cfg.GetDatabaseURL())
-	if err != nil {
-		log.Fatalf("Failed to parse database URL: %v", err)
-	}
+	var storage *repository.Storage
+	var databaseStates func(ttl time.Duration) bot.StateStore
+	var migrations *migrate.Runner
+	var closeDatabase func()
+	switch cfg.Database.Driver {
+	case config.DriverSQLite:
+		db, err := sqlite.Open(ctx, cfg.Database.Path)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		log.Printf("Using SQLite database %s", cfg.Database.Path)
 
-	// Configure connection pool settings
-	poolConfig.MaxConns = int32(cfg.Database.MaxConnections)
-	poolConfig.MaxConnIdleTime = time.Duration(cfg.Database.MaxIdleTime) * time.Minute
-	poolConfig.MaxConnLifetime = time.Duration(cfg.Database.ConnMaxLifetime) * time.Minute
+		storage = sqlite.NewStorage(db)
+		databaseStates = func(ttl time.Duration) bot.StateStore {
+			return sqlite.NewStateRepository(db, ttl, bot.StateIdle)
+		}
+		migrations, err = migrate.SQLite(db, log.Printf)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		closeDatabase = func() { db.Close() }
+	default:
+		dbPool := connectPostgres(ctx, cfg)
 
-	// Create database pool
-	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		storage = repository.NewStorage(dbPool)
+		databaseStates = func(ttl time.Duration) bot.StateStore {
+			return repository.NewStateRepository(dbPool, ttl, bot.StateIdle)
+		}
+		migrations, err = migrate.Postgres(dbPool, log.Printf)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		closeDatabase = dbPool.Close
 	}
-	defer dbPool.Close()
+	defer closeDatabase()
 
-	// Test database connection
-	if err := dbPool.Ping(ctx); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+	// Bring the schema up to date, rows older than multi-user support go to allowed_user
+	migrations.SetOwner(cfg.Telegram.AllowedUser)
+	if cfg.Database.AutoMigrate {
+		applied, err := migrations.Up(ctx, false)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database schema is up to date")
+		}
 	}
-	log.Println("Successfully connected to database")
 
 	// Initialize repositories
-	subscriptionRepo := repository.NewSubscriptionRepository(dbPool)
-	paymentRepo := repository.NewPaymentRepository(dbPool)
+	uow := storage.UnitOfWork
+	subscriptionRepo := storage.Subscriptions
+	paymentRepo := storage.Payments
+	reminderRepo := storage.Reminders
+	priceHistoryRepo := storage.PriceHistory
+	exchangeRateRepo := storage.ExchangeRates
+	userSettingsRepo := storage.Settings
+	categoryRepo := storage.Categories
+	budgetRepo := storage.Budgets
+
+	// Initialize exchange rate provider
+	var rateProvider rates.Provider
+	if cfg.Exchange.Provider == "fixture" {
+		rateProvider = rates.NewFixtureProvider(cfg.Exchange.FixturePath)
+	}
 
 	// Initialize services
-	subscriptionService := services.NewSubscriptionService(subscriptionRepo, paymentRepo)
-	analyticsService := services.NewAnalyticsService(paymentRepo, subscriptionRepo)
+	exchangeService := services.NewExchangeService(exchangeRateRepo, userSettingsRepo, rateProvider,
+		models.Currency(cfg.Exchange.BaseCurrency))
+	categoryService := services.NewCategoryService(categoryRepo)
+	subscriptionService := services.NewSubscriptionService(uow, subscriptionRepo, paymentRepo, priceHistoryRepo, categoryService)
+	analyticsService := services.NewAnalyticsService(paymentRepo, subscriptionRepo, priceHistoryRepo, exchangeService)
+	budgetService := services.NewBudgetService(budgetRepo, subscriptionRepo, analyticsService, categoryService)
+	reminderService := services.NewReminderService(subscriptionRepo, reminderRepo,
+		cfg.Notifications.DaysBefore, cfg.Notifications.TrialDaysBefore, time.Duration(cfg.Notifications.SnoozeHours)*time.Hour)
+	renewalService := services.NewRenewalService(uow, subscriptionRepo, paymentRepo, budgetService, cfg.Renewal.GraceDays)
+	backupService := services.NewBackupService(uow, subscriptionRepo, paymentRepo, priceHistoryRepo, userSettingsRepo, categoryRepo, budgetRepo)
+
+	// Initialize conversation state storage
+	stateTTL := time.Duration(cfg.State.TTL) * time.Minute
+	var states bot.StateStore
+	if cfg.State.Storage == "memory" {
+		states = bot.NewMemoryStateStore(stateTTL)
+	} else {
+		states = databaseStates(stateTTL)
+	}
 
 	// Initialize bot
-	telegramBot, err := bot.NewBot(cfg, subscriptionService, analyticsService)
+	telegramBot, err := bot.NewBot(cfg, subscriptionService, categoryService, budgetService, analyticsService, reminderService, exchangeService, backupService, states)
 	if err != nil {
 		log.Fatalf("Failed to initialize bot: %v", err)
 	}
 
+	// Initialize HTTP API
+	var apiServer *api.Server
+	if cfg.API.Enabled {
+		apiServer = api.NewServer(cfg, subscriptionService, categoryService, analyticsService)
+	}
+
+	// Initialize background jobs
+	jobs := scheduler.New()
+	if stateTTL > 0 {
+		jobs.Add("expired conversations cleanup", time.Hour, func(ctx context.Context) error {
+			removed, err := states.DeleteExpired(ctx)
+			if removed > 0 {
+				log.Printf("Removed %d abandoned conversations", removed)
+			}
+			return err
+		})
+	}
+	if cfg.Renewal.Enabled {
+		jobs.Add("subscription renewals", time.Duration(cfg.Renewal.CheckInterval)*time.Minute,
+			func(ctx context.Context) error {
+				return renewalService.ProcessRenewals(ctx, telegramBot)
+			})
+	}
+	if rateProvider != nil {
+		jobs.Add("exchange rates refresh", time.Duration(cfg.Exchange.RefreshInterval)*time.Minute,
+			func(ctx context.Context) error {
+				saved, err := exchangeService.RefreshRates(ctx)
+				if saved > 0 {
+					log.Printf("Updated %d exchange rates from %s", saved, rateProvider.Name())
+				}
+				return err
+			})
+	}
+	if cfg.Notifications.Enabled {
+		jobs.Add("payment reminders", time.Duration(cfg.Notifications.CheckInterval)*time.Minute,
+			func(ctx context.Context) error {
+				return reminderService.SendReminders(ctx, telegramBot)
+			})
+	}
+
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -87,11 +196,19 @@ func main() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		// Stop bot
-		telegramBot.Stop()
+		// Stop bot and background jobs
+		if err := telegramBot.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Webhook server shutdown error: %v", err)
+		}
+		if apiServer != nil {
+			if err := apiServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("API server shutdown error: %v", err)
+			}
+		}
+		jobs.Stop()
 
 		// Close database connections
-		dbPool.Close()
+		closeDatabase()
 
 		log.Println("Shutdown complete")
 
@@ -106,10 +223,45 @@ func main() {
 
 	// Start bot
 	log.Printf("Starting %s...", cfg.App.Name)
+	if cfg.Telegram.UseWebhook {
+		log.Printf("Webhook mode - listening on %s", cfg.Telegram.WebhookListen)
+	}
 	if cfg.IsDevelopment() {
 		log.Println("Bot is running in development mode")
 		log.Printf("Send /start to the bot to begin!")
 	}
 
+	jobs.Start(ctx)
+	if apiServer != nil {
+		go apiServer.ListenAndServe()
+	}
 	telegramBot.Start()
 }
+
+// connectPostgres creates the connection pool and checks that the database is reachable
+func connectPostgres(ctx context.Context, cfg *config.Config) *pgxpool.Pool {
+	// Create connection pool configuration
+	poolConfig, err := pgxpool.ParseConfig(cfg.GetDatabaseURL())
+	if err != nil {
+		log.Fatalf("Failed to parse database URL: %v", err)
+	}
+
+	// Configure connection pool settings
+	poolConfig.MaxConns = int32(cfg.Database.MaxConnections)
+	poolConfig.MaxConnIdleTime = time.Duration(cfg.Database.MaxIdleTime) * time.Minute
+	poolConfig.MaxConnLifetime = time.Duration(cfg.Database.ConnMaxLifetime) * time.Minute
+
+	// Create database pool
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Test database connection
+	if err := dbPool.Ping(ctx); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+	log.Println("Successfully connected to database")
+
+	return dbPool
+}
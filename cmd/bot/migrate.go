@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/migrate"
+	"sub-cos-counter/internal/repository/sqlite"
+)
+
+const migrateUsage = `Usage: bot migrate [-dry-run] [command]
+
+Commands:
+  up          apply every pending migration (default)
+  down [N]    revert the last N migrations, 1 by default
+  status      show the schema version and pending migrations
+
+Flags:
+`
+
+// runMigrate handles "bot migrate", which changes the schema without starting the bot
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	command := flags.Arg(0)
+	if command == "" {
+		command = "up"
+	}
+	steps := 1
+	if command == "down" && flags.NArg() > 1 {
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid number of migrations to revert: %s", flags.Arg(1))
+		}
+		steps = n
+	}
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	runner, closeDatabase := openMigrations(ctx, cfg)
+	defer closeDatabase()
+	runner.SetOwner(cfg.Telegram.AllowedUser)
+
+	switch command {
+	case "up":
+		applied, err := runner.Up(ctx, *dryRun)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database schema is up to date")
+		}
+	case "down":
+		reverted, err := runner.Down(ctx, steps, *dryRun)
+		if err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert")
+		}
+	case "status":
+		status, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get schema version: %v", err)
+		}
+		fmt.Printf("Schema version: %d of %d\n", status.Current, status.Latest)
+		if status.Baseline {
+			fmt.Println("Existing tables without a schema version, the first migration will be recorded as applied")
+		}
+		for _, m := range status.Pending {
+			fmt.Printf("Pending: %s\n", m)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// openMigrations connects to the configured database and returns its migration runner
+func openMigrations(ctx context.Context, cfg *config.Config) (*migrate.Runner, func()) {
+	switch cfg.Database.Driver {
+	case config.DriverSQLite:
+		db, err := sqlite.Open(ctx, cfg.Database.Path)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		runner, err := migrate.SQLite(db, log.Printf)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		return runner, func() { db.Close() }
+	default:
+		dbPool := connectPostgres(ctx, cfg)
+		runner, err := migrate.Postgres(dbPool, log.Printf)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		return runner, dbPool.Close
+	}
+}
@@ -90,20 +90,19 @@ deploy() {
     
     # Force remove conflicting containers if they exist
     log "Cleaning up any conflicting containers..."
-    docker rm -f subscription-bot-db subscription-bot subscription-bot-atlas 2>/dev/null || true
+    docker rm -f subscription-bot-db subscription-bot 2>/dev/null || true
     
     # Remove conflicting networks if they exist
     docker network rm docker_subscription-bot-network 2>/dev/null || true
     
+    # Run migrations, the bot service starts the database and waits for it
+    log "Running database migrations..."
+    docker-compose -f $COMPOSE_FILE -f $COMPOSE_PROD run --rm bot ./bot migrate up || error "Migration failed"
+    
     # Start services
     log "Starting services..."
     docker-compose -f $COMPOSE_FILE -f $COMPOSE_PROD up -d
     
-    # Run migrations
-    log "Running database migrations..."
-    sleep 10  # Wait for database to be ready
-    docker-compose -f $COMPOSE_FILE --profile migration run --rm atlas || warning "Migration failed or no changes"
-    
     success "Deployment completed successfully"
 }
 
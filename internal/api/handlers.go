@@ -0,0 +1,330 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+const (
+	dateLayout       = "2006-01-02"
+	monthLayout      = "2006-01"
+	defaultPageSize  = 50
+	defaultDaysAhead = 7
+	maxDaysAhead     = 366
+)
+
+type subscriptionsResponse struct {
+	Subscriptions []*models.Subscription `json:"subscriptions"`
+}
+
+type categoriesListResponse struct {
+	Categories []*models.UserCategory `json:"categories"`
+}
+
+type paymentsResponse struct {
+	Payments []*models.Payment `json:"payments"`
+	Older    string            `json:"older,omitempty"` // cursor of the next page towards older payments
+	Newer    string            `json:"newer,omitempty"` // cursor of the next page towards newer payments
+}
+
+type monthlyResponse struct {
+	Month    string                  `json:"month"`
+	Payments []models.PaymentSummary `json:"payments"`
+}
+
+type categoriesResponse struct {
+	From       string                                      `json:"from"`
+	To         string                                      `json:"to"`
+	Categories map[models.Category][]models.PaymentSummary `json:"categories"`
+}
+
+// handleListSubscriptions returns active subscriptions, or every one with
+// ?all=true, only those with the tag of ?tag when it is set
+func (s *Server) handleListSubscriptions(w http.ResponseWriter, r *http.Request, userID int64) {
+	all, err := parseBool(r, "all")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var subscriptions []*models.Subscription
+	if all {
+		subscriptions, err = s.subscriptions.GetAllSubscriptions(r.Context(), userID)
+	} else {
+		subscriptions, err = s.subscriptions.GetAllActiveSubscriptions(r.Context(), userID)
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if tag := parseTag(r.URL.Query().Get("tag")); tag != "" {
+		var tagged []*models.Subscription
+		for _, sub := range subscriptions {
+			if sub.HasTag(tag) {
+				tagged = append(tagged, sub)
+			}
+		}
+		subscriptions = tagged
+	}
+
+	writeJSON(w, http.StatusOK, subscriptionsResponse{Subscriptions: nonNil(subscriptions)})
+}
+
+func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req models.CreateSubscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.UserID = userID
+	req.NextPayment = models.DateOnly(req.NextPayment)
+	if req.TrialEndsAt != nil {
+		trialEndsAt := models.DateOnly(*req.TrialEndsAt)
+		req.TrialEndsAt = &trialEndsAt
+	}
+	if req.Category == "" {
+		req.Category = models.CategoryOther
+	}
+
+	sub, err := s.subscriptions.CreateSubscription(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+func (s *Server) handleGetSubscription(w http.ResponseWriter, r *http.Request, userID int64) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := s.subscriptions.GetSubscriptionByID(r.Context(), userID, id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) handleUpdateSubscription(w http.ResponseWriter, r *http.Request, userID int64) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSubscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.NextPayment != nil {
+		nextPayment := models.DateOnly(*req.NextPayment)
+		req.NextPayment = &nextPayment
+	}
+	if req.TrialEndsAt != nil {
+		trialEndsAt := models.DateOnly(*req.TrialEndsAt)
+		req.TrialEndsAt = &trialEndsAt
+	}
+
+	sub, err := s.subscriptions.UpdateSubscription(r.Context(), userID, id, &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// handleDeleteSubscription cancels the subscription, like the bot does it
+// stays in the history with its payments
+func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request, userID int64) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.subscriptions.DeleteSubscription(r.Context(), userID, id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListCategories returns the categories of the user, archived ones included
+func (s *Server) handleListCategories(w http.ResponseWriter, r *http.Request, userID int64) {
+	categories, err := s.categories.GetCategories(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, categoriesListResponse{Categories: nonNil(categories)})
+}
+
+// handleListPayments returns a page of the payment history, newest first
+func (s *Server) handleListPayments(w http.ResponseWriter, r *http.Request, userID int64) {
+	req, err := parsePageRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Filter.Category != "" {
+		known, err := s.hasCategory(r, userID, req.Filter.Category)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !known {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown category: %q", req.Filter.Category))
+			return
+		}
+	}
+
+	page, err := s.analytics.GetPaymentPage(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	resp := paymentsResponse{Payments: nonNil(page.Payments)}
+	if page.Older != nil {
+		resp.Older = page.Older.String()
+	}
+	if page.Newer != nil {
+		resp.Newer = page.Newer.String()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// hasCategory reports whether the user has a category with the key, archived or not
+func (s *Server) hasCategory(r *http.Request, userID int64, key models.Category) (bool, error) {
+	categories, err := s.categories.GetCategories(r.Context(), userID)
+	if err != nil {
+		return false, err
+	}
+	for _, category := range categories {
+		if category.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handleMonthlyExpense returns the payments of ?month=YYYY-MM per currency,
+// the current month by default
+func (s *Server) handleMonthlyExpense(w http.ResponseWriter, r *http.Request, userID int64) {
+	month := time.Now()
+	if value := r.URL.Query().Get("month"); value != "" {
+		parsed, err := time.Parse(monthLayout, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "month must be YYYY-MM")
+			return
+		}
+		month = parsed
+	}
+
+	summary, err := s.analytics.GetMonthlyExpense(r.Context(), userID, month)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, monthlyResponse{Month: month.Format(monthLayout), Payments: nonNil(summary)})
+}
+
+// handleCategoryAnalytics returns the payments per category between ?from and
+// ?to inclusive, the current month by default
+func (s *Server) handleCategoryAnalytics(w http.ResponseWriter, r *http.Request, userID int64) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, -1)
+
+	var err error
+	if from, err = parseDate(r, "from", from); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if to, err = parseDate(r, "to", to); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, "date range ends before it starts")
+		return
+	}
+
+	endOfDay := to.AddDate(0, 0, 1).Add(-time.Second)
+	categories, err := s.analytics.GetCategoryAnalytics(r.Context(), userID, from, endOfDay)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if categories == nil {
+		categories = map[models.Category][]models.PaymentSummary{}
+	}
+
+	writeJSON(w, http.StatusOK, categoriesResponse{
+		From:       from.Format(dateLayout),
+		To:         to.Format(dateLayout),
+		Categories: categories,
+	})
+}
+
+// handleUpcomingPayments returns subscriptions due in the next ?days days
+func (s *Server) handleUpcomingPayments(w http.ResponseWriter, r *http.Request, userID int64) {
+	days := defaultDaysAhead
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDaysAhead {
+			writeError(w, http.StatusBadRequest, "days must be between 1 and "+strconv.Itoa(maxDaysAhead))
+			return
+		}
+		days = parsed
+	}
+
+	upcoming, err := s.analytics.GetUpcomingPayments(r.Context(), userID, days)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscriptionsResponse{Subscriptions: nonNil(upcoming)})
+}
+
+// handleRecurringCost returns the monthly cost of active subscriptions in the
+// base currency of the user
+func (s *Server) handleRecurringCost(w http.ResponseWriter, r *http.Request, userID int64) {
+	total, err := s.analytics.GetMonthlyRecurringTotal(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, total)
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid subscription id")
+		return 0, false
+	}
+	return id, true
+}
+
+// nonNil keeps empty lists as [] instead of null in responses
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
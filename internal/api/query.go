@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// parsePageRequest reads the filter and position of a payment history page:
+// subscription_id, category, tag, currency, status, from, to, cursor,
+// direction (older or newer) and limit.
+func parsePageRequest(r *http.Request) (models.PaymentPageRequest, error) {
+	query := r.URL.Query()
+	req := models.PaymentPageRequest{
+		Filter: models.PaymentFilter{
+			Category: models.Category(query.Get("category")),
+			Tag:      parseTag(query.Get("tag")),
+			Status:   models.PaymentStatus(query.Get("status")),
+		},
+		Limit: defaultPageSize,
+	}
+
+	if value := query.Get("subscription_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return req, fmt.Errorf("invalid subscription_id: %q", value)
+		}
+		req.Filter.SubscriptionID = id
+	}
+	if value := query.Get("currency"); value != "" {
+		currency, ok := models.ParseCurrency(value)
+		if !ok {
+			return req, fmt.Errorf("unsupported currency: %q", value)
+		}
+		req.Filter.Currency = currency
+	}
+
+	var err error
+	if req.Filter.From, err = parseDate(r, "from", time.Time{}); err != nil {
+		return req, err
+	}
+	if req.Filter.To, err = parseDate(r, "to", time.Time{}); err != nil {
+		return req, err
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.ParsePaymentCursor(value)
+		if err != nil {
+			return req, err
+		}
+		req.Cursor = &cursor
+	}
+	switch direction := query.Get("direction"); direction {
+	case "", "older":
+	case "newer":
+		req.Newer = true
+	default:
+		return req, fmt.Errorf("direction must be older or newer, got %q", direction)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		if req.Limit, err = strconv.Atoi(value); err != nil {
+			return req, fmt.Errorf("invalid limit: %q", value)
+		}
+	}
+
+	return req, nil
+}
+
+// parseDate reads a YYYY-MM-DD query parameter, fallback is used when it's absent
+func parseDate(r *http.Request, name string, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be YYYY-MM-DD, got %q", name, value)
+	}
+	return date, nil
+}
+
+func parseBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", name, value)
+	}
+	return b, nil
+}
+
+// parseTag reads a tag the way tags are stored, "#Family" is "family"
+func parseTag(value string) string {
+	tags := models.NormalizeTags([]string{value})
+	if len(tags) == 0 {
+		return ""
+	}
+	return tags[0]
+}
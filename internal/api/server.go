@@ -0,0 +1,189 @@
+// Package api serves subscriptions, payments and analytics as JSON over HTTP,
+// for dashboards and scripts. It uses the same services as the Telegram bot.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/services"
+	"time"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// maxBodySize limits request bodies, a subscription is far smaller
+const maxBodySize = 64 << 10
+
+// SubscriptionService is the part of services.SubscriptionService used by the API
+type SubscriptionService interface {
+	CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error)
+	GetAllActiveSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error)
+	GetAllSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error)
+	GetSubscriptionByID(ctx context.Context, userID int64, id int) (*models.Subscription, error)
+	UpdateSubscription(ctx context.Context, userID int64, id int, req *models.UpdateSubscriptionRequest) (*models.Subscription, error)
+	DeleteSubscription(ctx context.Context, userID int64, id int) error
+}
+
+// CategoryService is the part of services.CategoryService used by the API
+type CategoryService interface {
+	GetCategories(ctx context.Context, userID int64) ([]*models.UserCategory, error)
+}
+
+// AnalyticsService is the part of services.AnalyticsService used by the API
+type AnalyticsService interface {
+	GetPaymentPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error)
+	GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error)
+	GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error)
+	GetUpcomingPayments(ctx context.Context, userID int64, days int) ([]*models.Subscription, error)
+	GetMonthlyRecurringTotal(ctx context.Context, userID int64) (models.ConvertedTotal, error)
+}
+
+type Server struct {
+	server        *http.Server
+	tokens        []config.APIToken
+	subscriptions SubscriptionService
+	categories    CategoryService
+	analytics     AnalyticsService
+}
+
+func NewServer(cfg *config.Config, subscriptions SubscriptionService, categories CategoryService, analytics AnalyticsService) *Server {
+	s := &Server{
+		tokens:        cfg.APITokens(),
+		subscriptions: subscriptions,
+		categories:    categories,
+		analytics:     analytics,
+	}
+
+	s.server = &http.Server{
+		Addr:              cfg.API.Listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Handler returns the routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/openapi.yaml", s.handleOpenAPI)
+
+	mux.Handle("GET /api/v1/subscriptions", s.authenticated(s.handleListSubscriptions))
+	mux.Handle("POST /api/v1/subscriptions", s.authenticated(s.handleCreateSubscription))
+	mux.Handle("GET /api/v1/subscriptions/{id}", s.authenticated(s.handleGetSubscription))
+	mux.Handle("PATCH /api/v1/subscriptions/{id}", s.authenticated(s.handleUpdateSubscription))
+	mux.Handle("DELETE /api/v1/subscriptions/{id}", s.authenticated(s.handleDeleteSubscription))
+
+	mux.Handle("GET /api/v1/categories", s.authenticated(s.handleListCategories))
+
+	mux.Handle("GET /api/v1/payments", s.authenticated(s.handleListPayments))
+
+	mux.Handle("GET /api/v1/analytics/monthly", s.authenticated(s.handleMonthlyExpense))
+	mux.Handle("GET /api/v1/analytics/categories", s.authenticated(s.handleCategoryAnalytics))
+	mux.Handle("GET /api/v1/analytics/upcoming", s.authenticated(s.handleUpcomingPayments))
+	mux.Handle("GET /api/v1/analytics/recurring", s.authenticated(s.handleRecurringCost))
+
+	return mux
+}
+
+func (s *Server) ListenAndServe() {
+	log.Printf("API server listening on %s", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("API server failed: %v", err)
+	}
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// handlerFunc is a handler of an authenticated request, userID is the owner of the token
+type handlerFunc func(w http.ResponseWriter, r *http.Request, userID int64)
+
+// authenticated resolves the bearer token to the user it was issued for
+func (s *Server) authenticated(next handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		userID, ok := s.userFor(token)
+		if !ok {
+			log.Printf("AUDIT: API request with invalid token from %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		next(w, r, userID)
+	})
+}
+
+// userFor compares the token with every configured one in constant time
+func (s *Server) userFor(token string) (int64, bool) {
+	var userID int64
+	found := false
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 && !found {
+			userID, found = t.UserID, true
+		}
+	}
+	return userID, found
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeServiceError answers with the status matching an error of a service.
+// Unexpected errors are logged and hidden from the client.
+func writeServiceError(w http.ResponseWriter, err error) {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		writeError(w, http.StatusBadRequest, validationErr.Reason)
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	default:
+		log.Printf("API request failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// decodeJSON reads the request body into dest, unknown fields are rejected
+// so that typos don't go unnoticed
+func decodeJSON(w http.ResponseWriter, r *http.Request, dest any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dest); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
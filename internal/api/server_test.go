@@ -0,0 +1,367 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/services"
+	"testing"
+	"time"
+)
+
+const (
+	ownerID    = 1001
+	ownerToken = "owner-token"
+	otherID    = 2002
+	otherToken = "other-token"
+)
+
+// fakeSubscriptions keeps subscriptions in memory, each user sees only their own
+type fakeSubscriptions struct {
+	subs   map[int]*models.Subscription
+	nextID int
+}
+
+func (f *fakeSubscriptions) CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	if req.Name == "" {
+		return nil, &services.ValidationError{Reason: "subscription name is required"}
+	}
+	f.nextID++
+	sub := &models.Subscription{
+		ID: f.nextID, UserID: req.UserID, Name: req.Name, Cost: req.Cost, Currency: req.Currency,
+		Period: req.Period, NextPayment: req.NextPayment, Category: req.Category, Active: true, Tags: req.Tags,
+	}
+	f.subs[sub.ID] = sub
+	return sub, nil
+}
+
+func (f *fakeSubscriptions) GetAllActiveSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	var result []*models.Subscription
+	for id := 1; id <= f.nextID; id++ {
+		if sub, ok := f.subs[id]; ok && sub.UserID == userID && sub.Active {
+			result = append(result, sub)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeSubscriptions) GetAllSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	var result []*models.Subscription
+	for id := 1; id <= f.nextID; id++ {
+		if sub, ok := f.subs[id]; ok && sub.UserID == userID {
+			result = append(result, sub)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeSubscriptions) GetSubscriptionByID(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	sub, ok := f.subs[id]
+	if !ok || sub.UserID != userID {
+		return nil, fmt.Errorf("failed to get subscription: subscription %w", repository.ErrNotFound)
+	}
+	return sub, nil
+}
+
+func (f *fakeSubscriptions) UpdateSubscription(ctx context.Context, userID int64, id int, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	sub, err := f.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	req.Apply(sub)
+	return sub, nil
+}
+
+func (f *fakeSubscriptions) DeleteSubscription(ctx context.Context, userID int64, id int) error {
+	sub, err := f.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	sub.Active = false
+	return nil
+}
+
+// fakeCategories gives every user the default categories
+type fakeCategories struct{}
+
+func (fakeCategories) GetCategories(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	var categories []*models.UserCategory
+	for i, req := range models.DefaultCategories() {
+		categories = append(categories, &models.UserCategory{ID: i + 1, UserID: userID, Key: req.Key, Name: req.Name, Emoji: req.Emoji})
+	}
+	return categories, nil
+}
+
+// fakeAnalytics answers with fixed data and remembers the last page request
+type fakeAnalytics struct {
+	pageRequest models.PaymentPageRequest
+}
+
+func (f *fakeAnalytics) GetPaymentPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error) {
+	if req.Limit > 100 {
+		return nil, &services.ValidationError{Reason: "page size must be between 1 and 100"}
+	}
+	f.pageRequest = req
+	payment := &models.Payment{ID: 7, UserID: userID, Amount: models.NewMoney(999), Currency: models.CurrencyUSD,
+		PaidAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Status: models.PaymentStatusCompleted}
+	older := models.CursorOf(payment)
+	return &models.PaymentPage{Payments: []*models.Payment{payment}, Older: &older}, nil
+}
+
+func (f *fakeAnalytics) GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error) {
+	return []models.PaymentSummary{{Currency: models.CurrencyUSD, TotalAmount: models.NewMoney(month.Day()), Count: int(month.Month())}}, nil
+}
+
+func (f *fakeAnalytics) GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
+	return nil, nil
+}
+
+func (f *fakeAnalytics) GetUpcomingPayments(ctx context.Context, userID int64, days int) ([]*models.Subscription, error) {
+	return nil, fmt.Errorf("database is down")
+}
+
+func (f *fakeAnalytics) GetMonthlyRecurringTotal(ctx context.Context, userID int64) (models.ConvertedTotal, error) {
+	return models.ConvertedTotal{Currency: models.CurrencyEUR, Amount: models.NewMoney(2500)}, nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeSubscriptions, *fakeAnalytics) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Telegram: config.TelegramConfig{AllowedUser: ownerID},
+		API: config.APIConfig{
+			Enabled: true,
+			Token:   ownerToken,
+			Tokens:  []config.APIToken{{Token: otherToken, UserID: otherID}},
+		},
+	}
+	subs := &fakeSubscriptions{subs: make(map[int]*models.Subscription)}
+	analytics := &fakeAnalytics{}
+
+	server := httptest.NewServer(NewServer(cfg, subs, fakeCategories{}, analytics).Handler())
+	t.Cleanup(server.Close)
+
+	return server, subs, analytics
+}
+
+func doRequest(t *testing.T, server *httptest.Server, method, path, token, body string) (*http.Response, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]any
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestAuthentication(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	for _, token := range []string{"", "wrong-token"} {
+		resp, body := doRequest(t, server, http.MethodGet, "/api/v1/subscriptions", token, "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for token %q, got %d", token, resp.StatusCode)
+		}
+		if body["error"] == nil {
+			t.Errorf("Expected an error message for token %q", token)
+		}
+	}
+
+	// The description is public so that tools can fetch it
+	resp, _ := doRequest(t, server, http.MethodGet, "/api/v1/openapi.yaml", "", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/yaml" {
+		t.Errorf("Expected the OpenAPI description, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestSubscriptionCRUD(t *testing.T) {
+	server, subs, _ := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodPost, "/api/v1/subscriptions", ownerToken,
+		`{"name": "Netflix", "cost": 1599, "currency": "USD", "period": {"unit": "month", "interval": 1},
+		  "next_payment": "2025-03-10T15:04:05Z", "tags": ["family"]}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %v", resp.StatusCode, body)
+	}
+	if body["user_id"] != float64(ownerID) || body["category"] != "other" {
+		t.Errorf("Expected the subscription of the owner in the default category, got %v", body)
+	}
+	// Only the date of the payment is kept
+	if body["next_payment"] != "2025-03-10T00:00:00Z" {
+		t.Errorf("Expected the payment date without time, got %v", body["next_payment"])
+	}
+
+	resp, body = doRequest(t, server, http.MethodPatch, "/api/v1/subscriptions/1", ownerToken, `{"cost": 1799}`)
+	if resp.StatusCode != http.StatusOK || body["cost"] != float64(1799) || body["name"] != "Netflix" {
+		t.Errorf("Expected the cost to change and the rest to stay, got %d %v", resp.StatusCode, body)
+	}
+
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/subscriptions?tag=%23Family", ownerToken, "")
+	if list, ok := body["subscriptions"].([]any); !ok || len(list) != 1 {
+		t.Errorf("Expected the subscription with the tag, got %v", body)
+	}
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/subscriptions?tag=work", ownerToken, "")
+	if list, ok := body["subscriptions"].([]any); !ok || len(list) != 0 {
+		t.Errorf("Expected no subscriptions with another tag, got %v", body)
+	}
+
+	// Tokens of other users don't see the subscription
+	resp, _ = doRequest(t, server, http.MethodGet, "/api/v1/subscriptions/1", otherToken, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user, got %d", resp.StatusCode)
+	}
+
+	resp, _ = doRequest(t, server, http.MethodDelete, "/api/v1/subscriptions/1", ownerToken, "")
+	if resp.StatusCode != http.StatusNoContent || subs.subs[1].Active {
+		t.Errorf("Expected the subscription to be cancelled, got %d", resp.StatusCode)
+	}
+
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/subscriptions", ownerToken, "")
+	if list, ok := body["subscriptions"].([]any); !ok || len(list) != 0 {
+		t.Errorf("Expected an empty list of active subscriptions, got %v", body)
+	}
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/subscriptions?all=true", ownerToken, "")
+	if list, ok := body["subscriptions"].([]any); !ok || len(list) != 1 {
+		t.Errorf("Expected the cancelled subscription with all=true, got %v", body)
+	}
+}
+
+func TestSubscriptionErrors(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"validation error", http.MethodPost, "/api/v1/subscriptions", `{"cost": 100}`, http.StatusBadRequest},
+		{"malformed JSON", http.MethodPost, "/api/v1/subscriptions", `{"name": `, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/api/v1/subscriptions", `{"name": "A", "price": 1}`, http.StatusBadRequest},
+		{"invalid id", http.MethodGet, "/api/v1/subscriptions/abc", "", http.StatusBadRequest},
+		{"missing subscription", http.MethodPatch, "/api/v1/subscriptions/42", `{"name": "B"}`, http.StatusNotFound},
+		{"invalid flag", http.MethodGet, "/api/v1/subscriptions?all=maybe", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := doRequest(t, server, tt.method, tt.path, ownerToken, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d: %v", tt.status, resp.StatusCode, body)
+			}
+			if body["error"] == nil {
+				t.Errorf("Expected an error message, got %v", body)
+			}
+		})
+	}
+}
+
+func TestListPayments(t *testing.T) {
+	server, _, analytics := newTestServer(t)
+
+	cursor := models.PaymentCursor{PaidAt: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), ID: 9}
+	resp, body := doRequest(t, server, http.MethodGet,
+		"/api/v1/payments?subscription_id=3&tag=Family&currency=eur&status=completed&from=2025-01-01&to=2025-03-31&limit=10&direction=newer&cursor="+cursor.String(),
+		ownerToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", resp.StatusCode, body)
+	}
+
+	req := analytics.pageRequest
+	expected := models.PaymentFilter{
+		SubscriptionID: 3,
+		Tag:            "family",
+		Currency:       models.CurrencyEUR,
+		Status:         models.PaymentStatusCompleted,
+		From:           time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:             time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+	}
+	if req.Filter != expected || req.Limit != 10 || !req.Newer || req.Cursor == nil || !req.Cursor.PaidAt.Equal(cursor.PaidAt) {
+		t.Errorf("Unexpected page request: %+v", req)
+	}
+
+	payments, _ := body["payments"].([]any)
+	if len(payments) != 1 || body["older"] == nil || body["newer"] != nil {
+		t.Errorf("Expected one payment with a cursor of older payments, got %v", body)
+	}
+
+	for _, query := range []string{"limit=500", "limit=x", "direction=up", "cursor=abc", "from=01.01.2025", "category=food"} {
+		resp, _ := doRequest(t, server, http.MethodGet, "/api/v1/payments?"+query, ownerToken, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestListCategories(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/api/v1/categories", ownerToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", resp.StatusCode, body)
+	}
+	categories, _ := body["categories"].([]any)
+	if len(categories) != 5 || categories[1].(map[string]any)["key"] != "work" {
+		t.Errorf("Expected the default categories, got %v", body)
+	}
+
+	resp, _ = doRequest(t, server, http.MethodGet, "/api/v1/payments?category=work", ownerToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a known category to filter payments, got %d", resp.StatusCode)
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	_, body := doRequest(t, server, http.MethodGet, "/api/v1/analytics/monthly?month=2025-02", ownerToken, "")
+	payments, _ := body["payments"].([]any)
+	if body["month"] != "2025-02" || len(payments) != 1 || payments[0].(map[string]any)["count"] != float64(2) {
+		t.Errorf("Expected February expenses, got %v", body)
+	}
+
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/analytics/categories?from=2025-01-01&to=2025-01-31", ownerToken, "")
+	if categories, ok := body["categories"].(map[string]any); !ok || len(categories) != 0 || body["to"] != "2025-01-31" {
+		t.Errorf("Expected empty categories of January, got %v", body)
+	}
+
+	resp, _ := doRequest(t, server, http.MethodGet, "/api/v1/analytics/categories?from=2025-02-01&to=2025-01-01", ownerToken, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a reversed range, got %d", resp.StatusCode)
+	}
+
+	_, body = doRequest(t, server, http.MethodGet, "/api/v1/analytics/recurring", ownerToken, "")
+	if body["currency"] != "EUR" || body["amount"] != float64(2500) {
+		t.Errorf("Expected the recurring total, got %v", body)
+	}
+
+	// Internal errors are not shown to the client
+	resp, body = doRequest(t, server, http.MethodGet, "/api/v1/analytics/upcoming?days=30", ownerToken, "")
+	if resp.StatusCode != http.StatusInternalServerError || body["error"] != "internal error" {
+		t.Errorf("Expected a generic 500, got %d %v", resp.StatusCode, body)
+	}
+
+	resp, _ = doRequest(t, server, http.MethodGet, "/api/v1/analytics/upcoming?days=0", ownerToken, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for days=0, got %d", resp.StatusCode)
+	}
+}
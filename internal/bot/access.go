@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"strings"
+	"sub-cos-counter/internal/config"
+)
+
+const accessDeniedText = "🔒 Извините, у вас нет доступа к этому боту.\n\nЕсли вы считаете, что это ошибка, свяжитесь с владельцем бота."
+const readOnlyDeniedText = "🔒 У вас доступ только для просмотра."
+
+// readOnlyActions lists callbacks and commands that viewers are allowed to use.
+var readOnlyActions = map[string]bool{
+	"/start":          true,
+	"my_subs":         true,
+	"sub_info":        true,
+	"monthly":         true,
+	"analytics":       true,
+	"history":         true,
+	"history_page":    true,
+	"history_filters": true,
+	"history_field":   true,
+	"history_set":     true,
+	"history_dates":   true,
+	"history_reset":   true,
+	"settings":        true,
+	"categories":      true,
+	"/categories":     true,
+	"budgets":         true,
+	"/budgets":        true,
+	"trials":          true,
+	"/rates":          true,
+	"/export":         true,
+	"/backup":         true,
+	"back":            true,
+}
+
+// grant is the role of a sender and the user whose data they work with.
+type grant struct {
+	role  string
+	owner int64 // whose data a viewer reads, owners read their own
+}
+
+// accessList resolves the role of a sender from the configured allow-list.
+type accessList struct {
+	enabled bool
+	users   map[int64]grant
+	chats   map[int64]grant
+}
+
+func newAccessList(cfg *config.Config) *accessList {
+	list := &accessList{
+		enabled: cfg.HasAccessControl(),
+		users:   make(map[int64]grant),
+		chats:   make(map[int64]grant),
+	}
+
+	if cfg.IsPersonalBot() {
+		list.users[cfg.Telegram.AllowedUser] = grant{role: config.RoleOwner}
+	}
+
+	for _, rule := range cfg.Telegram.Access {
+		g := grant{role: rule.Role}
+		if rule.Role == config.RoleViewer {
+			g.owner = rule.Owner
+			if g.owner == 0 {
+				g.owner = cfg.Telegram.AllowedUser
+			}
+		}
+
+		if rule.UserID != 0 {
+			list.grant(list.users, rule.UserID, g)
+		} else {
+			list.grant(list.chats, rule.ChatID, g)
+		}
+	}
+
+	return list
+}
+
+// grant keeps the strongest role when an ID is listed more than once.
+func (a *accessList) grant(grants map[int64]grant, id int64, g grant) {
+	if grants[id].role == config.RoleOwner {
+		return
+	}
+	grants[id] = g
+}
+
+// roleFor returns the role granted to the user, directly or through the chat,
+// and the user whose data they work with. A user rule takes precedence over
+// a chat rule.
+func (a *accessList) roleFor(userID, chatID int64) (role string, owner int64, ok bool) {
+	if !a.enabled {
+		return config.RoleOwner, userID, true
+	}
+
+	g, ok := a.users[userID]
+	if !ok {
+		g, ok = a.chats[chatID]
+	}
+	if !ok {
+		return "", 0, false
+	}
+
+	if g.role == config.RoleOwner {
+		return g.role, userID, true
+	}
+	return g.role, g.owner, true
+}
+
+// allows reports whether the role may perform the action.
+func (a *accessList) allows(role, action string) bool {
+	if role == config.RoleOwner {
+		return true
+	}
+	// Plain text is only meaningful inside wizards, which viewers cannot start
+	if action == "" {
+		return true
+	}
+	return readOnlyActions[action]
+}
+
+// actionName returns the callback unique ID or command the update invokes.
+func actionName(callbackUnique, text string) string {
+	if callbackUnique != "" {
+		return callbackUnique
+	}
+	if strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		// Commands in groups may be addressed as /start@bot_name
+		if at := strings.Index(command, "@"); at != -1 {
+			command = command[:at]
+		}
+		return command
+	}
+	return ""
+}
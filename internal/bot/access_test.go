@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository/memory"
+	"sub-cos-counter/internal/services"
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+func TestAccessListRoles(t *testing.T) {
+	cfg := &config.Config{
+		Telegram: config.TelegramConfig{
+			AllowedUser: 100,
+			Access: []config.AccessRule{
+				{UserID: 200, Role: config.RoleViewer},
+				{UserID: 100, Role: config.RoleViewer}, // must not downgrade the owner
+				{ChatID: -500, Role: config.RoleViewer, Owner: 700},
+				{ChatID: -600, Role: config.RoleOwner},
+			},
+		},
+	}
+	list := newAccessList(cfg)
+
+	tests := []struct {
+		name     string
+		userID   int64
+		chatID   int64
+		expected string
+		owner    int64
+		allowed  bool
+	}{
+		{"personal owner", 100, 100, config.RoleOwner, 100, true},
+		{"viewer user reads allowed user", 200, 200, config.RoleViewer, 100, true},
+		{"member of viewer chat", 300, -500, config.RoleViewer, 700, true},
+		{"member of owner chat", 300, -600, config.RoleOwner, 300, true},
+		{"user rule beats chat rule", 200, -600, config.RoleViewer, 100, true},
+		{"stranger", 300, 300, "", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			role, owner, ok := list.roleFor(test.userID, test.chatID)
+			if ok != test.allowed {
+				t.Fatalf("Expected allowed=%v, got %v", test.allowed, ok)
+			}
+			if role != test.expected || owner != test.owner {
+				t.Errorf("Expected role %q of %d, got %q of %d", test.expected, test.owner, role, owner)
+			}
+		})
+	}
+}
+
+func TestAccessListDisabled(t *testing.T) {
+	list := newAccessList(&config.Config{})
+
+	role, owner, ok := list.roleFor(12345, 12345)
+	if !ok || role != config.RoleOwner || owner != 12345 {
+		t.Errorf("Expected open bot to grant owner role, got %q of %d (allowed=%v)", role, owner, ok)
+	}
+}
+
+func TestViewerPermissions(t *testing.T) {
+	list := newAccessList(&config.Config{})
+
+	tests := []struct {
+		action  string
+		allowed bool
+	}{
+		{"/start", true},
+		{"my_subs", true},
+		{"sub_info", true},
+		{"history", true},
+		{"back", true},
+		{"", true},
+		{"add_sub", false},
+		{"pay_5", false},
+		{"pay", false},
+		{"delete_5", false},
+		{"edit_sub", false},
+		{"/rates", true},
+		{"/rate", false},
+		{"/export", true},
+		{"/import", false},
+		{"/backup", true},
+		{"/restore", false},
+		{"base_currency", false},
+		{"trials", true},
+		{"cancel_trial", false},
+		{"set_trial", false},
+		{"record_payment", false},
+		{"payment", false},
+		{"void_payment", false},
+		{"history_page", true},
+		{"history_set", true},
+		{"budgets", true},
+		{"/budgets", true},
+		{"budget_new", false},
+		{"budget_delete", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.action, func(t *testing.T) {
+			if got := list.allows(config.RoleViewer, test.action); got != test.allowed {
+				t.Errorf("Expected viewer access to %q to be %v, got %v", test.action, test.allowed, got)
+			}
+			if !list.allows(config.RoleOwner, test.action) {
+				t.Errorf("Expected owner to be allowed %q", test.action)
+			}
+		})
+	}
+}
+
+func TestActionName(t *testing.T) {
+	tests := []struct {
+		unique   string
+		text     string
+		expected string
+	}{
+		{"my_subs", "", "my_subs"},
+		{"", "/start", "/start"},
+		{"", "/start@sub_bot", "/start"},
+		{"", "/start payload", "/start"},
+		{"", "Netflix", ""},
+	}
+
+	for _, test := range tests {
+		if got := actionName(test.unique, test.text); got != test.expected {
+			t.Errorf("actionName(%q, %q) = %q, expected %q", test.unique, test.text, got, test.expected)
+		}
+	}
+}
+
+// fakeContext is a callback of the user in their private chat, it keeps the
+// text of the message the handler edits
+type fakeContext struct {
+	telebot.Context
+	sender   *telebot.User
+	callback *telebot.Callback
+	store    map[string]interface{}
+	edited   string
+}
+
+func (c *fakeContext) Sender() *telebot.User       { return c.sender }
+func (c *fakeContext) Chat() *telebot.Chat         { return &telebot.Chat{ID: c.sender.ID} }
+func (c *fakeContext) Callback() *telebot.Callback { return c.callback }
+func (c *fakeContext) Data() string                { return c.callback.Data }
+func (c *fakeContext) Text() string                { return "" }
+func (c *fakeContext) Get(key string) interface{}  { return c.store[key] }
+func (c *fakeContext) Set(key string, val interface{}) {
+	c.store[key] = val
+}
+
+func (c *fakeContext) Edit(what interface{}, opts ...interface{}) error {
+	c.edited, _ = what.(string)
+	return nil
+}
+
+func (c *fakeContext) Respond(resp ...*telebot.CallbackResponse) error {
+	return errors.New("unexpected callback response")
+}
+
+func TestViewerListsOwnerSubscriptions(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+
+	categories := services.NewCategoryService(storage.Categories)
+	subscriptions := services.NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, categories)
+	for userID, name := range map[int64]string{100: "Netflix", 300: "Spotify"} {
+		_, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+			UserID:      userID,
+			Name:        name,
+			Cost:        models.NewMoney(999),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Now().AddDate(0, 0, 7),
+			Category:    models.CategoryEntertainment,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+	}
+
+	api, err := telebot.NewBot(telebot.Settings{Offline: true})
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	b := &Bot{
+		bot:                 api,
+		subscriptionService: subscriptions,
+		states:              NewMemoryStateStore(time.Hour),
+		access: newAccessList(&config.Config{
+			Telegram: config.TelegramConfig{
+				Access: []config.AccessRule{
+					{UserID: 100, Role: config.RoleOwner},
+					{UserID: 200, Role: config.RoleViewer, Owner: 100},
+					{UserID: 300, Role: config.RoleOwner},
+				},
+			},
+		}),
+	}
+
+	for userID, expected := range map[int64]string{200: "Netflix", 300: "Spotify"} {
+		c := &fakeContext{
+			sender:   &telebot.User{ID: userID},
+			callback: &telebot.Callback{Unique: btnMySubscriptions.Unique},
+			store:    make(map[string]interface{}),
+		}
+		if err := b.authorize(b.handleMySubscriptions)(c); err != nil {
+			t.Fatalf("User %d failed to list subscriptions: %v", userID, err)
+		}
+		if !strings.Contains(c.edited, expected) || strings.Count(c.edited, "•") != 1 {
+			t.Errorf("User %d: expected only %s, got %q", userID, expected, c.edited)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/services"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxBackupFileSize limits uploaded backups, Telegram lets bots download up to 20 MB
+const maxBackupFileSize = 10 << 20
+
+const restorePrompt = "♻️ *Восстановление из резервной копии*\n\n" +
+	"Отправьте JSON файл, полученный командой /backup. Восстановить копию можно только " +
+	"в пустой аккаунт без подписок."
+
+// handleBackupCommand sends all data of the user as a JSON document
+func (b *Bot) handleBackupCommand(c telebot.Context) error {
+	backup, err := b.backupService.Create(context.Background(), ownerOf(c))
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка создания резервной копии: %v", err))
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка создания резервной копии: %v", err))
+	}
+
+	return c.Send(&telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(data)),
+		FileName: "backup-" + backup.CreatedAt.Format("2006-01-02") + ".json",
+		MIME:     "application/json",
+		Caption: fmt.Sprintf("💾 Резервная копия: подписок %d, платежей %d.\nВосстановить ее можно командой /restore",
+			len(backup.Subscriptions), len(backup.Payments)),
+	})
+}
+
+// handleRestoreCommand asks for a backup file
+func (b *Bot) handleRestoreCommand(c telebot.Context) error {
+	b.resetUserState(c.Sender().ID, StateRestoring)
+
+	return c.Send(restorePrompt, &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleRestoreDocument(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	doc := c.Message().Document
+	if doc.FileSize > maxBackupFileSize {
+		return c.Send("❌ Файл слишком большой, максимум 10 МБ")
+	}
+
+	file, err := b.bot.File(&doc.File)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка загрузки файла: %v", err))
+	}
+	defer file.Close()
+
+	var backup models.Backup
+	if err := json.NewDecoder(io.LimitReader(file, maxBackupFileSize)).Decode(&backup); err != nil {
+		return c.Send("❌ Это не резервная копия: файл не удалось прочитать как JSON")
+	}
+
+	err = b.backupService.Restore(context.Background(), userID, &backup)
+	if errors.Is(err, services.ErrAccountNotEmpty) {
+		b.clearUserState(userID)
+		return c.Send("❌ В аккаунте уже есть подписки. Резервную копию можно восстановить только в пустой аккаунт.")
+	}
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка восстановления, данные не изменены: %v", err))
+	}
+
+	b.clearUserState(userID)
+
+	text := fmt.Sprintf("✅ Данные восстановлены из копии от %s\n\n📋 Подписок: %d\n📜 Платежей: %d",
+		backup.CreatedAt.Format("02.01.2006 15:04"), len(backup.Subscriptions), len(backup.Payments))
+
+	return c.Send(text, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnMySubscriptions},
+			{btnBack},
+		},
+	})
+}
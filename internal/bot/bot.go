@@ -1,8 +1,10 @@
 package bot
 
 import (
+	"context"
 	"log"
 	"sub-cos-counter/internal/config"
+	"sub-cos-counter/internal/models"
 	"sub-cos-counter/internal/services"
 	"time"
 
@@ -12,13 +14,16 @@ import (
 type Bot struct {
 	bot                 *telebot.Bot
 	subscriptionService *services.SubscriptionService
+	categoryService     *services.CategoryService
+	budgetService       *services.BudgetService
 	analyticsService    *services.AnalyticsService
-	userStates          map[int64]*UserState
-}
-
-type UserState struct {
-	State string
-	Data  map[string]interface{}
+	reminderService     *services.ReminderService
+	exchangeService     *services.ExchangeService
+	backupService       *services.BackupService
+	access              *accessList
+	notifications       config.NotificationsConfig
+	webhook             *webhookServer
+	states              StateStore
 }
 
 const (
@@ -27,14 +32,58 @@ const (
 	StateWaitingForName     = "waiting_for_name"
 	StateWaitingForCost     = "waiting_for_cost"
 	StateWaitingForDate     = "waiting_for_date"
+
+	StateEditingSubscription = "editing_subscription"
+	StateEditingName         = "editing_name"
+	StateEditingCost         = "editing_cost"
+	StateEditingPeriod       = "editing_period"
+	StateEditingDate         = "editing_date"
+	StateEditingTrial        = "editing_trial"
+	StateEditingTags         = "editing_tags"
+	StateEditingNotes        = "editing_notes"
+	StateEditingURL          = "editing_url"
+
+	StateSelectingBaseCurrency = "selecting_base_currency"
+
+	StateRecordingAmount = "recording_amount"
+	StateRecordingDate   = "recording_date"
+	StateRecordingStatus = "recording_status"
+	StateRecordingNote   = "recording_note"
+	StateRecordingSettle = "recording_settle"
+
+	StateEditingPayment       = "editing_payment"
+	StateEditingPaymentAmount = "editing_payment_amount"
+	StateEditingPaymentDate   = "editing_payment_date"
+	StateEditingPaymentNote   = "editing_payment_note"
+
+	StateBrowsingHistory       = "browsing_history"
+	StateFilteringHistoryDates = "filtering_history_dates"
+
+	StateCreatingCategory      = "creating_category"
+	StateCreatingCategoryEmoji = "creating_category_emoji"
+	StateRenamingCategory      = "renaming_category"
+	StateSettingCategoryEmoji  = "setting_category_emoji"
+
+	StateSettingBudget = "setting_budget"
+
+	StateImporting = "importing"
+	StateRestoring = "restoring"
 )
 
-func NewBot(cfg *config.Config, subscriptionService *services.SubscriptionService, analyticsService *services.AnalyticsService) (*Bot, error) {
+func NewBot(cfg *config.Config, subscriptionService *services.SubscriptionService, categoryService *services.CategoryService, budgetService *services.BudgetService, analyticsService *services.AnalyticsService, reminderService *services.ReminderService, exchangeService *services.ExchangeService, backupService *services.BackupService, states StateStore) (*Bot, error) {
 	pref := telebot.Settings{
+		URL:    cfg.Telegram.APIURL,
 		Token:  cfg.GetBotToken(),
 		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
 	}
 
+	if cfg.Telegram.UseWebhook {
+		pref.Poller = &webhookPoller{
+			publicURL:   cfg.Telegram.WebhookURL,
+			secretToken: cfg.Telegram.WebhookSecret,
+		}
+	}
+
 	bot, err := telebot.NewBot(pref)
 	if err != nil {
 		return nil, err
@@ -43,8 +92,26 @@ func NewBot(cfg *config.Config, subscriptionService *services.SubscriptionServic
 	b := &Bot{
 		bot:                 bot,
 		subscriptionService: subscriptionService,
+		categoryService:     categoryService,
+		budgetService:       budgetService,
 		analyticsService:    analyticsService,
-		userStates:          make(map[int64]*UserState),
+		reminderService:     reminderService,
+		exchangeService:     exchangeService,
+		backupService:       backupService,
+		access:              newAccessList(cfg),
+		notifications:       cfg.Notifications,
+		states:              states,
+	}
+
+	if b.states == nil {
+		b.states = NewMemoryStateStore(0)
+	}
+
+	if cfg.Telegram.UseWebhook {
+		b.webhook, err = newWebhookServer(cfg.Telegram, bot.Updates)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Add debugging middleware
@@ -57,11 +124,18 @@ func NewBot(cfg *config.Config, subscriptionService *services.SubscriptionServic
 		}
 	})
 
+	// Reject senders that are not on the allow-list
+	b.bot.Use(b.authorize)
+
 	b.setupHandlers()
 	return b, nil
 }
 
 func (b *Bot) Start() {
+	if b.webhook != nil {
+		go b.webhook.listenAndServe()
+	}
+
 	log.Println("Bot started...")
 	b.bot.Start()
 }
@@ -70,37 +144,114 @@ func (b *Bot) Stop() {
 	b.bot.Stop()
 }
 
-func (b *Bot) getUserState(userID int64) *UserState {
-	if state, exists := b.userStates[userID]; exists {
-		return state
+// Shutdown stops accepting webhook requests, waits for the ones in flight
+// and then stops the bot.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	var err error
+	if b.webhook != nil {
+		err = b.webhook.shutdown(ctx)
 	}
 
-	state := &UserState{
-		State: StateIdle,
-		Data:  make(map[string]interface{}),
+	b.Stop()
+	return err
+}
+
+// authorize is a middleware that lets through only allowed senders and
+// keeps viewers away from actions that change data.
+func (b *Bot) authorize(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		sender := c.Sender()
+		if sender == nil {
+			return nil
+		}
+
+		var chatID int64
+		if chat := c.Chat(); chat != nil {
+			chatID = chat.ID
+		}
+
+		var unique string
+		if cb := c.Callback(); cb != nil {
+			unique = cb.Unique
+		}
+		action := actionName(unique, c.Text())
+
+		role, owner, ok := b.access.roleFor(sender.ID, chatID)
+		if !ok {
+			log.Printf("AUDIT: access denied - user: %d (@%s), chat: %d, action: %q",
+				sender.ID, sender.Username, chatID, action)
+			return b.reject(c, accessDeniedText)
+		}
+
+		if !b.access.allows(role, action) {
+			log.Printf("AUDIT: action denied - user: %d (@%s), chat: %d, role: %s, action: %q",
+				sender.ID, sender.Username, chatID, role, action)
+			return b.reject(c, readOnlyDeniedText)
+		}
+
+		c.Set("role", role)
+		c.Set("owner", owner)
+		return next(c)
+	}
+}
+
+// ownerOf returns the user whose data the update reads: the sender for
+// owners, the owner named by the access rule for viewers. The user state
+// stays with the sender, so a viewer's filters don't touch the owner's.
+func ownerOf(c telebot.Context) int64 {
+	if owner, ok := c.Get("owner").(int64); ok {
+		return owner
+	}
+	return c.Sender().ID
+}
+
+func (b *Bot) reject(c telebot.Context, text string) error {
+	if c.Callback() != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: text, ShowAlert: true})
+	}
+	return c.Send(text)
+}
+
+func (b *Bot) getUserState(userID int64) *models.UserState {
+	state, err := b.states.Get(context.Background(), userID)
+	if err != nil {
+		log.Printf("Failed to load state for user %d: %v", userID, err)
+		return models.NewUserState(StateIdle)
 	}
-	b.userStates[userID] = state
 	return state
 }
 
+func (b *Bot) updateUserState(userID int64, fn func(state *models.UserState)) {
+	if err := b.states.Update(context.Background(), userID, fn); err != nil {
+		log.Printf("Failed to save state for user %d: %v", userID, err)
+	}
+}
+
+// resetUserState drops collected data and moves the user to the given step.
+func (b *Bot) resetUserState(userID int64, state string) {
+	b.updateUserState(userID, func(s *models.UserState) {
+		*s = *models.NewUserState(state)
+	})
+}
+
 func (b *Bot) setState(userID int64, state string) {
-	userState := b.getUserState(userID)
-	userState.State = state
+	b.updateUserState(userID, func(s *models.UserState) {
+		s.State = state
+	})
 }
 
 func (b *Bot) setData(userID int64, key string, value interface{}) {
-	userState := b.getUserState(userID)
-	userState.Data[key] = value
+	b.updateUserState(userID, func(s *models.UserState) {
+		s.Data[key] = value
+	})
 }
 
 func (b *Bot) getData(userID int64, key string) interface{} {
-	userState := b.getUserState(userID)
-	return userState.Data[key]
+	return b.getUserState(userID).Data[key]
 }
 
 func (b *Bot) clearUserState(userID int64) {
-	b.userStates[userID] = &UserState{
-		State: StateIdle,
-		Data:  make(map[string]interface{}),
+	if err := b.states.Delete(context.Background(), userID); err != nil {
+		log.Printf("Failed to clear state for user %d: %v", userID, err)
 	}
 }
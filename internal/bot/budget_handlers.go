@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+const budgetPrompt = "🎯 Введите месячный лимит в формате `сумма валюта [категория]`, например:\n\n" +
+	"`30000 RUB` — на все подписки\n" +
+	"`50 USD Развлечения` — на одну категорию\n\n" +
+	"Лимит, который уже задан для той же категории и валюты, будет заменен."
+
+const overallBudgetLabel = "Все категории"
+
+// handleBudgets shows the spending of this month against the budgets, from /budgets or the menu
+func (b *Bot) handleBudgets(c telebot.Context) error {
+	userID := ownerOf(c)
+	b.clearUserState(c.Sender().ID)
+
+	statuses, err := b.budgetService.GetBudgetStatus(context.Background(), userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения бюджетов: %v", err))
+	}
+
+	labels := b.categoryLabels(userID)
+	text := "🎯 *Бюджеты на месяц*\n\n"
+	if len(statuses) == 0 {
+		text += "Бюджеты пока не заданы. Задайте лимит на все подписки или на категорию, " +
+			"и бот предупредит, когда расходы подойдут к нему."
+	}
+	for _, status := range statuses {
+		text += formatBudgetStatus(status, labels) + "\n\n"
+	}
+
+	keyboard := [][]telebot.InlineButton{{btnNewBudget}}
+	for _, status := range statuses {
+		btn := btnDeleteBudget
+		btn.Text = "🗑️ " + budgetLabel(status.Budget, labels) + " · " + string(status.Budget.Currency)
+		btn.Data = budgetPayload(status.Budget)
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{btnBack})
+
+	markup := &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleNewBudget(c telebot.Context) error {
+	b.resetUserState(c.Sender().ID, StateSettingBudget)
+
+	return c.Edit(budgetPrompt, &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleBudgetInput(c telebot.Context) error {
+	userID := c.Sender().ID
+	ctx := context.Background()
+
+	limit, currency, name, err := parseBudgetInput(c.Text())
+	if err != nil {
+		return c.Send("❌ Некорректные данные. "+budgetPrompt, telebot.ModeMarkdown)
+	}
+
+	var category models.Category
+	if name != "" {
+		categories, err := b.categoryService.GetActiveCategories(ctx, userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка получения категорий: %v", err))
+		}
+		found := slices.IndexFunc(categories, func(c *models.UserCategory) bool { return c.Matches(name) })
+		if found < 0 {
+			return c.Send(fmt.Sprintf("❌ Категория «%s» не найдена. Попробуйте еще раз:", name))
+		}
+		category = categories[found].Key
+	}
+
+	budget, err := b.budgetService.SetBudget(ctx, userID, category, currency, limit)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при сохранении бюджета: %v\n\nПопробуйте еще раз:", err))
+	}
+
+	b.clearUserState(userID)
+
+	return c.Send(fmt.Sprintf("✅ Бюджет сохранен: %s — %s в месяц",
+		budgetLabel(budget, b.categoryLabels(userID)), budget.Currency.Format(budget.Limit)), &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnBudgets},
+			{btnBack},
+		},
+	})
+}
+
+func (b *Bot) handleDeleteBudget(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	category, currency, ok := parseBudgetPayload(c.Data())
+	if !ok {
+		return c.Send("❌ Некорректные данные бюджета")
+	}
+
+	if err := b.budgetService.DeleteBudget(context.Background(), userID, category, currency); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при удалении бюджета: %v", err))
+	}
+
+	return b.handleBudgets(c)
+}
+
+// sendBudgetAlerts warns about the budgets the payment has brought close to
+// the limit or over it. The payment is already saved, so a failed check is
+// only logged.
+func (b *Bot) sendBudgetAlerts(c telebot.Context, payment *models.Payment) {
+	alerts, err := b.budgetService.CheckPayment(context.Background(), payment)
+	if err != nil {
+		log.Printf("Failed to check budgets for user %d: %v", payment.UserID, err)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	if err := c.Send(b.formatBudgetAlerts(payment.UserID, alerts), budgetAlertMarkup(), telebot.ModeMarkdown); err != nil {
+		log.Printf("Failed to send budget alert to user %d: %v", payment.UserID, err)
+	}
+}
+
+// SendBudgetAlerts implements services.RenewalNotifier.
+func (b *Bot) SendBudgetAlerts(ctx context.Context, userID int64, alerts []models.BudgetAlert) error {
+	_, err := b.bot.Send(&telebot.User{ID: userID}, b.formatBudgetAlerts(userID, alerts), budgetAlertMarkup(), telebot.ModeMarkdown)
+	return err
+}
+
+func (b *Bot) formatBudgetAlerts(userID int64, alerts []models.BudgetAlert) string {
+	labels := b.categoryLabels(userID)
+	text := ""
+	for _, alert := range alerts {
+		text += formatBudgetAlert(alert, labels) + "\n\n"
+	}
+	return strings.TrimSpace(text)
+}
+
+func budgetAlertMarkup() *telebot.ReplyMarkup {
+	return &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{btnBudgets}},
+	}
+}
+
+func formatBudgetStatus(status models.BudgetStatus, labels map[models.Category]string) string {
+	budget := status.Budget
+	percent := status.Percent()
+
+	icon := "✅"
+	switch {
+	case percent >= 100:
+		icon = "🔴"
+	case percent >= float64(models.BudgetThresholds[0]):
+		icon = "⚠️"
+	}
+
+	text := fmt.Sprintf("%s *%s*\n%s из %s (%.0f%%)",
+		icon, escapeMarkdown(budgetLabel(budget, labels)),
+		budget.Currency.Format(status.Spent), budget.Currency.Format(budget.Limit), percent)
+
+	if remaining := status.Remaining(); remaining.IsPositive() {
+		text += "\nОсталось: " + budget.Currency.Format(remaining)
+	} else if remaining < 0 {
+		text += "\nПревышение: " + budget.Currency.Format(-remaining)
+	}
+	return text
+}
+
+func formatBudgetAlert(alert models.BudgetAlert, labels map[models.Category]string) string {
+	budget := alert.Budget
+	header := fmt.Sprintf("⚠️ *Израсходовано %d%% бюджета*", alert.Threshold)
+	if alert.Threshold >= 100 {
+		header = "🔴 *Бюджет превышен*"
+	}
+
+	return fmt.Sprintf("%s\n\n🎯 %s: %s из %s (%.0f%%)",
+		header, escapeMarkdown(budgetLabel(budget, labels)),
+		budget.Currency.Format(alert.Spent), budget.Currency.Format(budget.Limit), alert.Percent())
+}
+
+func budgetLabel(budget *models.Budget, labels map[models.Category]string) string {
+	if budget.IsOverall() {
+		return overallBudgetLabel
+	}
+	return labelOf(labels, budget.Category)
+}
+
+// budgetPayload identifies a budget in button payloads by its currency and
+// category, the overall budget has no category
+func budgetPayload(budget *models.Budget) string {
+	return string(budget.Currency) + ":" + string(budget.Category)
+}
+
+func parseBudgetPayload(data string) (models.Category, models.Currency, bool) {
+	code, category, ok := strings.Cut(data, ":")
+	if !ok {
+		return "", "", false
+	}
+	currency, ok := models.ParseCurrency(code)
+	return models.Category(category), currency, ok
+}
+
+// parseBudgetInput reads "30000 RUB" or "50 USD Развлечения", the name of
+// the category is empty for an overall budget
+func parseBudgetInput(input string) (models.Money, models.Currency, string, error) {
+	fields := strings.Fields(input)
+	if len(fields) < 2 {
+		return 0, "", "", fmt.Errorf("invalid budget: %q", input)
+	}
+
+	currency, ok := models.ParseCurrency(fields[1])
+	if !ok {
+		return 0, "", "", fmt.Errorf("unsupported currency: %q", fields[1])
+	}
+
+	limit, err := models.ParseMoneyIn(fields[0], currency)
+	if err != nil || !limit.IsPositive() {
+		return 0, "", "", fmt.Errorf("invalid budget limit: %q", fields[0])
+	}
+
+	return limit, currency, strings.Join(fields[2:], " "), nil
+}
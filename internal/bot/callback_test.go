@@ -3,13 +3,14 @@ package bot
 import (
 	"sub-cos-counter/internal/models"
 	"testing"
+	"time"
 )
 
 // Test that simulates real Telebot callback handling
 func TestCallbackHandlerRegistration(t *testing.T) {
 	// Create a minimal bot setup similar to real usage
 	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+		states: NewMemoryStateStore(time.Hour),
 	}
 
 	// Test callback data processing directly
@@ -17,14 +18,10 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 
 	// Step 1: Category selection
 	t.Run("Category Selection", func(t *testing.T) {
-		// Simulate handleCategorySelection logic
-		callbackData := "cat_work"
+		// Simulate handleCategorySelection logic, the payload is the category key
+		callbackData := "work"
 
-		var category models.Category
-		switch callbackData {
-		case "cat_work":
-			category = models.CategoryWork
-		}
+		category := models.Category(callbackData)
 
 		bot.setData(userID, "category", category)
 
@@ -38,13 +35,9 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 	// Step 2: Currency selection
 	t.Run("Currency Selection", func(t *testing.T) {
 		// Simulate handleCurrencySelection logic
-		callbackData := "curr_usd"
+		callbackData := "USD"
 
-		var currency models.Currency
-		switch callbackData {
-		case "curr_usd":
-			currency = models.CurrencyUSD
-		}
+		currency, _ := models.ParseCurrency(callbackData)
 
 		bot.setData(userID, "currency", currency)
 
@@ -66,13 +59,13 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 		// Simulate handlePeriodSelection logic
 		callbackData := "period_month"
 
-		var periodDays int
+		var period models.BillingPeriod
 		switch callbackData {
 		case "period_month":
-			periodDays = 30
+			period = models.Every(1, models.PeriodMonthly)
 		}
 
-		bot.setData(userID, "period_days", periodDays)
+		bot.setData(userID, "period", period)
 
 		// Verify all previous data is still there
 		category := bot.getData(userID, "category")
@@ -86,9 +79,9 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 		}
 
 		// Verify period
-		stored := bot.getData(userID, "period_days")
-		if stored != 30 {
-			t.Errorf("Period not stored correctly: expected 30, got %v", stored)
+		stored := bot.getData(userID, "period")
+		if stored != models.Every(1, models.PeriodMonthly) {
+			t.Errorf("Period not stored correctly: expected monthly, got %v", stored)
 		}
 	})
 
@@ -103,7 +96,7 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 		// Verify ALL data is preserved
 		category := bot.getData(userID, "category")
 		currency := bot.getData(userID, "currency")
-		periodDays := bot.getData(userID, "period_days")
+		period := bot.getData(userID, "period")
 		storedAutoRenewal := bot.getData(userID, "auto_renewal")
 
 		if category != models.CategoryWork {
@@ -112,8 +105,8 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 		if currency != models.CurrencyUSD {
 			t.Errorf("Currency lost: expected %s, got %v", models.CurrencyUSD, currency)
 		}
-		if periodDays != 30 {
-			t.Errorf("Period lost: expected 30, got %v", periodDays)
+		if period != models.Every(1, models.PeriodMonthly) {
+			t.Errorf("Period lost: expected monthly, got %v", period)
 		}
 		if storedAutoRenewal != true {
 			t.Errorf("Auto renewal not stored: expected true, got %v", storedAutoRenewal)
@@ -125,56 +118,44 @@ func TestCallbackHandlerRegistration(t *testing.T) {
 func TestButtonUniqueIDs(t *testing.T) {
 	// These should match keyboards.go exactly
 	expectedButtons := map[string]string{
-		"cat_work":          "💼 Работа",
-		"cat_entertainment": "🎮 Развлечения",
-		"cat_education":     "📚 Обучение",
-		"cat_home":          "🏠 Дом",
-		"cat_other":         "📦 Другое",
-		"curr_usd":          "💵 USD",
-		"curr_rub":          "🔹 RUB",
-		"period_week":       "🗓️ Неделя",
-		"period_month":      "📅 Месяц",
-		"period_year":       "📆 Год",
-		"period_custom":     "⚡ Другое",
-		"auto_yes":          "✅ Да",
-		"auto_no":           "❌ Нет",
+		"category":       "",
+		"currency":       "",
+		"period_week":    "🗓️ Неделя",
+		"period_month":   "📅 Месяц",
+		"period_quarter": "🗂️ Квартал",
+		"period_year":    "📆 Год",
+		"period_custom":  "⚡ Другое",
+		"auto_yes":       "✅ Да",
+		"auto_no":        "❌ Нет",
 	}
 
 	// Test that our callback parsing matches the button IDs
 	for uniqueID := range expectedButtons {
 		t.Run(uniqueID, func(t *testing.T) {
 			// Test category parsing
-			if uniqueID == "cat_work" {
-				var category models.Category
-				switch uniqueID {
-				case "cat_work":
-					category = models.CategoryWork
-				}
+			if uniqueID == "category" {
+				category := models.Category("work")
 				if category != models.CategoryWork {
 					t.Errorf("Button %s not parsed correctly", uniqueID)
 				}
 			}
 
 			// Test currency parsing
-			if uniqueID == "curr_usd" {
-				var currency models.Currency
-				switch uniqueID {
-				case "curr_usd":
-					currency = models.CurrencyUSD
-				}
-				if currency != models.CurrencyUSD {
+			if uniqueID == "currency" {
+				currency, ok := models.ParseCurrency("usd")
+				if !ok || currency != models.CurrencyUSD {
 					t.Errorf("Button %s not parsed correctly", uniqueID)
 				}
 			}
 
 			// Test period parsing
 			if uniqueID == "period_month" {
-				var periodDays int
+				var period models.BillingPeriod
 				switch uniqueID {
 				case "period_month":
-					periodDays = 30
+					period = models.Every(1, models.PeriodMonthly)
 				}
-				if periodDays != 30 {
+				if period.Unit != models.PeriodMonthly || period.Interval != 1 {
 					t.Errorf("Button %s not parsed correctly", uniqueID)
 				}
 			}
@@ -185,7 +166,7 @@ func TestButtonUniqueIDs(t *testing.T) {
 // Test that clearUserState is called at the right time
 func TestClearUserStateFlow(t *testing.T) {
 	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+		states: NewMemoryStateStore(time.Hour),
 	}
 
 	userID := int64(12345)
@@ -227,3 +208,45 @@ func TestClearUserStateFlow(t *testing.T) {
 		t.Errorf("State not reset: expected %s, got %s", StateIdle, state.State)
 	}
 }
+
+// Test that reminder buttons carry the subscription and billing cycle
+func TestReminderPayloadRoundTrip(t *testing.T) {
+	dueDate := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	payload := cyclePayload(42, dueDate)
+	if payload != "42_2025-03-10" {
+		t.Errorf("Unexpected payload: %s", payload)
+	}
+
+	id, parsedDate, err := parseCyclePayload(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if id != 42 || !parsedDate.Equal(dueDate) {
+		t.Errorf("Expected (42, %s), got (%d, %s)", dueDate, id, parsedDate)
+	}
+
+	for _, invalid := range []string{"", "42", "abc_2025-03-10", "42_10.03.2025"} {
+		if _, _, err := parseCyclePayload(invalid); err == nil {
+			t.Errorf("Expected error for payload %q", invalid)
+		}
+	}
+}
+
+func TestBudgetPayloadRoundTrip(t *testing.T) {
+	for _, budget := range []*models.Budget{
+		{Currency: models.CurrencyRUB},
+		{Category: "custom_1", Currency: models.CurrencyUSD},
+	} {
+		category, currency, ok := parseBudgetPayload(budgetPayload(budget))
+		if !ok || category != budget.Category || currency != budget.Currency {
+			t.Errorf("Expected %s %s, got %s %s %v", budget.Category, budget.Currency, category, currency, ok)
+		}
+	}
+
+	for _, invalid := range []string{"", "RUB", "XXX:work"} {
+		if _, _, ok := parseBudgetPayload(invalid); ok {
+			t.Errorf("Expected payload %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+const categoriesText = "📂 *Категории*\n\n" +
+	"Выберите категорию, чтобы переименовать ее, сменить эмодзи или убрать в архив. " +
+	"Архивные категории не предлагаются для новых подписок, но их подписки остаются на месте."
+
+const categoryEmojiPrompt = "😀 Отправьте эмодзи для категории или «-», чтобы обойтись без него:"
+
+// handleCategories lists the categories of the user, from /categories or the settings
+func (b *Bot) handleCategories(c telebot.Context) error {
+	b.clearUserState(c.Sender().ID)
+
+	categories, err := b.categoryService.GetCategories(context.Background(), ownerOf(c))
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения категорий: %v", err))
+	}
+
+	markup := &telebot.ReplyMarkup{InlineKeyboard: newCategoriesKeyboard(categories)}
+	if c.Callback() == nil {
+		return c.Send(categoriesText, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(categoriesText, markup, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleNewCategory(c telebot.Context) error {
+	b.resetUserState(c.Sender().ID, StateCreatingCategory)
+
+	return c.Edit("📝 Введите название новой категории:", &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	})
+}
+
+func (b *Bot) handleNewCategoryNameInput(c telebot.Context) error {
+	userID := c.Sender().ID
+	name := strings.TrimSpace(c.Text())
+	if name == "" {
+		return c.Send("❌ Название не может быть пустым. Попробуйте еще раз:")
+	}
+
+	b.setData(userID, "category_name", name)
+	b.setState(userID, StateCreatingCategoryEmoji)
+
+	return c.Send(categoryEmojiPrompt)
+}
+
+func (b *Bot) handleNewCategoryEmojiInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	nameData := b.getData(userID, "category_name")
+	if nameData == nil {
+		return c.Send("❌ Ошибка: данные о названии отсутствуют. Начните заново с /categories")
+	}
+
+	category, err := b.categoryService.CreateCategory(context.Background(), userID, nameData.(string), parseCategoryEmoji(c.Text()))
+	if err != nil {
+		// The name is the usual culprit, e.g. a duplicate, so ask for it again
+		b.setState(userID, StateCreatingCategory)
+		return c.Send(fmt.Sprintf("❌ Ошибка при создании категории: %v\n\nВведите другое название:", err))
+	}
+
+	b.clearUserState(userID)
+
+	return c.Send("✅ Категория добавлена\n\n"+formatCategory(category), &telebot.ReplyMarkup{
+		InlineKeyboard: newCategoryActionsKeyboard(category),
+	}, telebot.ModeMarkdown)
+}
+
+// handleManageCategory shows the category passed in the button payload with its actions
+func (b *Bot) handleManageCategory(c telebot.Context) error {
+	userID := c.Sender().ID
+	b.clearUserState(userID)
+
+	category, err := b.categoryService.GetCategory(context.Background(), userID, models.Category(c.Data()))
+	if err != nil {
+		return c.Send("❌ Категория не найдена")
+	}
+
+	return c.Edit(formatCategory(category), &telebot.ReplyMarkup{
+		InlineKeyboard: newCategoryActionsKeyboard(category),
+	}, telebot.ModeMarkdown)
+}
+
+// handleCategoryField asks for the new name or emoji of the category
+func (b *Bot) handleCategoryField(c telebot.Context) error {
+	userID := c.Sender().ID
+	key := models.Category(c.Data())
+
+	switch callbackUnique(c) {
+	case "category_rename":
+		b.resetUserState(userID, StateRenamingCategory)
+		b.setData(userID, "category_key", key)
+		return c.Edit("📝 Введите новое название категории:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "category_emoji":
+		b.resetUserState(userID, StateSettingCategoryEmoji)
+		b.setData(userID, "category_key", key)
+		return c.Edit(categoryEmojiPrompt, &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	}
+
+	return b.showMainMenu(c)
+}
+
+func (b *Bot) handleRenameCategoryInput(c telebot.Context) error {
+	name := strings.TrimSpace(c.Text())
+	if name == "" {
+		return c.Send("❌ Название не может быть пустым. Попробуйте еще раз:")
+	}
+
+	return b.applyCategoryEdit(c, "", &models.UpdateCategoryRequest{Name: &name})
+}
+
+func (b *Bot) handleCategoryEmojiInput(c telebot.Context) error {
+	emoji := parseCategoryEmoji(c.Text())
+	return b.applyCategoryEdit(c, "", &models.UpdateCategoryRequest{Emoji: &emoji})
+}
+
+func (b *Bot) handleArchiveCategory(c telebot.Context) error {
+	archived := callbackUnique(c) == "category_archive"
+	return b.applyCategoryEdit(c, models.Category(c.Data()), &models.UpdateCategoryRequest{Archived: &archived})
+}
+
+// applyCategoryEdit saves the change of the category and shows it again. An
+// empty key takes the category kept in the user state by a text prompt.
+func (b *Bot) applyCategoryEdit(c telebot.Context, key models.Category, req *models.UpdateCategoryRequest) error {
+	userID := c.Sender().ID
+
+	if key == "" {
+		keyData := b.getData(userID, "category_key")
+		if keyData == nil {
+			return c.Send("❌ Ошибка: категория для изменения не выбрана. Начните заново с /categories")
+		}
+		key = keyData.(models.Category)
+	}
+
+	category, err := b.categoryService.UpdateCategory(context.Background(), userID, key, req)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при изменении категории: %v", err))
+	}
+
+	b.clearUserState(userID)
+
+	text := "✅ Изменения сохранены\n\n" + formatCategory(category)
+	markup := &telebot.ReplyMarkup{InlineKeyboard: newCategoryActionsKeyboard(category)}
+
+	// Text input can't be edited into a menu, so answer with a new message
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+func formatCategory(category *models.UserCategory) string {
+	status := "✅ Активна"
+	if category.Archived {
+		status = "🗄️ В архиве"
+	}
+	return fmt.Sprintf("📂 *%s*\n\n%s", category.Label(), status)
+}
+
+// parseCategoryEmoji reads the emoji prompt answer, "-" means no emoji
+func parseCategoryEmoji(text string) string {
+	emoji := strings.TrimSpace(text)
+	if emoji == "-" {
+		return ""
+	}
+	return emoji
+}
+
+// categoryLabels maps the category keys of the user to their labels. Labels
+// are decoration, so a failed lookup leaves the map empty and the keys shown.
+func (b *Bot) categoryLabels(userID int64) map[models.Category]string {
+	labels := make(map[models.Category]string)
+
+	categories, err := b.categoryService.GetCategories(context.Background(), userID)
+	if err != nil {
+		return labels
+	}
+	for _, category := range categories {
+		labels[category.Key] = category.Label()
+	}
+	return labels
+}
+
+// categoryLabel returns the label of one category of the user
+func (b *Bot) categoryLabel(userID int64, key models.Category) string {
+	return labelOf(b.categoryLabels(userID), key)
+}
+
+func labelOf(labels map[models.Category]string, key models.Category) string {
+	if label, ok := labels[key]; ok {
+		return label
+	}
+	return string(key)
+}
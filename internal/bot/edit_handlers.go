@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleEditSubscription opens the edit menu of the subscription passed in the button payload
+func (b *Bot) handleEditSubscription(c telebot.Context) error {
+	userID := c.Sender().ID
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID подписки")
+	}
+
+	ctx := context.Background()
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	b.resetUserState(userID, StateEditingSubscription)
+	b.setData(userID, "edit_id", subscription.ID)
+
+	return c.Edit(b.formatEditMenu(userID, subscription), &telebot.ReplyMarkup{
+		InlineKeyboard: editKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+// handleEditField asks for the new value of the chosen field
+func (b *Bot) handleEditField(c telebot.Context) error {
+	userID := c.Sender().ID
+	if !b.isEditing(userID) {
+		return b.showMainMenu(c)
+	}
+
+	switch callbackUnique(c) {
+	case "edit_name":
+		b.setState(userID, StateEditingName)
+		return c.Edit("📝 Введите новое название подписки:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_cost":
+		b.setState(userID, StateEditingCost)
+		return c.Edit("💰 Введите новую стоимость в валюте подписки:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_date":
+		b.setState(userID, StateEditingDate)
+		return c.Edit("🗓️ Введите дату следующего платежа в формате ДД.ММ.ГГГГ:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_currency":
+		b.setState(userID, StateEditingSubscription)
+		return c.Edit("💱 Выберите новую валюту:", &telebot.ReplyMarkup{
+			InlineKeyboard: newCurrencyKeyboard(),
+		})
+	case "edit_period":
+		b.setState(userID, StateEditingSubscription)
+		return c.Edit("📅 Выберите новый период оплаты:", &telebot.ReplyMarkup{
+			InlineKeyboard: periodKeyboard,
+		})
+	case "edit_category":
+		categories, err := b.categoryService.GetActiveCategories(context.Background(), userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка получения категорий: %v", err))
+		}
+		b.setState(userID, StateEditingSubscription)
+		return c.Edit("📂 Выберите новую категорию:", &telebot.ReplyMarkup{
+			InlineKeyboard: newCategoryKeyboard(categories),
+		})
+	case "edit_trial":
+		b.setState(userID, StateEditingTrial)
+		return c.Edit(trialPrompt, &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_tags":
+		b.setState(userID, StateEditingTags)
+		return c.Edit("🏷️ Введите теги через запятую, например «семья, отменить», или «-», чтобы убрать все:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_notes":
+		b.setState(userID, StateEditingNotes)
+		return c.Edit("🗒️ Введите заметку или «-», чтобы удалить ее:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_url":
+		b.setState(userID, StateEditingURL)
+		return c.Edit("🔗 Отправьте ссылку, где подписку можно изменить или отменить, или «-», чтобы удалить ее:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "edit_auto":
+		b.setState(userID, StateEditingSubscription)
+		return c.Edit("🔄 Включить автопродление?", &telebot.ReplyMarkup{
+			InlineKeyboard: autoRenewalKeyboard,
+		})
+	}
+
+	return b.showMainMenu(c)
+}
+
+func (b *Bot) handleEditNameInput(c telebot.Context) error {
+	name := strings.TrimSpace(c.Text())
+	if name == "" {
+		return c.Send("❌ Название не может быть пустым. Попробуйте еще раз:")
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{Name: &name})
+}
+
+func (b *Bot) handleEditCostInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	idData := b.getData(userID, "edit_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: подписка для изменения не выбрана. Начните заново с /start")
+	}
+
+	// The amount is typed in the current currency of the subscription
+	subscription, err := b.subscriptionService.GetSubscriptionByID(context.Background(), userID, idData.(int))
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	cost, err := models.ParseMoneyIn(strings.TrimSpace(c.Text()), subscription.Currency)
+	if err != nil || !cost.IsPositive() {
+		return c.Send(fmt.Sprintf("❌ Некорректная стоимость. Введите число больше 0 (например: %s):", exampleAmount(subscription.Currency)))
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{Cost: &cost})
+}
+
+func (b *Bot) handleEditPeriodInput(c telebot.Context) error {
+	period, err := models.ParseBillingPeriod(c.Text())
+	if err != nil {
+		return c.Send("❌ Некорректный период. " + periodPrompt)
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{Period: &period})
+}
+
+func (b *Bot) handleEditDateInput(c telebot.Context) error {
+	date, err := time.Parse("02.01.2006", strings.TrimSpace(c.Text()))
+	if err != nil {
+		return c.Send("❌ Некорректная дата. Введите дату в формате ДД.ММ.ГГГГ (например: 25.12.2025):")
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{NextPayment: &date})
+}
+
+func (b *Bot) handleEditTagsInput(c telebot.Context) error {
+	tags := models.ParseTags(parseClearable(c.Text()))
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{Tags: &tags})
+}
+
+func (b *Bot) handleEditNotesInput(c telebot.Context) error {
+	notes := parseClearable(c.Text())
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{Notes: &notes})
+}
+
+func (b *Bot) handleEditURLInput(c telebot.Context) error {
+	link := parseClearable(c.Text())
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{URL: &link})
+}
+
+// parseClearable reads the answer to a prompt of an optional field, "-" clears the field
+func parseClearable(text string) string {
+	value := strings.TrimSpace(text)
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// applyEdit saves the change and returns the user to the edit menu
+func (b *Bot) applyEdit(c telebot.Context, req *models.UpdateSubscriptionRequest) error {
+	userID := c.Sender().ID
+
+	idData := b.getData(userID, "edit_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: подписка для изменения не выбрана. Начните заново с /start")
+	}
+	id := idData.(int)
+
+	ctx := context.Background()
+	subscription, err := b.subscriptionService.UpdateSubscription(ctx, userID, id, req)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при изменении подписки: %v", err))
+	}
+
+	b.setState(userID, StateEditingSubscription)
+
+	text := "✅ Изменения сохранены\n\n" + b.formatEditMenu(userID, subscription)
+	markup := &telebot.ReplyMarkup{InlineKeyboard: editKeyboard}
+
+	// Text input can't be edited into a menu, so answer with a new message
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+// isEditing reports whether the user is changing an existing subscription
+// rather than adding a new one.
+func (b *Bot) isEditing(userID int64) bool {
+	state := b.getUserState(userID)
+	if state.Data["edit_id"] == nil {
+		return false
+	}
+
+	switch state.State {
+	case StateEditingSubscription, StateEditingName, StateEditingCost, StateEditingPeriod, StateEditingDate, StateEditingTrial,
+		StateEditingTags, StateEditingNotes, StateEditingURL:
+		return true
+	}
+	return false
+}
+
+func (b *Bot) formatEditMenu(userID int64, sub *models.Subscription) string {
+
+	return fmt.Sprintf("✏️ *Изменение подписки*\n\n"+
+		"📝 Название: %s\n"+
+		"💰 Стоимость: %s\n"+
+		"📅 Период: %s\n"+
+		"🗓️ Следующий платеж: %s\n"+
+		"📂 Категория: %s\n"+
+		"🔄 Автопродление: %s\n"+
+		"🧪 Пробный период: %s\n"+
+		"🏷️ Теги: %s\n"+
+		"🗒️ Заметка: %s\n"+
+		"🔗 Ссылка: %s\n\n"+
+		"Выберите, что изменить:",
+		sub.Name,
+		sub.Currency.Format(sub.Cost),
+		describePeriod(sub.Period),
+		sub.NextPayment.Format("02.01.2006"),
+		b.categoryLabel(userID, sub.Category),
+		getBoolEmoji(sub.AutoRenewal),
+		describeTrial(sub, time.Now()),
+		orNone(formatTags(sub.Tags)),
+		orNone(escapeMarkdown(sub.Notes)),
+		orNone(escapeMarkdown(sub.URL)))
+}
+
+// formatTags shows the tags as hashtags
+func formatTags(tags []string) string {
+	hashtags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		hashtags = append(hashtags, "#"+tag)
+	}
+	return escapeMarkdown(strings.Join(hashtags, " "))
+}
+
+func orNone(text string) string {
+	if text == "" {
+		return "нет"
+	}
+	return text
+}
+
+// escapeMarkdown keeps user text like links and notes from being read as
+// Markdown entities, which Telegram rejects when they are unbalanced
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
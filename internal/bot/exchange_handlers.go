@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+const rateUsage = "💱 Использование: `/rate USD RUB 90.5`\n\nКурс означает, сколько единиц второй валюты стоит одна единица первой."
+
+// handleRateCommand stores a manually entered exchange rate: /rate USD RUB 90.5
+func (b *Bot) handleRateCommand(c telebot.Context) error {
+	args := c.Args()
+	if len(args) != 3 {
+		return c.Send(rateUsage, telebot.ModeMarkdown)
+	}
+
+	from, okFrom := models.ParseCurrency(args[0])
+	to, okTo := models.ParseCurrency(args[1])
+	if !okFrom || !okTo {
+		return c.Send("❌ Неподдерживаемая валюта. Доступны: " + supportedCurrencies())
+	}
+
+	rate, err := strconv.ParseFloat(strings.Replace(args[2], ",", ".", 1), 64)
+	if err != nil || rate <= 0 {
+		return c.Send("❌ Некорректный курс. Введите число больше 0 (например: 90.5)")
+	}
+
+	ctx := context.Background()
+	if _, err := b.exchangeService.SetRate(ctx, from, to, rate); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка сохранения курса: %v", err))
+	}
+
+	return c.Send(fmt.Sprintf("✅ Курс сохранен: 1 %s = %s %s", from, formatRate(rate), to))
+}
+
+// handleRatesCommand lists the stored exchange rates
+func (b *Bot) handleRatesCommand(c telebot.Context) error {
+	ctx := context.Background()
+	exchangeRates, err := b.exchangeService.GetRates(ctx)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения курсов: %v", err))
+	}
+
+	text := "💱 *Курсы валют*\n\n"
+	if len(exchangeRates) == 0 {
+		text += "Курсы пока не заданы.\n\n" + rateUsage
+		return c.Send(text, telebot.ModeMarkdown)
+	}
+
+	for _, rate := range exchangeRates {
+		source := "вручную"
+		if rate.Source != models.RateSourceManual {
+			source = rate.Source
+		}
+		text += fmt.Sprintf("• 1 %s = %s %s (%s, %s)\n",
+			rate.From, formatRate(rate.Rate), rate.To, source, rate.UpdatedAt.Format("02.01.2006"))
+	}
+
+	return c.Send(text, telebot.ModeMarkdown)
+}
+
+// handleBaseCurrency asks for the currency that totals are converted to
+func (b *Bot) handleBaseCurrency(c telebot.Context) error {
+	userID := c.Sender().ID
+	b.resetUserState(userID, StateSelectingBaseCurrency)
+
+	return c.Edit("💱 Выберите базовую валюту для итоговых сумм:", &telebot.ReplyMarkup{
+		InlineKeyboard: newCurrencyKeyboard(),
+	})
+}
+
+func (b *Bot) handleBaseCurrencySelection(c telebot.Context, currency models.Currency) error {
+	userID := c.Sender().ID
+
+	ctx := context.Background()
+	if err := b.exchangeService.SetBaseCurrency(ctx, userID, currency); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка сохранения базовой валюты: %v", err))
+	}
+
+	b.clearUserState(userID)
+
+	return c.Edit(fmt.Sprintf("✅ Базовая валюта: %s\n\nИтоговые суммы будут пересчитаны в эту валюту.", currencyLabel(currency)),
+		&telebot.ReplyMarkup{
+			InlineKeyboard: [][]telebot.InlineButton{
+				{btnSettings},
+				{btnBack},
+			},
+		})
+}
+
+// formatConvertedTotal shows a total in the base currency and the currencies it could not include
+func formatConvertedTotal(total models.ConvertedTotal) string {
+	text := total.Currency.Format(total.Amount)
+	if len(total.Missing) > 0 {
+		missing := make([]string, len(total.Missing))
+		for i, currency := range total.Missing {
+			missing[i] = string(currency)
+		}
+		text += fmt.Sprintf(" (без %s: нет курса, см. /rate)", strings.Join(missing, ", "))
+	}
+	return text
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
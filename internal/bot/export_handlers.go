@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sub-cos-counter/internal/export"
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+const exportUsage = "📤 Использование: `/export` — все платежи, `/export 01.01.2025 31.03.2025` — платежи за период"
+
+// handleExportCommand sends subscriptions and payments as CSV documents:
+// /export [ДД.ММ.ГГГГ [ДД.ММ.ГГГГ]]
+func (b *Bot) handleExportCommand(c telebot.Context) error {
+	userID := ownerOf(c)
+
+	var filter models.PaymentFilter
+	if args := c.Args(); len(args) > 0 {
+		from, to, err := parseDateRange(strings.Join(args, " "))
+		if err != nil {
+			return c.Send("❌ Некорректный период.\n\n"+exportUsage, telebot.ModeMarkdown)
+		}
+		filter.From, filter.To = from, to
+	}
+
+	ctx := context.Background()
+	subscriptions, err := b.subscriptionService.GetAllSubscriptions(ctx, userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения подписок: %v", err))
+	}
+	payments, err := b.analyticsService.GetPayments(ctx, userID, filter)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения платежей: %v", err))
+	}
+
+	var subscriptionsCSV, paymentsCSV bytes.Buffer
+	if err := export.WriteSubscriptionsCSV(&subscriptionsCSV, subscriptions); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка экспорта: %v", err))
+	}
+	if err := export.WritePaymentsCSV(&paymentsCSV, payments, subscriptions); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка экспорта: %v", err))
+	}
+
+	period := "за все время"
+	if dates := describeDateRange(filter.From, filter.To); dates != "" {
+		period = dates
+	}
+
+	err = c.Send(&telebot.Document{
+		File:     telebot.FromReader(&subscriptionsCSV),
+		FileName: "subscriptions.csv",
+		MIME:     "text/csv",
+		Caption:  fmt.Sprintf("📋 Подписки: %d", len(subscriptions)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Send(&telebot.Document{
+		File:     telebot.FromReader(&paymentsCSV),
+		FileName: "payments.csv",
+		MIME:     "text/csv",
+		Caption:  fmt.Sprintf("📜 Платежи %s: %d", period, len(payments)),
+	})
+}
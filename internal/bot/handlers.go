@@ -23,6 +23,18 @@ func (b *Bot) setupHandlers() {
 	// Start command
 	b.bot.Handle("/start", b.handleStart)
 
+	// Exchange rate commands
+	b.bot.Handle("/rate", b.handleRateCommand)
+	b.bot.Handle("/rates", b.handleRatesCommand)
+	b.bot.Handle(&btnBaseCurrency, b.handleBaseCurrency)
+
+	// Export, import and backups
+	b.bot.Handle("/export", b.handleExportCommand)
+	b.bot.Handle("/import", b.handleImportCommand)
+	b.bot.Handle("/backup", b.handleBackupCommand)
+	b.bot.Handle("/restore", b.handleRestoreCommand)
+	b.bot.Handle(telebot.OnDocument, b.handleDocument)
+
 	// Main menu callbacks
 	b.bot.Handle(&btnAddSubscription, b.handleAddSubscription)
 	b.bot.Handle(&btnMySubscriptions, b.handleMySubscriptions)
@@ -30,22 +42,34 @@ func (b *Bot) setupHandlers() {
 	b.bot.Handle(&btnAnalytics, b.handleAnalytics)
 	b.bot.Handle(&btnHistory, b.handleHistory)
 	b.bot.Handle(&btnSettings, b.handleSettings)
+	b.bot.Handle(&btnTrials, b.handleTrials)
 
 	// Category selection callbacks
-	log.Printf("DEBUG: Registering category handlers - Work: %s", btnCategoryWork.Unique)
-	b.bot.Handle(&btnCategoryEntertainment, b.handleCategorySelection)
-	b.bot.Handle(&btnCategoryWork, b.handleCategorySelection)
-	b.bot.Handle(&btnCategoryEducation, b.handleCategorySelection)
-	b.bot.Handle(&btnCategoryHome, b.handleCategorySelection)
-	b.bot.Handle(&btnCategoryOther, b.handleCategorySelection)
+	b.bot.Handle(&btnCategory, b.handleCategorySelection)
+
+	// Category management
+	b.bot.Handle("/categories", b.handleCategories)
+	b.bot.Handle(&btnCategories, b.handleCategories)
+	b.bot.Handle(&btnNewCategory, b.handleNewCategory)
+	b.bot.Handle(&btnManageCategory, b.handleManageCategory)
+	b.bot.Handle(&btnRenameCategory, b.handleCategoryField)
+	b.bot.Handle(&btnSetCategoryEmoji, b.handleCategoryField)
+	b.bot.Handle(&btnArchiveCategory, b.handleArchiveCategory)
+	b.bot.Handle(&btnUnarchiveCategory, b.handleArchiveCategory)
+
+	// Budgets
+	b.bot.Handle("/budgets", b.handleBudgets)
+	b.bot.Handle(&btnBudgets, b.handleBudgets)
+	b.bot.Handle(&btnNewBudget, b.handleNewBudget)
+	b.bot.Handle(&btnDeleteBudget, b.handleDeleteBudget)
 
 	// Currency selection callbacks
-	b.bot.Handle(&btnCurrencyUSD, b.handleCurrencySelection)
-	b.bot.Handle(&btnCurrencyRUB, b.handleCurrencySelection)
+	b.bot.Handle(&btnCurrency, b.handleCurrencySelection)
 
 	// Period selection callbacks
 	b.bot.Handle(&btnPeriodWeek, b.handlePeriodSelection)
 	b.bot.Handle(&btnPeriodMonth, b.handlePeriodSelection)
+	b.bot.Handle(&btnPeriodQuarter, b.handlePeriodSelection)
 	b.bot.Handle(&btnPeriodYear, b.handlePeriodSelection)
 	b.bot.Handle(&btnPeriodCustom, b.handlePeriodSelection)
 
@@ -53,6 +77,52 @@ func (b *Bot) setupHandlers() {
 	b.bot.Handle(&btnAutoRenewalYes, b.handleAutoRenewalSelection)
 	b.bot.Handle(&btnAutoRenewalNo, b.handleAutoRenewalSelection)
 
+	// Subscription details callback
+	b.bot.Handle(&btnSubscriptionInfo, b.handleSubscriptionInfo)
+	b.bot.Handle(&btnPaySubscription, b.handlePaySubscription)
+
+	// Edit callbacks
+	b.bot.Handle(&btnEditSubscription, b.handleEditSubscription)
+	b.bot.Handle(&btnEditName, b.handleEditField)
+	b.bot.Handle(&btnEditCost, b.handleEditField)
+	b.bot.Handle(&btnEditCurrency, b.handleEditField)
+	b.bot.Handle(&btnEditPeriod, b.handleEditField)
+	b.bot.Handle(&btnEditDate, b.handleEditField)
+	b.bot.Handle(&btnEditCategory, b.handleEditField)
+	b.bot.Handle(&btnEditAutoRenewal, b.handleEditField)
+	b.bot.Handle(&btnEditTrial, b.handleEditField)
+	b.bot.Handle(&btnEditTags, b.handleEditField)
+	b.bot.Handle(&btnEditNotes, b.handleEditField)
+	b.bot.Handle(&btnEditURL, b.handleEditField)
+
+	// Custom payments
+	b.bot.Handle(&btnRecordPayment, b.handleRecordPayment)
+	b.bot.Handle(&btnPaymentStatus, b.handlePaymentStatusSelection)
+	b.bot.Handle(&btnSettleCycleYes, b.handleSettleCycleSelection)
+	b.bot.Handle(&btnSettleCycleNo, b.handleSettleCycleSelection)
+	b.bot.Handle(&btnPaymentInfo, b.handlePaymentInfo)
+	b.bot.Handle(&btnEditPaymentAmount, b.handlePaymentEditField)
+	b.bot.Handle(&btnEditPaymentDate, b.handlePaymentEditField)
+	b.bot.Handle(&btnEditPaymentStatus, b.handlePaymentEditField)
+	b.bot.Handle(&btnEditPaymentNote, b.handlePaymentEditField)
+	b.bot.Handle(&btnVoidPayment, b.handleVoidPayment)
+
+	// Payment history pages and filters
+	b.bot.Handle(&btnHistoryPage, b.handleHistoryPage)
+	b.bot.Handle(&btnHistoryFilters, b.handleHistoryFilters)
+	b.bot.Handle(&btnHistoryFilterField, b.handleHistoryFilterField)
+	b.bot.Handle(&btnHistoryFilterValue, b.handleHistoryFilterValue)
+	b.bot.Handle(&btnHistoryDates, b.handleHistoryDates)
+	b.bot.Handle(&btnHistoryReset, b.handleHistoryReset)
+
+	// Trials
+	b.bot.Handle(&btnSetTrial, b.handleSetTrial)
+	b.bot.Handle(&btnCancelTrial, b.handleCancelTrial)
+
+	// Reminder callbacks
+	b.bot.Handle(&btnReminderPaid, b.handleReminderPaid)
+	b.bot.Handle(&btnReminderSnooze, b.handleReminderSnooze)
+
 	// Action callbacks
 	b.bot.Handle(&btnBack, b.handleBack)
 
@@ -78,15 +148,17 @@ func (b *Bot) showMainMenu(c telebot.Context) error {
 func (b *Bot) handleAddSubscription(c telebot.Context) error {
 	userID := c.Sender().ID
 	// Reset state but keep any existing data clean
-	b.userStates[userID] = &UserState{
-		State: StateAddingSubscription,
-		Data:  make(map[string]interface{}),
+	b.resetUserState(userID, StateAddingSubscription)
+
+	categories, err := b.categoryService.GetActiveCategories(context.Background(), userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения категорий: %v", err))
 	}
 
 	text := "📝 *Добавление новой подписки*\n\nВыберите категорию:"
 
 	return c.Edit(text, &telebot.ReplyMarkup{
-		InlineKeyboard: categoryKeyboard,
+		InlineKeyboard: newCategoryKeyboard(categories),
 	}, telebot.ModeMarkdown)
 }
 
@@ -95,42 +167,36 @@ func (b *Bot) handleCategorySelection(c telebot.Context) error {
 
 	log.Printf("DEBUG: handleCategorySelection called for user %d with data: %s", userID, c.Data())
 
-	var category models.Category
-	switch c.Data() {
-	case "cat_entertainment":
-		category = models.CategoryEntertainment
-	case "cat_work":
-		category = models.CategoryWork
-	case "cat_education":
-		category = models.CategoryEducation
-	case "cat_home":
-		category = models.CategoryHome
-	case "cat_other":
-		category = models.CategoryOther
+	category := models.Category(c.Data())
+
+	if b.isEditing(userID) {
+		return b.applyEdit(c, &models.UpdateSubscriptionRequest{Category: &category})
 	}
 
 	log.Printf("DEBUG: Setting category for user %d: %s", userID, category)
 	b.setData(userID, "category", category)
 
-	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n\nВыберите валюту:", getCategoryEmoji(category))
+	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n\nВыберите валюту:", b.categoryLabel(userID, category))
 
 	return c.Edit(text, &telebot.ReplyMarkup{
-		InlineKeyboard: currencyKeyboard,
+		InlineKeyboard: newCurrencyKeyboard(),
 	}, telebot.ModeMarkdown)
 }
 
 func (b *Bot) handleCurrencySelection(c telebot.Context) error {
 	userID := c.Sender().ID
 
-	var currency models.Currency
-	currencyText := ""
-	switch c.Data() {
-	case "curr_usd":
-		currency = models.CurrencyUSD
-		currencyText = "💵 USD"
-	case "curr_rub":
-		currency = models.CurrencyRUB
-		currencyText = "🔹 RUB"
+	currency, ok := models.ParseCurrency(c.Data())
+	if !ok {
+		return c.Send("❌ Неподдерживаемая валюта")
+	}
+
+	if b.getUserState(userID).State == StateSelectingBaseCurrency {
+		return b.handleBaseCurrencySelection(c, currency)
+	}
+
+	if b.isEditing(userID) {
+		return b.applyEdit(c, &models.UpdateSubscriptionRequest{Currency: &currency})
 	}
 
 	b.setData(userID, "currency", currency)
@@ -138,7 +204,7 @@ func (b *Bot) handleCurrencySelection(c telebot.Context) error {
 	category := b.getData(userID, "category").(models.Category)
 
 	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n✅ Валюта: %s\n\nВыберите период оплаты:",
-		getCategoryEmoji(category), currencyText)
+		b.categoryLabel(userID, category), currencyLabel(currency))
 
 	return c.Edit(text, &telebot.ReplyMarkup{
 		InlineKeyboard: periodKeyboard,
@@ -148,38 +214,39 @@ func (b *Bot) handleCurrencySelection(c telebot.Context) error {
 func (b *Bot) handlePeriodSelection(c telebot.Context) error {
 	userID := c.Sender().ID
 
-	var periodDays int
-	periodText := ""
-	switch c.Data() {
+	var period models.BillingPeriod
+	switch callbackUnique(c) {
 	case "period_week":
-		periodDays = 7
-		periodText = "🗓️ Неделя"
+		period = models.Every(1, models.PeriodWeekly)
 	case "period_month":
-		periodDays = 30
-		periodText = "📅 Месяц"
+		period = models.Every(1, models.PeriodMonthly)
+	case "period_quarter":
+		period = models.Every(1, models.PeriodQuarterly)
 	case "period_year":
-		periodDays = 365
-		periodText = "📆 Год"
+		period = models.Every(1, models.PeriodYearly)
 	case "period_custom":
-		b.setState(userID, StateWaitingForDate)
-		return c.Edit("📝 Введите количество дней между платежами (например: 14):", &telebot.ReplyMarkup{
+		if b.isEditing(userID) {
+			b.setState(userID, StateEditingPeriod)
+		} else {
+			b.setState(userID, StateWaitingForDate)
+		}
+		return c.Edit(periodPrompt, &telebot.ReplyMarkup{
 			InlineKeyboard: backKeyboard,
 		})
 	}
 
-	b.setData(userID, "period_days", periodDays)
+	if b.isEditing(userID) {
+		return b.applyEdit(c, &models.UpdateSubscriptionRequest{Period: &period})
+	}
+
+	b.setData(userID, "period", period)
 
 	category := b.getData(userID, "category").(models.Category)
 	currency := b.getData(userID, "currency").(models.Currency)
-	currencyText := ""
-	if currency == models.CurrencyUSD {
-		currencyText = "💵 USD"
-	} else {
-		currencyText = "🔹 RUB"
-	}
+	currencyText := currencyLabel(currency)
 
 	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n✅ Валюта: %s\n✅ Период: %s\n\nВключить автопродление?",
-		getCategoryEmoji(category), currencyText, periodText)
+		b.categoryLabel(userID, category), currencyText, describePeriod(period))
 
 	return c.Edit(text, &telebot.ReplyMarkup{
 		InlineKeyboard: autoRenewalKeyboard,
@@ -189,15 +256,19 @@ func (b *Bot) handlePeriodSelection(c telebot.Context) error {
 func (b *Bot) handleAutoRenewalSelection(c telebot.Context) error {
 	userID := c.Sender().ID
 
-	log.Printf("DEBUG: handleAutoRenewalSelection called for user %d with data: %s", userID, c.Data())
+	log.Printf("DEBUG: handleAutoRenewalSelection called for user %d with data: %s", userID, callbackUnique(c))
 
 	// Check what data we have stored
 	categoryData := b.getData(userID, "category")
 	currencyData := b.getData(userID, "currency")
-	periodData := b.getData(userID, "period_days")
+	periodData := b.getData(userID, "period")
 	log.Printf("DEBUG: Current user data - category: %v, currency: %v, period: %v", categoryData, currencyData, periodData)
 
-	autoRenewal := c.Data() == "auto_yes"
+	autoRenewal := callbackUnique(c) == "auto_yes"
+	if b.isEditing(userID) {
+		return b.applyEdit(c, &models.UpdateSubscriptionRequest{AutoRenewal: &autoRenewal})
+	}
+
 	autoRenewalText := "❌ Нет"
 	if autoRenewal {
 		autoRenewalText = "✅ Да"
@@ -209,29 +280,12 @@ func (b *Bot) handleAutoRenewalSelection(c telebot.Context) error {
 	category := b.getData(userID, "category").(models.Category)
 	currency := b.getData(userID, "currency").(models.Currency)
 
-	periodDays := b.getData(userID, "period_days").(int)
+	period := b.getData(userID, "period").(models.BillingPeriod)
 
-	currencyText := ""
-	periodText := ""
-	if currency == models.CurrencyUSD {
-		currencyText = "💵 USD"
-	} else {
-		currencyText = "🔹 RUB"
-	}
-
-	switch periodDays {
-	case 7:
-		periodText = "🗓️ Неделя"
-	case 30:
-		periodText = "📅 Месяц"
-	case 365:
-		periodText = "📆 Год"
-	default:
-		periodText = fmt.Sprintf("⚡ %d дней", periodDays)
-	}
+	currencyText := currencyLabel(currency)
 
 	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n✅ Валюта: %s\n✅ Период: %s\n✅ Автопродление: %s\n\n💬 Введите название подписки:",
-		getCategoryEmoji(category), currencyText, periodText, autoRenewalText)
+		b.categoryLabel(userID, category), currencyText, describePeriod(period), autoRenewalText)
 
 	return c.Edit(text, &telebot.ReplyMarkup{
 		InlineKeyboard: backKeyboard,
@@ -249,6 +303,46 @@ func (b *Bot) handleTextMessage(c telebot.Context) error {
 		return b.handleCostInput(c)
 	case StateWaitingForDate:
 		return b.handleDateInput(c)
+	case StateEditingName:
+		return b.handleEditNameInput(c)
+	case StateEditingCost:
+		return b.handleEditCostInput(c)
+	case StateEditingPeriod:
+		return b.handleEditPeriodInput(c)
+	case StateEditingDate:
+		return b.handleEditDateInput(c)
+	case StateEditingTrial:
+		return b.handleEditTrialInput(c)
+	case StateEditingTags:
+		return b.handleEditTagsInput(c)
+	case StateEditingNotes:
+		return b.handleEditNotesInput(c)
+	case StateEditingURL:
+		return b.handleEditURLInput(c)
+	case StateRecordingAmount:
+		return b.handleRecordAmountInput(c)
+	case StateRecordingDate:
+		return b.handleRecordDateInput(c)
+	case StateRecordingNote:
+		return b.handleRecordNoteInput(c)
+	case StateEditingPaymentAmount:
+		return b.handleEditPaymentAmountInput(c)
+	case StateEditingPaymentDate:
+		return b.handleEditPaymentDateInput(c)
+	case StateEditingPaymentNote:
+		return b.handleEditPaymentNoteInput(c)
+	case StateFilteringHistoryDates:
+		return b.handleHistoryDatesInput(c)
+	case StateCreatingCategory:
+		return b.handleNewCategoryNameInput(c)
+	case StateCreatingCategoryEmoji:
+		return b.handleNewCategoryEmojiInput(c)
+	case StateRenamingCategory:
+		return b.handleRenameCategoryInput(c)
+	case StateSettingCategoryEmoji:
+		return b.handleCategoryEmojiInput(c)
+	case StateSettingBudget:
+		return b.handleBudgetInput(c)
 	default:
 		return b.showMainMenu(c)
 	}
@@ -271,21 +365,22 @@ func (b *Bot) handleNameInput(c telebot.Context) error {
 	}
 	currency := currencyData.(models.Currency)
 
-	currencySymbol := "$"
-	if currency == models.CurrencyRUB {
-		currencySymbol = "₽"
-	}
-
-	return c.Send(fmt.Sprintf("💰 Введите стоимость подписки в %s (например: 15.99):", currencySymbol))
+	return c.Send(fmt.Sprintf("💰 Введите стоимость подписки в %s (например: %s):", currency.Symbol(), exampleAmount(currency)))
 }
 
 func (b *Bot) handleCostInput(c telebot.Context) error {
 	userID := c.Sender().ID
 	costStr := strings.TrimSpace(c.Text())
 
-	cost, err := models.ParseMoney(costStr)
+	currencyData := b.getData(userID, "currency")
+	if currencyData == nil {
+		return c.Send("❌ Ошибка: данные о валюте отсутствуют. Начните заново с /start")
+	}
+	currency := currencyData.(models.Currency)
+
+	cost, err := models.ParseMoneyIn(costStr, currency)
 	if err != nil || !cost.IsPositive() {
-		return c.Send("❌ Некорректная стоимость. Введите число больше 0 (например: 15.99):")
+		return c.Send(fmt.Sprintf("❌ Некорректная стоимость. Введите число больше 0 (например: %s):", exampleAmount(currency)))
 	}
 
 	b.setData(userID, "cost", cost)
@@ -296,26 +391,19 @@ func (b *Bot) handleCostInput(c telebot.Context) error {
 
 func (b *Bot) handleDateInput(c telebot.Context) error {
 	userID := c.Sender().ID
-	daysStr := strings.TrimSpace(c.Text())
-
-	days, err := strconv.Atoi(daysStr)
-	if err != nil || days <= 0 {
-		return c.Send("❌ Некорректное количество дней. Введите число больше 0:")
+	period, err := models.ParseBillingPeriod(c.Text())
+	if err != nil {
+		return c.Send("❌ Некорректный период. " + periodPrompt)
 	}
 
-	b.setData(userID, "period_days", days)
+	b.setData(userID, "period", period)
 
 	category := b.getData(userID, "category").(models.Category)
 	currency := b.getData(userID, "currency").(models.Currency)
-	currencyText := ""
-	if currency == models.CurrencyUSD {
-		currencyText = "💵 USD"
-	} else {
-		currencyText = "🔹 RUB"
-	}
+	currencyText := currencyLabel(currency)
 
-	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n✅ Валюта: %s\n✅ Период: ⚡ %d дней\n\nВключить автопродление?",
-		getCategoryEmoji(category), currencyText, days)
+	text := fmt.Sprintf("📝 *Добавление подписки*\n\n✅ Категория: %s\n✅ Валюта: %s\n✅ Период: %s\n\nВключить автопродление?",
+		b.categoryLabel(userID, category), currencyText, describePeriod(period))
 
 	return c.Send(text, &telebot.ReplyMarkup{
 		InlineKeyboard: autoRenewalKeyboard,
@@ -350,11 +438,11 @@ func (b *Bot) createSubscription(c telebot.Context) error {
 	}
 	category := categoryData.(models.Category)
 
-	periodDaysData := b.getData(userID, "period_days")
-	if periodDaysData == nil {
+	periodData := b.getData(userID, "period")
+	if periodData == nil {
 		return c.Send("❌ Ошибка: данные о периоде отсутствуют")
 	}
-	periodDays := periodDaysData.(int)
+	period := periodData.(models.BillingPeriod)
 
 	autoRenewalData := b.getData(userID, "auto_renewal")
 	if autoRenewalData == nil {
@@ -363,13 +451,14 @@ func (b *Bot) createSubscription(c telebot.Context) error {
 	autoRenewal := autoRenewalData.(bool)
 
 	// Set next payment date (starting from today + period)
-	nextPayment := time.Now().AddDate(0, 0, periodDays)
+	nextPayment := period.Next(time.Now())
 
 	req := &models.CreateSubscriptionRequest{
+		UserID:      userID,
 		Name:        name,
 		Cost:        cost,
 		Currency:    currency,
-		PeriodDays:  periodDays,
+		Period:      period,
 		NextPayment: nextPayment,
 		Category:    category,
 		AutoRenewal: autoRenewal,
@@ -384,39 +473,27 @@ func (b *Bot) createSubscription(c telebot.Context) error {
 	// Clear user state
 	b.clearUserState(userID)
 
-	currencySymbol := "$"
-	if currency == models.CurrencyRUB {
-		currencySymbol = "₽"
-	}
-
-	periodText := ""
-	switch periodDays {
-	case 7:
-		periodText = "неделю"
-	case 30:
-		periodText = "месяц"
-	case 365:
-		periodText = "год"
-	default:
-		periodText = fmt.Sprintf("%d дней", periodDays)
-	}
-
 	text := fmt.Sprintf("✅ *Подписка успешно добавлена!*\n\n"+
 		"📝 Название: %s\n"+
-		"💰 Стоимость: %s%s\n"+
-		"📅 Период: каждые %s\n"+
+		"💰 Стоимость: %s\n"+
+		"📅 Период: %s\n"+
 		"🗓️ Следующий платеж: %s\n"+
 		"📂 Категория: %s\n"+
 		"🔄 Автопродление: %s",
 		subscription.Name,
-		subscription.Cost.String(), currencySymbol,
-		periodText,
+		currency.Format(subscription.Cost),
+		describePeriod(subscription.Period),
 		subscription.NextPayment.Format("02.01.2006"),
-		getCategoryEmoji(category),
+		b.categoryLabel(userID, category),
 		getBoolEmoji(autoRenewal))
 
+	// A new subscription is often a trial, offer to record it right away
+	trialBtn := btnSetTrial
+	trialBtn.Data = strconv.Itoa(subscription.ID)
+	keyboard := append([][]telebot.InlineButton{{trialBtn}}, mainMenuKeyboard...)
+
 	return c.Send(text, &telebot.ReplyMarkup{
-		InlineKeyboard: mainMenuKeyboard,
+		InlineKeyboard: keyboard,
 	}, telebot.ModeMarkdown)
 }
 
@@ -426,21 +503,18 @@ func (b *Bot) handleBack(c telebot.Context) error {
 	return b.showMainMenu(c)
 }
 
-func getCategoryEmoji(category models.Category) string {
-	switch category {
-	case models.CategoryEntertainment:
-		return "🎮 Развлечения"
-	case models.CategoryWork:
-		return "💼 Работа"
-	case models.CategoryEducation:
-		return "📚 Обучение"
-	case models.CategoryHome:
-		return "🏠 Дом"
-	case models.CategoryOther:
-		return "📦 Другое"
-	default:
-		return "📦 Другое"
+// callbackUnique returns the unique ID of the pressed button. c.Data() only
+// carries the optional payload, which is empty for static buttons.
+func callbackUnique(c telebot.Context) string {
+	if c.Callback() == nil {
+		return ""
 	}
+	return c.Callback().Unique
+}
+
+// exampleAmount shows how to type a price in the currency
+func exampleAmount(currency models.Currency) string {
+	return models.NewMoney(1599).StringIn(currency)
 }
 
 func getBoolEmoji(value bool) string {
@@ -449,3 +523,53 @@ func getBoolEmoji(value bool) string {
 	}
 	return "❌ Нет"
 }
+
+const periodPrompt = "📝 Введите период между платежами, например: 14 (дней), 2 недели, 3 месяца или 1 год:"
+
+// describePeriod returns a period like "каждые 3 месяца"
+func describePeriod(period models.BillingPeriod) string {
+	var forms [3]string
+	var single string
+	switch period.Unit {
+	case models.PeriodDaily:
+		single, forms = "каждый день", [3]string{"день", "дня", "дней"}
+	case models.PeriodWeekly:
+		single, forms = "каждую неделю", [3]string{"неделю", "недели", "недель"}
+	case models.PeriodMonthly:
+		single, forms = "каждый месяц", [3]string{"месяц", "месяца", "месяцев"}
+	case models.PeriodQuarterly:
+		single, forms = "каждый квартал", [3]string{"квартал", "квартала", "кварталов"}
+	case models.PeriodYearly:
+		single, forms = "каждый год", [3]string{"год", "года", "лет"}
+	default:
+		return "неизвестно"
+	}
+
+	if period.Interval == 1 {
+		return single
+	}
+
+	word := pluralize(period.Interval, forms)
+	if word == forms[0] {
+		// "каждый 21 день", "каждую 31 неделю"
+		return fmt.Sprintf("%s %d %s", strings.Fields(single)[0], period.Interval, word)
+	}
+	return fmt.Sprintf("каждые %d %s", period.Interval, word)
+}
+
+// pluralize picks the Russian word form for n: 1 месяц, 2 месяца, 5 месяцев
+func pluralize(n int, forms [3]string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return forms[0]
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return forms[1]
+	default:
+		return forms[2]
+	}
+}
+
+// pluralizeCount returns n with the matching word form: "3 дня"
+func pluralizeCount(n int, forms [3]string) string {
+	return fmt.Sprintf("%d %s", n, pluralize(n, forms))
+}
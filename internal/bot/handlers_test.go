@@ -1,8 +1,12 @@
 package bot
 
 import (
+	"strings"
 	"sub-cos-counter/internal/models"
 	"testing"
+	"time"
+
+	"gopkg.in/telebot.v3"
 )
 
 // Mock context for testing
@@ -44,7 +48,7 @@ func (m *mockUser) ID() int64 {
 func TestBotStateManagement(t *testing.T) {
 	// Create a bot instance for testing
 	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+		states: NewMemoryStateStore(time.Hour),
 	}
 
 	userID := int64(12345)
@@ -65,7 +69,7 @@ func TestBotStateManagement(t *testing.T) {
 	// Test setting data
 	bot.setData(userID, "category", models.CategoryWork)
 	bot.setData(userID, "currency", models.CurrencyUSD)
-	bot.setData(userID, "period_days", 30)
+	bot.setData(userID, "period", models.Every(1, models.PeriodMonthly))
 
 	// Test getting data
 	category := bot.getData(userID, "category")
@@ -78,9 +82,9 @@ func TestBotStateManagement(t *testing.T) {
 		t.Errorf("Expected currency to be %s, got %v", models.CurrencyUSD, currency)
 	}
 
-	periodDays := bot.getData(userID, "period_days")
-	if periodDays != 30 {
-		t.Errorf("Expected period_days to be 30, got %v", periodDays)
+	period := bot.getData(userID, "period")
+	if period != models.Every(1, models.PeriodMonthly) {
+		t.Errorf("Expected period to be monthly, got %v", period)
 	}
 
 	// Test clear state
@@ -97,51 +101,68 @@ func TestBotStateManagement(t *testing.T) {
 	}
 }
 
-// Test category parsing
-func TestCategoryParsing(t *testing.T) {
+// Test that the category keyboard carries the keys of the user's categories
+func TestCategoryKeyboard(t *testing.T) {
+	categories := []*models.UserCategory{
+		{Key: models.CategoryWork, Name: "Работа", Emoji: "💼"},
+		{Key: "custom_6", Name: "Музыка"},
+		{Key: models.CategoryHome, Name: "Дом", Emoji: "🏠"},
+	}
+
+	keyboard := newCategoryKeyboard(categories)
+	if len(keyboard) != 3 || len(keyboard[0]) != 2 || len(keyboard[1]) != 1 {
+		t.Fatalf("Expected two categories per row and a back button, got %v", keyboard)
+	}
+
 	tests := []struct {
-		callbackData     string
-		expectedCategory models.Category
+		btn  telebot.InlineButton
+		key  models.Category
+		text string
 	}{
-		{"cat_work", models.CategoryWork},
-		{"cat_entertainment", models.CategoryEntertainment},
-		{"cat_education", models.CategoryEducation},
-		{"cat_home", models.CategoryHome},
-		{"cat_other", models.CategoryOther},
+		{keyboard[0][0], models.CategoryWork, "💼 Работа"},
+		{keyboard[0][1], "custom_6", "Музыка"},
+		{keyboard[1][0], models.CategoryHome, "🏠 Дом"},
+	}
+	for _, test := range tests {
+		if test.btn.Unique != btnCategory.Unique || models.Category(test.btn.Data) != test.key || test.btn.Text != test.text {
+			t.Errorf("Expected button %s with %s, got %+v", test.text, test.key, test.btn)
+		}
 	}
 
-	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+	if keyboard[2][0].Unique != btnBack.Unique {
+		t.Errorf("Expected the back button last, got %s", keyboard[2][0].Unique)
 	}
+	if btnCategory.Data != "" {
+		t.Error("Category keyboard changed the shared button")
+	}
+}
 
-	for _, test := range tests {
-		t.Run(test.callbackData, func(t *testing.T) {
-			userID := int64(12345)
+func TestTagFilterKeyboard(t *testing.T) {
+	tags := []string{"company", "family", "cancel soon", "work"}
 
-			// Simulate category selection
-			var category models.Category
-			switch test.callbackData {
-			case "cat_entertainment":
-				category = models.CategoryEntertainment
-			case "cat_work":
-				category = models.CategoryWork
-			case "cat_education":
-				category = models.CategoryEducation
-			case "cat_home":
-				category = models.CategoryHome
-			case "cat_other":
-				category = models.CategoryOther
-			}
+	keyboard := newTagFilterKeyboard(btnMySubscriptions, tags, "")
+	if len(keyboard) != 2 || len(keyboard[0]) != 3 || len(keyboard[1]) != 1 {
+		t.Fatalf("Expected three tags per row without the all button, got %v", keyboard)
+	}
+	if btn := keyboard[0][2]; btn.Unique != btnMySubscriptions.Unique || btn.Data != "cancel soon" || btn.Text != "#cancel soon" {
+		t.Errorf("Expected a filter by the tag, got %+v", btn)
+	}
 
-			bot.setData(userID, "category", category)
+	keyboard = newTagFilterKeyboard(btnAnalytics, tags, "family")
+	if keyboard[0][1].Text != "✅ #family" {
+		t.Errorf("Expected the current tag marked, got %q", keyboard[0][1].Text)
+	}
+	if all := keyboard[len(keyboard)-1][0]; all.Unique != btnAnalytics.Unique || all.Data != "" {
+		t.Errorf("Expected the all button last, got %+v", all)
+	}
+	if btnMySubscriptions.Data != "" || btnAnalytics.Data != "" {
+		t.Error("Tag keyboard changed the shared buttons")
+	}
+}
 
-			// Verify data was set correctly
-			storedCategory := bot.getData(userID, "category")
-			if storedCategory != test.expectedCategory {
-				t.Errorf("For callback %s, expected category %s, got %v",
-					test.callbackData, test.expectedCategory, storedCategory)
-			}
-		})
+func TestEscapeMarkdown(t *testing.T) {
+	if got := escapeMarkdown("https://example.com/my_account?a=*b*"); got != `https://example.com/my\_account?a=\*b\*` {
+		t.Errorf("Unexpected escaped text %q", got)
 	}
 }
 
@@ -151,12 +172,14 @@ func TestCurrencyParsing(t *testing.T) {
 		callbackData     string
 		expectedCurrency models.Currency
 	}{
-		{"curr_usd", models.CurrencyUSD},
-		{"curr_rub", models.CurrencyRUB},
+		{"USD", models.CurrencyUSD},
+		{"RUB", models.CurrencyRUB},
+		{"EUR", models.CurrencyEUR},
+		{"KZT", models.CurrencyKZT},
 	}
 
 	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+		states: NewMemoryStateStore(time.Hour),
 	}
 
 	for _, test := range tests {
@@ -164,12 +187,9 @@ func TestCurrencyParsing(t *testing.T) {
 			userID := int64(12345)
 
 			// Simulate currency selection
-			var currency models.Currency
-			switch test.callbackData {
-			case "curr_usd":
-				currency = models.CurrencyUSD
-			case "curr_rub":
-				currency = models.CurrencyRUB
+			currency, ok := models.ParseCurrency(test.callbackData)
+			if !ok {
+				t.Fatalf("Currency %s is not supported", test.callbackData)
 			}
 
 			bot.setData(userID, "currency", currency)
@@ -184,10 +204,37 @@ func TestCurrencyParsing(t *testing.T) {
 	}
 }
 
+// Test that the currency keyboard offers every registered currency
+func TestCurrencyKeyboard(t *testing.T) {
+	keyboard := newCurrencyKeyboard()
+
+	offered := map[string]bool{}
+	for _, row := range keyboard[:len(keyboard)-1] {
+		for _, btn := range row {
+			if btn.Unique != btnCurrency.Unique {
+				t.Errorf("Unexpected button %s in currency keyboard", btn.Unique)
+			}
+			offered[btn.Data] = true
+		}
+	}
+
+	for _, info := range models.Currencies() {
+		if !offered[string(info.Code)] {
+			t.Errorf("Currency %s is missing from the keyboard", info.Code)
+		}
+	}
+
+	// Every call must return fresh buttons with a plain payload
+	keyboard[0][0].Data = "changed"
+	if newCurrencyKeyboard()[0][0].Data != string(models.CurrencyUSD) {
+		t.Error("Currency keyboard buttons are shared between calls")
+	}
+}
+
 // Test complete subscription flow data preservation
 func TestSubscriptionFlowDataPreservation(t *testing.T) {
 	bot := &Bot{
-		userStates: make(map[int64]*UserState),
+		states: NewMemoryStateStore(time.Hour),
 	}
 
 	userID := int64(12345)
@@ -202,7 +249,7 @@ func TestSubscriptionFlowDataPreservation(t *testing.T) {
 	bot.setData(userID, "currency", models.CurrencyUSD)
 
 	// Step 3: Period
-	bot.setData(userID, "period_days", 30)
+	bot.setData(userID, "period", models.Every(1, models.PeriodMonthly))
 
 	// Step 4: Auto renewal
 	bot.setData(userID, "auto_renewal", true)
@@ -224,9 +271,9 @@ func TestSubscriptionFlowDataPreservation(t *testing.T) {
 		t.Errorf("Currency lost: expected %s, got %v", models.CurrencyUSD, currency)
 	}
 
-	periodDays := bot.getData(userID, "period_days")
-	if periodDays != 30 {
-		t.Errorf("Period lost: expected 30, got %v", periodDays)
+	period := bot.getData(userID, "period")
+	if period != models.Every(1, models.PeriodMonthly) {
+		t.Errorf("Period lost: expected monthly, got %v", period)
 	}
 
 	autoRenewal := bot.getData(userID, "auto_renewal")
@@ -244,3 +291,153 @@ func TestSubscriptionFlowDataPreservation(t *testing.T) {
 		t.Errorf("Cost lost: expected %d, got %v", models.Money(1999), cost)
 	}
 }
+
+// Test that selection handlers only switch to edit mode for an edited subscription
+func TestIsEditing(t *testing.T) {
+	bot := &Bot{
+		states: NewMemoryStateStore(time.Hour),
+	}
+	userID := int64(12345)
+
+	bot.resetUserState(userID, StateAddingSubscription)
+	if bot.isEditing(userID) {
+		t.Error("Adding a subscription must not be treated as editing")
+	}
+
+	bot.resetUserState(userID, StateEditingSubscription)
+	if bot.isEditing(userID) {
+		t.Error("Editing without a selected subscription must not be treated as editing")
+	}
+
+	bot.setData(userID, "edit_id", 7)
+	for _, state := range []string{StateEditingSubscription, StateEditingName, StateEditingCost, StateEditingPeriod, StateEditingDate} {
+		bot.setState(userID, state)
+		if !bot.isEditing(userID) {
+			t.Errorf("Expected state %s to be treated as editing", state)
+		}
+	}
+
+	bot.clearUserState(userID)
+	if bot.isEditing(userID) {
+		t.Error("Expected editing to end after the state is cleared")
+	}
+}
+
+func TestDescribePeriod(t *testing.T) {
+	tests := []struct {
+		period   models.BillingPeriod
+		expected string
+	}{
+		{models.Every(1, models.PeriodWeekly), "каждую неделю"},
+		{models.Every(1, models.PeriodMonthly), "каждый месяц"},
+		{models.Every(3, models.PeriodMonthly), "каждые 3 месяца"},
+		{models.Every(14, models.PeriodDaily), "каждые 14 дней"},
+		{models.Every(21, models.PeriodDaily), "каждый 21 день"},
+		{models.Every(2, models.PeriodQuarterly), "каждые 2 квартала"},
+		{models.Every(5, models.PeriodYearly), "каждые 5 лет"},
+	}
+
+	for _, tt := range tests {
+		if got := describePeriod(tt.period); got != tt.expected {
+			t.Errorf("describePeriod(%+v) = %q, expected %q", tt.period, got, tt.expected)
+		}
+	}
+}
+
+func TestParseTrialInput(t *testing.T) {
+	endsAt, cost, err := parseTrialInput("31.12.2025 1.99", models.CurrencyUSD)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !endsAt.Equal(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)) || cost != models.NewMoney(199) {
+		t.Errorf("Unexpected trial: %v %v", endsAt, cost)
+	}
+
+	if _, cost, err := parseTrialInput("31.12.2025", models.CurrencyUSD); err != nil || !cost.IsZero() {
+		t.Errorf("Expected a free trial, got %v %v", cost, err)
+	}
+
+	for _, input := range []string{"", "31.12", "31.12.2025 abc", "31.12.2025 1 2"} {
+		if _, _, err := parseTrialInput(input, models.CurrencyUSD); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseBudgetInput(t *testing.T) {
+	limit, currency, category, err := parseBudgetInput("49,99 usd Развлечения и хобби")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if limit != models.NewMoney(4999) || currency != models.CurrencyUSD || category != "Развлечения и хобби" {
+		t.Errorf("Unexpected budget: %v %v %q", limit, currency, category)
+	}
+
+	if _, _, category, err := parseBudgetInput("30000 RUB"); err != nil || category != "" {
+		t.Errorf("Expected an overall budget, got %q (%v)", category, err)
+	}
+
+	for _, input := range []string{"", "30000", "30000 XXX", "0 RUB", "abc RUB"} {
+		if _, _, _, err := parseBudgetInput(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParsePaymentDate(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	today, err := parsePaymentDate(" - ", now)
+	if err != nil || !today.Equal(now) {
+		t.Errorf("Expected «-» to mean now, got %v (%v)", today, err)
+	}
+
+	date, err := parsePaymentDate("05.02.2025", now)
+	if err != nil || !date.Equal(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date: %v (%v)", date, err)
+	}
+
+	if _, err := parsePaymentDate("2025-02-05", now); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+	if note := parsePaymentNote(" - "); note != "" {
+		t.Errorf("Expected «-» to mean no note, got %q", note)
+	}
+}
+
+func TestFormatPaymentEscapesNote(t *testing.T) {
+	payment := &models.Payment{
+		Amount:   models.NewMoney(999),
+		Currency: models.CurrencyUSD,
+		Status:   models.PaymentStatusCompleted,
+		PaidAt:   time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Note:     "скидка_50%",
+	}
+
+	if text := formatPayment(payment); !strings.HasSuffix(text, `💬 Заметка: скидка\_50%`) {
+		t.Errorf("Expected the note to be escaped, got %q", text)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	from, to, err := parseDateRange("01.01.2025 31.03.2025")
+	if err != nil || !from.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected range: %v - %v (%v)", from, to, err)
+	}
+
+	from, to, err = parseDateRange("15.02.2025")
+	if err != nil || from.IsZero() || !to.IsZero() {
+		t.Errorf("Expected an open range, got %v - %v (%v)", from, to, err)
+	}
+
+	from, to, err = parseDateRange(" - ")
+	if err != nil || !from.IsZero() || !to.IsZero() {
+		t.Errorf("Expected «-» to remove the range, got %v - %v (%v)", from, to, err)
+	}
+
+	for _, invalid := range []string{"", "31.03.2025 01.01.2025", "01.01.2025 02.01.2025 03.01.2025", "2025-01-01"} {
+		if _, _, err := parseDateRange(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
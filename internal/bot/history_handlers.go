@@ -0,0 +1,368 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const historyPageSize = 10
+
+// History filter fields as passed in button payloads
+const (
+	historyFieldSubscription = "sub"
+	historyFieldCategory     = "cat"
+	historyFieldCurrency     = "cur"
+	historyFieldStatus       = "status"
+)
+
+const historyDatesPrompt = "🗓️ Введите период в формате ДД.ММ.ГГГГ ДД.ММ.ГГГГ, одну дату, чтобы показать платежи начиная с нее, или «-», чтобы убрать фильтр:"
+
+// Directions of btnHistoryPage
+const (
+	historyOlder = "older"
+	historyNewer = "newer"
+)
+
+// handleHistory shows the newest payments matching the history filters
+func (b *Bot) handleHistory(c telebot.Context) error {
+	b.openHistory(c.Sender().ID)
+	return b.showHistoryPage(c, nil, false)
+}
+
+func (b *Bot) handleHistoryPage(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	direction, rawCursor, _ := strings.Cut(c.Data(), ":")
+	cursor, err := models.ParsePaymentCursor(rawCursor)
+	if err != nil || (direction != historyOlder && direction != historyNewer) {
+		return c.Send("❌ Некорректная страница истории")
+	}
+
+	b.openHistory(userID)
+	return b.showHistoryPage(c, &cursor, direction == historyNewer)
+}
+
+// openHistory moves the user to the history. Filters survive only while the
+// user stays in the history or edits its payments.
+func (b *Bot) openHistory(userID int64) {
+	switch b.getUserState(userID).State {
+	case StateBrowsingHistory, StateFilteringHistoryDates,
+		StateEditingPayment, StateEditingPaymentAmount, StateEditingPaymentDate, StateEditingPaymentNote:
+		b.setState(userID, StateBrowsingHistory)
+	default:
+		b.resetUserState(userID, StateBrowsingHistory)
+	}
+}
+
+func (b *Bot) showHistoryPage(c telebot.Context, cursor *models.PaymentCursor, newer bool) error {
+	userID := ownerOf(c)
+	filter := b.historyFilter(c.Sender().ID)
+
+	page, err := b.analyticsService.GetPaymentPage(context.Background(), userID, models.PaymentPageRequest{
+		Filter: filter,
+		Cursor: cursor,
+		Newer:  newer,
+		Limit:  historyPageSize,
+	})
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения истории: %v", err))
+	}
+
+	text := "📜 *История платежей*\n\n"
+	if !filter.IsEmpty() {
+		text += "🔍 " + b.describeHistoryFilter(userID, filter) + "\n\n"
+	}
+
+	// Every payment gets a button to open it for editing
+	var keyboard [][]telebot.InlineButton
+	if len(page.Payments) == 0 {
+		text += "Платежей не найдено"
+	}
+	for _, payment := range page.Payments {
+		text += fmt.Sprintf("%s %s - %s\n",
+			paymentStatusIcon(payment.Status), payment.Currency.Format(payment.Amount), payment.PaidAt.Format("02.01.2006 15:04"))
+		if payment.Note != "" {
+			text += "    💬 " + escapeMarkdown(payment.Note) + "\n"
+		}
+
+		btn := btnPaymentInfo
+		btn.Text = fmt.Sprintf("%s %s, %s", paymentStatusIcon(payment.Status),
+			payment.Currency.Format(payment.Amount), payment.PaidAt.Format("02.01.2006"))
+		btn.Data = strconv.Itoa(payment.ID)
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	var navigation []telebot.InlineButton
+	if page.Newer != nil {
+		btn := btnHistoryPage
+		btn.Text = "◀️ Новее"
+		btn.Data = historyNewer + ":" + page.Newer.String()
+		navigation = append(navigation, btn)
+	}
+	if page.Older != nil {
+		btn := btnHistoryPage
+		btn.Text = "Старее ▶️"
+		btn.Data = historyOlder + ":" + page.Older.String()
+		navigation = append(navigation, btn)
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: append(keyboard, []telebot.InlineButton{btnHistoryFilters}, []telebot.InlineButton{btnBack}),
+	}
+
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleHistoryFilters(c telebot.Context) error {
+	b.openHistory(c.Sender().ID)
+	return b.showHistoryFilters(c)
+}
+
+func (b *Bot) showHistoryFilters(c telebot.Context) error {
+	text := "🔍 *Фильтры истории*\n\n"
+	if filter := b.historyFilter(c.Sender().ID); filter.IsEmpty() {
+		text += "Показываются все платежи"
+	} else {
+		text += b.describeHistoryFilter(ownerOf(c), filter)
+	}
+
+	markup := &telebot.ReplyMarkup{InlineKeyboard: newHistoryFiltersKeyboard()}
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+// handleHistoryFilterField offers the values of the chosen filter
+func (b *Bot) handleHistoryFilterField(c telebot.Context) error {
+	userID := ownerOf(c)
+	b.openHistory(c.Sender().ID)
+
+	field := c.Data()
+	var labels, values []string
+	var text string
+
+	switch field {
+	case historyFieldSubscription:
+		subscriptions, err := b.subscriptionService.GetAllActiveSubscriptions(context.Background(), userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка получения подписок: %v", err))
+		}
+		for _, sub := range subscriptions {
+			labels = append(labels, sub.Name)
+			values = append(values, strconv.Itoa(sub.ID))
+		}
+		text = "📝 Выберите подписку:"
+	case historyFieldCategory:
+		// Archived categories are offered too, their payments stay in the history
+		categories, err := b.categoryService.GetCategories(context.Background(), userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка получения категорий: %v", err))
+		}
+		for _, category := range categories {
+			labels = append(labels, category.Label())
+			values = append(values, string(category.Key))
+		}
+		text = "📂 Выберите категорию:"
+	case historyFieldCurrency:
+		for _, info := range models.Currencies() {
+			labels = append(labels, currencyLabel(info.Code))
+			values = append(values, string(info.Code))
+		}
+		text = "💱 Выберите валюту:"
+	case historyFieldStatus:
+		for _, status := range []models.PaymentStatus{
+			models.PaymentStatusCompleted, models.PaymentStatusPending, models.PaymentStatusFailed, models.PaymentStatusVoided,
+		} {
+			labels = append(labels, paymentStatusLabel(status))
+			values = append(values, string(status))
+		}
+		text = "📌 Выберите статус:"
+	default:
+		return c.Send("❌ Неизвестный фильтр")
+	}
+
+	return c.Edit(text, &telebot.ReplyMarkup{
+		InlineKeyboard: newHistoryValuesKeyboard(field, labels, values),
+	})
+}
+
+// handleHistoryFilterValue sets or, with an empty value, removes a filter
+func (b *Bot) handleHistoryFilterValue(c telebot.Context) error {
+	userID := c.Sender().ID
+	b.openHistory(userID)
+
+	field, value, _ := strings.Cut(c.Data(), ":")
+	filter := b.historyFilter(userID)
+
+	switch field {
+	case historyFieldSubscription:
+		id := 0
+		if value != "" {
+			var err error
+			if id, err = strconv.Atoi(value); err != nil {
+				return c.Send("❌ Некорректный ID подписки")
+			}
+		}
+		filter.SubscriptionID = id
+	case historyFieldCategory:
+		category := models.Category(value)
+		if value != "" {
+			if _, err := b.categoryService.GetCategory(context.Background(), ownerOf(c), category); err != nil {
+				return c.Send("❌ Неизвестная категория")
+			}
+		}
+		filter.Category = category
+	case historyFieldCurrency:
+		currency := models.Currency("")
+		if value != "" {
+			var ok bool
+			if currency, ok = models.ParseCurrency(value); !ok {
+				return c.Send("❌ Неподдерживаемая валюта")
+			}
+		}
+		filter.Currency = currency
+	case historyFieldStatus:
+		status := models.PaymentStatus(value)
+		if value != "" && !status.IsValid() {
+			return c.Send("❌ Неизвестный статус платежа")
+		}
+		filter.Status = status
+	default:
+		return c.Send("❌ Неизвестный фильтр")
+	}
+
+	b.setHistoryFilter(userID, filter)
+	return b.showHistoryFilters(c)
+}
+
+func (b *Bot) handleHistoryDates(c telebot.Context) error {
+	userID := c.Sender().ID
+	b.openHistory(userID)
+	b.setState(userID, StateFilteringHistoryDates)
+
+	return c.Edit(historyDatesPrompt, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{btnHistoryFilters}},
+	})
+}
+
+func (b *Bot) handleHistoryDatesInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	from, to, err := parseDateRange(c.Text())
+	if err != nil {
+		return c.Send("❌ Некорректный период. " + historyDatesPrompt)
+	}
+
+	filter := b.historyFilter(userID)
+	filter.From, filter.To = from, to
+	b.setHistoryFilter(userID, filter)
+	b.setState(userID, StateBrowsingHistory)
+
+	return b.showHistoryFilters(c)
+}
+
+func (b *Bot) handleHistoryReset(c telebot.Context) error {
+	userID := c.Sender().ID
+	b.resetUserState(userID, StateBrowsingHistory)
+	return b.showHistoryFilters(c)
+}
+
+// historyFilter reads the history filters kept in the user state
+func (b *Bot) historyFilter(userID int64) models.PaymentFilter {
+	data := b.getUserState(userID).Data
+
+	var filter models.PaymentFilter
+	filter.SubscriptionID, _ = data["history_sub"].(int)
+	filter.Category, _ = data["history_category"].(models.Category)
+	filter.Currency, _ = data["history_currency"].(models.Currency)
+	filter.Status, _ = data["history_status"].(models.PaymentStatus)
+	filter.From, _ = data["history_from"].(time.Time)
+	filter.To, _ = data["history_to"].(time.Time)
+	return filter
+}
+
+func (b *Bot) setHistoryFilter(userID int64, filter models.PaymentFilter) {
+	b.updateUserState(userID, func(s *models.UserState) {
+		s.Data["history_sub"] = filter.SubscriptionID
+		s.Data["history_category"] = filter.Category
+		s.Data["history_currency"] = filter.Currency
+		s.Data["history_status"] = filter.Status
+		s.Data["history_from"] = filter.From
+		s.Data["history_to"] = filter.To
+	})
+}
+
+func (b *Bot) describeHistoryFilter(userID int64, filter models.PaymentFilter) string {
+	var parts []string
+	if filter.SubscriptionID != 0 {
+		name := "—"
+		if sub, err := b.subscriptionService.GetSubscriptionByID(context.Background(), userID, filter.SubscriptionID); err == nil {
+			name = sub.Name
+		}
+		parts = append(parts, "📝 "+name)
+	}
+	if filter.Category != "" {
+		parts = append(parts, b.categoryLabel(userID, filter.Category))
+	}
+	if filter.Currency != "" {
+		parts = append(parts, currencyLabel(filter.Currency))
+	}
+	if filter.Status != "" {
+		parts = append(parts, paymentStatusLabel(filter.Status))
+	}
+	if dates := describeDateRange(filter.From, filter.To); dates != "" {
+		parts = append(parts, "🗓️ "+dates)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeDateRange(from, to time.Time) string {
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		return from.Format("02.01.2006") + " – " + to.Format("02.01.2006")
+	case !from.IsZero():
+		return "с " + from.Format("02.01.2006")
+	case !to.IsZero():
+		return "по " + to.Format("02.01.2006")
+	}
+	return ""
+}
+
+// parseDateRange reads "01.01.2025 31.03.2025", a single start date or "-" for no range
+func parseDateRange(input string) (from, to time.Time, err error) {
+	fields := strings.Fields(input)
+	if len(fields) == 1 && fields[0] == "-" {
+		return time.Time{}, time.Time{}, nil
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected one or two dates")
+	}
+
+	from, err = time.Parse("02.01.2006", fields[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if len(fields) == 2 {
+		to, err = time.Parse("02.01.2006", fields[1])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if to.Before(from) {
+			return time.Time{}, time.Time{}, fmt.Errorf("range ends before it starts")
+		}
+	}
+	return from, to, nil
+}
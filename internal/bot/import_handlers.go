@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sub-cos-counter/internal/importer"
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxImportFileSize limits uploaded files, a few hundred subscriptions take far less
+const maxImportFileSize = 1 << 20
+
+// maxReportErrors limits the skipped rows listed in one message
+const maxReportErrors = 20
+
+const importPrompt = "📥 *Импорт подписок*\n\n" +
+	"Отправьте CSV или JSON файл. Обязательные колонки: `name`, `cost`, `currency`, `period`, `next_payment`; " +
+	"необязательные: `category` (название вашей категории), `auto_renewal`, `trial_ends_at`, `trial_cost`.\n\n" +
+	"Подходит файл `subscriptions.csv` из /export. Пример строки:\n" +
+	"`Netflix,15.99,USD,1 month,2025-03-10`"
+
+// handleImportCommand asks for a file with subscriptions
+func (b *Bot) handleImportCommand(c telebot.Context) error {
+	b.resetUserState(c.Sender().ID, StateImporting)
+
+	return c.Send(importPrompt, &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+// handleDocument routes uploaded files by the conversation state
+func (b *Bot) handleDocument(c telebot.Context) error {
+	switch b.getUserState(c.Sender().ID).State {
+	case StateImporting:
+		return b.handleImportDocument(c)
+	case StateRestoring:
+		return b.handleRestoreDocument(c)
+	}
+
+	return c.Send("📎 Чтобы загрузить подписки из файла, используйте /import")
+}
+
+func (b *Bot) handleImportDocument(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	doc := c.Message().Document
+	if doc.FileSize > maxImportFileSize {
+		return c.Send("❌ Файл слишком большой, максимум 1 МБ")
+	}
+
+	var read func(io.Reader) ([]models.ImportRow, error)
+	switch strings.ToLower(filepath.Ext(doc.FileName)) {
+	case ".csv":
+		read = importer.ReadCSV
+	case ".json":
+		read = importer.ReadJSON
+	default:
+		return c.Send("❌ Поддерживаются только файлы .csv и .json")
+	}
+
+	file, err := b.bot.File(&doc.File)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка загрузки файла: %v", err))
+	}
+	defer file.Close()
+
+	rows, err := read(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Не удалось прочитать файл: %v", err))
+	}
+
+	report, err := b.subscriptionService.ImportSubscriptions(context.Background(), userID, rows)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка импорта, ни одна подписка не добавлена: %v", err))
+	}
+
+	b.clearUserState(userID)
+
+	return c.Send(formatImportReport(report), &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnMySubscriptions},
+			{btnBack},
+		},
+	})
+}
+
+// formatImportReport lists created subscriptions and explains skipped rows
+func formatImportReport(report *models.ImportReport) string {
+	text := fmt.Sprintf("📥 Импорт завершен\n\n✅ Добавлено подписок: %d\n", len(report.Created))
+	if len(report.Failed) == 0 {
+		return text
+	}
+
+	text += fmt.Sprintf("❌ Пропущено строк: %d\n\n", len(report.Failed))
+	for i, failed := range report.Failed {
+		if i == maxReportErrors {
+			text += fmt.Sprintf("… и еще %d", len(report.Failed)-maxReportErrors)
+			break
+		}
+
+		name := ""
+		if failed.Name != "" {
+			name = " (" + failed.Name + ")"
+		}
+		text += fmt.Sprintf("• Строка %d%s: %s\n", failed.Line, name, failed.Reason)
+	}
+	return text
+}
@@ -1,6 +1,10 @@
 package bot
 
-import "gopkg.in/telebot.v3"
+import (
+	"sub-cos-counter/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
 
 // Main menu buttons
 var (
@@ -10,29 +14,42 @@ var (
 	btnAnalytics       = telebot.InlineButton{Unique: "analytics", Text: "📊 Аналитика"}
 	btnHistory         = telebot.InlineButton{Unique: "history", Text: "📜 История платежей"}
 	btnSettings        = telebot.InlineButton{Unique: "settings", Text: "⚙️ Настройки"}
+	btnTrials          = telebot.InlineButton{Unique: "trials", Text: "🧪 Пробные периоды"}
+	btnBudgets         = telebot.InlineButton{Unique: "budgets", Text: "🎯 Бюджеты"}
 )
 
-// Category buttons
+// Category button, the category key is passed as payload
+var btnCategory = telebot.InlineButton{Unique: "category"}
+
+// Category management buttons. The payload of btnManageCategory and of the
+// buttons changing a category is the category key.
 var (
-	btnCategoryEntertainment = telebot.InlineButton{Unique: "cat_entertainment", Text: "🎮 Развлечения"}
-	btnCategoryWork          = telebot.InlineButton{Unique: "cat_work", Text: "💼 Работа"}
-	btnCategoryEducation     = telebot.InlineButton{Unique: "cat_education", Text: "📚 Обучение"}
-	btnCategoryHome          = telebot.InlineButton{Unique: "cat_home", Text: "🏠 Дом"}
-	btnCategoryOther         = telebot.InlineButton{Unique: "cat_other", Text: "📦 Другое"}
+	btnCategories        = telebot.InlineButton{Unique: "categories", Text: "📂 Категории"}
+	btnNewCategory       = telebot.InlineButton{Unique: "category_new", Text: "➕ Новая категория"}
+	btnManageCategory    = telebot.InlineButton{Unique: "category_manage"}
+	btnRenameCategory    = telebot.InlineButton{Unique: "category_rename", Text: "📝 Переименовать"}
+	btnSetCategoryEmoji  = telebot.InlineButton{Unique: "category_emoji", Text: "😀 Эмодзи"}
+	btnArchiveCategory   = telebot.InlineButton{Unique: "category_archive", Text: "🗄️ В архив"}
+	btnUnarchiveCategory = telebot.InlineButton{Unique: "category_unarchive", Text: "♻️ Вернуть из архива"}
 )
 
-// Currency buttons
+// Budget buttons, the payload of btnDeleteBudget is the currency and category
+// of the budget, see budgetPayload
 var (
-	btnCurrencyUSD = telebot.InlineButton{Unique: "curr_usd", Text: "💵 USD"}
-	btnCurrencyRUB = telebot.InlineButton{Unique: "curr_rub", Text: "🔹 RUB"}
+	btnNewBudget    = telebot.InlineButton{Unique: "budget_new", Text: "➕ Задать бюджет"}
+	btnDeleteBudget = telebot.InlineButton{Unique: "budget_delete"}
 )
 
+// Currency button, the currency code is passed as payload
+var btnCurrency = telebot.InlineButton{Unique: "currency"}
+
 // Period buttons
 var (
-	btnPeriodWeek   = telebot.InlineButton{Unique: "period_week", Text: "🗓️ Неделя"}
-	btnPeriodMonth  = telebot.InlineButton{Unique: "period_month", Text: "📅 Месяц"}
-	btnPeriodYear   = telebot.InlineButton{Unique: "period_year", Text: "📆 Год"}
-	btnPeriodCustom = telebot.InlineButton{Unique: "period_custom", Text: "⚡ Другое"}
+	btnPeriodWeek    = telebot.InlineButton{Unique: "period_week", Text: "🗓️ Неделя"}
+	btnPeriodMonth   = telebot.InlineButton{Unique: "period_month", Text: "📅 Месяц"}
+	btnPeriodQuarter = telebot.InlineButton{Unique: "period_quarter", Text: "🗂️ Квартал"}
+	btnPeriodYear    = telebot.InlineButton{Unique: "period_year", Text: "📆 Год"}
+	btnPeriodCustom  = telebot.InlineButton{Unique: "period_custom", Text: "⚡ Другое"}
 )
 
 // Auto renewal buttons
@@ -41,6 +58,70 @@ var (
 	btnAutoRenewalNo  = telebot.InlineButton{Unique: "auto_no", Text: "❌ Нет"}
 )
 
+// Settings buttons
+var btnBaseCurrency = telebot.InlineButton{Unique: "base_currency", Text: "💱 Базовая валюта"}
+
+// Subscription details button, the subscription ID is passed as payload
+var btnSubscriptionInfo = telebot.InlineButton{Unique: "sub_info", Text: "ℹ️ Подробнее"}
+
+// Pay button, the payload carries the subscription ID and the payment date being paid
+var btnPaySubscription = telebot.InlineButton{Unique: "pay", Text: "✅ Оплатить"}
+
+// Custom payment buttons. The payload of btnRecordPayment is a subscription ID,
+// of btnPaymentInfo a payment ID and of btnPaymentStatus a payment status.
+var (
+	btnRecordPayment     = telebot.InlineButton{Unique: "record_payment", Text: "🧾 Записать платеж"}
+	btnPaymentStatus     = telebot.InlineButton{Unique: "payment_status"}
+	btnSettleCycleYes    = telebot.InlineButton{Unique: "settle_yes", Text: "✅ Да"}
+	btnSettleCycleNo     = telebot.InlineButton{Unique: "settle_no", Text: "❌ Нет"}
+	btnPaymentInfo       = telebot.InlineButton{Unique: "payment"}
+	btnEditPaymentAmount = telebot.InlineButton{Unique: "payment_amount", Text: "💰 Сумма"}
+	btnEditPaymentDate   = telebot.InlineButton{Unique: "payment_date", Text: "🗓️ Дата"}
+	btnEditPaymentStatus = telebot.InlineButton{Unique: "payment_edit_status", Text: "📌 Статус"}
+	btnEditPaymentNote   = telebot.InlineButton{Unique: "payment_note", Text: "📝 Заметка"}
+	btnVoidPayment       = telebot.InlineButton{Unique: "void_payment", Text: "🚫 Аннулировать"}
+)
+
+// History buttons. The payload of btnHistoryPage is a direction with a cursor,
+// of btnHistoryFilterField a filter field and of btnHistoryFilterValue a field
+// with its value, like "cur:USD". An empty value removes the filter.
+var (
+	btnHistoryPage        = telebot.InlineButton{Unique: "history_page"}
+	btnHistoryFilters     = telebot.InlineButton{Unique: "history_filters", Text: "🔍 Фильтры"}
+	btnHistoryFilterField = telebot.InlineButton{Unique: "history_field"}
+	btnHistoryFilterValue = telebot.InlineButton{Unique: "history_set"}
+	btnHistoryDates       = telebot.InlineButton{Unique: "history_dates", Text: "🗓️ Даты"}
+	btnHistoryReset       = telebot.InlineButton{Unique: "history_reset", Text: "🧹 Сбросить"}
+)
+
+// Edit buttons, the subscription being edited is kept in the user state
+var (
+	btnEditSubscription = telebot.InlineButton{Unique: "edit_sub", Text: "✏️ Изменить"}
+	btnEditName         = telebot.InlineButton{Unique: "edit_name", Text: "📝 Название"}
+	btnEditCost         = telebot.InlineButton{Unique: "edit_cost", Text: "💰 Стоимость"}
+	btnEditCurrency     = telebot.InlineButton{Unique: "edit_currency", Text: "💱 Валюта"}
+	btnEditPeriod       = telebot.InlineButton{Unique: "edit_period", Text: "📅 Период"}
+	btnEditDate         = telebot.InlineButton{Unique: "edit_date", Text: "🗓️ Дата платежа"}
+	btnEditCategory     = telebot.InlineButton{Unique: "edit_category", Text: "📂 Категория"}
+	btnEditAutoRenewal  = telebot.InlineButton{Unique: "edit_auto", Text: "🔄 Автопродление"}
+	btnEditTrial        = telebot.InlineButton{Unique: "edit_trial", Text: "🧪 Пробный период"}
+	btnEditTags         = telebot.InlineButton{Unique: "edit_tags", Text: "🏷️ Теги"}
+	btnEditNotes        = telebot.InlineButton{Unique: "edit_notes", Text: "🗒️ Заметка"}
+	btnEditURL          = telebot.InlineButton{Unique: "edit_url", Text: "🔗 Ссылка"}
+)
+
+// Trial buttons, the payload carries the subscription ID
+var (
+	btnSetTrial    = telebot.InlineButton{Unique: "set_trial", Text: "🧪 Это пробный период"}
+	btnCancelTrial = telebot.InlineButton{Unique: "cancel_trial", Text: "🚫 Отменить подписку"}
+)
+
+// Reminder buttons, the payload carries the subscription ID and payment date
+var (
+	btnReminderPaid   = telebot.InlineButton{Unique: "remind_paid", Text: "✅ Оплачено"}
+	btnReminderSnooze = telebot.InlineButton{Unique: "remind_snooze", Text: "⏰ Напомнить позже"}
+)
+
 // Navigation buttons
 var (
 	btnBack = telebot.InlineButton{Unique: "back", Text: "⬅️ Назад"}
@@ -51,23 +132,107 @@ var mainMenuKeyboard = [][]telebot.InlineButton{
 	{btnAddSubscription, btnMySubscriptions},
 	{btnMonthlyExpense, btnAnalytics},
 	{btnHistory, btnSettings},
+	{btnTrials, btnBudgets},
 }
 
-var categoryKeyboard = [][]telebot.InlineButton{
-	{btnCategoryEntertainment, btnCategoryWork},
-	{btnCategoryEducation, btnCategoryHome},
-	{btnCategoryOther},
-	{btnBack},
+// newCategoryKeyboard offers the categories, two per row. It is built on each
+// call because the categories belong to the user.
+func newCategoryKeyboard(categories []*models.UserCategory) [][]telebot.InlineButton {
+	const perRow = 2
+
+	var keyboard [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, category := range categories {
+		btn := btnCategory
+		btn.Text = category.Label()
+		btn.Data = string(category.Key)
+
+		row = append(row, btn)
+		if len(row) == perRow {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard, []telebot.InlineButton{btnBack})
 }
 
-var currencyKeyboard = [][]telebot.InlineButton{
-	{btnCurrencyUSD, btnCurrencyRUB},
-	{btnBack},
+// newCategoriesKeyboard lists every category of the user for management,
+// archived ones marked
+func newCategoriesKeyboard(categories []*models.UserCategory) [][]telebot.InlineButton {
+	var keyboard [][]telebot.InlineButton
+	for _, category := range categories {
+		btn := btnManageCategory
+		btn.Text = category.Label()
+		if category.Archived {
+			btn.Text = "🗄️ " + category.Name
+		}
+		btn.Data = string(category.Key)
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	return append(keyboard,
+		[]telebot.InlineButton{btnNewCategory},
+		[]telebot.InlineButton{btnSettings, btnBack},
+	)
+}
+
+// newCategoryActionsKeyboard offers the changes of one category
+func newCategoryActionsKeyboard(category *models.UserCategory) [][]telebot.InlineButton {
+	button := func(btn telebot.InlineButton) telebot.InlineButton {
+		btn.Data = string(category.Key)
+		return btn
+	}
+
+	archive := button(btnArchiveCategory)
+	if category.Archived {
+		archive = button(btnUnarchiveCategory)
+	}
+
+	return [][]telebot.InlineButton{
+		{button(btnRenameCategory), button(btnSetCategoryEmoji)},
+		{archive},
+		{btnCategories, btnBack},
+	}
+}
+
+// newCurrencyKeyboard lists every supported currency. It is built on each call
+// because telebot rewrites the payload of sent buttons in place.
+func newCurrencyKeyboard() [][]telebot.InlineButton {
+	const perRow = 3
+
+	var keyboard [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, info := range models.Currencies() {
+		btn := btnCurrency
+		btn.Text = currencyLabel(info.Code)
+		btn.Data = string(info.Code)
+
+		row = append(row, btn)
+		if len(row) == perRow {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard, []telebot.InlineButton{btnBack})
+}
+
+// currencyLabel returns the currency sign with its code, like "€ EUR"
+func currencyLabel(currency models.Currency) string {
+	return currency.Symbol() + " " + string(currency)
 }
 
 var periodKeyboard = [][]telebot.InlineButton{
 	{btnPeriodWeek, btnPeriodMonth},
-	{btnPeriodYear, btnPeriodCustom},
+	{btnPeriodQuarter, btnPeriodYear},
+	{btnPeriodCustom},
 	{btnBack},
 }
 
@@ -76,6 +241,133 @@ var autoRenewalKeyboard = [][]telebot.InlineButton{
 	{btnBack},
 }
 
+var settleCycleKeyboard = [][]telebot.InlineButton{
+	{btnSettleCycleYes, btnSettleCycleNo},
+	{btnBack},
+}
+
+var paymentEditKeyboard = [][]telebot.InlineButton{
+	{btnEditPaymentAmount, btnEditPaymentDate},
+	{btnEditPaymentStatus, btnEditPaymentNote},
+	{btnVoidPayment},
+	{btnHistory, btnBack},
+}
+
+// newPaymentStatusKeyboard is built on every call because the buttons carry payloads
+func newPaymentStatusKeyboard() [][]telebot.InlineButton {
+	var row []telebot.InlineButton
+	for _, status := range recordableStatuses {
+		btn := btnPaymentStatus
+		btn.Text = paymentStatusLabel(status)
+		btn.Data = string(status)
+		row = append(row, btn)
+	}
+	return [][]telebot.InlineButton{row, {btnBack}}
+}
+
+// recordableStatuses can be chosen for a payment, voiding has its own button
+var recordableStatuses = []models.PaymentStatus{
+	models.PaymentStatusCompleted, models.PaymentStatusPending, models.PaymentStatusFailed,
+}
+
+// newHistoryFiltersKeyboard is built on every call because the buttons carry payloads
+func newHistoryFiltersKeyboard() [][]telebot.InlineButton {
+	field := func(text, name string) telebot.InlineButton {
+		btn := btnHistoryFilterField
+		btn.Text = text
+		btn.Data = name
+		return btn
+	}
+
+	show := btnHistory
+	show.Text = "📜 Показать"
+
+	return [][]telebot.InlineButton{
+		{field("📝 Подписка", historyFieldSubscription), field("📂 Категория", historyFieldCategory)},
+		{field("💱 Валюта", historyFieldCurrency), field("📌 Статус", historyFieldStatus)},
+		{btnHistoryDates, btnHistoryReset},
+		{show},
+		{btnBack},
+	}
+}
+
+// newHistoryValuesKeyboard offers the values of a history filter, three per row,
+// and a button to remove the filter
+func newHistoryValuesKeyboard(field string, labels, values []string) [][]telebot.InlineButton {
+	const perRow = 3
+
+	button := func(text, value string) telebot.InlineButton {
+		btn := btnHistoryFilterValue
+		btn.Text = text
+		btn.Data = field + ":" + value
+		return btn
+	}
+
+	var keyboard [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for i, value := range values {
+		row = append(row, button(labels[i], value))
+		if len(row) == perRow {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard,
+		[]telebot.InlineButton{button("🌐 Все", "")},
+		[]telebot.InlineButton{btnHistoryFilters},
+	)
+}
+
+var editKeyboard = [][]telebot.InlineButton{
+	{btnEditName, btnEditCost},
+	{btnEditCurrency, btnEditPeriod},
+	{btnEditDate, btnEditCategory},
+	{btnEditAutoRenewal, btnEditTrial},
+	{btnEditTags, btnEditNotes},
+	{btnEditURL},
+	{btnMySubscriptions, btnBack},
+}
+
+// newTagFilterKeyboard offers the tags of the user as filters of the screen
+// of btn, three per row. The payload of a button is its tag, the "all" button
+// shown while a filter is set has none.
+func newTagFilterKeyboard(btn telebot.InlineButton, tags []string, current string) [][]telebot.InlineButton {
+	const perRow = 3
+
+	button := func(text, tag string) telebot.InlineButton {
+		b := btn
+		b.Text = text
+		b.Data = tag
+		return b
+	}
+
+	var keyboard [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, tag := range tags {
+		text := "#" + tag
+		if tag == current {
+			text = "✅ " + text
+		}
+		row = append(row, button(text, tag))
+		if len(row) == perRow {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+	if current != "" {
+		keyboard = append(keyboard, []telebot.InlineButton{button("🌐 Все", "")})
+	}
+
+	return keyboard
+}
+
 var backKeyboard = [][]telebot.InlineButton{
 	{btnBack},
 }
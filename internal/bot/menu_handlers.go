@@ -2,21 +2,46 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/services"
+	"time"
 
 	"gopkg.in/telebot.v3"
 )
 
+// handleMySubscriptions lists the active subscriptions, only those with the
+// tag passed in the button payload when there is one
 func (b *Bot) handleMySubscriptions(c telebot.Context) error {
+	userID := ownerOf(c)
 	ctx := context.Background()
-	subscriptions, err := b.subscriptionService.GetAllActiveSubscriptions(ctx)
+	tag := c.Data()
+
+	tags, err := b.subscriptionService.GetTags(ctx, userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения подписок: %v", err))
+	}
+
+	var subscriptions []*models.Subscription
+	if tag == "" {
+		subscriptions, err = b.subscriptionService.GetAllActiveSubscriptions(ctx, userID)
+	} else {
+		subscriptions, err = b.subscriptionService.GetSubscriptionsByTag(ctx, userID, tag)
+	}
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка получения подписок: %v", err))
 	}
 
+	if len(subscriptions) == 0 && tag != "" {
+		keyboard := newTagFilterKeyboard(btnMySubscriptions, tags, tag)
+		return c.Edit("📋 *Мои подписки*\n\nНет активных подписок с тегом "+formatTags([]string{tag})+".", &telebot.ReplyMarkup{
+			InlineKeyboard: append(keyboard, []telebot.InlineButton{btnBack}),
+		}, telebot.ModeMarkdown)
+	}
+
 	if len(subscriptions) == 0 {
 		return c.Edit("📋 *Мои подписки*\n\nУ вас пока нет активных подписок.", &telebot.ReplyMarkup{
 			InlineKeyboard: [][]telebot.InlineButton{
@@ -27,40 +52,50 @@ func (b *Bot) handleMySubscriptions(c telebot.Context) error {
 	}
 
 	text := "📋 *Мои подписки*\n\n"
+	if tag != "" {
+		text = "📋 *Мои подписки* " + formatTags([]string{tag}) + "\n\n"
+	}
 	keyboard := [][]telebot.InlineButton{}
 
 	for _, sub := range subscriptions {
-		currencySymbol := "$"
-		if sub.Currency == models.CurrencyRUB {
-			currencySymbol = "₽"
-		}
 
 		status := ""
-		if sub.IsPaymentDue() {
+		if sub.IsTrial(time.Now()) {
+			status = " 🧪"
+		} else if sub.IsPaymentDue() {
 			status = " ⚠️"
 		}
 
-		text += fmt.Sprintf("• %s - %s%s%s\n  📅 Следующий платеж: %s\n\n",
-			sub.Name, sub.Cost.String(), currencySymbol, status, sub.NextPayment.Format("02.01.2006"))
+		text += fmt.Sprintf("• %s - %s%s\n  📅 Следующий платеж: %s\n",
+			sub.Name, sub.Currency.Format(sub.Cost), status, sub.NextPayment.Format("02.01.2006"))
+		if len(sub.Tags) > 0 {
+			text += "  🏷️ " + formatTags(sub.Tags) + "\n"
+		}
+		text += "\n"
 
 		// Create action buttons for each subscription
-		payBtn := telebot.InlineButton{
-			Unique: fmt.Sprintf("pay_%d", sub.ID),
-			Text:   fmt.Sprintf("✅ Оплатить %s", sub.Name),
-		}
+		payBtn := btnPaySubscription
+		payBtn.Text = fmt.Sprintf("✅ Оплатить %s", sub.Name)
+		payBtn.Data = cyclePayload(sub.ID, sub.NextPayment)
+
 		deleteBtn := telebot.InlineButton{
 			Unique: fmt.Sprintf("delete_%d", sub.ID),
 			Text:   fmt.Sprintf("❌ Удалить %s", sub.Name),
 		}
 
+		infoBtn := btnSubscriptionInfo
+		infoBtn.Text = fmt.Sprintf("ℹ️ %s", sub.Name)
+		infoBtn.Data = strconv.Itoa(sub.ID)
+
 		// Register handlers for these specific buttons
-		b.bot.Handle(&payBtn, b.handlePaySubscription)
 		b.bot.Handle(&deleteBtn, b.handleDeleteSubscription)
 
 		keyboard = append(keyboard, []telebot.InlineButton{payBtn})
+		keyboard = append(keyboard, []telebot.InlineButton{infoBtn})
 		keyboard = append(keyboard, []telebot.InlineButton{deleteBtn})
 	}
 
+	keyboard = append(keyboard, newTagFilterKeyboard(btnMySubscriptions, tags, tag)...)
 	keyboard = append(keyboard, []telebot.InlineButton{btnBack})
 
 	return c.Edit(text, &telebot.ReplyMarkup{
@@ -68,48 +103,131 @@ func (b *Bot) handleMySubscriptions(c telebot.Context) error {
 	}, telebot.ModeMarkdown)
 }
 
+func (b *Bot) handleSubscriptionInfo(c telebot.Context) error {
+	userID := ownerOf(c)
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID подписки")
+	}
+
+	ctx := context.Background()
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	history, err := b.subscriptionService.GetPriceHistory(ctx, userID, id)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения истории цен: %v", err))
+	}
+
+	text := fmt.Sprintf("ℹ️ *%s*\n\n"+
+		"💰 Стоимость: %s\n"+
+		"📅 Период: %s\n"+
+		"🗓️ Следующий платеж: %s\n"+
+		"📂 Категория: %s\n"+
+		"🔄 Автопродление: %s\n"+
+		"🧪 Пробный период: %s\n"+
+		"🏷️ Теги: %s\n",
+		subscription.Name,
+		subscription.Currency.Format(subscription.Cost),
+		describePeriod(subscription.Period),
+		subscription.NextPayment.Format("02.01.2006"),
+		b.categoryLabel(userID, subscription.Category),
+		getBoolEmoji(subscription.AutoRenewal),
+		describeTrial(subscription, time.Now()),
+		orNone(formatTags(subscription.Tags)))
+	if subscription.Notes != "" {
+		text += "🗒️ Заметка: " + escapeMarkdown(subscription.Notes) + "\n"
+	}
+	text += "\n📈 *История цен:*\n"
+
+	// Show the newest prices first, older ones are rarely interesting
+	shown := 0
+	for i := len(history) - 1; i >= 0 && shown < 10; i-- {
+		change := history[i]
+
+		period := "по сей день"
+		if change.EffectiveTo != nil {
+			period = "до " + change.EffectiveTo.Format("02.01.2006")
+		}
+
+		text += fmt.Sprintf("• %s с %s %s\n",
+			change.Currency.Format(change.Cost), change.EffectiveFrom.Format("02.01.2006"), period)
+		shown++
+	}
+	if len(history) == 0 {
+		text += "Нет данных\n"
+	}
+
+	editBtn := btnEditSubscription
+	editBtn.Data = strconv.Itoa(subscription.ID)
+	recordBtn := btnRecordPayment
+	recordBtn.Data = strconv.Itoa(subscription.ID)
+
+	keyboard := [][]telebot.InlineButton{
+		{editBtn},
+		{recordBtn},
+	}
+	if subscription.URL != "" {
+		keyboard = append(keyboard, []telebot.InlineButton{{Text: "🔗 Управление подпиской", URL: subscription.URL}})
+	}
+	keyboard = append(keyboard,
+		[]telebot.InlineButton{btnMySubscriptions},
+		[]telebot.InlineButton{btnBack},
+	)
+
+	return c.Edit(text, &telebot.ReplyMarkup{
+		InlineKeyboard: keyboard,
+	}, telebot.ModeMarkdown)
+}
+
 func (b *Bot) handlePaySubscription(c telebot.Context) error {
-	data := c.Data()
-	idStr := strings.TrimPrefix(data, "pay_")
-	id, err := strconv.Atoi(idStr)
+	userID := c.Sender().ID
+	id, dueDate, err := parseCyclePayload(c.Data())
 	if err != nil {
 		return c.Send("❌ Некорректный ID подписки")
 	}
 
 	ctx := context.Background()
-	err = b.subscriptionService.MarkAsPaid(ctx, id)
+	payment, err := b.subscriptionService.MarkAsPaid(ctx, userID, id, dueDate)
+	if errors.Is(err, services.ErrAlreadyPaid) {
+		return c.Respond(&telebot.CallbackResponse{Text: "ℹ️ Этот платеж уже отмечен"})
+	}
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка при отметке об оплате: %v", err))
 	}
 
-	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, id)
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
 	if err != nil {
 		return c.Send("❌ Ошибка получения подписки")
 	}
 
-	currencySymbol := "$"
-	if subscription.Currency == models.CurrencyRUB {
-		currencySymbol = "₽"
-	}
-
 	text := fmt.Sprintf("✅ *Платеж отмечен!*\n\n"+
 		"📝 Подписка: %s\n"+
-		"💰 Сумма: %s%s\n"+
+		"💰 Сумма: %s\n"+
 		"📅 Следующий платеж: %s",
 		subscription.Name,
-		subscription.Cost.String(), currencySymbol,
+		subscription.Currency.Format(subscription.Cost),
 		subscription.NextPayment.Format("02.01.2006"))
 
-	return c.Edit(text, &telebot.ReplyMarkup{
+	err = c.Edit(text, &telebot.ReplyMarkup{
 		InlineKeyboard: [][]telebot.InlineButton{
 			{btnMySubscriptions},
 			{btnBack},
 		},
 	}, telebot.ModeMarkdown)
+	if err != nil {
+		return err
+	}
+
+	b.sendBudgetAlerts(c, payment)
+	return nil
 }
 
 func (b *Bot) handleDeleteSubscription(c telebot.Context) error {
-	data := c.Data()
+	userID := c.Sender().ID
+	data := callbackUnique(c)
 	idStr := strings.TrimPrefix(data, "delete_")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -117,12 +235,12 @@ func (b *Bot) handleDeleteSubscription(c telebot.Context) error {
 	}
 
 	ctx := context.Background()
-	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, id)
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
 	if err != nil {
 		return c.Send("❌ Ошибка получения подписки")
 	}
 
-	err = b.subscriptionService.DeleteSubscription(ctx, id)
+	err = b.subscriptionService.DeleteSubscription(ctx, userID, id)
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка при удалении: %v", err))
 	}
@@ -141,16 +259,17 @@ func (b *Bot) handleDeleteSubscription(c telebot.Context) error {
 }
 
 func (b *Bot) handleMonthlyExpense(c telebot.Context) error {
+	userID := ownerOf(c)
 	ctx := context.Background()
 
 	// Get current month expenses
-	currentExpenses, err := b.analyticsService.GetCurrentMonthExpense(ctx)
+	currentExpenses, err := b.analyticsService.GetCurrentMonthExpense(ctx, userID)
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка получения расходов: %v", err))
 	}
 
 	// Get monthly recurring costs
-	recurringCosts, err := b.analyticsService.GetMonthlyRecurringCost(ctx)
+	recurringCosts, err := b.analyticsService.GetMonthlyRecurringCost(ctx, userID)
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка расчета месячных расходов: %v", err))
 	}
@@ -163,13 +282,14 @@ func (b *Bot) handleMonthlyExpense(c telebot.Context) error {
 		text += "Пока нет платежей в этом месяце\n\n"
 	} else {
 		for _, expense := range currentExpenses {
-			currencySymbol := "$"
-			if expense.Currency == models.CurrencyRUB {
-				currencySymbol = "₽"
-			}
-			text += fmt.Sprintf("• %s%s (%d платежей)\n", expense.TotalAmount.String(), currencySymbol, expense.Count)
+			text += fmt.Sprintf("• %s (%d платежей)\n", expense.Currency.Format(expense.TotalAmount), expense.Count)
 		}
-		text += "\n"
+
+		expenseTotal, err := b.analyticsService.GetCurrentMonthExpenseTotal(ctx, userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка пересчета расходов: %v", err))
+		}
+		text += fmt.Sprintf("💱 Итого: %s\n\n", formatConvertedTotal(expenseTotal))
 	}
 
 	// Monthly recurring costs
@@ -178,12 +298,14 @@ func (b *Bot) handleMonthlyExpense(c telebot.Context) error {
 		text += "Нет активных подписок\n"
 	} else {
 		for currency, amount := range recurringCosts {
-			currencySymbol := "$"
-			if currency == models.CurrencyRUB {
-				currencySymbol = "₽"
-			}
-			text += fmt.Sprintf("• %s%s в месяц\n", amount.String(), currencySymbol)
+			text += fmt.Sprintf("• %s в месяц\n", currency.Format(amount))
+		}
+
+		recurringTotal, err := b.analyticsService.GetMonthlyRecurringTotal(ctx, userID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("❌ Ошибка пересчета месячных расходов: %v", err))
 		}
+		text += fmt.Sprintf("💱 Итого: %s в месяц\n", formatConvertedTotal(recurringTotal))
 	}
 
 	return c.Edit(text, &telebot.ReplyMarkup{
@@ -193,87 +315,114 @@ func (b *Bot) handleMonthlyExpense(c telebot.Context) error {
 	}, telebot.ModeMarkdown)
 }
 
+// handleAnalytics shows this month's payments per category, only those of
+// subscriptions with the tag passed in the button payload when there is one
 func (b *Bot) handleAnalytics(c telebot.Context) error {
+	userID := ownerOf(c)
 	ctx := context.Background()
-	analytics, err := b.analyticsService.GetCurrentMonthCategoryAnalytics(ctx)
+	tag := c.Data()
+
+	var analytics map[models.Category][]models.PaymentSummary
+	var err error
+	if tag == "" {
+		analytics, err = b.analyticsService.GetCurrentMonthCategoryAnalytics(ctx, userID)
+	} else {
+		analytics, err = b.analyticsService.GetCurrentMonthTagAnalytics(ctx, userID, tag)
+	}
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ Ошибка получения аналитики: %v", err))
 	}
 
+	totals, err := b.analyticsService.ConvertCategoryAnalytics(ctx, userID, analytics)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка пересчета аналитики: %v", err))
+	}
+
+	tags, err := b.subscriptionService.GetTags(ctx, userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения аналитики: %v", err))
+	}
+
+	labels := b.categoryLabels(userID)
+
 	text := "📊 *Аналитика по категориям*\n\n"
+	if tag != "" {
+		text = "📊 *Аналитика по категориям* " + formatTags([]string{tag}) + "\n\n"
+	}
 
 	if len(analytics) == 0 {
 		text += "Нет данных за текущий месяц"
 	} else {
 		for category, summaries := range analytics {
-			text += fmt.Sprintf("%s\n", getCategoryEmoji(category))
+			text += fmt.Sprintf("%s\n", labelOf(labels, category))
 			for _, summary := range summaries {
-				currencySymbol := "$"
-				if summary.Currency == models.CurrencyRUB {
-					currencySymbol = "₽"
-				}
-				text += fmt.Sprintf("  %s%s (%d платежей)\n", summary.TotalAmount.String(), currencySymbol, summary.Count)
+				text += fmt.Sprintf("  %s (%d платежей)\n", summary.Currency.Format(summary.TotalAmount), summary.Count)
 			}
+			text += fmt.Sprintf("  💱 Итого: %s\n", formatConvertedTotal(totals[category]))
 			text += "\n"
 		}
 	}
 
-	return c.Edit(text, &telebot.ReplyMarkup{
-		InlineKeyboard: [][]telebot.InlineButton{
-			{btnBack},
-		},
-	}, telebot.ModeMarkdown)
-}
-
-func (b *Bot) handleHistory(c telebot.Context) error {
-	ctx := context.Background()
-	payments, err := b.analyticsService.GetPaymentHistory(ctx, 10) // Last 10 payments
+	increases, err := b.analyticsService.GetLastYearPriceIncreases(ctx, userID)
 	if err != nil {
-		return c.Send(fmt.Sprintf("❌ Ошибка получения истории: %v", err))
+		return c.Send(fmt.Sprintf("❌ Ошибка получения истории цен: %v", err))
 	}
 
-	text := "📜 *История платежей*\n\n"
-
-	if len(payments) == 0 {
-		text += "История платежей пуста"
-	} else {
-		for _, payment := range payments {
-			currencySymbol := "$"
-			if payment.Currency == models.CurrencyRUB {
-				currencySymbol = "₽"
-			}
-
-			statusIcon := "✅"
-			switch payment.Status {
-			case models.PaymentStatusPending:
-				statusIcon = "⏳"
-			case models.PaymentStatusFailed:
-				statusIcon = "❌"
-			}
-
-			text += fmt.Sprintf("%s %s%s - %s\n",
-				statusIcon, payment.Amount.String(), currencySymbol, payment.PaidAt.Format("02.01.2006 15:04"))
+	if len(increases) > 0 {
+		text += "\n📈 *Подорожания за 12 месяцев:*\n"
+		for _, increase := range increases {
+			text += fmt.Sprintf("• %s: %s → %s (+%.0f%%) с %s\n",
+				increase.Name,
+				increase.Currency.Format(increase.OldCost),
+				increase.Currency.Format(increase.NewCost),
+				increase.Percent(), increase.ChangedAt.Format("02.01.2006"))
 		}
 	}
 
+	keyboard := newTagFilterKeyboard(btnAnalytics, tags, tag)
+	keyboard = append(keyboard, []telebot.InlineButton{btnBack})
+
 	return c.Edit(text, &telebot.ReplyMarkup{
-		InlineKeyboard: [][]telebot.InlineButton{
-			{btnBack},
-		},
+		InlineKeyboard: keyboard,
 	}, telebot.ModeMarkdown)
 }
 
 func (b *Bot) handleSettings(c telebot.Context) error {
+	notifications := "выключены"
+	if b.notifications.Enabled {
+		notifications = fmt.Sprintf("за %d дн. и в день платежа", b.notifications.DaysBefore)
+	}
+
+	baseCurrency, err := b.exchangeService.GetBaseCurrency(context.Background(), ownerOf(c))
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения настроек: %v", err))
+	}
+
 	text := "⚙️ *Настройки*\n\nДоступные функции:\n\n" +
-		"• Поддержка валют: USD, RUB\n" +
-		"• Автоматические уведомления о платежах\n" +
+		"• Поддержка валют: " + supportedCurrencies() + "\n" +
+		"• Базовая валюта для итогов: " + currencyLabel(baseCurrency) + "\n" +
+		"• Автоматические уведомления о платежах: " + notifications + "\n" +
+		"• Свои категории подписок: /categories\n" +
+		"• Месячные бюджеты и предупреждения о перерасходе: /budgets\n" +
 		"• Аналитика по категориям\n" +
 		"• История всех операций\n\n" +
-		"Для настройки уведомлений обратитесь к разработчику."
+		"Уведомления настраиваются в секции `notifications` конфигурации.\n" +
+		"Курсы валют: /rates, задать курс: `/rate USD RUB 90.5`"
 
 	return c.Edit(text, &telebot.ReplyMarkup{
 		InlineKeyboard: [][]telebot.InlineButton{
+			{btnBaseCurrency},
+			{btnCategories},
 			{btnBack},
 		},
 	}, telebot.ModeMarkdown)
 }
+
+// supportedCurrencies lists the codes of the currency registry
+func supportedCurrencies() string {
+	var codes []string
+	for _, info := range models.Currencies() {
+		codes = append(codes, string(info.Code))
+	}
+	return strings.Join(codes, ", ")
+}
@@ -0,0 +1,419 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/services"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const paymentDatePrompt = "🗓️ Введите дату платежа в формате ДД.ММ.ГГГГ или «-», если платеж сегодня:"
+const paymentNotePrompt = "📝 Добавьте заметку к платежу (скидка, налог, курс) или отправьте «-»:"
+
+// handleRecordPayment starts recording a payment of the subscription passed in the button payload
+func (b *Bot) handleRecordPayment(c telebot.Context) error {
+	userID := c.Sender().ID
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID подписки")
+	}
+
+	subscription, err := b.subscriptionService.GetSubscriptionByID(context.Background(), userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	b.resetUserState(userID, StateRecordingAmount)
+	b.setData(userID, "payment_sub_id", subscription.ID)
+	b.setData(userID, "payment_currency", subscription.Currency)
+
+	text := fmt.Sprintf("🧾 *Новый платеж: %s*\n\n💰 Введите сумму в %s (стоимость подписки: %s):",
+		subscription.Name, subscription.Currency.Symbol(), subscription.Currency.Format(subscription.Cost))
+
+	return c.Edit(text, &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+func (b *Bot) handleRecordAmountInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	currencyData := b.getData(userID, "payment_currency")
+	if currencyData == nil {
+		return c.Send("❌ Ошибка: данные о валюте отсутствуют. Начните заново с /start")
+	}
+	currency := currencyData.(models.Currency)
+
+	amount, err := models.ParseMoneyIn(strings.TrimSpace(c.Text()), currency)
+	if err != nil || amount < 0 {
+		return c.Send(fmt.Sprintf("❌ Некорректная сумма. Введите число (например: %s):", exampleAmount(currency)))
+	}
+
+	b.setData(userID, "payment_amount", amount)
+	b.setState(userID, StateRecordingDate)
+
+	return c.Send(paymentDatePrompt)
+}
+
+func (b *Bot) handleRecordDateInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	paidAt, err := parsePaymentDate(c.Text(), time.Now())
+	if err != nil {
+		return c.Send("❌ Некорректная дата. " + paymentDatePrompt)
+	}
+
+	b.setData(userID, "payment_paid_at", paidAt)
+	b.setState(userID, StateRecordingStatus)
+
+	return c.Send("📌 Выберите статус платежа:", &telebot.ReplyMarkup{
+		InlineKeyboard: newPaymentStatusKeyboard(),
+	})
+}
+
+// handlePaymentStatusSelection handles the status of a new payment or a new
+// status of an existing one
+func (b *Bot) handlePaymentStatusSelection(c telebot.Context) error {
+	userID := c.Sender().ID
+	status := models.PaymentStatus(c.Data())
+	if !status.IsValid() {
+		return c.Send("❌ Неизвестный статус платежа")
+	}
+
+	switch b.getUserState(userID).State {
+	case StateEditingPayment:
+		return b.applyPaymentEdit(c, &models.UpdatePaymentRequest{Status: &status})
+	case StateRecordingStatus:
+		b.setData(userID, "payment_status", status)
+		b.setState(userID, StateRecordingNote)
+		return c.Edit(paymentNotePrompt, &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	}
+
+	return b.showMainMenu(c)
+}
+
+func (b *Bot) handleRecordNoteInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	note := parsePaymentNote(c.Text())
+	b.setData(userID, "payment_note", note)
+
+	// Only a completed payment can pay for the current billing cycle
+	if b.getData(userID, "payment_status") != models.PaymentStatusCompleted {
+		return b.saveRecordedPayment(c, false)
+	}
+
+	idData := b.getData(userID, "payment_sub_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: подписка не выбрана. Начните заново с /start")
+	}
+
+	subscription, err := b.subscriptionService.GetSubscriptionByID(context.Background(), userID, idData.(int))
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	b.setState(userID, StateRecordingSettle)
+
+	text := fmt.Sprintf("🔄 Засчитать платеж как оплату периода с датой платежа %s?\n\n"+
+		"Тогда следующий платеж по подписке будет назначен на %s.",
+		subscription.NextPayment.Format("02.01.2006"),
+		subscription.Period.Next(subscription.NextPayment).Format("02.01.2006"))
+
+	return c.Send(text, &telebot.ReplyMarkup{
+		InlineKeyboard: settleCycleKeyboard,
+	})
+}
+
+func (b *Bot) handleSettleCycleSelection(c telebot.Context) error {
+	userID := c.Sender().ID
+	if b.getUserState(userID).State != StateRecordingSettle {
+		return b.showMainMenu(c)
+	}
+
+	return b.saveRecordedPayment(c, callbackUnique(c) == "settle_yes")
+}
+
+// saveRecordedPayment records the payment collected by the dialog. With
+// settle the payment pays for the current billing cycle of the subscription.
+func (b *Bot) saveRecordedPayment(c telebot.Context, settle bool) error {
+	userID := c.Sender().ID
+
+	idData := b.getData(userID, "payment_sub_id")
+	amountData := b.getData(userID, "payment_amount")
+	currencyData := b.getData(userID, "payment_currency")
+	paidAtData := b.getData(userID, "payment_paid_at")
+	statusData := b.getData(userID, "payment_status")
+	if idData == nil || amountData == nil || currencyData == nil || paidAtData == nil || statusData == nil {
+		return c.Send("❌ Ошибка: данные о платеже отсутствуют. Начните заново с /start")
+	}
+
+	note, _ := b.getData(userID, "payment_note").(string)
+	paidAt := paidAtData.(time.Time)
+
+	req := &models.CreatePaymentRequest{
+		UserID:         userID,
+		SubscriptionID: idData.(int),
+		Amount:         amountData.(models.Money),
+		Currency:       currencyData.(models.Currency),
+		Status:         statusData.(models.PaymentStatus),
+		PaidAt:         &paidAt,
+		Note:           note,
+	}
+
+	ctx := context.Background()
+	var dueDate *time.Time
+	if settle {
+		subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, req.SubscriptionID)
+		if err != nil {
+			return c.Send("❌ Ошибка получения подписки")
+		}
+		dueDate = &subscription.NextPayment
+	}
+
+	payment, err := b.subscriptionService.RecordPayment(ctx, req, dueDate)
+	if errors.Is(err, services.ErrAlreadyPaid) {
+		return c.Send("ℹ️ Этот период уже оплачен. Запишите платеж без зачета периода.")
+	}
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при записи платежа: %v", err))
+	}
+
+	b.clearUserState(userID)
+
+	text := "✅ *Платеж записан!*\n\n" + formatPayment(payment)
+	markup := &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{
+		{btnHistory},
+		{btnBack},
+	}}
+
+	if c.Callback() == nil {
+		err = c.Send(text, markup, telebot.ModeMarkdown)
+	} else {
+		err = c.Edit(text, markup, telebot.ModeMarkdown)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.sendBudgetAlerts(c, payment)
+	return nil
+}
+
+// handlePaymentInfo shows a payment from the history with edit buttons
+func (b *Bot) handlePaymentInfo(c telebot.Context) error {
+	userID := c.Sender().ID
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID платежа")
+	}
+
+	payment, err := b.subscriptionService.GetPayment(context.Background(), userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения платежа")
+	}
+
+	// Keep the history filters to return to the same list afterwards
+	filter := b.historyFilter(userID)
+	b.resetUserState(userID, StateEditingPayment)
+	b.setHistoryFilter(userID, filter)
+	b.setData(userID, "payment_id", payment.ID)
+
+	return c.Edit(b.formatPaymentDetails(payment), &telebot.ReplyMarkup{
+		InlineKeyboard: paymentEditKeyboard,
+	}, telebot.ModeMarkdown)
+}
+
+// handlePaymentEditField asks for the new value of the chosen payment field
+func (b *Bot) handlePaymentEditField(c telebot.Context) error {
+	userID := c.Sender().ID
+	if !b.isEditingPayment(userID) {
+		return b.showMainMenu(c)
+	}
+
+	switch callbackUnique(c) {
+	case "payment_amount":
+		b.setState(userID, StateEditingPaymentAmount)
+		return c.Edit("💰 Введите новую сумму платежа:", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "payment_date":
+		b.setState(userID, StateEditingPaymentDate)
+		return c.Edit(paymentDatePrompt, &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "payment_note":
+		b.setState(userID, StateEditingPaymentNote)
+		return c.Edit(paymentNotePrompt, &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		})
+	case "payment_edit_status":
+		b.setState(userID, StateEditingPayment)
+		return c.Edit("📌 Выберите новый статус платежа:", &telebot.ReplyMarkup{
+			InlineKeyboard: newPaymentStatusKeyboard(),
+		})
+	}
+
+	return b.showMainMenu(c)
+}
+
+func (b *Bot) handleEditPaymentAmountInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	idData := b.getData(userID, "payment_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: платеж не выбран. Начните заново с /start")
+	}
+
+	payment, err := b.subscriptionService.GetPayment(context.Background(), userID, idData.(int))
+	if err != nil {
+		return c.Send("❌ Ошибка получения платежа")
+	}
+
+	amount, err := models.ParseMoneyIn(strings.TrimSpace(c.Text()), payment.Currency)
+	if err != nil || amount < 0 {
+		return c.Send(fmt.Sprintf("❌ Некорректная сумма. Введите число (например: %s):", exampleAmount(payment.Currency)))
+	}
+
+	return b.applyPaymentEdit(c, &models.UpdatePaymentRequest{Amount: &amount})
+}
+
+func (b *Bot) handleEditPaymentDateInput(c telebot.Context) error {
+	paidAt, err := parsePaymentDate(c.Text(), time.Now())
+	if err != nil {
+		return c.Send("❌ Некорректная дата. " + paymentDatePrompt)
+	}
+
+	return b.applyPaymentEdit(c, &models.UpdatePaymentRequest{PaidAt: &paidAt})
+}
+
+func (b *Bot) handleEditPaymentNoteInput(c telebot.Context) error {
+	note := parsePaymentNote(c.Text())
+	return b.applyPaymentEdit(c, &models.UpdatePaymentRequest{Note: &note})
+}
+
+func (b *Bot) handleVoidPayment(c telebot.Context) error {
+	userID := c.Sender().ID
+	if !b.isEditingPayment(userID) {
+		return b.showMainMenu(c)
+	}
+
+	status := models.PaymentStatusVoided
+	return b.applyPaymentEdit(c, &models.UpdatePaymentRequest{Status: &status})
+}
+
+// applyPaymentEdit saves the change and returns the user to the payment menu
+func (b *Bot) applyPaymentEdit(c telebot.Context, req *models.UpdatePaymentRequest) error {
+	userID := c.Sender().ID
+
+	idData := b.getData(userID, "payment_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: платеж не выбран. Начните заново с /start")
+	}
+
+	payment, err := b.subscriptionService.UpdatePayment(context.Background(), userID, idData.(int), req)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при изменении платежа: %v", err))
+	}
+
+	b.setState(userID, StateEditingPayment)
+
+	text := "✅ Изменения сохранены\n\n" + b.formatPaymentDetails(payment)
+	markup := &telebot.ReplyMarkup{InlineKeyboard: paymentEditKeyboard}
+
+	// Text input can't be edited into a menu, so answer with a new message
+	if c.Callback() == nil {
+		return c.Send(text, markup, telebot.ModeMarkdown)
+	}
+	return c.Edit(text, markup, telebot.ModeMarkdown)
+}
+
+// isEditingPayment reports whether the user has opened a payment from the history
+func (b *Bot) isEditingPayment(userID int64) bool {
+	state := b.getUserState(userID)
+	if state.Data["payment_id"] == nil {
+		return false
+	}
+
+	switch state.State {
+	case StateEditingPayment, StateEditingPaymentAmount, StateEditingPaymentDate, StateEditingPaymentNote:
+		return true
+	}
+	return false
+}
+
+func (b *Bot) formatPaymentDetails(payment *models.Payment) string {
+	name := "—"
+	subscription, err := b.subscriptionService.GetSubscriptionByID(context.Background(), payment.UserID, payment.SubscriptionID)
+	if err == nil {
+		name = subscription.Name
+	}
+
+	return fmt.Sprintf("🧾 *Платеж*\n\n📝 Подписка: %s\n%s\n\nВыберите, что изменить:", name, formatPayment(payment))
+}
+
+func formatPayment(payment *models.Payment) string {
+	text := fmt.Sprintf("💰 Сумма: %s\n"+
+		"🗓️ Дата: %s\n"+
+		"📌 Статус: %s",
+		payment.Currency.Format(payment.Amount),
+		payment.PaidAt.Format("02.01.2006"),
+		paymentStatusLabel(payment.Status))
+
+	if payment.Note != "" {
+		text += "\n💬 Заметка: " + escapeMarkdown(payment.Note)
+	}
+	return text
+}
+
+func paymentStatusIcon(status models.PaymentStatus) string {
+	switch status {
+	case models.PaymentStatusPending:
+		return "⏳"
+	case models.PaymentStatusFailed:
+		return "❌"
+	case models.PaymentStatusVoided:
+		return "🚫"
+	default:
+		return "✅"
+	}
+}
+
+func paymentStatusLabel(status models.PaymentStatus) string {
+	switch status {
+	case models.PaymentStatusPending:
+		return "⏳ Ожидает"
+	case models.PaymentStatusFailed:
+		return "❌ Не прошел"
+	case models.PaymentStatusVoided:
+		return "🚫 Аннулирован"
+	default:
+		return "✅ Выполнен"
+	}
+}
+
+// parsePaymentDate reads a date like 25.12.2025, "-" means today
+func parsePaymentDate(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "-" {
+		return now, nil
+	}
+	return time.Parse("02.01.2006", input)
+}
+
+// parsePaymentNote treats "-" as no note
+func parsePaymentNote(input string) string {
+	note := strings.TrimSpace(input)
+	if note == "-" {
+		return ""
+	}
+	return note
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/services"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const cycleDateLayout = "2006-01-02"
+
+// SendPaymentReminder implements services.Notifier.
+func (b *Bot) SendPaymentReminder(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error {
+	if kind == models.ReminderKindTrialEnding {
+		return b.sendTrialEndingReminder(sub)
+	}
+
+	header := "🔔 *Скоро платеж*"
+	if kind == models.ReminderKindDue {
+		header = "⚠️ *Пора оплатить подписку*"
+	}
+
+	text := fmt.Sprintf("%s\n\n"+
+		"📝 Подписка: %s\n"+
+		"💰 Сумма: %s\n"+
+		"📅 Дата платежа: %s",
+		header,
+		sub.Name,
+		sub.Currency.Format(sub.Cost),
+		sub.NextPayment.Format("02.01.2006"))
+
+	payload := cyclePayload(sub.ID, sub.NextPayment)
+	paidBtn := btnReminderPaid
+	paidBtn.Data = payload
+	snoozeBtn := btnReminderSnooze
+	snoozeBtn.Data = payload
+
+	_, err := b.bot.Send(&telebot.User{ID: sub.UserID}, text, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{paidBtn, snoozeBtn},
+		},
+	}, telebot.ModeMarkdown)
+	return err
+}
+
+// sendTrialEndingReminder warns that the trial converts to paid soon and offers to cancel it
+func (b *Bot) sendTrialEndingReminder(sub *models.Subscription) error {
+	text := fmt.Sprintf("🧪 *Пробный период заканчивается*\n\n"+
+		"📝 Подписка: %s\n"+
+		"📅 Окончание: %s\n"+
+		"💳 Затем: %s %s\n\n"+
+		"Если подписка не нужна, отмените ее до окончания пробного периода.",
+		sub.Name,
+		sub.TrialEndsAt.Format("02.01.2006"),
+		sub.Currency.Format(sub.Cost),
+		describePeriod(sub.Period))
+
+	cancelBtn := btnCancelTrial
+	cancelBtn.Data = strconv.Itoa(sub.ID)
+	snoozeBtn := btnReminderSnooze
+	snoozeBtn.Data = cyclePayload(sub.ID, sub.NextPayment)
+
+	_, err := b.bot.Send(&telebot.User{ID: sub.UserID}, text, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{cancelBtn, snoozeBtn},
+		},
+	}, telebot.ModeMarkdown)
+	return err
+}
+
+func (b *Bot) handleReminderPaid(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	id, dueDate, err := parseCyclePayload(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректные данные напоминания")
+	}
+
+	ctx := context.Background()
+	payment, paidErr := b.subscriptionService.MarkAsPaid(ctx, userID, id, dueDate)
+	if paidErr != nil && !errors.Is(paidErr, services.ErrAlreadyPaid) {
+		return c.Send(fmt.Sprintf("❌ Ошибка при отметке об оплате: %v", paidErr))
+	}
+
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	// The reminder belongs to a cycle that has already been paid
+	if paidErr != nil {
+		return c.Edit(fmt.Sprintf("ℹ️ Платеж по подписке %s уже отмечен.\n\n📅 Следующий платеж: %s",
+			subscription.Name, subscription.NextPayment.Format("02.01.2006")))
+	}
+
+	err = c.Edit(fmt.Sprintf("✅ *Платеж отмечен!*\n\n📝 Подписка: %s\n📅 Следующий платеж: %s",
+		subscription.Name, subscription.NextPayment.Format("02.01.2006")), telebot.ModeMarkdown)
+	if err != nil {
+		return err
+	}
+
+	b.sendBudgetAlerts(c, payment)
+	return nil
+}
+
+func (b *Bot) handleReminderSnooze(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	id, dueDate, err := parseCyclePayload(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректные данные напоминания")
+	}
+
+	until, err := b.reminderService.Snooze(context.Background(), userID, id, dueDate)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Не удалось отложить напоминание: %v", err))
+	}
+
+	return c.Respond(&telebot.CallbackResponse{
+		Text: fmt.Sprintf("⏰ Напомню %s", until.Format("02.01.2006 15:04")),
+	})
+}
+
+// cyclePayload identifies a billing cycle in button payloads by the
+// subscription ID and the payment date
+func cyclePayload(subscriptionID int, dueDate time.Time) string {
+	return fmt.Sprintf("%d_%s", subscriptionID, dueDate.Format(cycleDateLayout))
+}
+
+func parseCyclePayload(data string) (int, time.Time, error) {
+	idStr, dateStr, found := strings.Cut(data, "_")
+	if !found {
+		return 0, time.Time{}, fmt.Errorf("invalid reminder payload: %s", data)
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid subscription ID: %s", idStr)
+	}
+
+	dueDate, err := time.Parse(cycleDateLayout, dateStr)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid payment date: %s", dateStr)
+	}
+
+	return id, dueDate, nil
+}
+
+// SendRenewalNotice implements services.RenewalNotifier.
+func (b *Bot) SendRenewalNotice(ctx context.Context, sub *models.Subscription, payments []*models.Payment) error {
+
+	text := fmt.Sprintf("🔄 *Подписка продлена автоматически*\n\n"+
+		"📝 Подписка: %s\n"+
+		"💰 Сумма: %s\n",
+		sub.Name, sub.Currency.Format(sub.Cost))
+
+	if len(payments) > 1 {
+		text += fmt.Sprintf("🧾 Записано платежей за пропущенные периоды: %d\n", len(payments))
+	}
+
+	text += fmt.Sprintf("📅 Следующий платеж: %s", sub.NextPayment.Format("02.01.2006"))
+
+	_, err := b.bot.Send(&telebot.User{ID: sub.UserID}, text, telebot.ModeMarkdown)
+	return err
+}
+
+// SendExpirationNotice implements services.RenewalNotifier.
+func (b *Bot) SendExpirationNotice(ctx context.Context, sub *models.Subscription) error {
+	text := fmt.Sprintf("⌛ *Подписка истекла*\n\n"+
+		"📝 Подписка: %s\n"+
+		"📅 Дата платежа: %s\n\n"+
+		"Платеж не был отмечен, а автопродление выключено, поэтому подписка деактивирована.",
+		sub.Name, sub.NextPayment.Format("02.01.2006"))
+
+	_, err := b.bot.Send(&telebot.User{ID: sub.UserID}, text, telebot.ModeMarkdown)
+	return err
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+	"sync"
+	"time"
+)
+
+// StateStore keeps conversation states between updates.
+// Implementations must be safe for concurrent use and must treat states
+// that were not updated within their TTL as absent.
+type StateStore interface {
+	// Get returns a copy of the user's state, or an idle state if there is none.
+	Get(ctx context.Context, userID int64) (*models.UserState, error)
+	// Update atomically applies fn to the user's state and saves the result.
+	Update(ctx context.Context, userID int64, fn func(state *models.UserState)) error
+	// Delete forgets the user's state.
+	Delete(ctx context.Context, userID int64) error
+	// DeleteExpired removes abandoned conversations and returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// MemoryStateStore is a StateStore that lives in process memory.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	states map[int64]*models.UserState
+}
+
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	return &MemoryStateStore{
+		ttl:    ttl,
+		states: make(map[int64]*models.UserState),
+	}
+}
+
+func (s *MemoryStateStore) Get(ctx context.Context, userID int64) (*models.UserState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(userID).Clone(), nil
+}
+
+func (s *MemoryStateStore) Update(ctx context.Context, userID int64, fn func(state *models.UserState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.load(userID).Clone()
+	fn(state)
+	state.UpdatedAt = time.Now()
+	s.states[userID] = state
+
+	return nil
+}
+
+func (s *MemoryStateStore) Delete(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, userID)
+	return nil
+}
+
+func (s *MemoryStateStore) DeleteExpired(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for userID, state := range s.states {
+		if state.IsExpired(now, s.ttl) {
+			delete(s.states, userID)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// load must be called with the lock held.
+func (s *MemoryStateStore) load(userID int64) *models.UserState {
+	state, exists := s.states[userID]
+	if !exists || state.IsExpired(time.Now(), s.ttl) {
+		return models.NewUserState(StateIdle)
+	}
+	return state
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that concurrent updates of one user are not lost
+func TestMemoryStateStoreConcurrentUpdates(t *testing.T) {
+	store := NewMemoryStateStore(time.Hour)
+	ctx := context.Background()
+	userID := int64(12345)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Update(ctx, userID, func(state *models.UserState) {
+				count, _ := state.Data["count"].(int)
+				state.Data["count"] = count + 1
+			})
+		}()
+	}
+	wg.Wait()
+
+	state, err := store.Get(ctx, userID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.Data["count"] != 100 {
+		t.Errorf("Expected 100 updates, got %v", state.Data["count"])
+	}
+}
+
+// Test that callers cannot change stored state without Update
+func TestMemoryStateStoreReturnsCopies(t *testing.T) {
+	store := NewMemoryStateStore(time.Hour)
+	ctx := context.Background()
+	userID := int64(12345)
+
+	store.Update(ctx, userID, func(state *models.UserState) {
+		state.Data["name"] = "Netflix"
+	})
+
+	state, _ := store.Get(ctx, userID)
+	state.Data["name"] = "Spotify"
+
+	state, _ = store.Get(ctx, userID)
+	if state.Data["name"] != "Netflix" {
+		t.Errorf("Stored state was modified through a copy: %v", state.Data["name"])
+	}
+}
+
+// Test that abandoned conversations expire
+func TestMemoryStateStoreTTL(t *testing.T) {
+	store := NewMemoryStateStore(time.Hour)
+	ctx := context.Background()
+
+	store.Update(ctx, 1, func(state *models.UserState) {
+		state.State = StateWaitingForName
+	})
+	store.Update(ctx, 2, func(state *models.UserState) {
+		state.State = StateWaitingForCost
+	})
+
+	// Pretend user 1 left the dialog two hours ago
+	store.states[1].UpdatedAt = time.Now().Add(-2 * time.Hour)
+
+	state, _ := store.Get(ctx, 1)
+	if state.State != StateIdle {
+		t.Errorf("Expected expired state to read as %s, got %s", StateIdle, state.State)
+	}
+
+	removed, err := store.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired state to be removed, got %d", removed)
+	}
+
+	state, _ = store.Get(ctx, 2)
+	if state.State != StateWaitingForCost {
+		t.Errorf("Active state was lost: got %s", state.State)
+	}
+}
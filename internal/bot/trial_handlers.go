@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const trialPrompt = "🧪 Введите дату окончания пробного периода в формате ДД.ММ.ГГГГ.\n\n" +
+	"Если пробный период платный, добавьте его стоимость через пробел: 31.12.2025 1.99\n" +
+	"Чтобы убрать пробный период, отправьте «-»."
+
+// handleTrials lists the trials that have not converted to paid yet
+func (b *Bot) handleTrials(c telebot.Context) error {
+	userID := ownerOf(c)
+	ctx := context.Background()
+	trials, err := b.subscriptionService.GetActiveTrials(ctx, userID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка получения пробных периодов: %v", err))
+	}
+
+	if len(trials) == 0 {
+		return c.Edit("🧪 *Пробные периоды*\n\nАктивных пробных периодов нет.\n\n"+
+			"Отметить подписку как пробную можно в меню ✏️ Изменить.", &telebot.ReplyMarkup{
+			InlineKeyboard: backKeyboard,
+		}, telebot.ModeMarkdown)
+	}
+
+	now := time.Now()
+	text := "🧪 *Пробные периоды*\n\n"
+	keyboard := [][]telebot.InlineButton{}
+
+	for _, sub := range trials {
+		text += fmt.Sprintf("• %s - осталось %s\n  🧪 Пробный: %s до %s\n  💳 Затем: %s %s\n\n",
+			sub.Name,
+			pluralizeCount(sub.TrialDaysLeft(now), [3]string{"день", "дня", "дней"}),
+			formatTrialCost(sub),
+			sub.TrialEndsAt.Format("02.01.2006"),
+			sub.Currency.Format(sub.Cost),
+			describePeriod(sub.Period))
+
+		infoBtn := btnSubscriptionInfo
+		infoBtn.Text = fmt.Sprintf("ℹ️ %s", sub.Name)
+		infoBtn.Data = strconv.Itoa(sub.ID)
+
+		cancelBtn := btnCancelTrial
+		cancelBtn.Text = fmt.Sprintf("🚫 Отменить %s", sub.Name)
+		cancelBtn.Data = strconv.Itoa(sub.ID)
+
+		keyboard = append(keyboard, []telebot.InlineButton{infoBtn, cancelBtn})
+	}
+
+	keyboard = append(keyboard, []telebot.InlineButton{btnBack})
+
+	return c.Edit(text, &telebot.ReplyMarkup{
+		InlineKeyboard: keyboard,
+	}, telebot.ModeMarkdown)
+}
+
+// handleSetTrial asks for the trial of the subscription passed in the button payload
+func (b *Bot) handleSetTrial(c telebot.Context) error {
+	userID := c.Sender().ID
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID подписки")
+	}
+
+	b.resetUserState(userID, StateEditingTrial)
+	b.setData(userID, "edit_id", id)
+
+	return c.Send(trialPrompt, &telebot.ReplyMarkup{
+		InlineKeyboard: backKeyboard,
+	})
+}
+
+func (b *Bot) handleEditTrialInput(c telebot.Context) error {
+	userID := c.Sender().ID
+	input := strings.TrimSpace(c.Text())
+
+	if input == "-" {
+		return b.applyEdit(c, &models.UpdateSubscriptionRequest{EndTrial: true})
+	}
+
+	idData := b.getData(userID, "edit_id")
+	if idData == nil {
+		return c.Send("❌ Ошибка: подписка для изменения не выбрана. Начните заново с /start")
+	}
+
+	subscription, err := b.subscriptionService.GetSubscriptionByID(context.Background(), userID, idData.(int))
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	endsAt, cost, err := parseTrialInput(input, subscription.Currency)
+	if err != nil {
+		return c.Send("❌ Некорректные данные. " + trialPrompt)
+	}
+	if !models.DateOnly(endsAt).After(models.DateOnly(time.Now())) {
+		return c.Send("❌ Дата окончания пробного периода должна быть в будущем. Попробуйте еще раз:")
+	}
+
+	return b.applyEdit(c, &models.UpdateSubscriptionRequest{TrialEndsAt: &endsAt, TrialCost: &cost})
+}
+
+// handleCancelTrial deactivates a subscription before its trial converts to paid
+func (b *Bot) handleCancelTrial(c telebot.Context) error {
+	userID := c.Sender().ID
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Send("❌ Некорректный ID подписки")
+	}
+
+	ctx := context.Background()
+	subscription, err := b.subscriptionService.GetSubscriptionByID(ctx, userID, id)
+	if err != nil {
+		return c.Send("❌ Ошибка получения подписки")
+	}
+
+	if err := b.subscriptionService.DeleteSubscription(ctx, userID, id); err != nil {
+		return c.Send(fmt.Sprintf("❌ Ошибка при отмене подписки: %v", err))
+	}
+
+	text := fmt.Sprintf("🚫 *Подписка отменена*\n\n"+
+		"📝 Название: %s\n"+
+		"Не забудьте отменить ее и в самом сервисе, чтобы после пробного периода не было списаний.",
+		subscription.Name)
+
+	return c.Edit(text, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnTrials},
+			{btnBack},
+		},
+	}, telebot.ModeMarkdown)
+}
+
+// parseTrialInput reads "31.12.2025" or "31.12.2025 1.99"
+func parseTrialInput(input string, currency models.Currency) (time.Time, models.Money, error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, 0, fmt.Errorf("invalid trial: %q", input)
+	}
+
+	endsAt, err := time.Parse("02.01.2006", fields[0])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid trial end date: %w", err)
+	}
+
+	var cost models.Money
+	if len(fields) == 2 {
+		cost, err = models.ParseMoneyIn(fields[1], currency)
+		if err != nil || cost < 0 {
+			return time.Time{}, 0, fmt.Errorf("invalid trial cost: %q", fields[1])
+		}
+	}
+
+	return endsAt, cost, nil
+}
+
+func describeTrial(sub *models.Subscription, now time.Time) string {
+	if !sub.IsTrial(now) {
+		return "нет"
+	}
+	return fmt.Sprintf("%s до %s", formatTrialCost(sub), sub.TrialEndsAt.Format("02.01.2006"))
+}
+
+func formatTrialCost(sub *models.Subscription) string {
+	if sub.TrialCost.IsZero() {
+		return "бесплатно"
+	}
+	return sub.Currency.Format(sub.TrialCost)
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sub-cos-counter/internal/config"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookPoller registers the webhook with Telegram and then waits for the bot
+// to stop. Updates are delivered by webhookServer, not by the poller itself.
+type webhookPoller struct {
+	publicURL   string
+	secretToken string
+}
+
+func (p *webhookPoller) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	err := b.SetWebhook(&telebot.Webhook{
+		SecretToken: p.secretToken,
+		Endpoint:    &telebot.WebhookEndpoint{PublicURL: p.publicURL},
+	})
+	if err != nil {
+		b.OnError(fmt.Errorf("failed to set webhook: %w", err), nil)
+		return
+	}
+	log.Printf("Webhook registered at %s", p.publicURL)
+
+	<-stop
+}
+
+// webhookServer receives updates from Telegram over HTTP.
+type webhookServer struct {
+	server      *http.Server
+	secretToken string
+	updates     chan<- telebot.Update
+}
+
+func newWebhookServer(cfg config.TelegramConfig, updates chan<- telebot.Update) (*webhookServer, error) {
+	publicURL, err := url.Parse(cfg.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+
+	path := publicURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	s := &webhookServer{
+		secretToken: cfg.WebhookSecret,
+		updates:     updates,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("POST "+path, s.handleUpdate)
+
+	s.server = &http.Server{
+		Addr:              cfg.WebhookListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s, nil
+}
+
+func (s *webhookServer) listenAndServe() {
+	log.Printf("Webhook server listening on %s", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Webhook server failed: %v", err)
+	}
+}
+
+func (s *webhookServer) shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *webhookServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.secretToken)) != 1 {
+		log.Printf("AUDIT: webhook request with invalid secret token from %s", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update telebot.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case s.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		// Telegram retries updates that were not acknowledged
+		http.Error(w, "timeout", http.StatusServiceUnavailable)
+	}
+}
+
+func (s *webhookServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
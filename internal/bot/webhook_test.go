@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sub-cos-counter/internal/config"
+	"testing"
+	"time"
+)
+
+type apiCall struct {
+	method string
+	params map[string]interface{}
+}
+
+// newFakeTelegram starts a server that answers Bot API calls and reports them.
+func newFakeTelegram(t *testing.T) (*httptest.Server, chan apiCall) {
+	calls := make(chan apiCall, 16)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		params := map[string]interface{}{}
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 0 {
+			json.Unmarshal(body, &params)
+		}
+
+		var result string
+		switch method {
+		case "getMe":
+			result = `{"id": 1, "is_bot": true, "first_name": "Test", "username": "test_bot"}`
+		case "sendMessage":
+			result = `{"message_id": 1, "date": 0, "chat": {"id": 42, "type": "private"}}`
+		default:
+			result = `true`
+		}
+
+		calls <- apiCall{method: method, params: params}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "result": ` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, calls
+}
+
+func waitForCall(t *testing.T, calls chan apiCall, method string) apiCall {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case call := <-calls:
+			if call.method == method {
+				return call
+			}
+		case <-timeout:
+			t.Fatalf("Bot API method %s was not called", method)
+		}
+	}
+}
+
+func TestWebhookMode(t *testing.T) {
+	telegram, calls := newFakeTelegram(t)
+
+	cfg := &config.Config{
+		Telegram: config.TelegramConfig{
+			BotToken:      "test-token",
+			APIURL:        telegram.URL,
+			UseWebhook:    true,
+			WebhookURL:    "https://bot.example.com/telegram/webhook",
+			WebhookListen: "127.0.0.1:0",
+			WebhookSecret: "s3cret",
+		},
+	}
+
+	b, err := NewBot(cfg, nil, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	waitForCall(t, calls, "getMe")
+
+	go b.Start()
+
+	// The webhook is registered with the public URL and the secret
+	call := waitForCall(t, calls, "setWebhook")
+	if call.params["url"] != cfg.Telegram.WebhookURL {
+		t.Errorf("Expected webhook URL %s, got %v", cfg.Telegram.WebhookURL, call.params["url"])
+	}
+	if call.params["secret_token"] != cfg.Telegram.WebhookSecret {
+		t.Errorf("Expected secret token to be registered, got %v", call.params["secret_token"])
+	}
+
+	handler := b.webhook.server.Handler
+	update := `{"update_id": 1, "message": {"message_id": 1, "date": 0, "text": "/start",
+		"from": {"id": 42, "first_name": "User"}, "chat": {"id": 42, "type": "private"}}}`
+
+	post := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(update))
+		if secret != "" {
+			req.Header.Set(secretTokenHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("rejects missing secret", func(t *testing.T) {
+		if code := post(""); code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", code)
+		}
+	})
+
+	t.Run("rejects wrong secret", func(t *testing.T) {
+		if code := post("wrong"); code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", code)
+		}
+	})
+
+	t.Run("rejects wrong path", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/other", strings.NewReader(update))
+		req.Header.Set(secretTokenHeader, "s3cret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("processes valid update", func(t *testing.T) {
+		if code := post("s3cret"); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+
+		call := waitForCall(t, calls, "sendMessage")
+		if chatID, _ := call.params["chat_id"].(string); chatID != "42" {
+			t.Errorf("Expected reply to chat 42, got %v", call.params["chat_id"])
+		}
+	})
+
+	t.Run("health check", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", rec.Code)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := b.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+}
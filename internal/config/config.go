@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"sub-cos-counter/internal/models"
 
 	"github.com/spf13/viper"
 )
@@ -19,6 +20,21 @@ type Config struct {
 
 	// Logging settings
 	Logging LoggingConfig `mapstructure:"logging"`
+
+	// Payment reminder settings
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+
+	// Automatic renewal settings
+	Renewal RenewalConfig `mapstructure:"renewal"`
+
+	// Conversation state settings
+	State StateConfig `mapstructure:"state"`
+
+	// Currency conversion settings
+	Exchange ExchangeConfig `mapstructure:"exchange"`
+
+	// HTTP API settings
+	API APIConfig `mapstructure:"api"`
 }
 
 type AppConfig struct {
@@ -29,13 +45,41 @@ type AppConfig struct {
 }
 
 type TelegramConfig struct {
-	BotToken    string `mapstructure:"bot_token"`
-	WebhookURL  string `mapstructure:"webhook_url"`
-	UseWebhook  bool   `mapstructure:"use_webhook"`
-	AllowedUser int64  `mapstructure:"allowed_user"` // Для персонального использования
+	BotToken      string       `mapstructure:"bot_token"`
+	APIURL        string       `mapstructure:"api_url"` // Пусто - официальный Bot API
+	WebhookURL    string       `mapstructure:"webhook_url"`
+	UseWebhook    bool         `mapstructure:"use_webhook"`
+	WebhookListen string       `mapstructure:"webhook_listen"` // Адрес HTTP-сервера для вебхука
+	WebhookSecret string       `mapstructure:"webhook_secret"` // Секрет для заголовка X-Telegram-Bot-Api-Secret-Token
+	AllowedUser   int64        `mapstructure:"allowed_user"`   // Для персонального использования
+	Access        []AccessRule `mapstructure:"access"`         // Список разрешённых пользователей и чатов
 }
 
+// Roles that can be granted by an access rule
+const (
+	RoleOwner  = "owner"
+	RoleViewer = "viewer"
+)
+
+// AccessRule grants a role to a Telegram user or to every member of a chat.
+// Exactly one of UserID and ChatID should be set. Viewers read the data of
+// Owner, or of AllowedUser when it is not set.
+type AccessRule struct {
+	UserID int64  `mapstructure:"user_id"`
+	ChatID int64  `mapstructure:"chat_id"`
+	Role   string `mapstructure:"role"`
+	Owner  int64  `mapstructure:"owner"` // Чьи подписки видит viewer
+}
+
+// Storage backends selected by DatabaseConfig.Driver
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type DatabaseConfig struct {
+	Driver          string `mapstructure:"driver"` // postgres, sqlite
+	Path            string `mapstructure:"path"`   // Файл базы SQLite
 	URL             string `mapstructure:"url"`
 	Host            string `mapstructure:"host"`
 	Port            int    `mapstructure:"port"`
@@ -46,6 +90,46 @@ type DatabaseConfig struct {
 	MaxConnections  int    `mapstructure:"max_connections"`
 	MaxIdleTime     int    `mapstructure:"max_idle_time"`
 	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"`
+	AutoMigrate     bool   `mapstructure:"auto_migrate"` // Применять миграции при запуске
+}
+
+type NotificationsConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	DaysBefore      int  `mapstructure:"days_before"`       // days before the payment date
+	TrialDaysBefore int  `mapstructure:"trial_days_before"` // days before a trial converts to paid
+	CheckInterval   int  `mapstructure:"check_interval"`    // minutes
+	SnoozeHours     int  `mapstructure:"snooze_hours"`
+}
+
+type RenewalConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	CheckInterval int  `mapstructure:"check_interval"` // minutes
+	GraceDays     int  `mapstructure:"grace_days"`     // days before an unpaid subscription is deactivated
+}
+
+type StateConfig struct {
+	Storage string `mapstructure:"storage"` // memory, database (postgres is the old name of database)
+	TTL     int    `mapstructure:"ttl"`     // minutes, 0 - never expire
+}
+
+type ExchangeConfig struct {
+	BaseCurrency    string `mapstructure:"base_currency"`    // default currency of converted totals
+	Provider        string `mapstructure:"provider"`         // none, fixture
+	FixturePath     string `mapstructure:"fixture_path"`     // JSON file for the fixture provider
+	RefreshInterval int    `mapstructure:"refresh_interval"` // minutes
+}
+
+type APIConfig struct {
+	Enabled bool       `mapstructure:"enabled"`
+	Listen  string     `mapstructure:"listen"` // Адрес HTTP-сервера API
+	Token   string     `mapstructure:"token"`  // Токен владельца персонального бота
+	Tokens  []APIToken `mapstructure:"tokens"` // Токены других пользователей
+}
+
+// APIToken lets scripts act on behalf of a Telegram user through the API.
+type APIToken struct {
+	Token  string `mapstructure:"token"`
+	UserID int64  `mapstructure:"user_id"`
 }
 
 type LoggingConfig struct {
@@ -56,6 +140,35 @@ type LoggingConfig struct {
 }
 
 func Load() (*Config, error) {
+	config, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate required fields
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// LoadDatabase reads the configuration like Load but validates only the
+// database settings, for commands that need no bot, like migrate.
+func LoadDatabase() (*Config, error) {
+	config, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateDatabase(config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+func read() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
@@ -72,15 +185,32 @@ func Load() (*Config, error) {
 
 	// Bind environment variables explicitly
 	viper.BindEnv("telegram.bot_token", "TELEGRAM_BOT_TOKEN")
+	viper.BindEnv("telegram.use_webhook", "TELEGRAM_USE_WEBHOOK")
+	viper.BindEnv("telegram.webhook_url", "TELEGRAM_WEBHOOK_URL")
+	viper.BindEnv("telegram.webhook_listen", "TELEGRAM_WEBHOOK_LISTEN")
+	viper.BindEnv("telegram.webhook_secret", "TELEGRAM_WEBHOOK_SECRET")
+	viper.BindEnv("telegram.allowed_user", "TELEGRAM_ALLOWED_USER")
+	viper.BindEnv("database.driver", "DATABASE_DRIVER")
+	viper.BindEnv("database.path", "DATABASE_PATH")
 	viper.BindEnv("database.url", "DATABASE_URL")
 	viper.BindEnv("database.host", "DATABASE_HOST")
 	viper.BindEnv("database.port", "DATABASE_PORT")
 	viper.BindEnv("database.name", "DATABASE_NAME")
 	viper.BindEnv("database.user", "DATABASE_USER")
 	viper.BindEnv("database.password", "DATABASE_PASSWORD")
+	viper.BindEnv("database.auto_migrate", "DATABASE_AUTO_MIGRATE")
 	viper.BindEnv("app.environment", "APP_ENVIRONMENT")
 	viper.BindEnv("app.debug", "APP_DEBUG")
 	viper.BindEnv("logging.level", "LOGGING_LEVEL")
+	viper.BindEnv("notifications.enabled", "NOTIFICATIONS_ENABLED")
+	viper.BindEnv("notifications.days_before", "NOTIFICATIONS_DAYS_BEFORE")
+	viper.BindEnv("notifications.trial_days_before", "NOTIFICATIONS_TRIAL_DAYS_BEFORE")
+	viper.BindEnv("exchange.base_currency", "EXCHANGE_BASE_CURRENCY")
+	viper.BindEnv("exchange.provider", "EXCHANGE_PROVIDER")
+	viper.BindEnv("exchange.fixture_path", "EXCHANGE_FIXTURE_PATH")
+	viper.BindEnv("api.enabled", "API_ENABLED")
+	viper.BindEnv("api.listen", "API_LISTEN")
+	viper.BindEnv("api.token", "API_TOKEN")
 
 	// Read config file (optional)
 	if err := viper.ReadInConfig(); err != nil {
@@ -100,11 +230,6 @@ func Load() (*Config, error) {
 		config.Database.URL = buildDatabaseURL(config.Database)
 	}
 
-	// Validate required fields
-	if err := validateConfig(&config); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
-	}
-
 	return &config, nil
 }
 
@@ -117,9 +242,12 @@ func setDefaults() {
 
 	// Telegram defaults
 	viper.SetDefault("telegram.use_webhook", false)
+	viper.SetDefault("telegram.webhook_listen", ":8080")
 	viper.SetDefault("telegram.allowed_user", 0)
 
 	// Database defaults
+	viper.SetDefault("database.driver", DriverPostgres)
+	viper.SetDefault("database.path", "sub_cos_counter.db")
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
 	viper.SetDefault("database.name", "sub_cos_counter")
@@ -128,6 +256,33 @@ func setDefaults() {
 	viper.SetDefault("database.max_connections", 25)
 	viper.SetDefault("database.max_idle_time", 15)     // minutes
 	viper.SetDefault("database.conn_max_lifetime", 60) // minutes
+	viper.SetDefault("database.auto_migrate", true)
+
+	// Notification defaults
+	viper.SetDefault("notifications.enabled", true)
+	viper.SetDefault("notifications.days_before", 3)
+	viper.SetDefault("notifications.trial_days_before", 3)
+	viper.SetDefault("notifications.check_interval", 60) // minutes
+	viper.SetDefault("notifications.snooze_hours", 24)
+
+	// Renewal defaults
+	viper.SetDefault("renewal.enabled", true)
+	viper.SetDefault("renewal.check_interval", 60) // minutes
+	viper.SetDefault("renewal.grace_days", 7)
+
+	// Conversation state defaults
+	viper.SetDefault("state.storage", "database")
+	viper.SetDefault("state.ttl", 1440) // minutes
+
+	// Exchange rate defaults
+	viper.SetDefault("exchange.base_currency", "USD")
+	viper.SetDefault("exchange.provider", "none")
+	viper.SetDefault("exchange.fixture_path", "")
+	viper.SetDefault("exchange.refresh_interval", 1440) // minutes
+
+	// API defaults
+	viper.SetDefault("api.enabled", false)
+	viper.SetDefault("api.listen", ":8081")
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -145,8 +300,114 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("telegram bot token is required")
 	}
 
-	if config.Database.URL == "" && config.Database.Host == "" {
-		return fmt.Errorf("database configuration is required")
+	if err := validateDatabase(config); err != nil {
+		return err
+	}
+
+	if config.Telegram.UseWebhook {
+		if config.Telegram.WebhookURL == "" {
+			return fmt.Errorf("telegram webhook_url is required in webhook mode")
+		}
+		if config.Telegram.WebhookSecret == "" {
+			return fmt.Errorf("telegram webhook_secret is required in webhook mode")
+		}
+	}
+
+	if config.Notifications.Enabled {
+		if config.Notifications.DaysBefore < 0 {
+			return fmt.Errorf("notifications days_before must not be negative")
+		}
+		if config.Notifications.TrialDaysBefore < 0 {
+			return fmt.Errorf("notifications trial_days_before must not be negative")
+		}
+		if config.Notifications.CheckInterval <= 0 {
+			return fmt.Errorf("notifications check_interval must be positive")
+		}
+		if config.Notifications.SnoozeHours <= 0 {
+			return fmt.Errorf("notifications snooze_hours must be positive")
+		}
+	}
+
+	if config.Renewal.Enabled {
+		if config.Renewal.CheckInterval <= 0 {
+			return fmt.Errorf("renewal check_interval must be positive")
+		}
+		if config.Renewal.GraceDays < 0 {
+			return fmt.Errorf("renewal grace_days must not be negative")
+		}
+	}
+
+	switch config.State.Storage {
+	case "memory", "database":
+	case "postgres":
+		config.State.Storage = "database"
+	default:
+		return fmt.Errorf("unknown state storage %q", config.State.Storage)
+	}
+	if config.State.TTL < 0 {
+		return fmt.Errorf("state ttl must not be negative")
+	}
+
+	if !models.Currency(config.Exchange.BaseCurrency).IsValid() {
+		return fmt.Errorf("unsupported exchange base_currency %q", config.Exchange.BaseCurrency)
+	}
+	switch config.Exchange.Provider {
+	case "none":
+	case "fixture":
+		if config.Exchange.FixturePath == "" {
+			return fmt.Errorf("exchange fixture_path is required for the fixture provider")
+		}
+		if config.Exchange.RefreshInterval <= 0 {
+			return fmt.Errorf("exchange refresh_interval must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown exchange provider %q", config.Exchange.Provider)
+	}
+
+	if config.API.Enabled {
+		if config.API.Token == "" && len(config.API.Tokens) == 0 {
+			return fmt.Errorf("api token is required when the api is enabled")
+		}
+		if config.API.Token != "" && !config.IsPersonalBot() {
+			return fmt.Errorf("api token requires telegram allowed_user, use api tokens otherwise")
+		}
+		for i, token := range config.API.Tokens {
+			if token.Token == "" || token.UserID == 0 {
+				return fmt.Errorf("api token %d must set token and user_id", i)
+			}
+		}
+	}
+
+	for i, rule := range config.Telegram.Access {
+		if (rule.UserID == 0) == (rule.ChatID == 0) {
+			return fmt.Errorf("telegram access rule %d must set exactly one of user_id and chat_id", i)
+		}
+		if rule.Role != RoleOwner && rule.Role != RoleViewer {
+			return fmt.Errorf("telegram access rule %d has unknown role %q", i, rule.Role)
+		}
+		if rule.Role == RoleOwner && rule.Owner != 0 {
+			return fmt.Errorf("telegram access rule %d sets owner, which only viewers have", i)
+		}
+		if rule.Role == RoleViewer && rule.Owner == 0 && !config.IsPersonalBot() {
+			return fmt.Errorf("telegram access rule %d must set the owner whose data the viewer reads", i)
+		}
+	}
+
+	return nil
+}
+
+func validateDatabase(config *Config) error {
+	switch config.Database.Driver {
+	case DriverPostgres:
+		if config.Database.URL == "" && config.Database.Host == "" {
+			return fmt.Errorf("database configuration is required")
+		}
+	case DriverSQLite:
+		if config.Database.Path == "" {
+			return fmt.Errorf("database path is required for sqlite")
+		}
+	default:
+		return fmt.Errorf("unknown database driver %q", config.Database.Driver)
 	}
 
 	return nil
@@ -176,3 +437,17 @@ func (c *Config) IsDebugMode() bool {
 func (c *Config) IsPersonalBot() bool {
 	return c.Telegram.AllowedUser != 0
 }
+
+// APITokens returns every API token with the user it acts for.
+func (c *Config) APITokens() []APIToken {
+	tokens := make([]APIToken, 0, len(c.API.Tokens)+1)
+	if c.API.Token != "" {
+		tokens = append(tokens, APIToken{Token: c.API.Token, UserID: c.Telegram.AllowedUser})
+	}
+	return append(tokens, c.API.Tokens...)
+}
+
+// HasAccessControl reports whether the bot is restricted to an allow-list.
+func (c *Config) HasAccessControl() bool {
+	return c.IsPersonalBot() || len(c.Telegram.Access) > 0
+}
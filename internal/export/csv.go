@@ -0,0 +1,122 @@
+// Package export writes user data as files for spreadsheets and accountants.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// DateLayout is the ISO 8601 date used in every exported file
+const DateLayout = "2006-01-02"
+
+// SubscriptionColumns is the header of the subscriptions file
+var SubscriptionColumns = []string{
+	"id", "name", "category", "cost", "currency", "period", "next_payment",
+	"auto_renewal", "active", "trial_ends_at", "trial_cost", "tags", "notes", "url", "created_at",
+}
+
+// PaymentColumns is the header of the payments file
+var PaymentColumns = []string{
+	"id", "paid_at", "subscription_id", "subscription", "category",
+	"amount", "currency", "status", "note",
+}
+
+// WriteSubscriptionsCSV writes one row per subscription. Amounts are plain
+// decimals in the precision of their currency, like Money.String() writes
+// them, periods are written as "<interval> <unit>", e.g. "3 month", and tags
+// are separated by commas.
+func WriteSubscriptionsCSV(w io.Writer, subscriptions []*models.Subscription) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(SubscriptionColumns); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, sub := range subscriptions {
+		trialEndsAt, trialCost := "", ""
+		if sub.TrialEndsAt != nil {
+			trialEndsAt = sub.TrialEndsAt.Format(DateLayout)
+			trialCost = sub.TrialCost.StringIn(sub.Currency)
+		}
+
+		err := writer.Write([]string{
+			strconv.Itoa(sub.ID),
+			sub.Name,
+			string(sub.Category),
+			sub.Cost.StringIn(sub.Currency),
+			string(sub.Currency),
+			FormatPeriod(sub.Period),
+			sub.NextPayment.Format(DateLayout),
+			strconv.FormatBool(sub.AutoRenewal),
+			strconv.FormatBool(sub.Active),
+			trialEndsAt,
+			trialCost,
+			strings.Join(sub.Tags, ", "),
+			sub.Notes,
+			sub.URL,
+			formatDate(sub.CreatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write subscription %d: %w", sub.ID, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WritePaymentsCSV writes one row per payment. The name and category of the
+// paid subscription are looked up in subscriptions by ID and left empty when
+// the subscription is missing.
+func WritePaymentsCSV(w io.Writer, payments []*models.Payment, subscriptions []*models.Subscription) error {
+	byID := make(map[int]*models.Subscription, len(subscriptions))
+	for _, sub := range subscriptions {
+		byID[sub.ID] = sub
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(PaymentColumns); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, payment := range payments {
+		name, category := "", ""
+		if sub, ok := byID[payment.SubscriptionID]; ok {
+			name, category = sub.Name, string(sub.Category)
+		}
+
+		err := writer.Write([]string{
+			strconv.Itoa(payment.ID),
+			formatDate(payment.PaidAt),
+			strconv.Itoa(payment.SubscriptionID),
+			name,
+			category,
+			payment.Amount.StringIn(payment.Currency),
+			string(payment.Currency),
+			string(payment.Status),
+			payment.Note,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write payment %d: %w", payment.ID, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// FormatPeriod writes a period the way models.ParseBillingPeriod reads it
+func FormatPeriod(period models.BillingPeriod) string {
+	return fmt.Sprintf("%d %s", period.Interval, period.Unit)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(DateLayout)
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"testing"
+	"time"
+)
+
+func readCSV(t *testing.T, data string) [][]string {
+	t.Helper()
+
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	return records
+}
+
+func TestWriteSubscriptionsCSV(t *testing.T) {
+	trialEndsAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	subscriptions := []*models.Subscription{
+		{
+			ID:          1,
+			Name:        "Netflix, Premium",
+			Category:    models.CategoryEntertainment,
+			Cost:        models.NewMoney(1599),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+			AutoRenewal: true,
+			Active:      true,
+			Tags:        []string{"family", "work"},
+			Notes:       "shared with Anna",
+			URL:         "https://netflix.com/cancel",
+			CreatedAt:   time.Date(2025, 1, 10, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			ID:          2,
+			Name:        "Kindle",
+			Category:    models.CategoryEducation,
+			Cost:        models.NewMoney(1500),
+			Currency:    "JPY",
+			Period:      models.Every(3, models.PeriodMonthly),
+			NextPayment: trialEndsAt,
+			TrialEndsAt: &trialEndsAt,
+			TrialCost:   models.NewMoney(100),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSubscriptionsCSV(&buf, subscriptions); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	records := readCSV(t, buf.String())
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(SubscriptionColumns, ",") {
+		t.Errorf("Unexpected header: %v", records[0])
+	}
+
+	expected := []string{"1", "Netflix, Premium", "entertainment", "15.99", "USD", "1 month", "2025-03-10", "true", "true", "", "",
+		"family, work", "shared with Anna", "https://netflix.com/cancel", "2025-01-10"}
+	if strings.Join(records[1], "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, records[1])
+	}
+
+	// Yen has no minor units, trials are written with their cost
+	expected = []string{"2", "Kindle", "education", "1500", "JPY", "3 month", "2025-04-01", "false", "false", "2025-04-01", "100", "", "", "", ""}
+	if strings.Join(records[2], "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, records[2])
+	}
+}
+
+func TestWritePaymentsCSV(t *testing.T) {
+	subscriptions := []*models.Subscription{{ID: 7, Name: "Spotify", Category: models.CategoryEntertainment}}
+	payments := []*models.Payment{
+		{
+			ID:             1,
+			SubscriptionID: 7,
+			Amount:         models.NewMoney(999),
+			Currency:       models.CurrencyEUR,
+			PaidAt:         time.Date(2025, 2, 3, 23, 15, 0, 0, time.UTC),
+			Status:         models.PaymentStatusCompleted,
+			Note:           "с НДС \"20%\"",
+		},
+		{
+			ID:             2,
+			SubscriptionID: 8,
+			Amount:         models.NewMoney(50000),
+			Currency:       models.CurrencyRUB,
+			PaidAt:         time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC),
+			Status:         models.PaymentStatusVoided,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePaymentsCSV(&buf, payments, subscriptions); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	records := readCSV(t, buf.String())
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+	}
+
+	expected := []string{"1", "2025-02-03", "7", "Spotify", "entertainment", "9.99", "EUR", "completed", "с НДС \"20%\""}
+	if strings.Join(records[1], "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, records[1])
+	}
+
+	// An unknown subscription leaves its name and category empty
+	expected = []string{"2", "2025-02-04", "8", "", "", "500.00", "RUB", "voided", ""}
+	if strings.Join(records[2], "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, records[2])
+	}
+}
+
+func TestFormatPeriodParsesBack(t *testing.T) {
+	for _, period := range []models.BillingPeriod{
+		models.Every(14, models.PeriodDaily),
+		models.Every(2, models.PeriodWeekly),
+		models.Every(1, models.PeriodMonthly),
+		models.Every(1, models.PeriodQuarterly),
+		models.Every(1, models.PeriodYearly),
+	} {
+		parsed, err := models.ParseBillingPeriod(FormatPeriod(period))
+		if err != nil || parsed != period {
+			t.Errorf("Expected %v to parse back, got %v (%v)", period, parsed, err)
+		}
+	}
+}
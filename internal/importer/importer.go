@@ -0,0 +1,247 @@
+// Package importer reads subscriptions from CSV and JSON files, in the format
+// written by the export package or typed by hand.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sub-cos-counter/internal/export"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// MaxRows limits the number of subscriptions in one file
+const MaxRows = 500
+
+// requiredColumns must be present in every file, other columns are optional
+// and unknown ones, like id of an exported file, are ignored
+var requiredColumns = []string{"name", "cost", "currency", "period", "next_payment"}
+
+// ReadCSV reads subscriptions from a CSV file with a header row. Both comma
+// and semicolon separated files are accepted. An error is returned only when
+// the file as a whole can't be read, problems of single rows are reported in
+// their ImportRow.
+func ReadCSV(r io.Reader) ([]models.ImportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // spreadsheets often start with a BOM
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if header, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	if err := checkColumns(header); err != nil {
+		return nil, err
+	}
+
+	var rows []models.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("too many subscriptions, at most %d are allowed", MaxRows)
+		}
+
+		fields := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				fields[column] = strings.TrimSpace(record[i])
+			}
+		}
+
+		req, err := parseRow(fields)
+		rows = append(rows, models.ImportRow{Line: line, Request: req, Err: err})
+	}
+
+	return rows, nil
+}
+
+// ReadJSON reads subscriptions from a JSON array of objects that have the
+// same keys as the CSV columns. Amounts may be numbers or strings.
+func ReadJSON(r io.Reader) ([]models.ImportRow, error) {
+	var objects []map[string]any
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if len(objects) > MaxRows {
+		return nil, fmt.Errorf("too many subscriptions, at most %d are allowed", MaxRows)
+	}
+
+	rows := make([]models.ImportRow, 0, len(objects))
+	for i, object := range objects {
+		fields := make(map[string]string, len(object))
+		for key, value := range object {
+			fields[strings.ToLower(key)] = jsonString(value)
+		}
+
+		row := models.ImportRow{Line: i + 1}
+		if err := checkColumns(keys(fields)); err != nil {
+			row.Err = err
+		} else {
+			row.Request, row.Err = parseRow(fields)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseRow(fields map[string]string) (*models.CreateSubscriptionRequest, error) {
+	if active, ok := fields["active"]; ok && active != "" {
+		if isActive, err := strconv.ParseBool(active); err == nil && !isActive {
+			return nil, fmt.Errorf("subscription is cancelled")
+		}
+	}
+
+	currency, ok := models.ParseCurrency(fields["currency"])
+	if !ok {
+		return nil, fmt.Errorf("unsupported currency: %q", fields["currency"])
+	}
+
+	cost, err := models.ParseMoneyIn(fields["cost"], currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cost: %w", err)
+	}
+
+	period, err := models.ParseBillingPeriod(fields["period"])
+	if err != nil {
+		return nil, err
+	}
+
+	nextPayment, err := parseDate(fields["next_payment"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid next payment date: %w", err)
+	}
+
+	req := &models.CreateSubscriptionRequest{
+		Name:        fields["name"],
+		Cost:        cost,
+		Currency:    currency,
+		Period:      period,
+		NextPayment: nextPayment,
+		Category:    models.CategoryOther,
+	}
+
+	if category := fields["category"]; category != "" {
+		req.Category = models.Category(strings.ToLower(category))
+	}
+
+	if autoRenewal := fields["auto_renewal"]; autoRenewal != "" {
+		if req.AutoRenewal, err = strconv.ParseBool(autoRenewal); err != nil {
+			return nil, fmt.Errorf("invalid auto renewal: %q", autoRenewal)
+		}
+	}
+
+	if trialEndsAt := fields["trial_ends_at"]; trialEndsAt != "" {
+		date, err := parseDate(trialEndsAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trial end date: %w", err)
+		}
+		req.TrialEndsAt = &date
+	}
+
+	if trialCost := fields["trial_cost"]; trialCost != "" {
+		if req.TrialCost, err = models.ParseMoneyIn(trialCost, currency); err != nil {
+			return nil, fmt.Errorf("invalid trial cost: %w", err)
+		}
+	}
+
+	req.Tags = models.ParseTags(fields["tags"])
+	req.Notes = fields["notes"]
+	req.URL = fields["url"]
+
+	return req, nil
+}
+
+// parseDate accepts ISO dates of exported files and dates typed in the bot
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{export.DateLayout, "02.01.2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or DD.MM.YYYY, got %q", value)
+}
+
+func checkColumns(columns []string) error {
+	present := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		present[column] = true
+	}
+
+	var missing []string
+	for _, column := range requiredColumns {
+		if !present[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func jsonString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		// Lists like tags are read like their comma separated CSV column
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, jsonString(item))
+		}
+		return strings.Join(items, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func keys(fields map[string]string) []string {
+	result := make([]string, 0, len(fields))
+	for key := range fields {
+		result = append(result, key)
+	}
+	return result
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
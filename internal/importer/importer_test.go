@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sub-cos-counter/internal/export"
+	"sub-cos-counter/internal/models"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	data := "\xef\xbb\xbfName;Cost;Currency;Period;Next_Payment;Category;Auto_Renewal\n" +
+		"Netflix;15,99;usd;1 month;2025-03-10;entertainment;true\n" +
+		"\n" +
+		"Spotify;abc;EUR;1 month;10.03.2025;;\n" +
+		"Gym;30;EUR;2 недели;10.03.2025;;\n"
+
+	rows, err := ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	netflix := rows[0]
+	if netflix.Err != nil || netflix.Line != 2 {
+		t.Fatalf("Unexpected first row: %+v", netflix)
+	}
+	req := netflix.Request
+	if req.Name != "Netflix" || req.Cost != models.NewMoney(1599) || req.Currency != models.CurrencyUSD ||
+		req.Period != models.Every(1, models.PeriodMonthly) || !req.NextPayment.Equal(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)) ||
+		req.Category != models.CategoryEntertainment || !req.AutoRenewal {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	// Blank lines are skipped but still counted
+	if rows[1].Err == nil || rows[1].Line != 4 {
+		t.Errorf("Expected an error on line 4, got %+v", rows[1])
+	}
+	if rows[2].Err != nil || rows[2].Request.Category != models.CategoryOther || rows[2].Request.Period != models.Every(2, models.PeriodWeekly) {
+		t.Errorf("Unexpected last row: %+v", rows[2])
+	}
+}
+
+func TestReadCSVRejectsMissingColumns(t *testing.T) {
+	if _, err := ReadCSV(strings.NewReader("name,cost\nNetflix,15.99\n")); err == nil {
+		t.Error("Expected an error for missing columns")
+	}
+}
+
+func TestReadCSVReadsExportedFile(t *testing.T) {
+	trialEndsAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	subscriptions := []*models.Subscription{
+		{
+			ID: 1, Name: "Kindle", Category: models.CategoryEducation, Cost: models.NewMoney(499), Currency: models.CurrencyEUR,
+			Period: models.Every(1, models.PeriodQuarterly), NextPayment: trialEndsAt, Active: true,
+			TrialEndsAt: &trialEndsAt, TrialCost: models.NewMoney(99), Tags: []string{"cancel soon", "family"}, URL: "https://kindle.com",
+		},
+		{
+			ID: 2, Name: "Old", Category: models.CategoryOther, Cost: models.NewMoney(100), Currency: models.CurrencyEUR,
+			Period: models.Every(1, models.PeriodYearly), NextPayment: trialEndsAt,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteSubscriptionsCSV(&buf, subscriptions); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	rows, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read exported CSV: %v", err)
+	}
+
+	req := rows[0].Request
+	if rows[0].Err != nil || req.Name != "Kindle" || req.Period != models.Every(1, models.PeriodQuarterly) ||
+		req.TrialEndsAt == nil || !req.TrialEndsAt.Equal(trialEndsAt) || req.TrialCost != models.NewMoney(99) ||
+		!slices.Equal(req.Tags, []string{"cancel soon", "family"}) || req.URL != "https://kindle.com" {
+		t.Errorf("Unexpected round trip: %+v (%v)", req, rows[0].Err)
+	}
+
+	// Cancelled subscriptions are not brought back
+	if rows[1].Err == nil {
+		t.Error("Expected cancelled subscription to be skipped")
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	data := `[
+		{"name": "Netflix", "cost": 15.99, "currency": "USD", "period": "1 month", "next_payment": "2025-03-10", "auto_renewal": true, "tags": ["Family", "work"]},
+		{"name": "Spotify", "cost": "9.99", "currency": "EUR", "next_payment": "2025-03-10"}
+	]`
+
+	rows, err := ReadJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read JSON: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	if rows[0].Err != nil || rows[0].Request.Cost != models.NewMoney(1599) || !rows[0].Request.AutoRenewal ||
+		!slices.Equal(rows[0].Request.Tags, []string{"family", "work"}) {
+		t.Errorf("Unexpected first row: %+v (%v)", rows[0].Request, rows[0].Err)
+	}
+	if rows[1].Err == nil || rows[1].Line != 2 {
+		t.Errorf("Expected missing period to be reported on row 2, got %+v", rows[1])
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`{"name": "Netflix"}`)); err == nil {
+		t.Error("Expected an error for an object instead of an array")
+	}
+}
@@ -0,0 +1,325 @@
+// Package migrate brings a database to the schema version of the binary. It
+// applies the numbered migrations of package migrations in order and keeps
+// the applied versions in the schema_version table.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Driver runs migrations on one kind of database. Every method but Lock is
+// called while the lock is held.
+type Driver interface {
+	// Lock waits until no other process migrates the database and keeps
+	// them waiting until unlock is called.
+	Lock(ctx context.Context) (unlock func(), err error)
+	// Versions returns the applied versions, none before the first migration
+	Versions(ctx context.Context) ([]int, error)
+	// Tables returns the columns of every table but schema_version as
+	// "name type", by table name. It is empty for a new database.
+	Tables(ctx context.Context) (map[string][]string, error)
+	// TablesOf returns the tables the migration creates in an empty database,
+	// like Tables, and leaves the database unchanged.
+	TablesOf(ctx context.Context, m Migration) (map[string][]string, error)
+	// Up runs the migration and records its version in one transaction. The
+	// owner is the only row of the temporary table migration_owner(user_id)
+	// while the migration runs, the table is empty when owner is 0.
+	Up(ctx context.Context, m Migration, owner int64) error
+	// Down reverts the migration and removes its version in one transaction
+	Down(ctx context.Context, m Migration) error
+	// Baseline records the version as applied without running the migration
+	Baseline(ctx context.Context, m Migration) error
+}
+
+// Load reads NNNN_name.up.sql and NNNN_name.down.sql files. Versions must
+// start at 1 and have no gaps, and every migration needs both files.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both up and down files", m)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Runner applies migrations through a driver
+type Runner struct {
+	driver     Driver
+	migrations []Migration
+	owner      int64
+	logf       func(format string, args ...any)
+}
+
+// New creates a runner, logf receives progress and the SQL of dry runs
+func New(driver Driver, migrations []Migration, logf func(format string, args ...any)) *Runner {
+	return &Runner{driver: driver, migrations: migrations, logf: logf}
+}
+
+// SetOwner gives the migrations the user that data created before multi-user
+// support belongs to. Without an owner a migration that needs one fails while
+// there is such data.
+func (r *Runner) SetOwner(userID int64) {
+	r.owner = userID
+}
+
+// Status describes the version of a database
+type Status struct {
+	Current int
+	Latest  int
+	// Baseline is set for a database created before the runner whose tables
+	// are exactly those of the first migration. The migration is recorded
+	// without running it.
+	Baseline bool
+	Pending  []Migration
+}
+
+// Status returns the version of the database and the migrations it lacks
+func (r *Runner) Status(ctx context.Context) (*Status, error) {
+	unlock, err := r.driver.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return r.status(ctx)
+}
+
+func (r *Runner) status(ctx context.Context) (*Status, error) {
+	versions, err := r.driver.Versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Latest: len(r.migrations)}
+	for _, version := range versions {
+		if version > status.Current {
+			status.Current = version
+		}
+	}
+	if status.Current > status.Latest {
+		return nil, fmt.Errorf("database is at version %d, newer than the latest migration %d of this binary",
+			status.Current, status.Latest)
+	}
+
+	if len(versions) == 0 && len(r.migrations) > 0 {
+		status.Baseline, err = r.matchesFirst(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if status.Baseline {
+			status.Current = 1
+		}
+	}
+	status.Pending = r.migrations[status.Current:]
+
+	return status, nil
+}
+
+// matchesFirst reports whether a database without schema versions has the
+// tables of the first migration. Tables of any other schema can't be told
+// apart from a partly migrated database, so they are refused instead of
+// guessing which migrations they lack.
+func (r *Runner) matchesFirst(ctx context.Context) (bool, error) {
+	tables, err := r.driver.Tables(ctx)
+	if err != nil || len(tables) == 0 {
+		return false, err
+	}
+
+	first := r.migrations[0]
+	expected, err := r.driver.TablesOf(ctx, first)
+	if err != nil {
+		return false, fmt.Errorf("failed to get tables of migration %s: %w", first, err)
+	}
+	if diff := diffTables(tables, expected); diff != "" {
+		return false, fmt.Errorf("database has tables but no schema version, and they differ from migration %s (%s): "+
+			"bring it to that schema or to an empty database first", first, diff)
+	}
+
+	return true, nil
+}
+
+// diffTables describes the first difference between the tables, empty when
+// they have the same columns
+func diffTables(tables, expected map[string][]string) string {
+	names := make([]string, 0, len(tables)+len(expected))
+	for name := range tables {
+		names = append(names, name)
+	}
+	for name := range expected {
+		if _, ok := tables[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		columns, ok := tables[name]
+		want, wanted := expected[name]
+		switch {
+		case !wanted:
+			return "unexpected table " + name
+		case !ok:
+			return "missing table " + name
+		}
+
+		for _, column := range columns {
+			if !slices.Contains(want, column) {
+				return fmt.Sprintf("unexpected column %s.%s", name, column)
+			}
+		}
+		for _, column := range want {
+			if !slices.Contains(columns, column) {
+				return fmt.Sprintf("missing column %s.%s", name, column)
+			}
+		}
+	}
+
+	return ""
+}
+
+// Up applies every pending migration in order and returns them. It stops at
+// the first failure, the failed migration is rolled back completely. A dry
+// run logs the SQL of the pending migrations without changing anything.
+func (r *Runner) Up(ctx context.Context, dryRun bool) ([]Migration, error) {
+	unlock, err := r.driver.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	status, err := r.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Baseline {
+		first := r.migrations[0]
+		r.logf("Database has the tables of %s but no schema version, recording it as applied", first)
+		if !dryRun {
+			if err := r.driver.Baseline(ctx, first); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var applied []Migration
+	for _, m := range status.Pending {
+		if dryRun {
+			r.logf("Would apply %s:\n%s", m, m.Up)
+			applied = append(applied, m)
+			continue
+		}
+
+		if err := r.driver.Up(ctx, m, r.owner); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %s: %w", m, err)
+		}
+		r.logf("Applied migration %s", m)
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last steps migrations, newest first, and returns them.
+// A dry run logs their SQL without changing anything.
+func (r *Runner) Down(ctx context.Context, steps int, dryRun bool) ([]Migration, error) {
+	unlock, err := r.driver.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	status, err := r.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status.Baseline {
+		return nil, fmt.Errorf("database has no schema version, run the migrations up first")
+	}
+	if steps > status.Current {
+		steps = status.Current
+	}
+
+	var reverted []Migration
+	for version := status.Current; version > status.Current-steps; version-- {
+		m := r.migrations[version-1]
+		if dryRun {
+			r.logf("Would revert %s:\n%s", m, m.Down)
+			reverted = append(reverted, m)
+			continue
+		}
+
+		if err := r.driver.Down(ctx, m); err != nil {
+			return reverted, fmt.Errorf("failed to revert migration %s: %w", m, err)
+		}
+		r.logf("Reverted migration %s", m)
+		reverted = append(reverted, m)
+	}
+
+	return reverted, nil
+}
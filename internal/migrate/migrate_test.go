@@ -0,0 +1,405 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sub-cos-counter/internal/migrate"
+	"sub-cos-counter/internal/repository/sqlite"
+	"sub-cos-counter/migrations"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+var testMigrations = fstest.MapFS{
+	"0001_notes.up.sql":     file(`CREATE TABLE notes (id INTEGER PRIMARY KEY, text TEXT NOT NULL);`),
+	"0001_notes.down.sql":   file(`DROP TABLE notes;`),
+	"0002_welcome.up.sql":   file(`INSERT INTO notes (text) VALUES ('welcome');`),
+	"0002_welcome.down.sql": file(`DELETE FROM notes WHERE text = 'welcome';`),
+	"README.md":             file(`not a migration`),
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newRunner(t *testing.T, db *sql.DB, fsys fstest.MapFS) *migrate.Runner {
+	t.Helper()
+
+	migrations, err := migrate.Load(fsys)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrate.New(migrate.NewSQLiteDriver(db), migrations, t.Logf)
+}
+
+func countNotes(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM notes`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count notes: %v", err)
+	}
+	return count
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := migrate.Load(testMigrations)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[1].String() != "0002_welcome" || !strings.Contains(migrations[1].Down, "DELETE") {
+		t.Errorf("Unexpected second migration: %+v", migrations[1])
+	}
+
+	broken := map[string]fstest.MapFS{
+		"missing down": {
+			"0001_notes.up.sql": file(`SELECT 1;`),
+		},
+		"gap": {
+			"0001_notes.up.sql":   file(`SELECT 1;`),
+			"0001_notes.down.sql": file(`SELECT 1;`),
+			"0003_other.up.sql":   file(`SELECT 1;`),
+			"0003_other.down.sql": file(`SELECT 1;`),
+		},
+		"two names": {
+			"0001_notes.up.sql":   file(`SELECT 1;`),
+			"0001_other.down.sql": file(`SELECT 1;`),
+		},
+	}
+	for name, fsys := range broken {
+		if _, err := migrate.Load(fsys); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestRunnerUpAndDown(t *testing.T) {
+	db := openDB(t)
+	runner := newRunner(t, db, testMigrations)
+	ctx := context.Background()
+
+	applied, err := runner.Up(ctx, false)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 2 || countNotes(t, db) != 1 {
+		t.Fatalf("Expected both migrations applied, got %d and %d notes", len(applied), countNotes(t, db))
+	}
+
+	applied, err = runner.Up(ctx, false)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("Expected nothing to apply, got %d: %v", len(applied), err)
+	}
+
+	reverted, err := runner.Down(ctx, 1, false)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("Expected migration 2 reverted, got %v: %v", reverted, err)
+	}
+	if countNotes(t, db) != 0 {
+		t.Errorf("Expected the welcome note removed")
+	}
+
+	status, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Current != 1 || len(status.Pending) != 1 {
+		t.Errorf("Expected version 1 with one pending migration, got %+v", status)
+	}
+}
+
+func TestRunnerDryRunChangesNothing(t *testing.T) {
+	db := openDB(t)
+	runner := newRunner(t, db, testMigrations)
+	ctx := context.Background()
+
+	applied, err := runner.Up(ctx, true)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Expected 2 migrations in the dry run, got %d: %v", len(applied), err)
+	}
+
+	status, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Current != 0 {
+		t.Errorf("Expected the dry run to leave version 0, got %d", status.Current)
+	}
+}
+
+func TestRunnerRollsBackFailedMigration(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	broken := fstest.MapFS{}
+	for name, f := range testMigrations {
+		broken[name] = f
+	}
+	broken["0002_welcome.up.sql"] = file(`INSERT INTO notes (text) VALUES ('welcome'); INSERT INTO missing VALUES (1);`)
+
+	if _, err := newRunner(t, db, broken).Up(ctx, false); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if countNotes(t, db) != 0 {
+		t.Errorf("Expected the failed migration rolled back")
+	}
+
+	status, err := newRunner(t, db, testMigrations).Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Current != 1 {
+		t.Errorf("Expected version 1 after the failure, got %d", status.Current)
+	}
+}
+
+func TestRunnerRejectsNewerDatabase(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	if _, err := newRunner(t, db, testMigrations).Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	older := fstest.MapFS{
+		"0001_notes.up.sql":   testMigrations["0001_notes.up.sql"],
+		"0001_notes.down.sql": testMigrations["0001_notes.down.sql"],
+	}
+	if _, err := newRunner(t, db, older).Up(ctx, false); err == nil {
+		t.Error("Expected an error for a database newer than the migrations")
+	}
+}
+
+// Test that a database created before the runner keeps its tables
+func TestRunnerBaselinesExistingTables(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	runner, err := migrate.SQLite(db, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	// The tables of the first migration, created without the runner
+	all, err := migrate.Load(migrations.SQLite())
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := db.Exec(all[0].Up); err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	status, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Baseline || status.Current != 1 {
+		t.Fatalf("Expected a baseline at version 1, got %+v", status)
+	}
+
+	if _, err := runner.Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	status, err = runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Baseline || status.Current != status.Latest {
+		t.Errorf("Expected the latest version without baseline, got %+v", status)
+	}
+}
+
+// Test that tables other than those of the first migration are not taken for it
+func TestRunnerRefusesUnknownTables(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	if _, err := db.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY, text TEXT NOT NULL, author TEXT)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	runner := newRunner(t, db, testMigrations)
+	if _, err := runner.Up(ctx, false); err == nil || !strings.Contains(err.Error(), "notes.author") {
+		t.Fatalf("Expected the extra column to be reported, got %v", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE notes DROP COLUMN author`); err != nil {
+		t.Fatalf("Failed to drop column: %v", err)
+	}
+
+	applied, err := runner.Up(ctx, false)
+	if err != nil || len(applied) != 1 || countNotes(t, db) != 1 {
+		t.Errorf("Expected the matching table taken as version 1, got %v: %v", applied, err)
+	}
+}
+
+// Test that two instances starting at once apply every migration once
+func TestRunnerConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		db, err := sqlite.Open(ctx, path)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		runner := newRunner(t, db, testMigrations)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runner.Up(ctx, false); err != nil {
+				errs <- fmt.Errorf("instance failed: %w", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	check, err := sqlite.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer check.Close()
+	if count := countNotes(t, check); count != 1 {
+		t.Errorf("Expected the welcome note once, got %d", count)
+	}
+}
+
+func TestEmbeddedSQLiteMigrationsRoundTrip(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	runner, err := migrate.SQLite(db, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	applied, err := runner.Up(ctx, false)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if _, err := runner.Down(ctx, len(applied), false); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')`).Scan(&tables); err != nil {
+		t.Fatalf("Failed to count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("Expected every table dropped, %d left", tables)
+	}
+
+	if _, err := runner.Up(ctx, false); err != nil {
+		t.Fatalf("Up after down failed: %v", err)
+	}
+}
+
+// Test that users who had subscriptions before categories get the defaults
+func TestEmbeddedSQLiteCategoriesSeedExistingUsers(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	all, err := migrate.Load(migrations.SQLite())
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	first := migrate.New(migrate.NewSQLiteDriver(db), all[:1], t.Logf)
+	if _, err := first.Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO subscriptions (user_id, name, cost, currency, next_payment, category, created_at, updated_at)
+		VALUES (7, 'Netflix', 999, 'USD', '2025-03-10', 'work', 0, 0)`)
+	if err != nil {
+		t.Fatalf("Failed to create subscriptions: %v", err)
+	}
+
+	if _, err := migrate.New(migrate.NewSQLiteDriver(db), all, t.Logf).Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	var users, count int
+	if err := db.QueryRow(`SELECT COUNT(DISTINCT user_id), COUNT(*) FROM categories`).Scan(&users, &count); err != nil {
+		t.Fatalf("Failed to count categories: %v", err)
+	}
+	if users != 1 || count != 5 {
+		t.Errorf("Expected the five defaults of one user, got %d categories of %d users", count, users)
+	}
+}
+
+// Test that rows created before multi-user support go to the configured
+// owner, and that without one the migration refuses to leave them unowned
+func TestEmbeddedSQLiteMigrationsAssignOwner(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	all, err := migrate.Load(migrations.SQLite())
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrate.New(migrate.NewSQLiteDriver(db), all[:1], t.Logf).Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO subscriptions (user_id, name, cost, currency, next_payment, category, created_at, updated_at)
+		VALUES (NULL, 'Legacy', 100, 'USD', '2025-03-10', 'other', 0, 0);
+		INSERT INTO payments (user_id, subscription_id, amount, currency, paid_at, created_at)
+		VALUES (NULL, 1, 100, 'USD', 0, 0)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy rows: %v", err)
+	}
+
+	runner := migrate.New(migrate.NewSQLiteDriver(db), all, t.Logf)
+	if _, err := runner.Up(ctx, false); err == nil {
+		t.Fatal("Expected the migration to refuse rows without an owner")
+	}
+
+	runner.SetOwner(7)
+	if _, err := runner.Up(ctx, false); err != nil {
+		t.Fatalf("Up with an owner failed: %v", err)
+	}
+
+	var subscriptionOwner, paymentOwner int64
+	if err := db.QueryRow(`SELECT user_id FROM subscriptions`).Scan(&subscriptionOwner); err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if err := db.QueryRow(`SELECT user_id FROM payments`).Scan(&paymentOwner); err != nil {
+		t.Fatalf("Failed to get payment: %v", err)
+	}
+	if subscriptionOwner != 7 || paymentOwner != 7 {
+		t.Errorf("Expected the rows assigned to user 7, got %d and %d", subscriptionOwner, paymentOwner)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO subscriptions (user_id, name, cost, currency, next_payment, category, created_at, updated_at)
+		VALUES (NULL, 'Orphan', 100, 'USD', '2025-03-10', 'other', 0, 0)`)
+	if err == nil {
+		t.Error("Expected a subscription without an owner to be refused")
+	}
+}
@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/migrations"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey identifies the advisory lock of the runner, any constant unique to this application
+const lockKey int64 = 0x5375624d69677261 // "SubMigra"
+
+const postgresVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+	    version INTEGER PRIMARY KEY,
+	    name VARCHAR(255) NOT NULL,
+	    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`
+
+// PostgresDriver runs migrations on PostgreSQL. The lock is a session
+// advisory lock, held by one connection for the whole run.
+type PostgresDriver struct {
+	pool *pgxpool.Pool
+	conn *pgxpool.Conn
+}
+
+func NewPostgresDriver(pool *pgxpool.Pool) *PostgresDriver {
+	return &PostgresDriver{pool: pool}
+}
+
+// Postgres returns a runner of the embedded PostgreSQL migrations
+func Postgres(pool *pgxpool.Pool, logf func(format string, args ...any)) (*Runner, error) {
+	all, err := Load(migrations.Postgres())
+	if err != nil {
+		return nil, err
+	}
+	return New(NewPostgresDriver(pool), all, logf), nil
+}
+
+func (d *PostgresDriver) Lock(ctx context.Context) (func(), error) {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	d.conn = conn
+
+	return func() {
+		// A lost unlock is released with the connection
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+		d.conn = nil
+	}, nil
+}
+
+func (d *PostgresDriver) Versions(ctx context.Context) ([]int, error) {
+	exists, err := d.tableExists(ctx, "schema_version")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := d.conn.Query(ctx, `SELECT version FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// postgresTables lists the columns of the tables in a schema, the format %s
+// is replaced by the OID of the schema
+const postgresTables = `
+	SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod)
+	FROM pg_class c
+	JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+	WHERE c.relnamespace = %s AND c.relkind = 'r' AND c.relname <> 'schema_version'
+	ORDER BY c.relname, a.attnum`
+
+// Tables looks in the first schema of the search path, where the
+// repositories create and find their tables
+func (d *PostgresDriver) Tables(ctx context.Context) (map[string][]string, error) {
+	return postgresColumns(ctx, d.conn,
+		fmt.Sprintf(postgresTables, `(SELECT oid FROM pg_namespace WHERE nspname = current_schema())`))
+}
+
+// TablesOf runs the migration with only the temporary schema in the search
+// path, so its tables don't clash with the existing ones, and rolls it back.
+func (d *PostgresDriver) TablesOf(ctx context.Context, m Migration) (map[string][]string, error) {
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SET LOCAL search_path TO pg_temp`); err != nil {
+		return nil, fmt.Errorf("failed to set search path: %w", err)
+	}
+	if _, err := tx.Exec(ctx, m.Up); err != nil {
+		return nil, err
+	}
+
+	return postgresColumns(ctx, tx, fmt.Sprintf(postgresTables, `pg_my_temp_schema()`))
+}
+
+func (d *PostgresDriver) Up(ctx context.Context, m Migration, owner int64) error {
+	return d.change(ctx, m.Up, owner, `INSERT INTO schema_version (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+}
+
+func (d *PostgresDriver) Down(ctx context.Context, m Migration) error {
+	return d.change(ctx, m.Down, 0, `DELETE FROM schema_version WHERE version = $1`, m.Version)
+}
+
+func (d *PostgresDriver) Baseline(ctx context.Context, m Migration) error {
+	return d.change(ctx, "", 0, `INSERT INTO schema_version (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+}
+
+// change runs the SQL of a migration and records it in one transaction
+func (d *PostgresDriver) change(ctx context.Context, sql string, owner int64, record string, args ...any) error {
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, postgresVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema_version: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE migration_owner (user_id BIGINT NOT NULL) ON COMMIT DROP`); err != nil {
+		return fmt.Errorf("failed to create migration_owner: %w", err)
+	}
+	if owner != 0 {
+		if _, err := tx.Exec(ctx, `INSERT INTO migration_owner (user_id) VALUES ($1)`, owner); err != nil {
+			return fmt.Errorf("failed to set migration owner: %w", err)
+		}
+	}
+	if sql != "" {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func postgresColumns(ctx context.Context, q interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}, query string) (map[string][]string, error) {
+	rows, err := q.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := make(map[string][]string)
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		tables[table] = append(tables[table], column+" "+dataType)
+	}
+
+	return tables, rows.Err()
+}
+
+// tableExists looks the table up in the search path, like the queries of the repositories
+func (d *PostgresDriver) tableExists(ctx context.Context, table string) (bool, error) {
+	var exists bool
+	if err := d.conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,111 @@
+package migrate_test
+
+import (
+	"context"
+	"sub-cos-counter/internal/migrate"
+	"sub-cos-counter/internal/testdb"
+	"sub-cos-counter/migrations"
+	"testing"
+)
+
+// Test that a database created from the original schema.sql, before the
+// runner, is upgraded by every later migration
+func TestPostgresUpgradesBaselineSchema(t *testing.T) {
+	pool := testdb.Empty(t)
+	ctx := context.Background()
+
+	all, err := migrate.Load(migrations.Postgres())
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := pool.Exec(ctx, all[0].Up); err != nil {
+		t.Fatalf("Failed to create baseline tables: %v", err)
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO subscriptions (name, cost, currency, period_days, next_payment, category) VALUES
+		    ('Netflix', 999, 'USD', 30, '2025-03-10', 'entertainment'),
+		    ('Gym', 5000, 'RUB', 14, '2025-03-12', 'other'),
+		    ('Domain', 1500, 'USD', 365, '2025-11-02', 'work')`)
+	if err != nil {
+		t.Fatalf("Failed to create subscriptions: %v", err)
+	}
+
+	runner, err := migrate.Postgres(pool, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	status, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Baseline || status.Current != 1 {
+		t.Fatalf("Expected a baseline at version 1, got %+v", status)
+	}
+
+	// The subscriptions need an owner before they can be upgraded
+	if _, err := runner.Up(ctx, false); err == nil {
+		t.Fatal("Expected the migration to refuse subscriptions without an owner")
+	}
+	runner.SetOwner(42)
+	if _, err := runner.Up(ctx, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	expected := map[string]struct {
+		unit     string
+		interval int
+		anchor   int
+	}{
+		"Netflix": {"month", 1, 10},
+		"Gym":     {"week", 2, 0},
+		"Domain":  {"year", 1, 2},
+	}
+	rows, err := pool.Query(ctx, `SELECT name, user_id, period_unit, period_interval, anchor_day FROM subscriptions`)
+	if err != nil {
+		t.Fatalf("Failed to get subscriptions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, unit string
+		var userID int64
+		var interval, anchor int
+		if err := rows.Scan(&name, &userID, &unit, &interval, &anchor); err != nil {
+			t.Fatalf("Failed to scan subscription: %v", err)
+		}
+		if userID != 42 {
+			t.Errorf("%s: expected owner 42, got %d", name, userID)
+		}
+		if want := expected[name]; unit != want.unit || interval != want.interval || anchor != want.anchor {
+			t.Errorf("%s: expected period %+v, got %s %d %d", name, want, unit, interval, anchor)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Failed to read subscriptions: %v", err)
+	}
+
+	var prices int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM subscription_price_history WHERE effective_to IS NULL`).Scan(&prices); err != nil {
+		t.Fatalf("Failed to count prices: %v", err)
+	}
+	if prices != 3 {
+		t.Errorf("Expected the current price of every subscription, got %d", prices)
+	}
+}
+
+// Test that tables which are not exactly the first migration are refused
+func TestPostgresRefusesUnknownSchema(t *testing.T) {
+	pool := testdb.Empty(t)
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `CREATE TABLE subscriptions (id SERIAL PRIMARY KEY, user_id BIGINT)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	runner, err := migrate.Postgres(pool, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := runner.Up(ctx, false); err == nil {
+		t.Error("Expected an error for tables that differ from the first migration")
+	}
+}
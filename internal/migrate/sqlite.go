@@ -0,0 +1,202 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sub-cos-counter/migrations"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+	    version INTEGER PRIMARY KEY,
+	    name TEXT NOT NULL,
+	    applied_at INTEGER NOT NULL
+	)`
+
+// SQLiteDriver runs migrations on SQLite. The lock is the write lock of the
+// database: the whole run is one transaction of a dedicated connection and
+// every migration a savepoint in it.
+type SQLiteDriver struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func NewSQLiteDriver(db *sql.DB) *SQLiteDriver {
+	return &SQLiteDriver{db: db}
+}
+
+// SQLite returns a runner of the embedded SQLite migrations
+func SQLite(db *sql.DB, logf func(format string, args ...any)) (*Runner, error) {
+	all, err := Load(migrations.SQLite())
+	if err != nil {
+		return nil, err
+	}
+	return New(NewSQLiteDriver(db), all, logf), nil
+}
+
+// Lock begins the transaction of the run. The migrations it applied are
+// committed on unlock, including those before a failed one.
+func (d *SQLiteDriver) Lock(ctx context.Context) (func(), error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	d.conn = conn
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), `COMMIT`); err != nil {
+			conn.ExecContext(context.Background(), `ROLLBACK`)
+		}
+		conn.Close()
+		d.conn = nil
+	}, nil
+}
+
+func (d *SQLiteDriver) Versions(ctx context.Context) ([]int, error) {
+	exists, err := d.tableExists(ctx, "schema_version")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := d.conn.QueryContext(ctx, `SELECT version FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+const sqliteTables = `
+	SELECT m.name, c.name, c.type
+	FROM sqlite_master m
+	JOIN pragma_table_info(m.name) c
+	WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' AND m.name <> 'schema_version'
+	ORDER BY m.name, c.cid`
+
+func (d *SQLiteDriver) Tables(ctx context.Context) (map[string][]string, error) {
+	return sqliteColumns(ctx, d.conn)
+}
+
+// TablesOf runs the migration in a new in-memory database, the tables of the
+// database file would clash with its own.
+func (d *SQLiteDriver) TablesOf(ctx context.Context, m Migration) (map[string][]string, error) {
+	scratch, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	defer scratch.Close()
+	// Every connection to :memory: is a database of its own
+	scratch.SetMaxOpenConns(1)
+
+	if _, err := scratch.ExecContext(ctx, m.Up); err != nil {
+		return nil, err
+	}
+
+	return sqliteColumns(ctx, scratch)
+}
+
+func sqliteColumns(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}) (map[string][]string, error) {
+	rows, err := q.QueryContext(ctx, sqliteTables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := make(map[string][]string)
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		tables[table] = append(tables[table], column+" "+dataType)
+	}
+
+	return tables, rows.Err()
+}
+
+func (d *SQLiteDriver) Up(ctx context.Context, m Migration, owner int64) error {
+	return d.change(ctx, m.Up, owner, `INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UnixMicro())
+}
+
+func (d *SQLiteDriver) Down(ctx context.Context, m Migration) error {
+	return d.change(ctx, m.Down, 0, `DELETE FROM schema_version WHERE version = ?`, m.Version)
+}
+
+func (d *SQLiteDriver) Baseline(ctx context.Context, m Migration) error {
+	return d.change(ctx, "", 0, `INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UnixMicro())
+}
+
+// change runs the SQL of a migration and records it in one savepoint. The
+// temporary migration_owner table lives only as long as the savepoint.
+func (d *SQLiteDriver) change(ctx context.Context, query string, owner int64, record string, args ...any) error {
+	if _, err := d.conn.ExecContext(ctx, `SAVEPOINT migration`); err != nil {
+		return fmt.Errorf("failed to begin savepoint: %w", err)
+	}
+
+	err := func() error {
+		if _, err := d.conn.ExecContext(ctx, sqliteVersionTable); err != nil {
+			return fmt.Errorf("failed to create schema_version: %w", err)
+		}
+		if _, err := d.conn.ExecContext(ctx, `CREATE TEMP TABLE migration_owner (user_id INTEGER NOT NULL)`); err != nil {
+			return fmt.Errorf("failed to create migration_owner: %w", err)
+		}
+		if owner != 0 {
+			if _, err := d.conn.ExecContext(ctx, `INSERT INTO migration_owner (user_id) VALUES (?)`, owner); err != nil {
+				return fmt.Errorf("failed to set migration owner: %w", err)
+			}
+		}
+		if query != "" {
+			if _, err := d.conn.ExecContext(ctx, query); err != nil {
+				return err
+			}
+		}
+		if _, err := d.conn.ExecContext(ctx, `DROP TABLE temp.migration_owner`); err != nil {
+			return fmt.Errorf("failed to drop migration_owner: %w", err)
+		}
+		if _, err := d.conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		d.conn.ExecContext(ctx, `ROLLBACK TO migration`)
+		d.conn.ExecContext(ctx, `RELEASE migration`)
+		return err
+	}
+
+	if _, err := d.conn.ExecContext(ctx, `RELEASE migration`); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteDriver) tableExists(ctx context.Context, table string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`
+	if err := d.conn.QueryRowContext(ctx, query, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// BackupVersion is the version of the backup format written by this build.
+// Increase it when the format changes and keep reading older versions.
+// Version 1 has no categories, its subscriptions use the default ones.
+// Version 2 has no tags, notes or links.
+// Version 3 has no budgets.
+const BackupVersion = 4
+
+// Backup is a full copy of the data of one user. IDs in the backup refer to
+// each other, they are replaced by new ones on restore.
+type Backup struct {
+	Version       int             `json:"version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Subscriptions []*Subscription `json:"subscriptions"`
+	PriceHistory  []*PriceChange  `json:"price_history"`
+	Payments      []*Payment      `json:"payments"`
+	Settings      BackupSettings  `json:"settings"`
+	Categories    []*UserCategory `json:"categories,omitempty"`
+	Budgets       []*Budget       `json:"budgets,omitempty"`
+}
+
+type BackupSettings struct {
+	BaseCurrency Currency `json:"base_currency,omitempty"` // empty - not chosen
+}
+
+// CheckReferences makes sure the payments and prices belong to subscriptions
+// of the backup, the subscriptions and budgets to its or default categories
+// and the version can be read
+func (b *Backup) CheckReferences() error {
+	if b.Version < 1 || b.Version > BackupVersion {
+		return fmt.Errorf("unsupported backup version: %d", b.Version)
+	}
+
+	keys := make(map[Category]bool)
+	for _, category := range DefaultCategories() {
+		keys[category.Key] = true
+	}
+	seen := make(map[Category]bool, len(b.Categories))
+	for _, category := range b.Categories {
+		if seen[category.Key] {
+			return fmt.Errorf("duplicate category key: %s", category.Key)
+		}
+		seen[category.Key] = true
+		keys[category.Key] = true
+	}
+
+	ids := make(map[int]bool, len(b.Subscriptions))
+	for _, sub := range b.Subscriptions {
+		if ids[sub.ID] {
+			return fmt.Errorf("duplicate subscription id: %d", sub.ID)
+		}
+		if !keys[sub.Category] {
+			return fmt.Errorf("subscription %d refers to unknown category %s", sub.ID, sub.Category)
+		}
+		ids[sub.ID] = true
+	}
+
+	budgets := make(map[[2]string]bool, len(b.Budgets))
+	for _, budget := range b.Budgets {
+		if !budget.IsOverall() && !keys[budget.Category] {
+			return fmt.Errorf("budget refers to unknown category %s", budget.Category)
+		}
+		key := [2]string{string(budget.Category), string(budget.Currency)}
+		if budgets[key] {
+			return fmt.Errorf("duplicate budget: %s %s", budget.Category, budget.Currency)
+		}
+		budgets[key] = true
+	}
+
+	for _, change := range b.PriceHistory {
+		if !ids[change.SubscriptionID] {
+			return fmt.Errorf("price %d refers to unknown subscription %d", change.ID, change.SubscriptionID)
+		}
+	}
+	for _, payment := range b.Payments {
+		if !ids[payment.SubscriptionID] {
+			return fmt.Errorf("payment %d refers to unknown subscription %d", payment.ID, payment.SubscriptionID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBackupCheckReferences(t *testing.T) {
+	valid := func() *Backup {
+		return &Backup{
+			Version:       BackupVersion,
+			Subscriptions: []*Subscription{{ID: 1, Category: CategoryWork}, {ID: 2, Category: "custom_1"}},
+			PriceHistory:  []*PriceChange{{ID: 10, SubscriptionID: 1}},
+			Payments:      []*Payment{{ID: 20, SubscriptionID: 2}},
+			Categories:    []*UserCategory{{Key: "custom_1", Name: "Облако"}},
+			Budgets:       []*Budget{{Currency: CurrencyRUB, Limit: 100000}, {Category: "custom_1", Currency: CurrencyRUB, Limit: 50000}},
+		}
+	}
+
+	if err := valid().CheckReferences(); err != nil {
+		t.Errorf("Expected a valid backup, got %v", err)
+	}
+
+	tests := map[string]func(b *Backup){
+		"future version":          func(b *Backup) { b.Version = BackupVersion + 1 },
+		"missing version":         func(b *Backup) { b.Version = 0 },
+		"duplicate id":            func(b *Backup) { b.Subscriptions[1].ID = 1 },
+		"orphan price":            func(b *Backup) { b.PriceHistory[0].SubscriptionID = 3 },
+		"orphan payment":          func(b *Backup) { b.Payments[0].SubscriptionID = 3 },
+		"unknown category":        func(b *Backup) { b.Subscriptions[0].Category = "custom_2" },
+		"unknown budget category": func(b *Backup) { b.Budgets[1].Category = "custom_2" },
+		"duplicate budget":        func(b *Backup) { b.Budgets[1].Category = "" },
+		"duplicate category": func(b *Backup) {
+			b.Categories = append(b.Categories, &UserCategory{Key: "custom_1", Name: "Хранилище"})
+		},
+	}
+	for name, corrupt := range tests {
+		t.Run(name, func(t *testing.T) {
+			backup := valid()
+			corrupt(backup)
+			if err := backup.CheckReferences(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestBackupJSONRoundTrip(t *testing.T) {
+	trialEndsAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	original := &Backup{
+		Version:   BackupVersion,
+		CreatedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Subscriptions: []*Subscription{{
+			ID: 1, Name: "Kindle", Cost: NewMoney(499), Currency: CurrencyEUR,
+			Period: BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 31}, TrialEndsAt: &trialEndsAt,
+		}},
+		Payments:   []*Payment{{ID: 5, SubscriptionID: 1, Amount: NewMoney(99), Currency: CurrencyEUR, Status: PaymentStatusVoided, Note: "ошибка"}},
+		Settings:   BackupSettings{BaseCurrency: CurrencyRUB},
+		Categories: []*UserCategory{{Key: "custom_1", Name: "Облако", Emoji: "☁️", Archived: true}},
+	}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	var decoded Backup
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	sub := decoded.Subscriptions[0]
+	if sub.Period != original.Subscriptions[0].Period || sub.Cost != NewMoney(499) || !sub.TrialEndsAt.Equal(trialEndsAt) {
+		t.Errorf("Subscription mismatch: %+v", sub)
+	}
+	if payment := decoded.Payments[0]; payment.Status != PaymentStatusVoided || payment.Note != "ошибка" {
+		t.Errorf("Payment mismatch: %+v", payment)
+	}
+	if decoded.Settings.BaseCurrency != CurrencyRUB || decoded.Version != BackupVersion {
+		t.Errorf("Settings mismatch: %+v", decoded)
+	}
+	if category := decoded.Categories[0]; category.Key != "custom_1" || category.Emoji != "☁️" || !category.Archived {
+		t.Errorf("Category mismatch: %+v", category)
+	}
+}
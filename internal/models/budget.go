@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Budget limits the spending of a user in a currency per calendar month,
+// either in one category or, without a category, in all of them.
+type Budget struct {
+	ID        int       `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Category  Category  `json:"category,omitempty"` // empty for the overall budget
+	Currency  Currency  `json:"currency"`
+	Limit     Money     `json:"limit"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsOverall reports whether the budget limits the spending in all categories
+func (b *Budget) IsOverall() bool {
+	return b.Category == ""
+}
+
+// BudgetThresholds are the shares of a budget, in percent, that are alerted
+// about when a payment crosses them.
+var BudgetThresholds = []int{80, 100}
+
+// BudgetStatus is the spending of the current month against a budget
+type BudgetStatus struct {
+	Budget *Budget `json:"budget"`
+	Spent  Money   `json:"spent"`
+}
+
+// Percent returns the share of the limit that is spent
+func (s BudgetStatus) Percent() float64 {
+	return float64(s.Spent) / float64(s.Budget.Limit) * 100
+}
+
+// Remaining returns what is left of the limit, negative when it is exceeded
+func (s BudgetStatus) Remaining() Money {
+	return s.Budget.Limit - s.Spent
+}
+
+// BudgetAlert tells that a payment made the spending cross a threshold
+type BudgetAlert struct {
+	BudgetStatus
+	Threshold int `json:"threshold"` // one of BudgetThresholds
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// UserCategory is a category of subscriptions of one user. Subscriptions
+// refer to it by Key, which never changes, so the name and emoji can be
+// edited freely. Archived categories are hidden from the choice of a
+// category but keep their subscriptions.
+type UserCategory struct {
+	ID        int       `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Key       Category  `json:"key"`
+	Name      string    `json:"name"`
+	Emoji     string    `json:"emoji,omitempty"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Label is the emoji and the name, as shown on buttons
+func (c *UserCategory) Label() string {
+	if c.Emoji == "" {
+		return c.Name
+	}
+	return c.Emoji + " " + c.Name
+}
+
+// Matches reports whether s is the key or, ignoring case, the name of the category
+func (c *UserCategory) Matches(s string) bool {
+	return string(c.Key) == s || strings.EqualFold(c.Name, strings.TrimSpace(s))
+}
+
+type CreateCategoryRequest struct {
+	UserID int64    `json:"user_id"`
+	Key    Category `json:"key"`
+	Name   string   `json:"name"`
+	Emoji  string   `json:"emoji"`
+}
+
+// UpdateCategoryRequest changes the fields that are set and keeps the rest.
+type UpdateCategoryRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Emoji    *string `json:"emoji,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// Apply copies the requested changes onto the category.
+func (r *UpdateCategoryRequest) Apply(c *UserCategory) {
+	if r.Name != nil {
+		c.Name = *r.Name
+	}
+	if r.Emoji != nil {
+		c.Emoji = *r.Emoji
+	}
+	if r.Archived != nil {
+		c.Archived = *r.Archived
+	}
+}
+
+// DefaultCategories are the categories a user starts with, in the order of the keyboard
+func DefaultCategories() []CreateCategoryRequest {
+	return []CreateCategoryRequest{
+		{Key: CategoryEntertainment, Name: "Развлечения", Emoji: "🎮"},
+		{Key: CategoryWork, Name: "Работа", Emoji: "💼"},
+		{Key: CategoryEducation, Name: "Обучение", Emoji: "📚"},
+		{Key: CategoryHome, Name: "Дом", Emoji: "🏠"},
+		{Key: CategoryOther, Name: "Другое", Emoji: "📦"},
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestUserCategoryLabelAndMatches(t *testing.T) {
+	category := &UserCategory{Key: "custom_1", Name: "Облако", Emoji: "☁️"}
+
+	if label := category.Label(); label != "☁️ Облако" {
+		t.Errorf("Unexpected label: %q", label)
+	}
+	for _, s := range []string{"custom_1", "облако", " ОБЛАКО "} {
+		if !category.Matches(s) {
+			t.Errorf("Expected %q to match", s)
+		}
+	}
+	if category.Matches("other") {
+		t.Error("Expected another key not to match")
+	}
+
+	category.Emoji = ""
+	if label := category.Label(); label != "Облако" {
+		t.Errorf("Expected the name without emoji, got %q", label)
+	}
+}
+
+func TestUpdateCategoryRequestApply(t *testing.T) {
+	category := &UserCategory{Key: CategoryWork, Name: "Работа", Emoji: "💼"}
+
+	name, archived := "Офис", true
+	(&UpdateCategoryRequest{Name: &name, Archived: &archived}).Apply(category)
+
+	if category.Name != "Офис" || category.Emoji != "💼" || !category.Archived || category.Key != CategoryWork {
+		t.Errorf("Unexpected category after update: %+v", category)
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	CurrencyUSD Currency = "USD"
+	CurrencyEUR Currency = "EUR"
+	CurrencyGBP Currency = "GBP"
+	CurrencyRUB Currency = "RUB"
+	CurrencyKZT Currency = "KZT"
+)
+
+// CurrencyInfo describes a supported currency
+type CurrencyInfo struct {
+	Code   Currency
+	Symbol string
+	// MinorDigits is the number of digits after the decimal point, 2 for cents
+	MinorDigits int
+}
+
+// currencies is the registry of supported currencies, in keyboard order
+var currencies = []CurrencyInfo{
+	{CurrencyUSD, "$", 2},
+	{CurrencyEUR, "€", 2},
+	{CurrencyGBP, "£", 2},
+	{CurrencyRUB, "₽", 2},
+	{CurrencyKZT, "₸", 2},
+	{"UAH", "₴", 2},
+	{"BYN", "Br", 2},
+	{"GEL", "₾", 2},
+	{"AMD", "֏", 2},
+	{"TRY", "₺", 2},
+	{"PLN", "zł", 2},
+	{"CHF", "Fr", 2},
+	{"CNY", "¥", 2},
+	{"INR", "₹", 2},
+	{"AED", "AED", 2},
+	{"JPY", "¥", 0},
+	{"KRW", "₩", 0},
+	{"KWD", "KD", 3},
+}
+
+var currencyIndex = func() map[Currency]CurrencyInfo {
+	index := make(map[Currency]CurrencyInfo, len(currencies))
+	for _, info := range currencies {
+		index[info.Code] = info
+	}
+	return index
+}()
+
+// Currencies returns all supported currencies
+func Currencies() []CurrencyInfo {
+	result := make([]CurrencyInfo, len(currencies))
+	copy(result, currencies)
+	return result
+}
+
+// LookupCurrency returns the registry entry of a currency code
+func LookupCurrency(code Currency) (CurrencyInfo, bool) {
+	info, ok := currencyIndex[code]
+	return info, ok
+}
+
+// ParseCurrency accepts a currency code in any case, like "eur"
+func ParseCurrency(str string) (Currency, bool) {
+	code := Currency(strings.ToUpper(strings.TrimSpace(str)))
+	_, ok := currencyIndex[code]
+	return code, ok
+}
+
+func (c Currency) IsValid() bool {
+	_, ok := currencyIndex[c]
+	return ok
+}
+
+// Symbol returns the currency sign, or the code for unknown currencies
+func (c Currency) Symbol() string {
+	if info, ok := currencyIndex[c]; ok {
+		return info.Symbol
+	}
+	return string(c)
+}
+
+// MinorDigits returns the number of decimal places, 2 for unknown currencies
+func (c Currency) MinorDigits() int {
+	if info, ok := currencyIndex[c]; ok {
+		return info.MinorDigits
+	}
+	return 2
+}
+
+// Format returns the amount with the currency sign, like "15.99€"
+func (c Currency) Format(m Money) string {
+	return m.StringIn(c) + c.Symbol()
+}
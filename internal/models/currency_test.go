@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestCurrencyRegistry(t *testing.T) {
+	for _, info := range Currencies() {
+		if len(info.Code) != 3 || info.Symbol == "" {
+			t.Errorf("Invalid registry entry: %+v", info)
+		}
+		if found, ok := LookupCurrency(info.Code); !ok || found != info {
+			t.Errorf("Currency %s is not indexed", info.Code)
+		}
+	}
+
+	if Currency("XXX").IsValid() {
+		t.Error("Unknown currency must not be valid")
+	}
+	if Currency("XXX").Symbol() != "XXX" {
+		t.Error("Expected unknown currency to be shown by its code")
+	}
+
+	if code, ok := ParseCurrency(" eur "); !ok || code != CurrencyEUR {
+		t.Errorf("Expected EUR, got %q (%v)", code, ok)
+	}
+}
+
+func TestCurrencyFormat(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		amount   Money
+		expected string
+	}{
+		{CurrencyUSD, Money(1599), "15.99$"},
+		{CurrencyRUB, Money(29900), "299.00₽"},
+		{CurrencyGBP, Money(1099), "10.99£"},
+		{"JPY", Money(980), "980¥"},
+	}
+
+	for _, test := range tests {
+		if got := test.currency.Format(test.amount); got != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Sources of exchange rates
+const (
+	RateSourceManual = "manual"
+)
+
+// ExchangeRate says how many units of To one unit of From costs
+type ExchangeRate struct {
+	From      Currency  `json:"from"`
+	To        Currency  `json:"to"`
+	Rate      float64   `json:"rate"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConvertedTotal is a sum of amounts in different currencies expressed in one currency.
+// Currencies without a known rate are left out of Amount and listed in Missing.
+type ConvertedTotal struct {
+	Currency Currency   `json:"currency"`
+	Amount   Money      `json:"amount"`
+	Missing  []Currency `json:"missing,omitempty"`
+}
+
+type currencyPair struct {
+	from, to Currency
+}
+
+// RateTable looks up conversion rates, using inverse rates and one
+// intermediate currency when there is no direct rate.
+type RateTable struct {
+	rates      map[currencyPair]float64
+	base       Currency
+	currencies []Currency // sorted
+}
+
+// NewRateTable creates a table of the rates. Cross rates go through base
+// when they can, it is usually the currency the rates are quoted in.
+func NewRateTable(rates []*ExchangeRate, base Currency) *RateTable {
+	table := &RateTable{rates: make(map[currencyPair]float64), base: base}
+
+	// Inverse rates go first, so that a stored rate always wins over a derived one
+	for _, rate := range rates {
+		if rate.Rate > 0 && rate.From != rate.To {
+			table.rates[currencyPair{rate.To, rate.From}] = 1 / rate.Rate
+		}
+	}
+	for _, rate := range rates {
+		if rate.Rate > 0 && rate.From != rate.To {
+			table.rates[currencyPair{rate.From, rate.To}] = rate.Rate
+		}
+	}
+
+	for pair := range table.rates {
+		table.currencies = append(table.currencies, pair.from)
+	}
+	slices.Sort(table.currencies)
+	table.currencies = slices.Compact(table.currencies)
+
+	return table
+}
+
+// Rate returns the rate from one currency to another
+func (t *RateTable) Rate(from, to Currency) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if rate, ok := t.rates[currencyPair{from, to}]; ok {
+		return rate, true
+	}
+
+	// Cross rate through a common currency, e.g. KZT -> USD -> EUR. Rates
+	// through different currencies rarely agree, so the base currency is
+	// tried first and then the others in order of their codes.
+	if rate, ok := t.cross(from, t.base, to); ok {
+		return rate, true
+	}
+	for _, via := range t.currencies {
+		if rate, ok := t.cross(from, via, to); ok {
+			return rate, true
+		}
+	}
+
+	return 0, false
+}
+
+func (t *RateTable) cross(from, via, to Currency) (float64, bool) {
+	first, ok := t.rates[currencyPair{from, via}]
+	if !ok {
+		return 0, false
+	}
+	second, ok := t.rates[currencyPair{via, to}]
+	return first * second, ok
+}
+
+// Convert changes an amount from one currency to another, respecting the
+// number of minor digits of both currencies
+func (t *RateTable) Convert(amount Money, from, to Currency) (Money, bool) {
+	rate, ok := t.Rate(from, to)
+	if !ok {
+		return 0, false
+	}
+
+	major := float64(amount.Cents()) / float64(pow10(from.MinorDigits()))
+	minor := math.Round(major * rate * float64(pow10(to.MinorDigits())))
+	return NewMoney(int(minor)), true
+}
+
+// Total converts every amount into the target currency and sums them up
+func (t *RateTable) Total(amounts map[Currency]Money, to Currency) ConvertedTotal {
+	total := ConvertedTotal{Currency: to}
+	for currency, amount := range amounts {
+		converted, ok := t.Convert(amount, currency, to)
+		if !ok {
+			total.Missing = append(total.Missing, currency)
+			continue
+		}
+		total.Amount = total.Amount.Add(converted)
+	}
+
+	slices.Sort(total.Missing)
+	return total
+}
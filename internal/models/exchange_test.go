@@ -0,0 +1,99 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRateTableConvert(t *testing.T) {
+	table := NewRateTable([]*ExchangeRate{
+		{From: CurrencyUSD, To: CurrencyRUB, Rate: 90},
+		{From: CurrencyEUR, To: CurrencyUSD, Rate: 1.1},
+		{From: CurrencyUSD, To: "JPY", Rate: 150},
+	}, CurrencyUSD)
+
+	tests := []struct {
+		name     string
+		amount   Money
+		from     Currency
+		to       Currency
+		expected Money
+	}{
+		{"direct", NewMoney(1000), CurrencyUSD, CurrencyRUB, NewMoney(90000)},
+		{"inverse", NewMoney(90000), CurrencyRUB, CurrencyUSD, NewMoney(1000)},
+		{"cross", NewMoney(1000), CurrencyEUR, CurrencyRUB, NewMoney(99000)},
+		{"same currency", NewMoney(1234), CurrencyKZT, CurrencyKZT, NewMoney(1234)},
+		{"zero minor digits", NewMoney(1000), CurrencyUSD, "JPY", NewMoney(1500)},
+		{"from zero minor digits", NewMoney(1500), "JPY", CurrencyUSD, NewMoney(1000)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, ok := table.Convert(test.amount, test.from, test.to)
+			if !ok {
+				t.Fatalf("Expected a rate from %s to %s", test.from, test.to)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+
+	if _, ok := table.Convert(NewMoney(100), CurrencyKZT, CurrencyUSD); ok {
+		t.Error("Expected no rate for KZT")
+	}
+}
+
+func TestRateTablePrefersStoredRate(t *testing.T) {
+	table := NewRateTable([]*ExchangeRate{
+		{From: CurrencyUSD, To: CurrencyRUB, Rate: 90},
+		{From: CurrencyRUB, To: CurrencyUSD, Rate: 0.0125},
+	}, CurrencyUSD)
+
+	if rate, _ := table.Rate(CurrencyRUB, CurrencyUSD); rate != 0.0125 {
+		t.Errorf("Expected stored rate 0.0125, got %v", rate)
+	}
+	if rate, _ := table.Rate(CurrencyUSD, CurrencyRUB); rate != 90 {
+		t.Errorf("Expected stored rate 90, got %v", rate)
+	}
+}
+
+// Test that a cross rate goes through the base currency, or the first code
+// when the base has no rates, whatever the order of the map
+func TestRateTableCrossRateIsDeterministic(t *testing.T) {
+	rates := []*ExchangeRate{
+		{From: CurrencyKZT, To: CurrencyUSD, Rate: 0.002},
+		{From: CurrencyUSD, To: CurrencyRUB, Rate: 90},
+		{From: CurrencyKZT, To: CurrencyEUR, Rate: 0.0019},
+		{From: CurrencyEUR, To: CurrencyRUB, Rate: 100},
+	}
+
+	for i := 0; i < 20; i++ {
+		if rate, _ := NewRateTable(rates, CurrencyUSD).Rate(CurrencyKZT, CurrencyRUB); math.Abs(rate-0.18) > 1e-9 {
+			t.Fatalf("Expected the rate through USD 0.18, got %v", rate)
+		}
+		if rate, _ := NewRateTable(rates, CurrencyGBP).Rate(CurrencyKZT, CurrencyRUB); math.Abs(rate-0.19) > 1e-9 {
+			t.Fatalf("Expected the rate through EUR 0.19, got %v", rate)
+		}
+	}
+}
+
+func TestRateTableTotal(t *testing.T) {
+	table := NewRateTable([]*ExchangeRate{
+		{From: CurrencyUSD, To: CurrencyRUB, Rate: 90},
+	}, CurrencyUSD)
+
+	total := table.Total(map[Currency]Money{
+		CurrencyUSD: NewMoney(1000),
+		CurrencyRUB: NewMoney(50000),
+		CurrencyKZT: NewMoney(100000),
+		CurrencyGBP: NewMoney(500),
+	}, CurrencyRUB)
+
+	if total.Currency != CurrencyRUB || total.Amount != NewMoney(140000) {
+		t.Errorf("Expected 1400.00 RUB, got %s %s", total.Amount.String(), total.Currency)
+	}
+	if len(total.Missing) != 2 || total.Missing[0] != CurrencyGBP || total.Missing[1] != CurrencyKZT {
+		t.Errorf("Expected GBP and KZT to be missing, got %v", total.Missing)
+	}
+}
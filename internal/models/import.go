@@ -0,0 +1,23 @@
+package models
+
+// ImportRow is a subscription read from an imported file. Err is set when
+// the row could not be read, Request is nil then.
+type ImportRow struct {
+	Line    int
+	Request *CreateSubscriptionRequest
+	Err     error
+}
+
+// ImportError explains why a row of an imported file was skipped
+type ImportError struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport lists the subscriptions created by an import and the rows
+// that were skipped
+type ImportReport struct {
+	Created []*Subscription `json:"created"`
+	Failed  []ImportError   `json:"failed"`
+}
@@ -22,6 +22,16 @@ func NewMoneyFromDollarsAndCents(dollars, cents int) Money {
 
 // ParseMoney parses a string like "15.99" into Money (stored as cents)
 func ParseMoney(str string) (Money, error) {
+	return parseMinorUnits(str, 2)
+}
+
+// ParseMoneyIn parses an amount in the given currency into its minor units,
+// e.g. "1500" yen or "1.250" Kuwaiti dinars
+func ParseMoneyIn(str string, currency Currency) (Money, error) {
+	return parseMinorUnits(str, currency.MinorDigits())
+}
+
+func parseMinorUnits(str string, digits int) (Money, error) {
 	str = strings.TrimSpace(str)
 
 	// Replace comma with dot for international support
@@ -41,13 +51,11 @@ func ParseMoney(str string) (Money, error) {
 	if len(parts) == 2 {
 		centStr := parts[1]
 		// Validate cents format
-		if len(centStr) > 2 {
-			return Money(0), fmt.Errorf("too many digits after decimal point: %s (max 2 digits)", centStr)
-		}
-		// Pad to 2 digits if only 1 digit provided
-		if len(centStr) == 1 {
-			centStr += "0"
+		if len(centStr) > digits {
+			return Money(0), fmt.Errorf("too many digits after decimal point: %s (max %d digits)", centStr, digits)
 		}
+		// Pad to the currency precision if fewer digits provided
+		centStr += strings.Repeat("0", digits-len(centStr))
 
 		cents, err = strconv.Atoi(centStr)
 		if err != nil {
@@ -55,7 +63,7 @@ func ParseMoney(str string) (Money, error) {
 		}
 	}
 
-	return NewMoneyFromDollarsAndCents(dollars, cents), nil
+	return Money(dollars*pow10(digits) + cents), nil
 }
 
 // String returns the money as a formatted string like "15.99"
@@ -65,6 +73,26 @@ func (m Money) String() string {
 	return fmt.Sprintf("%d.%02d", dollars, cents)
 }
 
+// StringIn formats the money with the precision of the currency,
+// e.g. "15.99" dollars, "1500" yen or "1.250" Kuwaiti dinars
+func (m Money) StringIn(currency Currency) string {
+	digits := currency.MinorDigits()
+	if digits == 0 {
+		return fmt.Sprintf("%d", int(m))
+	}
+
+	unit := pow10(digits)
+	return fmt.Sprintf("%d.%0*d", int(m)/unit, digits, int(m)%unit)
+}
+
+func pow10(digits int) int {
+	result := 1
+	for i := 0; i < digits; i++ {
+		result *= 10
+	}
+	return result
+}
+
 // Cents returns the total amount in cents
 func (m Money) Cents() int {
 	return int(m)
@@ -97,8 +125,8 @@ func (m Money) IsPositive() bool {
 
 // Value implements the driver.Valuer interface for database storage
 func (m Money) Value() (driver.Value, error) {
-	// Store as integer (cents)
-	return int(m), nil
+	// Store as integer (cents), database/sql only accepts int64
+	return int64(m), nil
 }
 
 // Scan implements the sql.Scanner interface for database reading
@@ -149,3 +149,40 @@ func TestNewMoneyFromDollarsAndCents(t *testing.T) {
 		})
 	}
 }
+
+func TestMoneyInCurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		expected Money
+		output   string
+	}{
+		{"15.99", CurrencyEUR, Money(1599), "15.99"},
+		{"4990", CurrencyKZT, Money(499000), "4990.00"},
+		{"1500", "JPY", Money(1500), "1500"},
+		{"1.25", "KWD", Money(1250), "1.250"},
+		{"0,5", "KWD", Money(500), "0.500"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.currency)+" "+test.input, func(t *testing.T) {
+			result, err := ParseMoneyIn(test.input, test.currency)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, result)
+			}
+			if got := result.StringIn(test.currency); got != test.output {
+				t.Errorf("Expected %q, got %q", test.output, got)
+			}
+		})
+	}
+
+	if _, err := ParseMoneyIn("15.5", "JPY"); err == nil {
+		t.Error("Expected error for fractional yen")
+	}
+	if _, err := ParseMoneyIn("1.2345", "KWD"); err == nil {
+		t.Error("Expected error for more than 3 decimal places")
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,23 +13,171 @@ const (
 	PaymentStatusCompleted PaymentStatus = "completed"
 	PaymentStatusPending   PaymentStatus = "pending"
 	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusVoided    PaymentStatus = "voided" // recorded by mistake, kept for the history
 )
 
+func (s PaymentStatus) IsValid() bool {
+	switch s {
+	case PaymentStatusCompleted, PaymentStatusPending, PaymentStatusFailed, PaymentStatusVoided:
+		return true
+	}
+	return false
+}
+
 type Payment struct {
 	ID             int           `json:"id"`
+	UserID         int64         `json:"user_id"`
 	SubscriptionID int           `json:"subscription_id"`
 	Amount         Money         `json:"amount"`
 	Currency       Currency      `json:"currency"`
 	PaidAt         time.Time     `json:"paid_at"`
 	Status         PaymentStatus `json:"status"`
+	Note           string        `json:"note,omitempty"`
 	CreatedAt      time.Time     `json:"created_at"`
 }
 
 type CreatePaymentRequest struct {
+	UserID         int64         `json:"user_id"`
 	SubscriptionID int           `json:"subscription_id"`
 	Amount         Money         `json:"amount"`
 	Currency       Currency      `json:"currency"`
 	Status         PaymentStatus `json:"status"`
+	PaidAt         *time.Time    `json:"paid_at,omitempty"`         // defaults to now
+	IdempotencyKey string        `json:"idempotency_key,omitempty"` // recorded once per key, see BillingCycleKey
+	Note           string        `json:"note,omitempty"`
+}
+
+// UpdatePaymentRequest changes the fields that are set and keeps the rest.
+type UpdatePaymentRequest struct {
+	Amount *Money         `json:"amount,omitempty"`
+	PaidAt *time.Time     `json:"paid_at,omitempty"`
+	Status *PaymentStatus `json:"status,omitempty"`
+	Note   *string        `json:"note,omitempty"`
+}
+
+// Apply copies the requested changes onto the payment.
+func (r *UpdatePaymentRequest) Apply(p *Payment) {
+	if r.Amount != nil {
+		p.Amount = *r.Amount
+	}
+	if r.PaidAt != nil {
+		p.PaidAt = *r.PaidAt
+	}
+	if r.Status != nil {
+		p.Status = *r.Status
+	}
+	if r.Note != nil {
+		p.Note = *r.Note
+	}
+}
+
+// BillingCycleKey identifies the payment of one billing cycle of a subscription,
+// the cycle that is due on dueDate.
+func BillingCycleKey(subscriptionID int, dueDate time.Time) string {
+	return fmt.Sprintf("subscription:%d:%s", subscriptionID, DateOnly(dueDate).Format("2006-01-02"))
+}
+
+// PaymentFilter narrows the payment history. Zero fields match every payment.
+type PaymentFilter struct {
+	SubscriptionID int
+	Category       Category
+	Tag            string // payments of subscriptions with the tag
+	Currency       Currency
+	Status         PaymentStatus
+	From           time.Time // first day, inclusive
+	To             time.Time // last day, inclusive
+}
+
+func (f PaymentFilter) IsEmpty() bool {
+	return f == PaymentFilter{}
+}
+
+// PaymentCursor points at a payment of the history, which is ordered by
+// payment date and ID, newest first.
+type PaymentCursor struct {
+	PaidAt time.Time
+	ID     int
+}
+
+func CursorOf(p *Payment) PaymentCursor {
+	return PaymentCursor{PaidAt: p.PaidAt, ID: p.ID}
+}
+
+// String encodes the cursor compactly enough for a button payload
+func (c PaymentCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.PaidAt.UnixMicro(), c.ID)
+}
+
+func ParsePaymentCursor(s string) (PaymentCursor, error) {
+	micros, id, ok := strings.Cut(s, ".")
+	if !ok {
+		return PaymentCursor{}, fmt.Errorf("invalid payment cursor: %q", s)
+	}
+
+	paidAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return PaymentCursor{}, fmt.Errorf("invalid payment cursor: %q", s)
+	}
+	paymentID, err := strconv.Atoi(id)
+	if err != nil {
+		return PaymentCursor{}, fmt.Errorf("invalid payment cursor: %q", s)
+	}
+
+	return PaymentCursor{PaidAt: time.UnixMicro(paidAt), ID: paymentID}, nil
+}
+
+// PaymentPageRequest asks for a page of the history. Without a cursor it
+// starts from the newest payment, otherwise it continues from the cursor
+// towards older payments or, with Newer, towards newer ones.
+type PaymentPageRequest struct {
+	Filter PaymentFilter
+	Cursor *PaymentCursor
+	Newer  bool
+	Limit  int
+}
+
+// PaymentPage is a page of the history, newest first. Older and Newer point
+// at the edges of the page when there are more payments in that direction.
+type PaymentPage struct {
+	Payments []*Payment
+	Older    *PaymentCursor
+	Newer    *PaymentCursor
+}
+
+// NewPaymentPage builds the page from up to Limit+1 payments read in the
+// direction of the request, the extra payment only tells that there are more.
+func NewPaymentPage(payments []*Payment, req PaymentPageRequest) *PaymentPage {
+	hasMore := len(payments) > req.Limit
+	if hasMore {
+		payments = payments[:req.Limit]
+	}
+
+	// Newer payments are read oldest first, the page is always shown newest first
+	if req.Newer {
+		for i, j := 0, len(payments)-1; i < j; i, j = i+1, j-1 {
+			payments[i], payments[j] = payments[j], payments[i]
+		}
+	}
+
+	page := &PaymentPage{Payments: payments}
+	if len(payments) == 0 {
+		return page
+	}
+
+	// The way back always leads to the cursor we came from
+	hasOlder := hasMore && !req.Newer || req.Newer && req.Cursor != nil
+	hasNewer := hasMore && req.Newer || !req.Newer && req.Cursor != nil
+
+	if hasOlder {
+		cursor := CursorOf(payments[len(payments)-1])
+		page.Older = &cursor
+	}
+	if hasNewer {
+		cursor := CursorOf(payments[0])
+		page.Newer = &cursor
+	}
+
+	return page
 }
 
 type PaymentSummary struct {
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBillingCycleKey(t *testing.T) {
+	due := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	key := BillingCycleKey(42, due)
+	if key != "subscription:42:2025-03-10" {
+		t.Errorf("Unexpected key: %s", key)
+	}
+
+	// The time of day must not split one cycle into several keys
+	if other := BillingCycleKey(42, due.Add(15*time.Hour)); other != key {
+		t.Errorf("Expected the same key for the same day, got %s and %s", key, other)
+	}
+	if BillingCycleKey(42, due.AddDate(0, 1, 0)) == key || BillingCycleKey(43, due) == key {
+		t.Error("Expected different keys for different cycles")
+	}
+}
+
+func TestUpdatePaymentRequestApply(t *testing.T) {
+	paidAt := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	payment := &Payment{Amount: 999, PaidAt: paidAt, Status: PaymentStatusCompleted, Note: "со скидкой"}
+
+	amount := Money(1299)
+	status := PaymentStatusVoided
+	req := &UpdatePaymentRequest{Amount: &amount, Status: &status}
+	req.Apply(payment)
+
+	if payment.Amount != amount || payment.Status != status {
+		t.Errorf("Expected requested fields to change, got %+v", payment)
+	}
+	if !payment.PaidAt.Equal(paidAt) || payment.Note != "со скидкой" {
+		t.Errorf("Expected other fields to stay, got %+v", payment)
+	}
+}
+
+func TestPaymentStatusIsValid(t *testing.T) {
+	for _, status := range []PaymentStatus{PaymentStatusCompleted, PaymentStatusPending, PaymentStatusFailed, PaymentStatusVoided} {
+		if !status.IsValid() {
+			t.Errorf("Expected %s to be valid", status)
+		}
+	}
+	if PaymentStatus("refunded").IsValid() {
+		t.Error("Expected unknown status to be invalid")
+	}
+}
+
+func TestPaymentCursorRoundTrip(t *testing.T) {
+	cursor := PaymentCursor{PaidAt: time.Date(2025, 3, 10, 12, 30, 15, 123456000, time.UTC), ID: 42}
+
+	parsed, err := ParsePaymentCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("Failed to parse cursor: %v", err)
+	}
+	if !parsed.PaidAt.Equal(cursor.PaidAt) || parsed.ID != cursor.ID {
+		t.Errorf("Expected %v, got %v", cursor, parsed)
+	}
+
+	for _, invalid := range []string{"", "42", "abc.1", "1.abc"} {
+		if _, err := ParsePaymentCursor(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestNewPaymentPage(t *testing.T) {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	payments := func(ids ...int) []*Payment {
+		var result []*Payment
+		for _, id := range ids {
+			result = append(result, &Payment{ID: id, PaidAt: day.AddDate(0, 0, id)})
+		}
+		return result
+	}
+
+	// The first page knows only about older payments
+	first := NewPaymentPage(payments(5, 4, 3), PaymentPageRequest{Limit: 2})
+	if len(first.Payments) != 2 || first.Newer != nil || first.Older == nil || first.Older.ID != 4 {
+		t.Errorf("Unexpected first page: %+v", first)
+	}
+
+	// The last page going back in time leads forward to where we came from
+	cursor := CursorOf(&Payment{ID: 4, PaidAt: day.AddDate(0, 0, 4)})
+	last := NewPaymentPage(payments(3), PaymentPageRequest{Cursor: &cursor, Limit: 2})
+	if last.Older != nil || last.Newer == nil || last.Newer.ID != 3 {
+		t.Errorf("Unexpected last page: %+v", last)
+	}
+
+	// Newer payments arrive oldest first and are shown newest first
+	cursor = CursorOf(&Payment{ID: 1, PaidAt: day.AddDate(0, 0, 1)})
+	newer := NewPaymentPage(payments(2, 3, 4), PaymentPageRequest{Cursor: &cursor, Newer: true, Limit: 2})
+	if newer.Payments[0].ID != 3 || newer.Payments[1].ID != 2 {
+		t.Errorf("Expected newest first, got %d, %d", newer.Payments[0].ID, newer.Payments[1].ID)
+	}
+	if newer.Newer == nil || newer.Newer.ID != 3 || newer.Older == nil || newer.Older.ID != 2 {
+		t.Errorf("Unexpected newer page: %+v", newer)
+	}
+
+	if empty := NewPaymentPage(nil, PaymentPageRequest{Cursor: &cursor, Limit: 2}); empty.Older != nil || empty.Newer != nil {
+		t.Errorf("Expected no navigation on an empty page: %+v", empty)
+	}
+}
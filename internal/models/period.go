@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PeriodUnit string
+
+const (
+	PeriodDaily     PeriodUnit = "day"
+	PeriodWeekly    PeriodUnit = "week"
+	PeriodMonthly   PeriodUnit = "month"
+	PeriodQuarterly PeriodUnit = "quarter"
+	PeriodYearly    PeriodUnit = "year"
+)
+
+// BillingPeriod is how often a subscription is paid, e.g. every 2 months.
+// Month based periods keep the payment on AnchorDay, moved to the last day of
+// shorter months, so a subscription paid on the 31st is due on Feb 28/29.
+type BillingPeriod struct {
+	Unit     PeriodUnit `json:"unit"`
+	Interval int        `json:"interval"`
+	// AnchorDay is the day of month for month based units, 0 - the day of the previous payment
+	AnchorDay int `json:"anchor_day,omitempty"`
+}
+
+func Every(interval int, unit PeriodUnit) BillingPeriod {
+	return BillingPeriod{Unit: unit, Interval: interval}
+}
+
+// PeriodFromDays converts a legacy period in days to the closest calendar period
+func PeriodFromDays(days int) BillingPeriod {
+	switch {
+	case days >= 28 && days <= 31:
+		return Every(1, PeriodMonthly)
+	case days >= 89 && days <= 92:
+		return Every(1, PeriodQuarterly)
+	case days >= 365 && days <= 366:
+		return Every(1, PeriodYearly)
+	case days > 0 && days%7 == 0:
+		return Every(days/7, PeriodWeekly)
+	default:
+		return Every(days, PeriodDaily)
+	}
+}
+
+func (p BillingPeriod) Validate() error {
+	switch p.Unit {
+	case PeriodDaily, PeriodWeekly, PeriodMonthly, PeriodQuarterly, PeriodYearly:
+	default:
+		return fmt.Errorf("unknown period unit %q", p.Unit)
+	}
+	if p.Interval <= 0 {
+		return fmt.Errorf("period interval must be positive")
+	}
+	if p.AnchorDay < 0 || p.AnchorDay > 31 {
+		return fmt.Errorf("anchor day must be between 1 and 31")
+	}
+	return nil
+}
+
+// months returns the length of month based periods, 0 for day based ones
+func (p BillingPeriod) months() int {
+	switch p.Unit {
+	case PeriodMonthly:
+		return p.Interval
+	case PeriodQuarterly:
+		return 3 * p.Interval
+	case PeriodYearly:
+		return 12 * p.Interval
+	}
+	return 0
+}
+
+// IsMonthBased reports whether the period follows the calendar months
+func (p BillingPeriod) IsMonthBased() bool {
+	return p.months() > 0
+}
+
+// AnchoredTo returns the period anchored to the day of month of date
+func (p BillingPeriod) AnchoredTo(date time.Time) BillingPeriod {
+	if p.IsMonthBased() {
+		p.AnchorDay = date.Day()
+	} else {
+		p.AnchorDay = 0
+	}
+	return p
+}
+
+// Next returns the payment date one period after from
+func (p BillingPeriod) Next(from time.Time) time.Time {
+	switch p.Unit {
+	case PeriodDaily:
+		return from.AddDate(0, 0, p.Interval)
+	case PeriodWeekly:
+		return from.AddDate(0, 0, 7*p.Interval)
+	}
+
+	day := p.AnchorDay
+	if day == 0 {
+		day = from.Day()
+	}
+
+	// Day 1 of the target month never overflows, the day is clamped afterwards
+	first := time.Date(from.Year(), from.Month(), 1, from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), from.Location())
+	target := first.AddDate(0, p.months(), 0)
+	if last := daysIn(target); day > last {
+		day = last
+	}
+
+	return target.AddDate(0, 0, day-1)
+}
+
+// MonthlyCost returns the average monthly price of a subscription paid cost per period
+func (p BillingPeriod) MonthlyCost(cost Money) Money {
+	if months := p.months(); months > 0 {
+		return NewMoney(cost.Cents() / months)
+	}
+
+	days := p.Interval
+	if p.Unit == PeriodWeekly {
+		days *= 7
+	}
+	if days <= 0 {
+		return NewMoney(0)
+	}
+
+	// Average days in a month is 30.44
+	// To avoid float calculations, multiply by 3044 and divide by 100 * days
+	return NewMoney(cost.Cents() * 3044 / (100 * days))
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// ParseBillingPeriod reads a period typed by a user, like "14", "2 недели",
+// "3 months" or "1 год". A bare number is a number of days.
+func ParseBillingPeriod(str string) (BillingPeriod, error) {
+	fields := strings.Fields(strings.ToLower(str))
+	if len(fields) == 0 || len(fields) > 2 {
+		return BillingPeriod{}, fmt.Errorf("invalid period: %q", str)
+	}
+
+	interval, err := strconv.Atoi(fields[0])
+	if err != nil || interval <= 0 {
+		return BillingPeriod{}, fmt.Errorf("invalid period interval: %q", fields[0])
+	}
+
+	unit := PeriodDaily
+	if len(fields) == 2 {
+		var ok bool
+		if unit, ok = parsePeriodUnit(fields[1]); !ok {
+			return BillingPeriod{}, fmt.Errorf("unknown period unit: %q", fields[1])
+		}
+	}
+
+	return Every(interval, unit), nil
+}
+
+var periodUnitPrefixes = []struct {
+	prefix string
+	unit   PeriodUnit
+}{
+	{"д", PeriodDaily}, {"day", PeriodDaily},
+	{"н", PeriodWeekly}, {"week", PeriodWeekly},
+	{"мес", PeriodMonthly}, {"month", PeriodMonthly},
+	{"кв", PeriodQuarterly}, {"quarter", PeriodQuarterly},
+	{"г", PeriodYearly}, {"л", PeriodYearly}, {"year", PeriodYearly},
+}
+
+func parsePeriodUnit(word string) (PeriodUnit, bool) {
+	for _, candidate := range periodUnitPrefixes {
+		if strings.HasPrefix(word, candidate.prefix) {
+			return candidate.unit, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBillingPeriodNext(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		period   BillingPeriod
+		from     time.Time
+		expected time.Time
+	}{
+		{"daily", Every(3, PeriodDaily), date(2025, 2, 27), date(2025, 3, 2)},
+		{"weekly", Every(2, PeriodWeekly), date(2025, 12, 25), date(2026, 1, 8)},
+		{"monthly keeps the day", Every(1, PeriodMonthly), date(2025, 1, 15), date(2025, 2, 15)},
+		{"monthly clamps to end of month", BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 31}, date(2025, 1, 31), date(2025, 2, 28)},
+		{"monthly returns to anchor", BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 31}, date(2025, 2, 28), date(2025, 3, 31)},
+		{"monthly clamps in leap year", BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 30}, date(2024, 1, 30), date(2024, 2, 29)},
+		{"every two months", Every(2, PeriodMonthly), date(2025, 11, 5), date(2026, 1, 5)},
+		{"quarterly", BillingPeriod{Unit: PeriodQuarterly, Interval: 1, AnchorDay: 31}, date(2025, 1, 31), date(2025, 4, 30)},
+		{"yearly from leap day", BillingPeriod{Unit: PeriodYearly, Interval: 1, AnchorDay: 29}, date(2024, 2, 29), date(2025, 2, 28)},
+		{"yearly back to leap day", BillingPeriod{Unit: PeriodYearly, Interval: 4, AnchorDay: 29}, date(2024, 2, 29), date(2028, 2, 29)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.period.Next(test.from); !got.Equal(test.expected) {
+				t.Errorf("Expected %s, got %s", test.expected.Format("2006-01-02"), got.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestBillingPeriodMonthlyCost(t *testing.T) {
+	tests := []struct {
+		period   BillingPeriod
+		cost     Money
+		expected Money
+	}{
+		{Every(1, PeriodMonthly), NewMoney(999), NewMoney(999)},
+		{Every(1, PeriodYearly), NewMoney(12000), NewMoney(1000)},
+		{Every(1, PeriodQuarterly), NewMoney(3000), NewMoney(1000)},
+		{Every(1, PeriodWeekly), NewMoney(700), NewMoney(3044)},
+		{Every(30, PeriodDaily), NewMoney(3000), NewMoney(3044)},
+	}
+
+	for _, test := range tests {
+		if got := test.period.MonthlyCost(test.cost); got != test.expected {
+			t.Errorf("%+v: expected %d, got %d", test.period, test.expected, got)
+		}
+	}
+}
+
+func TestPeriodFromDays(t *testing.T) {
+	tests := map[int]BillingPeriod{
+		1:   Every(1, PeriodDaily),
+		7:   Every(1, PeriodWeekly),
+		14:  Every(2, PeriodWeekly),
+		30:  Every(1, PeriodMonthly),
+		90:  Every(1, PeriodQuarterly),
+		365: Every(1, PeriodYearly),
+		45:  Every(45, PeriodDaily),
+	}
+
+	for days, expected := range tests {
+		if got := PeriodFromDays(days); got != expected {
+			t.Errorf("%d days: expected %+v, got %+v", days, expected, got)
+		}
+	}
+}
+
+func TestParseBillingPeriod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected BillingPeriod
+		hasError bool
+	}{
+		{"14", Every(14, PeriodDaily), false},
+		{"2 недели", Every(2, PeriodWeekly), false},
+		{"3 месяца", Every(3, PeriodMonthly), false},
+		{"1 квартал", Every(1, PeriodQuarterly), false},
+		{"2 года", Every(2, PeriodYearly), false},
+		{"5 лет", Every(5, PeriodYearly), false},
+		{"6 Months", Every(6, PeriodMonthly), false},
+		{"", BillingPeriod{}, true},
+		{"0", BillingPeriod{}, true},
+		{"2 столетия", BillingPeriod{}, true},
+		{"месяц", BillingPeriod{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := ParseBillingPeriod(test.input)
+			if test.hasError {
+				if err == nil {
+					t.Errorf("Expected error for %q", test.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// PriceChange is a period during which a subscription had the same cost and currency.
+// The current price has no EffectiveTo.
+type PriceChange struct {
+	ID             int        `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	Cost           Money      `json:"cost"`
+	Currency       Currency   `json:"currency"`
+	EffectiveFrom  time.Time  `json:"effective_from"`
+	EffectiveTo    *time.Time `json:"effective_to,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PriceIncrease describes a subscription getting more expensive in the same currency.
+type PriceIncrease struct {
+	SubscriptionID int       `json:"subscription_id"`
+	Name           string    `json:"name"`
+	OldCost        Money     `json:"old_cost"`
+	NewCost        Money     `json:"new_cost"`
+	Currency       Currency  `json:"currency"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// Difference returns how much more the subscription costs now.
+func (p PriceIncrease) Difference() Money {
+	return NewMoney(p.NewCost.Cents() - p.OldCost.Cents())
+}
+
+// Percent returns the increase relative to the old cost.
+func (p PriceIncrease) Percent() float64 {
+	if p.OldCost.IsZero() {
+		return 0
+	}
+	return float64(p.Difference().Cents()) * 100 / float64(p.OldCost.Cents())
+}
+
+// FindPriceIncreases returns the increases that took effect at or after since.
+// history must be ordered by subscription and EffectiveFrom. A change of currency
+// is not an increase, since the amounts can't be compared.
+func FindPriceIncreases(history []*PriceChange, since time.Time) []PriceIncrease {
+	var increases []PriceIncrease
+	for i := 1; i < len(history); i++ {
+		prev, cur := history[i-1], history[i]
+		if prev.SubscriptionID != cur.SubscriptionID || prev.Currency != cur.Currency {
+			continue
+		}
+		if cur.EffectiveFrom.Before(since) || cur.Cost.Cents() <= prev.Cost.Cents() {
+			continue
+		}
+
+		increases = append(increases, PriceIncrease{
+			SubscriptionID: cur.SubscriptionID,
+			OldCost:        prev.Cost,
+			NewCost:        cur.Cost,
+			Currency:       cur.Currency,
+			ChangedAt:      cur.EffectiveFrom,
+		})
+	}
+
+	return increases
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindPriceIncreases(t *testing.T) {
+	day := func(month, d int) time.Time {
+		return time.Date(2025, time.Month(month), d, 0, 0, 0, 0, time.UTC)
+	}
+
+	history := []*PriceChange{
+		// Netflix: increase, then a discount
+		{SubscriptionID: 1, Cost: NewMoney(999), Currency: CurrencyUSD, EffectiveFrom: day(1, 1)},
+		{SubscriptionID: 1, Cost: NewMoney(1299), Currency: CurrencyUSD, EffectiveFrom: day(3, 1)},
+		{SubscriptionID: 1, Cost: NewMoney(1099), Currency: CurrencyUSD, EffectiveFrom: day(5, 1)},
+		// Spotify: switched currency, then increased
+		{SubscriptionID: 2, Cost: NewMoney(499), Currency: CurrencyUSD, EffectiveFrom: day(1, 1)},
+		{SubscriptionID: 2, Cost: NewMoney(29900), Currency: CurrencyRUB, EffectiveFrom: day(2, 1)},
+		{SubscriptionID: 2, Cost: NewMoney(34900), Currency: CurrencyRUB, EffectiveFrom: day(6, 1)},
+		// Single price, must not be compared with the previous subscription
+		{SubscriptionID: 3, Cost: NewMoney(99999), Currency: CurrencyRUB, EffectiveFrom: day(1, 1)},
+	}
+
+	increases := FindPriceIncreases(history, day(1, 15))
+	if len(increases) != 2 {
+		t.Fatalf("Expected 2 increases, got %d: %+v", len(increases), increases)
+	}
+
+	first := increases[0]
+	if first.SubscriptionID != 1 || first.OldCost.Cents() != 999 || first.NewCost.Cents() != 1299 {
+		t.Errorf("Unexpected first increase: %+v", first)
+	}
+	if first.Difference().Cents() != 300 {
+		t.Errorf("Expected difference of 300, got %d", first.Difference().Cents())
+	}
+
+	second := increases[1]
+	if second.SubscriptionID != 2 || second.Currency != CurrencyRUB || !second.ChangedAt.Equal(day(6, 1)) {
+		t.Errorf("Unexpected second increase: %+v", second)
+	}
+
+	// Increases before the window are left out
+	if increases := FindPriceIncreases(history, day(4, 1)); len(increases) != 1 {
+		t.Errorf("Expected 1 increase since April, got %d", len(increases))
+	}
+}
+
+func TestPriceIncreasePercent(t *testing.T) {
+	increase := PriceIncrease{OldCost: NewMoney(1000), NewCost: NewMoney(1250)}
+	if increase.Percent() != 25 {
+		t.Errorf("Expected 25%%, got %v", increase.Percent())
+	}
+
+	if (PriceIncrease{NewCost: NewMoney(100)}).Percent() != 0 {
+		t.Error("Expected 0% for a previously free subscription")
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+type ReminderKind string
+
+const (
+	ReminderKindUpcoming    ReminderKind = "upcoming"     // N days before the payment date
+	ReminderKindDue         ReminderKind = "due"          // on (or after) the payment date
+	ReminderKindTrialEnding ReminderKind = "trial_ending" // N days before a trial converts to paid
+)
+
+// PaymentReminder records that a reminder was sent for one billing cycle,
+// identified by the subscription and its payment date.
+type PaymentReminder struct {
+	SubscriptionID int          `json:"subscription_id"`
+	UserID         int64        `json:"user_id"`
+	DueDate        time.Time    `json:"due_date"`
+	Kind           ReminderKind `json:"kind"`
+	SentAt         time.Time    `json:"sent_at"`
+	SnoozedUntil   *time.Time   `json:"snoozed_until,omitempty"`
+}
+
+// ReminderKindFor decides which reminder, if any, is due for a payment date.
+// Dates are compared by calendar day so the time of the check does not matter.
+func ReminderKindFor(nextPayment, now time.Time, daysBefore int) (ReminderKind, bool) {
+	due := DateOnly(nextPayment)
+	today := DateOnly(now)
+
+	if !today.Before(due) {
+		return ReminderKindDue, true
+	}
+	if daysBefore > 0 && !today.Before(due.AddDate(0, 0, -daysBefore)) {
+		return ReminderKindUpcoming, true
+	}
+	return "", false
+}
+
+// SubscriptionReminderKind is ReminderKindFor that warns about trials
+// trialDaysBefore days before they convert to paid instead.
+func SubscriptionReminderKind(sub *Subscription, now time.Time, daysBefore, trialDaysBefore int) (ReminderKind, bool) {
+	if !sub.IsTrial(now) {
+		return ReminderKindFor(sub.NextPayment, now, daysBefore)
+	}
+	if _, ok := ReminderKindFor(*sub.TrialEndsAt, now, trialDaysBefore); ok {
+		return ReminderKindTrialEnding, true
+	}
+	return "", false
+}
+
+// DateOnly strips the time of day, keeping the calendar date.
+func DateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
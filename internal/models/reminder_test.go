@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReminderKindFor(t *testing.T) {
+	due := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		daysBefore int
+		expected   ReminderKind
+		ok         bool
+	}{
+		{"too early", time.Date(2025, 3, 6, 23, 0, 0, 0, time.UTC), 3, "", false},
+		{"first upcoming day", time.Date(2025, 3, 7, 0, 30, 0, 0, time.UTC), 3, ReminderKindUpcoming, true},
+		{"day before", time.Date(2025, 3, 9, 18, 0, 0, 0, time.UTC), 3, ReminderKindUpcoming, true},
+		{"due date", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), 3, ReminderKindDue, true},
+		{"overdue", time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC), 3, ReminderKindDue, true},
+		{"upcoming disabled", time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC), 0, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, ok := ReminderKindFor(due, test.now, test.daysBefore)
+			if ok != test.ok || kind != test.expected {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", test.expected, test.ok, kind, ok)
+			}
+		})
+	}
+}
+
+func TestSubscriptionReminderKindForTrial(t *testing.T) {
+	trialEnd := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	sub := &Subscription{Active: true, NextPayment: trialEnd, TrialEndsAt: &trialEnd}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected ReminderKind
+		ok       bool
+	}{
+		{"before the warning", time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC), "", false},
+		{"trial ending", time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC), ReminderKindTrialEnding, true},
+		{"converted to paid", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), ReminderKindDue, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, ok := SubscriptionReminderKind(sub, test.now, 3, 5)
+			if ok != test.ok || kind != test.expected {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", test.expected, test.ok, kind, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UserState is the position of a user in a multi-step conversation
+// together with the answers collected so far.
+type UserState struct {
+	State     string    `json:"state"`
+	Data      StateData `json:"data"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewUserState returns an empty state in the given conversation step.
+func NewUserState(state string) *UserState {
+	return &UserState{
+		State: state,
+		Data:  make(StateData),
+	}
+}
+
+// Clone returns a copy that can be changed without affecting the original.
+func (s *UserState) Clone() *UserState {
+	clone := &UserState{
+		State:     s.State,
+		Data:      make(StateData, len(s.Data)),
+		UpdatedAt: s.UpdatedAt,
+	}
+	for key, value := range s.Data {
+		clone.Data[key] = value
+	}
+	return clone
+}
+
+// IsExpired reports whether the state was last changed more than ttl ago.
+func (s *UserState) IsExpired(now time.Time, ttl time.Duration) bool {
+	return ttl > 0 && now.Sub(s.UpdatedAt) > ttl
+}
+
+// StateData holds conversation answers. It is encoded to JSON together with
+// the type of every value, so a round trip through storage keeps models.Money,
+// Category and other values usable with type assertions.
+type StateData map[string]interface{}
+
+type typedValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (d StateData) MarshalJSON() ([]byte, error) {
+	encoded := make(map[string]typedValue, len(d))
+
+	for key, value := range d {
+		var typeName string
+		switch value.(type) {
+		case string:
+			typeName = "string"
+		case int:
+			typeName = "int"
+		case bool:
+			typeName = "bool"
+		case Money:
+			typeName = "money"
+		case Category:
+			typeName = "category"
+		case Currency:
+			typeName = "currency"
+		case time.Time:
+			typeName = "time"
+		case BillingPeriod:
+			typeName = "period"
+		case PaymentStatus:
+			typeName = "payment_status"
+		default:
+			return nil, fmt.Errorf("unsupported state value %q of type %T", key, value)
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode state value %q: %w", key, err)
+		}
+		encoded[key] = typedValue{Type: typeName, Value: raw}
+	}
+
+	return json.Marshal(encoded)
+}
+
+func (d *StateData) UnmarshalJSON(data []byte) error {
+	var encoded map[string]typedValue
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded := make(StateData, len(encoded))
+	for key, typed := range encoded {
+		var err error
+		switch typed.Type {
+		case "string":
+			decoded[key], err = decodeValue[string](typed.Value)
+		case "int":
+			decoded[key], err = decodeValue[int](typed.Value)
+		case "bool":
+			decoded[key], err = decodeValue[bool](typed.Value)
+		case "money":
+			decoded[key], err = decodeValue[Money](typed.Value)
+		case "category":
+			decoded[key], err = decodeValue[Category](typed.Value)
+		case "currency":
+			decoded[key], err = decodeValue[Currency](typed.Value)
+		case "time":
+			decoded[key], err = decodeValue[time.Time](typed.Value)
+		case "period":
+			decoded[key], err = decodeValue[BillingPeriod](typed.Value)
+		case "payment_status":
+			decoded[key], err = decodeValue[PaymentStatus](typed.Value)
+		default:
+			err = fmt.Errorf("unknown type %q", typed.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode state value %q: %w", key, err)
+		}
+	}
+
+	*d = decoded
+	return nil
+}
+
+func decodeValue[T any](raw json.RawMessage) (T, error) {
+	var value T
+	err := json.Unmarshal(raw, &value)
+	return value, err
+}
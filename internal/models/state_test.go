@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStateDataRoundTrip(t *testing.T) {
+	nextPayment := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	original := StateData{
+		"name":         "Netflix",
+		"edit_id":      30,
+		"period":       Every(2, PeriodMonthly),
+		"auto_renewal": true,
+		"cost":         Money(1599),
+		"category":     CategoryEntertainment,
+		"currency":     CurrencyUSD,
+		"next_payment": nextPayment,
+		"status":       PaymentStatusPending,
+	}
+
+	encoded, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	var decoded StateData
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	// Type assertions used by the handlers must keep working
+	if decoded["name"].(string) != "Netflix" {
+		t.Errorf("Name mismatch: %v", decoded["name"])
+	}
+	if decoded["edit_id"].(int) != 30 {
+		t.Errorf("ID mismatch: %v", decoded["edit_id"])
+	}
+	if decoded["period"].(BillingPeriod) != Every(2, PeriodMonthly) {
+		t.Errorf("Period mismatch: %v", decoded["period"])
+	}
+	if decoded["auto_renewal"].(bool) != true {
+		t.Errorf("Auto renewal mismatch: %v", decoded["auto_renewal"])
+	}
+	if decoded["cost"].(Money) != Money(1599) {
+		t.Errorf("Cost mismatch: %v", decoded["cost"])
+	}
+	if decoded["category"].(Category) != CategoryEntertainment {
+		t.Errorf("Category mismatch: %v", decoded["category"])
+	}
+	if decoded["currency"].(Currency) != CurrencyUSD {
+		t.Errorf("Currency mismatch: %v", decoded["currency"])
+	}
+	if !decoded["next_payment"].(time.Time).Equal(nextPayment) {
+		t.Errorf("Date mismatch: %v", decoded["next_payment"])
+	}
+	if decoded["status"].(PaymentStatus) != PaymentStatusPending {
+		t.Errorf("Status mismatch: %v", decoded["status"])
+	}
+}
+
+func TestStateDataRejectsUnknownTypes(t *testing.T) {
+	if _, err := json.Marshal(StateData{"bad": 1.5}); err == nil {
+		t.Error("Expected error for unsupported value type")
+	}
+
+	var decoded StateData
+	if err := json.Unmarshal([]byte(`{"bad": {"type": "float", "value": 1.5}}`), &decoded); err == nil {
+		t.Error("Expected error for unknown encoded type")
+	}
+}
+
+func TestUserStateExpiry(t *testing.T) {
+	now := time.Now()
+	state := &UserState{UpdatedAt: now.Add(-2 * time.Hour)}
+
+	if !state.IsExpired(now, time.Hour) {
+		t.Error("Expected state older than TTL to be expired")
+	}
+	if state.IsExpired(now, 3*time.Hour) {
+		t.Error("Expected state younger than TTL not to be expired")
+	}
+	if state.IsExpired(now, 0) {
+		t.Error("Expected zero TTL to disable expiry")
+	}
+}
+
+func TestUserStateClone(t *testing.T) {
+	state := NewUserState("idle")
+	state.Data["name"] = "Netflix"
+
+	clone := state.Clone()
+	clone.Data["name"] = "Spotify"
+	clone.State = "waiting_for_name"
+
+	if state.Data["name"] != "Netflix" || state.State != "idle" {
+		t.Error("Changing the clone modified the original state")
+	}
+}
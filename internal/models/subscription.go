@@ -1,16 +1,13 @@
 package models
 
 import (
+	"slices"
+	"strings"
 	"time"
 )
 
-type Currency string
-
-const (
-	CurrencyUSD Currency = "USD"
-	CurrencyRUB Currency = "RUB"
-)
-
+// Category is the key of a category of the user, see UserCategory. The keys
+// below belong to the categories every user starts with.
 type Category string
 
 const (
@@ -21,28 +18,166 @@ const (
 	CategoryOther         Category = "other"
 )
 
+type SubscriptionState string
+
+const (
+	SubscriptionStateTrial    SubscriptionState = "trial"    // free or discounted trial until TrialEndsAt
+	SubscriptionStateActive   SubscriptionState = "active"   // paid subscription
+	SubscriptionStateInactive SubscriptionState = "inactive" // canceled or expired
+)
+
 type Subscription struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Cost        Money     `json:"cost"`
-	Currency    Currency  `json:"currency"`
-	PeriodDays  int       `json:"period_days"`
-	NextPayment time.Time `json:"next_payment"`
-	Category    Category  `json:"category"`
-	AutoRenewal bool      `json:"auto_renewal"`
-	Active      bool      `json:"active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int           `json:"id"`
+	UserID      int64         `json:"user_id"`
+	Name        string        `json:"name"`
+	Cost        Money         `json:"cost"`
+	Currency    Currency      `json:"currency"`
+	Period      BillingPeriod `json:"period"`
+	NextPayment time.Time     `json:"next_payment"`
+	Category    Category      `json:"category"`
+	AutoRenewal bool          `json:"auto_renewal"`
+	Active      bool          `json:"active"`
+	TrialEndsAt *time.Time    `json:"trial_ends_at,omitempty"` // the trial converts to paid on this day, nil - no trial
+	TrialCost   Money         `json:"trial_cost"`              // price of the trial, zero for free trials
+	Tags        []string      `json:"tags,omitempty"`          // sorted, see NormalizeTags
+	Notes       string        `json:"notes,omitempty"`
+	URL         string        `json:"url,omitempty"` // where the subscription is managed or cancelled
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
 }
 
 type CreateSubscriptionRequest struct {
-	Name        string    `json:"name"`
-	Cost        Money     `json:"cost"`
-	Currency    Currency  `json:"currency"`
-	PeriodDays  int       `json:"period_days"`
-	NextPayment time.Time `json:"next_payment"`
-	Category    Category  `json:"category"`
-	AutoRenewal bool      `json:"auto_renewal"`
+	UserID      int64         `json:"user_id"`
+	Name        string        `json:"name"`
+	Cost        Money         `json:"cost"`
+	Currency    Currency      `json:"currency"`
+	Period      BillingPeriod `json:"period"`
+	NextPayment time.Time     `json:"next_payment"`
+	Category    Category      `json:"category"`
+	AutoRenewal bool          `json:"auto_renewal"`
+	TrialEndsAt *time.Time    `json:"trial_ends_at,omitempty"`
+	TrialCost   Money         `json:"trial_cost"`
+	Tags        []string      `json:"tags,omitempty"`
+	Notes       string        `json:"notes,omitempty"`
+	URL         string        `json:"url,omitempty"`
+}
+
+// UpdateSubscriptionRequest changes the fields that are set and keeps the rest.
+type UpdateSubscriptionRequest struct {
+	Name        *string        `json:"name,omitempty"`
+	Cost        *Money         `json:"cost,omitempty"`
+	Currency    *Currency      `json:"currency,omitempty"`
+	Period      *BillingPeriod `json:"period,omitempty"`
+	NextPayment *time.Time     `json:"next_payment,omitempty"`
+	Category    *Category      `json:"category,omitempty"`
+	AutoRenewal *bool          `json:"auto_renewal,omitempty"`
+	TrialEndsAt *time.Time     `json:"trial_ends_at,omitempty"`
+	TrialCost   *Money         `json:"trial_cost,omitempty"`
+	// Tags replaces every tag, an empty list removes them
+	Tags  *[]string `json:"tags,omitempty"`
+	Notes *string   `json:"notes,omitempty"`
+	URL   *string   `json:"url,omitempty"`
+	// EndTrial removes the trial, e.g. when it was set by mistake
+	EndTrial bool `json:"end_trial,omitempty"`
+}
+
+// Apply copies the requested changes onto the subscription.
+func (r *UpdateSubscriptionRequest) Apply(s *Subscription) {
+	if r.Name != nil {
+		s.Name = *r.Name
+	}
+	if r.Cost != nil {
+		s.Cost = *r.Cost
+	}
+	if r.Currency != nil {
+		s.Currency = *r.Currency
+	}
+	if r.Period != nil {
+		s.Period = *r.Period
+	}
+	if r.NextPayment != nil {
+		s.NextPayment = *r.NextPayment
+	}
+	if r.Category != nil {
+		s.Category = *r.Category
+	}
+	if r.AutoRenewal != nil {
+		s.AutoRenewal = *r.AutoRenewal
+	}
+	if r.TrialCost != nil {
+		s.TrialCost = *r.TrialCost
+	}
+	if r.Tags != nil {
+		s.Tags = slices.Clone(*r.Tags)
+	}
+	if r.Notes != nil {
+		s.Notes = *r.Notes
+	}
+	if r.URL != nil {
+		s.URL = *r.URL
+	}
+	if r.EndTrial {
+		s.TrialEndsAt = nil
+		s.TrialCost = 0
+	}
+	// The first paid payment is due when the trial ends
+	if r.TrialEndsAt != nil {
+		trialEndsAt := *r.TrialEndsAt
+		s.TrialEndsAt = &trialEndsAt
+		s.NextPayment = trialEndsAt
+	}
+	// A new period or payment date moves the billing day of month
+	if r.Period != nil || r.NextPayment != nil || r.TrialEndsAt != nil {
+		s.Period = s.Period.AnchoredTo(s.NextPayment)
+	}
+}
+
+// IsTrial reports whether the trial of an active subscription has not ended yet
+func (s *Subscription) IsTrial(now time.Time) bool {
+	return s.Active && s.TrialEndsAt != nil && DateOnly(now).Before(DateOnly(*s.TrialEndsAt))
+}
+
+func (s *Subscription) State(now time.Time) SubscriptionState {
+	switch {
+	case !s.Active:
+		return SubscriptionStateInactive
+	case s.IsTrial(now):
+		return SubscriptionStateTrial
+	default:
+		return SubscriptionStateActive
+	}
+}
+
+// TrialDaysLeft returns the number of days until the trial converts to paid
+func (s *Subscription) TrialDaysLeft(now time.Time) int {
+	if !s.IsTrial(now) {
+		return 0
+	}
+	return int(DateOnly(*s.TrialEndsAt).Sub(DateOnly(now)).Hours() / 24)
+}
+
+// HasTag reports whether the subscription is tagged with tag, see NormalizeTags
+func (s *Subscription) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
+
+// NormalizeTags lowercases and trims tags, drops a leading "#" and removes
+// empty tags and duplicates. The result is sorted, like tags read from storage.
+func NormalizeTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+		if tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// ParseTags reads a comma separated list of tags, like "семья, #работа"
+func ParseTags(s string) []string {
+	return NormalizeTags(strings.Split(s, ","))
 }
 
 func (s *Subscription) IsPaymentDue() bool {
@@ -50,6 +185,23 @@ func (s *Subscription) IsPaymentDue() bool {
 }
 
 func (s *Subscription) UpdateNextPayment() {
-	s.NextPayment = s.NextPayment.AddDate(0, 0, s.PeriodDays)
+	s.NextPayment = s.Period.Next(s.NextPayment)
 	s.UpdatedAt = time.Now()
 }
+
+// CatchUp advances the subscription past every payment date up to and
+// including today and returns those dates, oldest first.
+func (s *Subscription) CatchUp(now time.Time) []time.Time {
+	if s.Period.Validate() != nil {
+		return nil
+	}
+
+	today := DateOnly(now)
+	var dates []time.Time
+	for !DateOnly(s.NextPayment).After(today) {
+		dates = append(dates, s.NextPayment)
+		s.UpdateNextPayment()
+	}
+
+	return dates
+}
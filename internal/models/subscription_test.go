@@ -0,0 +1,197 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionCatchUp(t *testing.T) {
+	now := time.Date(2025, 3, 20, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		nextPayment time.Time
+		period      BillingPeriod
+		expected    []time.Time
+		next        time.Time
+	}{
+		{
+			name:        "not due yet",
+			nextPayment: time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC),
+			period:      Every(1, PeriodMonthly),
+			expected:    nil,
+			next:        time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "due today",
+			nextPayment: time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC),
+			period:      Every(1, PeriodMonthly),
+			expected:    []time.Time{time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)},
+			next:        time.Date(2025, 4, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "several missed weeks",
+			nextPayment: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			period:      Every(1, PeriodWeekly),
+			expected: []time.Time{
+				time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			},
+			next: time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "invalid period",
+			nextPayment: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			period:      BillingPeriod{},
+			expected:    nil,
+			next:        time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sub := &Subscription{NextPayment: test.nextPayment, Period: test.period}
+
+			dates := sub.CatchUp(now)
+			if len(dates) != len(test.expected) {
+				t.Fatalf("Expected %d payment dates, got %d", len(test.expected), len(dates))
+			}
+			for i := range dates {
+				if !dates[i].Equal(test.expected[i]) {
+					t.Errorf("Date %d: expected %s, got %s", i, test.expected[i], dates[i])
+				}
+			}
+			if !sub.NextPayment.Equal(test.next) {
+				t.Errorf("Expected next payment %s, got %s", test.next, sub.NextPayment)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionRequestApply(t *testing.T) {
+	sub := &Subscription{
+		Name:        "Netflix",
+		Cost:        Money(999),
+		Currency:    CurrencyUSD,
+		Period:      BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 31},
+		Category:    CategoryEntertainment,
+		AutoRenewal: true,
+	}
+
+	cost := Money(1299)
+	autoRenewal := false
+	req := &UpdateSubscriptionRequest{Cost: &cost, AutoRenewal: &autoRenewal}
+	req.Apply(sub)
+
+	if sub.Cost != cost {
+		t.Errorf("Expected cost %s, got %s", cost, sub.Cost)
+	}
+	if sub.AutoRenewal {
+		t.Error("Expected auto renewal to be disabled")
+	}
+
+	// Fields that were not requested stay the same
+	if sub.Name != "Netflix" || sub.Currency != CurrencyUSD || sub.Period.AnchorDay != 31 || sub.Category != CategoryEntertainment {
+		t.Errorf("Unrequested fields changed: %+v", sub)
+	}
+}
+
+func TestUpdateSubscriptionRequestTags(t *testing.T) {
+	sub := &Subscription{Name: "Netflix", Tags: []string{"семья"}, Notes: "общий аккаунт"}
+
+	tags := []string{"работа"}
+	(&UpdateSubscriptionRequest{Tags: &tags}).Apply(sub)
+	tags[0] = "changed"
+	if !slices.Equal(sub.Tags, []string{"работа"}) || sub.Notes != "общий аккаунт" {
+		t.Errorf("Expected the tags replaced and the notes kept, got %+v", sub)
+	}
+
+	empty := []string{}
+	(&UpdateSubscriptionRequest{Tags: &empty}).Apply(sub)
+	if len(sub.Tags) != 0 || sub.HasTag("работа") {
+		t.Errorf("Expected the tags removed, got %v", sub.Tags)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"семья, #Работа", []string{"работа", "семья"}},
+		{" отменить ,, отменить, #", []string{"отменить"}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		if got := ParseTags(test.input); !slices.Equal(got, test.want) {
+			t.Errorf("ParseTags(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestUpdateSubscriptionRequestMovesAnchor(t *testing.T) {
+	sub := &Subscription{
+		Period:      BillingPeriod{Unit: PeriodMonthly, Interval: 1, AnchorDay: 31},
+		NextPayment: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	date := time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)
+	req := &UpdateSubscriptionRequest{NextPayment: &date}
+	req.Apply(sub)
+
+	if sub.Period.AnchorDay != 10 {
+		t.Errorf("Expected anchor day 10, got %d", sub.Period.AnchorDay)
+	}
+}
+
+func TestSubscriptionTrialState(t *testing.T) {
+	now := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+	trialEnd := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	sub := &Subscription{Active: true, TrialEndsAt: &trialEnd}
+
+	if state := sub.State(now); state != SubscriptionStateTrial {
+		t.Errorf("Expected trial state, got %s", state)
+	}
+	if days := sub.TrialDaysLeft(now); days != 14 {
+		t.Errorf("Expected 14 trial days left, got %d", days)
+	}
+	if state := sub.State(trialEnd); state != SubscriptionStateActive {
+		t.Errorf("Expected trial to convert on its last day, got %s", state)
+	}
+
+	sub.Active = false
+	if state := sub.State(now); state != SubscriptionStateInactive {
+		t.Errorf("Expected inactive state, got %s", state)
+	}
+}
+
+func TestUpdateSubscriptionRequestTrial(t *testing.T) {
+	sub := &Subscription{
+		Active:      true,
+		NextPayment: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Period:      Every(1, PeriodMonthly),
+	}
+
+	trialEnd := time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)
+	trialCost := NewMoney(199)
+	req := &UpdateSubscriptionRequest{TrialEndsAt: &trialEnd, TrialCost: &trialCost}
+	req.Apply(sub)
+
+	if sub.TrialEndsAt == nil || !sub.TrialEndsAt.Equal(trialEnd) || sub.TrialCost != trialCost {
+		t.Fatalf("Trial not applied: %v %v", sub.TrialEndsAt, sub.TrialCost)
+	}
+	if !sub.NextPayment.Equal(trialEnd) {
+		t.Errorf("Expected first paid payment on %v, got %v", trialEnd, sub.NextPayment)
+	}
+	if sub.Period.AnchorDay != 20 {
+		t.Errorf("Expected billing day 20, got %d", sub.Period.AnchorDay)
+	}
+
+	(&UpdateSubscriptionRequest{EndTrial: true}).Apply(sub)
+	if sub.TrialEndsAt != nil || sub.TrialCost != 0 {
+		t.Errorf("Expected trial to be removed, got %v %v", sub.TrialEndsAt, sub.TrialCost)
+	}
+}
@@ -0,0 +1,72 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// FixtureProvider reads rates from a local JSON file, so conversion works
+// without network access:
+//
+//	{"base": "USD", "rates": {"EUR": 0.92, "RUB": 90.5}}
+//
+// Each entry is the price of one unit of base in that currency.
+type FixtureProvider struct {
+	path string
+}
+
+func NewFixtureProvider(path string) *FixtureProvider {
+	return &FixtureProvider{path: path}
+}
+
+type fixture struct {
+	Base  models.Currency             `json:"base"`
+	Rates map[models.Currency]float64 `json:"rates"`
+}
+
+func (p *FixtureProvider) Name() string {
+	return "fixture"
+}
+
+func (p *FixtureProvider) Rates(ctx context.Context) ([]*models.ExchangeRate, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates fixture: %w", err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse rates fixture: %w", err)
+	}
+	if !f.Base.IsValid() {
+		return nil, fmt.Errorf("unsupported base currency %q in rates fixture", f.Base)
+	}
+
+	now := time.Now()
+	var rates []*models.ExchangeRate
+	for currency, rate := range f.Rates {
+		if !currency.IsValid() {
+			return nil, fmt.Errorf("unsupported currency %q in rates fixture", currency)
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("rate of %s must be positive", currency)
+		}
+		if currency == f.Base {
+			continue
+		}
+
+		rates = append(rates, &models.ExchangeRate{
+			From:      f.Base,
+			To:        currency,
+			Rate:      rate,
+			Source:    p.Name(),
+			UpdatedAt: now,
+		})
+	}
+
+	return rates, nil
+}
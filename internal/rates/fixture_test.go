@@ -0,0 +1,62 @@
+package rates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sub-cos-counter/internal/models"
+	"testing"
+)
+
+func writeFixture(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func TestFixtureProvider(t *testing.T) {
+	path := writeFixture(t, `{"base": "USD", "rates": {"USD": 1, "EUR": 0.92, "RUB": 90.5}}`)
+
+	rates, err := NewFixtureProvider(path).Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("Expected 2 rates, got %d", len(rates))
+	}
+
+	table := models.NewRateTable(rates, models.CurrencyUSD)
+	if rate, ok := table.Rate(models.CurrencyUSD, models.CurrencyRUB); !ok || rate != 90.5 {
+		t.Errorf("Expected USD/RUB 90.5, got %v", rate)
+	}
+	for _, rate := range rates {
+		if rate.Source != "fixture" {
+			t.Errorf("Expected fixture source, got %q", rate.Source)
+		}
+	}
+}
+
+func TestFixtureProviderErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":      `{"base": `,
+		"unknown base":      `{"base": "XXX", "rates": {"EUR": 0.92}}`,
+		"unknown currency":  `{"base": "USD", "rates": {"XXX": 1.5}}`,
+		"non-positive rate": `{"base": "USD", "rates": {"EUR": 0}}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewFixtureProvider(writeFixture(t, content)).Rates(context.Background()); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := NewFixtureProvider("missing.json").Rates(context.Background()); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
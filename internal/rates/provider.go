@@ -0,0 +1,16 @@
+// Package rates fetches currency exchange rates from external sources.
+package rates
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+)
+
+// Provider is a source of exchange rates. Rates it returns are stored and
+// replace earlier rates of the same currency pair.
+type Provider interface {
+	// Name identifies the provider in the stored rates and in logs.
+	Name() string
+	// Rates returns the current exchange rates.
+	Rates(ctx context.Context) ([]*models.ExchangeRate, error)
+}
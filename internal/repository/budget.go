@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// budgetColumns are read by scanBudget, in the same order
+const budgetColumns = `id, user_id, category, currency, amount, created_at, updated_at`
+
+type BudgetRepository struct {
+	db DBTX
+}
+
+func NewBudgetRepository(db *pgxpool.Pool) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+// Set saves the budget, replacing the limit of the budget the user has in
+// the same category and currency.
+func (r *BudgetRepository) Set(ctx context.Context, budget *models.Budget) error {
+	query := `
+		INSERT INTO budgets (user_id, category, currency, amount)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, category, currency) DO UPDATE
+		SET amount = EXCLUDED.amount, updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, query,
+		budget.UserID, budget.Category, budget.Currency, budget.Limit,
+	).Scan(&budget.ID, &budget.CreatedAt, &budget.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set budget: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns the budgets of the user, the overall ones first
+func (r *BudgetRepository) GetAll(ctx context.Context, userID int64) ([]*models.Budget, error) {
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets WHERE user_id = $1
+		ORDER BY category ASC, currency ASC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []*models.Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, budget)
+	}
+
+	return budgets, rows.Err()
+}
+
+func (r *BudgetRepository) Delete(ctx context.Context, userID int64, category models.Category, currency models.Currency) error {
+	query := `DELETE FROM budgets WHERE user_id = $1 AND category = $2 AND currency = $3`
+
+	tag, err := r.db.Exec(ctx, query, userID, category, currency)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("budget %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+func scanBudget(row pgx.Row) (*models.Budget, error) {
+	var budget models.Budget
+	err := row.Scan(
+		&budget.ID, &budget.UserID, &budget.Category, &budget.Currency, &budget.Limit,
+		&budget.CreatedAt, &budget.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
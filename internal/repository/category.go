@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrDuplicateCategory is returned by Create when the user already has a
+// category with the same key.
+var ErrDuplicateCategory = errors.New("category already exists")
+
+// categoryColumns are read by scanCategory, in the same order
+const categoryColumns = `id, user_id, key, name, emoji, archived, created_at, updated_at`
+
+type CategoryRepository struct {
+	db DBTX
+}
+
+func NewCategoryRepository(db *pgxpool.Pool) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// Create adds a category of the user. A key the user already has is left
+// as is and gets ErrDuplicateCategory, so defaults can be created twice.
+func (r *CategoryRepository) Create(ctx context.Context, req *models.CreateCategoryRequest) (*models.UserCategory, error) {
+	query := `
+		INSERT INTO categories (user_id, key, name, emoji)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(r.db.QueryRow(ctx, query, req.UserID, req.Key, req.Name, req.Emoji))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDuplicateCategory
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+
+	return category, nil
+}
+
+// Restore saves a category from a backup, replacing the name, emoji and
+// archived flag of the category with the same key if the user has one.
+func (r *CategoryRepository) Restore(ctx context.Context, category *models.UserCategory) error {
+	query := `
+		INSERT INTO categories (user_id, key, name, emoji, archived, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET name = EXCLUDED.name, emoji = EXCLUDED.emoji, archived = EXCLUDED.archived, updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.Exec(ctx, query,
+		category.UserID, category.Key, category.Name, category.Emoji, category.Archived, category.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore category: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns the categories of the user, archived ones included, oldest first
+func (r *CategoryRepository) GetAll(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories WHERE user_id = $1
+		ORDER BY id ASC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*models.UserCategory
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// Update saves the name, emoji and archived flag of the category
+func (r *CategoryRepository) Update(ctx context.Context, category *models.UserCategory) error {
+	query := `
+		UPDATE categories SET name = $3, emoji = $4, archived = $5, updated_at = NOW()
+		WHERE user_id = $1 AND key = $2
+		RETURNING updated_at`
+
+	err := r.db.QueryRow(ctx, query,
+		category.UserID, category.Key, category.Name, category.Emoji, category.Archived,
+	).Scan(&category.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("category %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return nil
+}
+
+func scanCategory(row pgx.Row) (*models.UserCategory, error) {
+	var category models.UserCategory
+	err := row.Scan(
+		&category.ID, &category.UserID, &category.Key, &category.Name, &category.Emoji,
+		&category.Archived, &category.CreatedAt, &category.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExchangeRateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExchangeRateRepository(db *pgxpool.Pool) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+// Save stores the rate, replacing the previous rate of the same currency pair
+func (r *ExchangeRateRepository) Save(ctx context.Context, rate *models.ExchangeRate) error {
+	query := `
+		INSERT INTO exchange_rates (currency_from, currency_to, rate, source, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (currency_from, currency_to) DO UPDATE
+		SET rate = EXCLUDED.rate, source = EXCLUDED.source, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.Exec(ctx, query, rate.From, rate.To, rate.Rate, rate.Source); err != nil {
+		return fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ExchangeRateRepository) GetAll(ctx context.Context) ([]*models.ExchangeRate, error) {
+	query := `
+		SELECT currency_from, currency_to, rate, source, updated_at
+		FROM exchange_rates ORDER BY currency_from, currency_to`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []*models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.From, &rate.To, &rate.Rate, &rate.Source, &rate.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, &rate)
+	}
+
+	return rates, nil
+}
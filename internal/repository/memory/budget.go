@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"unicode/utf8"
+)
+
+type budgets struct {
+	conn
+}
+
+func (r *budgets) Set(ctx context.Context, budget *models.Budget) error {
+	t := r.lock()
+	defer r.unlock()
+
+	if err := checkBudget(budget); err != nil {
+		return fmt.Errorf("failed to set budget: %w", err)
+	}
+
+	updatedAt := now()
+	if i := t.findBudget(budget.UserID, budget.Category, budget.Currency); i >= 0 {
+		existing := t.budgets[i]
+		existing.Limit = budget.Limit
+		existing.UpdatedAt = updatedAt
+		budget.ID, budget.CreatedAt, budget.UpdatedAt = existing.ID, existing.CreatedAt, existing.UpdatedAt
+		return nil
+	}
+
+	r.db.lastBudgetID++
+	budget.ID = r.db.lastBudgetID
+	budget.CreatedAt, budget.UpdatedAt = updatedAt, updatedAt
+	b := *budget
+	t.budgets = append(t.budgets, &b)
+
+	return nil
+}
+
+func (r *budgets) GetAll(ctx context.Context, userID int64) ([]*models.Budget, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	var result []*models.Budget
+	for _, budget := range t.budgets {
+		if budget.UserID == userID {
+			b := *budget
+			result = append(result, &b)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b *models.Budget) int {
+		return cmp.Or(cmp.Compare(a.Category, b.Category), cmp.Compare(a.Currency, b.Currency))
+	})
+
+	return result, nil
+}
+
+func (r *budgets) Delete(ctx context.Context, userID int64, category models.Category, currency models.Currency) error {
+	t := r.lock()
+	defer r.unlock()
+
+	i := t.findBudget(userID, category, currency)
+	if i < 0 {
+		return fmt.Errorf("budget %w", repository.ErrNotFound)
+	}
+	t.budgets = slices.Delete(t.budgets, i, i+1)
+
+	return nil
+}
+
+func (t *tables) findBudget(userID int64, category models.Category, currency models.Currency) int {
+	return slices.IndexFunc(t.budgets, func(b *models.Budget) bool {
+		return b.UserID == userID && b.Category == category && b.Currency == currency
+	})
+}
+
+// checkBudget applies the constraints of the budgets table
+func checkBudget(budget *models.Budget) error {
+	if err := checkCurrency(budget.Currency); err != nil {
+		return err
+	}
+	switch {
+	case utf8.RuneCountInString(string(budget.Category)) > 50:
+		return fmt.Errorf("budget category is too long")
+	case !budget.Limit.IsPositive():
+		return fmt.Errorf("budget limit must be positive")
+	}
+	return nil
+}
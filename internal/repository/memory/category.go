@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+	"unicode/utf8"
+)
+
+type categories struct {
+	conn
+}
+
+func (r *categories) Create(ctx context.Context, req *models.CreateCategoryRequest) (*models.UserCategory, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	if t.findCategory(req.UserID, req.Key) != nil {
+		return nil, repository.ErrDuplicateCategory
+	}
+
+	createdAt := now()
+	category := &models.UserCategory{
+		UserID:    req.UserID,
+		Key:       req.Key,
+		Name:      req.Name,
+		Emoji:     req.Emoji,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	if err := checkCategory(category); err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+
+	r.db.lastCategoryID++
+	category.ID = r.db.lastCategoryID
+	t.categories = append(t.categories, category)
+
+	c := *category
+	return &c, nil
+}
+
+func (r *categories) Restore(ctx context.Context, category *models.UserCategory) error {
+	t := r.lock()
+	defer r.unlock()
+
+	if err := checkCategory(category); err != nil {
+		return fmt.Errorf("failed to restore category: %w", err)
+	}
+
+	if existing := t.findCategory(category.UserID, category.Key); existing != nil {
+		existing.Name = category.Name
+		existing.Emoji = category.Emoji
+		existing.Archived = category.Archived
+		existing.UpdatedAt = now()
+		return nil
+	}
+
+	restored := *category
+	r.db.lastCategoryID++
+	restored.ID = r.db.lastCategoryID
+	restored.CreatedAt = category.CreatedAt.Truncate(time.Microsecond)
+	restored.UpdatedAt = now()
+	t.categories = append(t.categories, &restored)
+
+	return nil
+}
+
+func (r *categories) GetAll(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	var result []*models.UserCategory
+	for _, category := range t.categories {
+		if category.UserID == userID {
+			c := *category
+			result = append(result, &c)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *categories) Update(ctx context.Context, category *models.UserCategory) error {
+	t := r.lock()
+	defer r.unlock()
+
+	existing := t.findCategory(category.UserID, category.Key)
+	if existing == nil {
+		return fmt.Errorf("category %w", repository.ErrNotFound)
+	}
+	if err := checkCategory(category); err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	existing.Name = category.Name
+	existing.Emoji = category.Emoji
+	existing.Archived = category.Archived
+	existing.UpdatedAt = now()
+	category.UpdatedAt = existing.UpdatedAt
+
+	return nil
+}
+
+func (t *tables) findCategory(userID int64, key models.Category) *models.UserCategory {
+	for _, category := range t.categories {
+		if category.UserID == userID && category.Key == key {
+			return category
+		}
+	}
+	return nil
+}
+
+// checkCategory applies the length limits of the categories table
+func checkCategory(category *models.UserCategory) error {
+	switch {
+	case utf8.RuneCountInString(string(category.Key)) > 50:
+		return fmt.Errorf("category key is too long")
+	case utf8.RuneCountInString(category.Name) > 50:
+		return fmt.Errorf("category name is too long")
+	case utf8.RuneCountInString(category.Emoji) > 16:
+		return fmt.Errorf("category emoji is too long")
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Package memory keeps the data of every repository in memory. It behaves
+// like the PostgreSQL repositories, repotest checks that, and is used to test
+// services without a database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sync"
+	"time"
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// db is one in-memory database shared by the repositories of a Storage
+type db struct {
+	mu     sync.Mutex
+	tables *tables
+	// Sequences are not rolled back with a transaction, like in PostgreSQL
+	lastSubscriptionID int
+	lastPaymentID      int
+	lastPriceID        int
+	lastCategoryID     int
+	lastBudgetID       int
+}
+
+type tables struct {
+	subscriptions map[int]*models.Subscription
+	payments      map[int]*models.Payment
+	paymentKeys   map[string]int // idempotency key to payment ID
+	prices        []*models.PriceChange
+	settings      map[int64]models.Currency
+	categories    []*models.UserCategory // ordered by ID
+	budgets       []*models.Budget
+	rates         map[[2]models.Currency]*models.ExchangeRate
+	reminders     map[reminderKey]*reminder
+}
+
+type reminderKey struct {
+	subscriptionID int
+	dueDate        time.Time
+	kind           models.ReminderKind
+}
+
+type reminder struct {
+	userID       int64
+	snoozedUntil *time.Time
+}
+
+// New returns an empty storage
+func New() *repository.Storage {
+	d := &db{tables: &tables{
+		subscriptions: make(map[int]*models.Subscription),
+		payments:      make(map[int]*models.Payment),
+		paymentKeys:   make(map[string]int),
+		settings:      make(map[int64]models.Currency),
+		rates:         make(map[[2]models.Currency]*models.ExchangeRate),
+		reminders:     make(map[reminderKey]*reminder),
+	}}
+
+	c := conn{db: d}
+	return &repository.Storage{
+		Stores:        c.stores(),
+		ExchangeRates: &exchangeRates{c},
+		Reminders:     &reminders{c},
+		UnitOfWork:    d,
+	}
+}
+
+// Do runs fn while holding the database, so transactions run one after
+// another like with FOR UPDATE locks, and restores the data when fn fails.
+func (d *db) Do(ctx context.Context, fn func(tx repository.Stores) error) (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	snapshot := d.tables.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			d.tables = snapshot
+		}
+	}()
+
+	if err := fn(conn{db: d, inTx: true}.stores()); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// conn is how a repository reaches the database. Inside a transaction the
+// database is already held by Do.
+type conn struct {
+	db   *db
+	inTx bool
+}
+
+func (c conn) lock() *tables {
+	if !c.inTx {
+		c.db.mu.Lock()
+	}
+	return c.db.tables
+}
+
+func (c conn) unlock() {
+	if !c.inTx {
+		c.db.mu.Unlock()
+	}
+}
+
+func (c conn) stores() repository.Stores {
+	return repository.Stores{
+		Subscriptions: &subscriptions{c},
+		Payments:      &payments{c},
+		PriceHistory:  &priceHistory{c},
+		Settings:      &settings{c},
+		Categories:    &categories{c},
+		Budgets:       &budgets{c},
+	}
+}
+
+func (t *tables) clone() *tables {
+	clone := &tables{
+		subscriptions: make(map[int]*models.Subscription, len(t.subscriptions)),
+		payments:      make(map[int]*models.Payment, len(t.payments)),
+		paymentKeys:   make(map[string]int, len(t.paymentKeys)),
+		prices:        make([]*models.PriceChange, 0, len(t.prices)),
+		settings:      make(map[int64]models.Currency, len(t.settings)),
+		categories:    make([]*models.UserCategory, 0, len(t.categories)),
+		budgets:       make([]*models.Budget, 0, len(t.budgets)),
+		rates:         make(map[[2]models.Currency]*models.ExchangeRate, len(t.rates)),
+		reminders:     make(map[reminderKey]*reminder, len(t.reminders)),
+	}
+
+	for id, sub := range t.subscriptions {
+		clone.subscriptions[id] = copySubscription(sub)
+	}
+	for id, payment := range t.payments {
+		clone.payments[id] = copyPayment(payment)
+	}
+	for key, id := range t.paymentKeys {
+		clone.paymentKeys[key] = id
+	}
+	for _, change := range t.prices {
+		clone.prices = append(clone.prices, copyPriceChange(change))
+	}
+	for userID, currency := range t.settings {
+		clone.settings[userID] = currency
+	}
+	for _, category := range t.categories {
+		c := *category
+		clone.categories = append(clone.categories, &c)
+	}
+	for _, budget := range t.budgets {
+		b := *budget
+		clone.budgets = append(clone.budgets, &b)
+	}
+	for pair, rate := range t.rates {
+		r := *rate
+		clone.rates[pair] = &r
+	}
+	for key, r := range t.reminders {
+		c := *r
+		if r.snoozedUntil != nil {
+			until := *r.snoozedUntil
+			c.snoozedUntil = &until
+		}
+		clone.reminders[key] = &c
+	}
+
+	return clone
+}
+
+// now returns the time with the precision PostgreSQL keeps
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func checkCurrency(currency models.Currency) error {
+	if !currencyCode.MatchString(string(currency)) {
+		return fmt.Errorf("invalid currency code %q", currency)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sub-cos-counter/internal/models"
+)
+
+type exchangeRates struct {
+	conn
+}
+
+func (r *exchangeRates) Save(ctx context.Context, rate *models.ExchangeRate) error {
+	t := r.lock()
+	defer r.unlock()
+
+	if rate.Rate <= 0 {
+		return fmt.Errorf("failed to save exchange rate: rate must be positive")
+	}
+
+	saved := *rate
+	saved.UpdatedAt = now()
+	t.rates[[2]models.Currency{rate.From, rate.To}] = &saved
+
+	return nil
+}
+
+func (r *exchangeRates) GetAll(ctx context.Context) ([]*models.ExchangeRate, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	var rates []*models.ExchangeRate
+	for _, rate := range t.rates {
+		r := *rate
+		rates = append(rates, &r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].From != rates[j].From {
+			return rates[i].From < rates[j].From
+		}
+		return rates[i].To < rates[j].To
+	})
+
+	return rates, nil
+}
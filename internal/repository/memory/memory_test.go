@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/repotest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repository.Storage {
+		return New()
+	})
+}
@@ -0,0 +1,313 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+type payments struct {
+	conn
+}
+
+func (r *payments) Create(ctx context.Context, req *models.CreatePaymentRequest) (*models.Payment, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	if req.IdempotencyKey != "" {
+		if _, ok := t.paymentKeys[req.IdempotencyKey]; ok {
+			return nil, repository.ErrDuplicatePayment
+		}
+	}
+
+	createdAt := now()
+	payment := &models.Payment{
+		UserID:         req.UserID,
+		SubscriptionID: req.SubscriptionID,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		PaidAt:         createdAt,
+		Status:         req.Status,
+		Note:           req.Note,
+		CreatedAt:      createdAt,
+	}
+	if req.PaidAt != nil {
+		payment.PaidAt = req.PaidAt.Truncate(time.Microsecond)
+	}
+	if err := t.checkPayment(payment); err != nil {
+		return nil, fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	r.db.lastPaymentID++
+	payment.ID = r.db.lastPaymentID
+	t.payments[payment.ID] = payment
+	if req.IdempotencyKey != "" {
+		t.paymentKeys[req.IdempotencyKey] = payment.ID
+	}
+
+	return copyPayment(payment), nil
+}
+
+func (r *payments) Restore(ctx context.Context, payment *models.Payment) (int, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	restored := copyPayment(payment)
+	if err := t.checkPayment(restored); err != nil {
+		return 0, fmt.Errorf("failed to restore payment: %w", err)
+	}
+
+	r.db.lastPaymentID++
+	restored.ID = r.db.lastPaymentID
+	t.payments[restored.ID] = restored
+
+	return restored.ID, nil
+}
+
+func (r *payments) GetByID(ctx context.Context, userID int64, id int) (*models.Payment, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	payment, ok := t.payments[id]
+	if !ok || payment.UserID != userID {
+		return nil, fmt.Errorf("payment %w", repository.ErrNotFound)
+	}
+
+	return copyPayment(payment), nil
+}
+
+func (r *payments) Update(ctx context.Context, payment *models.Payment) error {
+	t := r.lock()
+	defer r.unlock()
+
+	current, ok := t.payments[payment.ID]
+	if !ok || current.UserID != payment.UserID {
+		return fmt.Errorf("payment %w", repository.ErrNotFound)
+	}
+
+	updated := copyPayment(current)
+	updated.Amount = payment.Amount
+	updated.PaidAt = payment.PaidAt.Truncate(time.Microsecond)
+	updated.Status = payment.Status
+	updated.Note = payment.Note
+	if err := t.checkPayment(updated); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	t.payments[updated.ID] = updated
+
+	return nil
+}
+
+func (r *payments) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.Payment, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterPayments(func(payment *models.Payment) bool {
+		return payment.UserID == userID && payment.SubscriptionID == subscriptionID
+	})
+	sortNewestFirst(result)
+
+	return result, nil
+}
+
+func (r *payments) GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error) {
+	startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	endOfMonth := startOfMonth.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+
+	t := r.lock()
+	defer r.unlock()
+
+	var summaries []models.PaymentSummary
+	for _, payment := range t.completedPayments(userID, startOfMonth, endOfMonth) {
+		summaries = addToSummary(summaries, payment)
+	}
+
+	return summaries, nil
+}
+
+func (r *payments) GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	analytics := make(map[models.Category][]models.PaymentSummary)
+	for _, payment := range t.completedPayments(userID, startDate, endDate) {
+		sub, ok := t.subscriptions[payment.SubscriptionID]
+		if !ok {
+			continue
+		}
+		analytics[sub.Category] = addToSummary(analytics[sub.Category], payment)
+	}
+
+	return analytics, nil
+}
+
+func (r *payments) GetAllPayments(ctx context.Context, userID int64, limit int) ([]*models.Payment, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterPayments(func(payment *models.Payment) bool {
+		return payment.UserID == userID
+	})
+	sortNewestFirst(result)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func (r *payments) GetPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	match := t.paymentFilter(userID, req.Filter)
+	result := t.filterPayments(func(payment *models.Payment) bool {
+		if !match(payment) {
+			return false
+		}
+		if req.Cursor == nil {
+			return true
+		}
+		if req.Newer {
+			return cursorBefore(*req.Cursor, payment)
+		}
+		return cursorBefore(models.CursorOf(payment), &models.Payment{PaidAt: req.Cursor.PaidAt, ID: req.Cursor.ID})
+	})
+
+	if req.Newer {
+		sortOldestFirst(result)
+	} else {
+		sortNewestFirst(result)
+	}
+	if len(result) > req.Limit+1 {
+		result = result[:req.Limit+1]
+	}
+
+	return models.NewPaymentPage(result, req), nil
+}
+
+func (r *payments) GetAll(ctx context.Context, userID int64, filter models.PaymentFilter) ([]*models.Payment, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterPayments(t.paymentFilter(userID, filter))
+	sortOldestFirst(result)
+
+	return result, nil
+}
+
+// paymentFilter matches the payments of the user like the WHERE conditions of the PostgreSQL repository
+func (t *tables) paymentFilter(userID int64, filter models.PaymentFilter) func(payment *models.Payment) bool {
+	return func(payment *models.Payment) bool {
+		if payment.UserID != userID {
+			return false
+		}
+		if filter.SubscriptionID != 0 && payment.SubscriptionID != filter.SubscriptionID {
+			return false
+		}
+		if filter.Category != "" {
+			sub, ok := t.subscriptions[payment.SubscriptionID]
+			if !ok || sub.Category != filter.Category {
+				return false
+			}
+		}
+		if filter.Tag != "" {
+			sub, ok := t.subscriptions[payment.SubscriptionID]
+			if !ok || !sub.HasTag(filter.Tag) {
+				return false
+			}
+		}
+		if filter.Currency != "" && payment.Currency != filter.Currency {
+			return false
+		}
+		if filter.Status != "" && payment.Status != filter.Status {
+			return false
+		}
+		if !filter.From.IsZero() && payment.PaidAt.Before(models.DateOnly(filter.From)) {
+			return false
+		}
+		if !filter.To.IsZero() && !payment.PaidAt.Before(models.DateOnly(filter.To).AddDate(0, 0, 1)) {
+			return false
+		}
+		return true
+	}
+}
+
+// completedPayments returns completed payments of the user between from and to inclusive
+func (t *tables) completedPayments(userID int64, from, to time.Time) []*models.Payment {
+	result := t.filterPayments(func(payment *models.Payment) bool {
+		return payment.UserID == userID && payment.Status == models.PaymentStatusCompleted &&
+			!payment.PaidAt.Before(from) && !payment.PaidAt.After(to)
+	})
+	// Summaries list currencies in alphabetical order
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Currency < result[j].Currency
+	})
+	return result
+}
+
+func (t *tables) filterPayments(match func(payment *models.Payment) bool) []*models.Payment {
+	var result []*models.Payment
+	for _, payment := range t.payments {
+		if match(payment) {
+			result = append(result, copyPayment(payment))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// checkPayment enforces the constraints of the payments table
+func (t *tables) checkPayment(payment *models.Payment) error {
+	if _, ok := t.subscriptions[payment.SubscriptionID]; !ok {
+		return fmt.Errorf("subscription %d doesn't exist", payment.SubscriptionID)
+	}
+	if err := checkCurrency(payment.Currency); err != nil {
+		return err
+	}
+	if !payment.Status.IsValid() {
+		return fmt.Errorf("invalid payment status %q", payment.Status)
+	}
+	return nil
+}
+
+func addToSummary(summaries []models.PaymentSummary, payment *models.Payment) []models.PaymentSummary {
+	for i := range summaries {
+		if summaries[i].Currency == payment.Currency {
+			summaries[i].TotalAmount = summaries[i].TotalAmount.Add(payment.Amount)
+			summaries[i].Count++
+			return summaries
+		}
+	}
+	return append(summaries, models.PaymentSummary{Currency: payment.Currency, TotalAmount: payment.Amount, Count: 1})
+}
+
+// cursorBefore reports whether the cursor comes before the payment in (paid_at, id) order
+func cursorBefore(cursor models.PaymentCursor, payment *models.Payment) bool {
+	if !cursor.PaidAt.Equal(payment.PaidAt) {
+		return cursor.PaidAt.Before(payment.PaidAt)
+	}
+	return cursor.ID < payment.ID
+}
+
+func sortOldestFirst(payments []*models.Payment) {
+	sort.SliceStable(payments, func(i, j int) bool {
+		return cursorBefore(models.CursorOf(payments[i]), payments[j])
+	})
+}
+
+func sortNewestFirst(payments []*models.Payment) {
+	sort.SliceStable(payments, func(i, j int) bool {
+		return cursorBefore(models.CursorOf(payments[j]), payments[i])
+	})
+}
+
+func copyPayment(payment *models.Payment) *models.Payment {
+	c := *payment
+	return &c
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+type priceHistory struct {
+	conn
+}
+
+func (r *priceHistory) Restore(ctx context.Context, change *models.PriceChange) error {
+	t := r.lock()
+	defer r.unlock()
+
+	if _, ok := t.subscriptions[change.SubscriptionID]; !ok {
+		return fmt.Errorf("failed to restore price: subscription %d doesn't exist", change.SubscriptionID)
+	}
+	if err := checkCurrency(change.Currency); err != nil {
+		return fmt.Errorf("failed to restore price: %w", err)
+	}
+
+	restored := copyPriceChange(change)
+	r.db.lastPriceID++
+	restored.ID = r.db.lastPriceID
+	t.prices = append(t.prices, restored)
+
+	return nil
+}
+
+func (r *priceHistory) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.PriceChange, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	return t.filterPrices(func(change *models.PriceChange, sub *models.Subscription) bool {
+		return sub.UserID == userID && change.SubscriptionID == subscriptionID
+	}), nil
+}
+
+func (r *priceHistory) GetByUserID(ctx context.Context, userID int64) ([]*models.PriceChange, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	return t.filterPrices(func(change *models.PriceChange, sub *models.Subscription) bool {
+		return sub.UserID == userID
+	}), nil
+}
+
+// filterPrices returns the matching prices grouped by subscription and oldest first
+func (t *tables) filterPrices(match func(change *models.PriceChange, sub *models.Subscription) bool) []*models.PriceChange {
+	var result []*models.PriceChange
+	for _, change := range t.prices {
+		if sub, ok := t.subscriptions[change.SubscriptionID]; ok && match(change, sub) {
+			result = append(result, copyPriceChange(change))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.SubscriptionID != b.SubscriptionID {
+			return a.SubscriptionID < b.SubscriptionID
+		}
+		if !a.EffectiveFrom.Equal(b.EffectiveFrom) {
+			return a.EffectiveFrom.Before(b.EffectiveFrom)
+		}
+		return a.ID < b.ID
+	})
+	return result
+}
+
+// recordPrice closes the current price period of the subscription and opens a new one
+func (t *tables) recordPrice(d *db, subscriptionID int, cost models.Money, currency models.Currency, at time.Time) {
+	for _, change := range t.prices {
+		if change.SubscriptionID == subscriptionID && change.EffectiveTo == nil {
+			effectiveTo := at
+			change.EffectiveTo = &effectiveTo
+		}
+	}
+
+	d.lastPriceID++
+	t.prices = append(t.prices, &models.PriceChange{
+		ID:             d.lastPriceID,
+		SubscriptionID: subscriptionID,
+		Cost:           cost,
+		Currency:       currency,
+		EffectiveFrom:  at,
+		CreatedAt:      now(),
+	})
+}
+
+func copyPriceChange(change *models.PriceChange) *models.PriceChange {
+	c := *change
+	if change.EffectiveTo != nil {
+		effectiveTo := *change.EffectiveTo
+		c.EffectiveTo = &effectiveTo
+	}
+	return &c
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+type reminders struct {
+	conn
+}
+
+func (r *reminders) Reserve(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) (bool, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	if _, ok := t.subscriptions[sub.ID]; !ok {
+		return false, fmt.Errorf("failed to reserve reminder: subscription %d doesn't exist", sub.ID)
+	}
+
+	key := reminderKey{subscriptionID: sub.ID, dueDate: models.DateOnly(sub.NextPayment), kind: kind}
+	if _, ok := t.reminders[key]; ok {
+		return false, nil
+	}
+	t.reminders[key] = &reminder{userID: sub.UserID}
+
+	return true, nil
+}
+
+func (r *reminders) Release(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error {
+	t := r.lock()
+	defer r.unlock()
+
+	delete(t.reminders, reminderKey{subscriptionID: sub.ID, dueDate: models.DateOnly(sub.NextPayment), kind: kind})
+
+	return nil
+}
+
+func (r *reminders) Snooze(ctx context.Context, userID int64, subscriptionID int, dueDate, until time.Time) error {
+	t := r.lock()
+	defer r.unlock()
+
+	dueDate = models.DateOnly(dueDate)
+	snoozed := false
+	for key, reminder := range t.reminders {
+		if key.subscriptionID == subscriptionID && key.dueDate.Equal(dueDate) && reminder.userID == userID {
+			snoozedUntil := until
+			reminder.snoozedUntil = &snoozedUntil
+			snoozed = true
+		}
+	}
+	if !snoozed {
+		return fmt.Errorf("reminder not found")
+	}
+
+	return nil
+}
+
+func (r *reminders) TakeSnoozed(ctx context.Context, now time.Time) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	ids := make(map[int]bool)
+	for key, reminder := range t.reminders {
+		if reminder.snoozedUntil == nil || reminder.snoozedUntil.After(now) {
+			continue
+		}
+		reminder.snoozedUntil = nil
+
+		sub, ok := t.subscriptions[key.subscriptionID]
+		if ok && sub.Active && sub.NextPayment.Equal(key.dueDate) {
+			ids[sub.ID] = true
+		}
+	}
+
+	return t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return ids[sub.ID]
+	}), nil
+}
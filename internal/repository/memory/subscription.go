@@ -0,0 +1,297 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+	"unicode/utf8"
+)
+
+type subscriptions struct {
+	conn
+}
+
+func (r *subscriptions) Create(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	createdAt := now()
+	sub := &models.Subscription{
+		UserID:      req.UserID,
+		Name:        req.Name,
+		Cost:        req.Cost,
+		Currency:    req.Currency,
+		Period:      req.Period,
+		NextPayment: models.DateOnly(req.NextPayment),
+		Category:    req.Category,
+		AutoRenewal: req.AutoRenewal,
+		Active:      true,
+		TrialEndsAt: dateOnly(req.TrialEndsAt),
+		TrialCost:   req.TrialCost,
+		Tags:        sortedTags(req.Tags),
+		Notes:       req.Notes,
+		URL:         req.URL,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+	if err := checkSubscription(sub); err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	r.db.lastSubscriptionID++
+	sub.ID = r.db.lastSubscriptionID
+	t.subscriptions[sub.ID] = sub
+	t.recordPrice(r.db, sub.ID, sub.Cost, sub.Currency, createdAt)
+
+	return copySubscription(sub), nil
+}
+
+func (r *subscriptions) Restore(ctx context.Context, sub *models.Subscription) (int, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	restored := copySubscription(sub)
+	restored.NextPayment = models.DateOnly(sub.NextPayment)
+	restored.TrialEndsAt = dateOnly(sub.TrialEndsAt)
+	restored.Tags = sortedTags(sub.Tags)
+	if err := checkSubscription(restored); err != nil {
+		return 0, fmt.Errorf("failed to restore subscription: %w", err)
+	}
+
+	r.db.lastSubscriptionID++
+	restored.ID = r.db.lastSubscriptionID
+	t.subscriptions[restored.ID] = restored
+
+	return restored.ID, nil
+}
+
+func (r *subscriptions) Count(ctx context.Context, userID int64) (int, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	return len(t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID
+	})), nil
+}
+
+func (r *subscriptions) GetByID(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	sub, ok := t.subscriptions[id]
+	if !ok || sub.UserID != userID {
+		return nil, fmt.Errorf("subscription %w", repository.ErrNotFound)
+	}
+
+	return copySubscription(sub), nil
+}
+
+// GetByIDForUpdate needs no lock of its own, Do holds the whole database
+func (r *subscriptions) GetByIDForUpdate(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	return r.GetByID(ctx, userID, id)
+}
+
+func (r *subscriptions) GetAllActive(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID && sub.Active
+	})
+	sortByNextPayment(result)
+
+	return result, nil
+}
+
+func (r *subscriptions) GetAll(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	return t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID
+	}), nil
+}
+
+func (r *subscriptions) Update(ctx context.Context, sub *models.Subscription) error {
+	t := r.lock()
+	defer r.unlock()
+
+	current, ok := t.subscriptions[sub.ID]
+	if !ok || current.UserID != sub.UserID {
+		return fmt.Errorf("subscription %w", repository.ErrNotFound)
+	}
+
+	updated := copySubscription(sub)
+	updated.NextPayment = models.DateOnly(sub.NextPayment)
+	updated.TrialEndsAt = dateOnly(sub.TrialEndsAt)
+	updated.Tags = sortedTags(sub.Tags)
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = now()
+	if err := checkSubscription(updated); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	if updated.Cost != current.Cost || updated.Currency != current.Currency {
+		t.recordPrice(r.db, updated.ID, updated.Cost, updated.Currency, updated.UpdatedAt)
+	}
+	t.subscriptions[updated.ID] = updated
+
+	return nil
+}
+
+func (r *subscriptions) Delete(ctx context.Context, userID int64, id int) error {
+	t := r.lock()
+	defer r.unlock()
+
+	sub, ok := t.subscriptions[id]
+	if !ok || sub.UserID != userID {
+		return fmt.Errorf("subscription %w", repository.ErrNotFound)
+	}
+	sub.Active = false
+	sub.UpdatedAt = now()
+
+	return nil
+}
+
+func (r *subscriptions) GetByCategory(ctx context.Context, userID int64, category models.Category) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID && sub.Category == category && sub.Active
+	})
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Cost > result[j].Cost
+	})
+
+	return result, nil
+}
+
+func (r *subscriptions) GetDuePayments(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	until := time.Now()
+	result := t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID && sub.Active && !sub.NextPayment.After(until)
+	})
+	sortByNextPayment(result)
+
+	return result, nil
+}
+
+func (r *subscriptions) GetActiveTrials(ctx context.Context, userID int64, today time.Time) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	today = models.DateOnly(today)
+	result := t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.UserID == userID && sub.Active && sub.TrialEndsAt != nil && sub.TrialEndsAt.After(today)
+	})
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TrialEndsAt.Before(*result[j].TrialEndsAt)
+	})
+
+	return result, nil
+}
+
+func (r *subscriptions) GetAllDueBefore(ctx context.Context, until time.Time) ([]*models.Subscription, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	result := t.filterSubscriptions(func(sub *models.Subscription) bool {
+		return sub.Active && !sub.NextPayment.After(until)
+	})
+	sortByNextPayment(result)
+
+	return result, nil
+}
+
+// filterSubscriptions returns copies of the matching subscriptions in the order they were added
+func (t *tables) filterSubscriptions(match func(sub *models.Subscription) bool) []*models.Subscription {
+	var result []*models.Subscription
+	for _, sub := range t.subscriptions {
+		if match(sub) {
+			result = append(result, copySubscription(sub))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func sortByNextPayment(subscriptions []*models.Subscription) {
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		return subscriptions[i].NextPayment.Before(subscriptions[j].NextPayment)
+	})
+}
+
+// checkSubscription enforces the constraints of the subscriptions table
+func checkSubscription(sub *models.Subscription) error {
+	if utf8.RuneCountInString(sub.Name) > 255 {
+		return fmt.Errorf("name is too long")
+	}
+	if err := checkCurrency(sub.Currency); err != nil {
+		return err
+	}
+	switch sub.Period.Unit {
+	case models.PeriodDaily, models.PeriodWeekly, models.PeriodMonthly, models.PeriodQuarterly, models.PeriodYearly:
+	default:
+		return fmt.Errorf("invalid period unit %q", sub.Period.Unit)
+	}
+	if sub.Period.Interval <= 0 {
+		return fmt.Errorf("period interval must be positive")
+	}
+	if sub.Period.AnchorDay < 0 || sub.Period.AnchorDay > 31 {
+		return fmt.Errorf("invalid anchor day %d", sub.Period.AnchorDay)
+	}
+	if sub.TrialCost < 0 {
+		return fmt.Errorf("trial cost must not be negative")
+	}
+	if utf8.RuneCountInString(sub.Notes) > 1000 {
+		return fmt.Errorf("notes are too long")
+	}
+	if utf8.RuneCountInString(sub.URL) > 2048 {
+		return fmt.Errorf("url is too long")
+	}
+	for _, tag := range sub.Tags {
+		if utf8.RuneCountInString(tag) > 50 {
+			return fmt.Errorf("tag is too long")
+		}
+	}
+	return nil
+}
+
+func copySubscription(sub *models.Subscription) *models.Subscription {
+	c := *sub
+	if sub.TrialEndsAt != nil {
+		trialEndsAt := *sub.TrialEndsAt
+		c.TrialEndsAt = &trialEndsAt
+	}
+	c.Tags = slices.Clone(sub.Tags)
+	return &c
+}
+
+// sortedTags copies the tags in the order the tags tables return them
+func sortedTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// dateOnly copies a nullable DATE column
+func dateOnly(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	date := models.DateOnly(*t)
+	return &date
+}
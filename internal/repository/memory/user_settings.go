@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+)
+
+type settings struct {
+	conn
+}
+
+func (r *settings) GetBaseCurrency(ctx context.Context, userID int64) (models.Currency, bool, error) {
+	t := r.lock()
+	defer r.unlock()
+
+	currency, ok := t.settings[userID]
+	return currency, ok, nil
+}
+
+func (r *settings) SetBaseCurrency(ctx context.Context, userID int64, currency models.Currency) error {
+	t := r.lock()
+	defer r.unlock()
+
+	if err := checkCurrency(currency); err != nil {
+		return fmt.Errorf("failed to set base currency: %w", err)
+	}
+	t.settings[userID] = currency
+
+	return nil
+}
@@ -2,80 +2,133 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sub-cos-counter/internal/models"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrDuplicatePayment is returned by Create when a payment with the same
+// idempotency key is already recorded.
+var ErrDuplicatePayment = errors.New("payment is already recorded")
+
+// paymentColumns are read by scanPayment, in the same order
+const paymentColumns = `id, user_id, subscription_id, amount, currency, paid_at, status, note, created_at`
+
 type PaymentRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
 func NewPaymentRepository(db *pgxpool.Pool) *PaymentRepository {
 	return &PaymentRepository{db: db}
 }
 
+// Create records a payment. A payment with an idempotency key is recorded at
+// most once, repeated requests get ErrDuplicatePayment.
 func (r *PaymentRepository) Create(ctx context.Context, req *models.CreatePaymentRequest) (*models.Payment, error) {
 	query := `
-		INSERT INTO payments (subscription_id, amount, currency, status)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, subscription_id, amount, currency, paid_at, status, created_at`
+		INSERT INTO payments (user_id, subscription_id, amount, currency, status, paid_at, idempotency_key, note)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, NOW()), NULLIF($7, ''), $8)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING ` + paymentColumns
 
-	var payment models.Payment
-	err := r.db.QueryRow(ctx, query,
-		req.SubscriptionID, req.Amount, req.Currency, req.Status,
-	).Scan(
-		&payment.ID, &payment.SubscriptionID, &payment.Amount, &payment.Currency,
-		&payment.PaidAt, &payment.Status, &payment.CreatedAt,
-	)
+	payment, err := scanPayment(r.db.QueryRow(ctx, query,
+		req.UserID, req.SubscriptionID, req.Amount, req.Currency, req.Status, req.PaidAt, req.IdempotencyKey, req.Note,
+	))
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDuplicatePayment
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create payment: %w", err)
 	}
 
-	return &payment, nil
+	return payment, nil
 }
 
-func (r *PaymentRepository) GetBySubscriptionID(ctx context.Context, subscriptionID int) ([]*models.Payment, error) {
+// Restore inserts a payment from a backup as is and returns its new ID
+func (r *PaymentRepository) Restore(ctx context.Context, payment *models.Payment) (int, error) {
 	query := `
-		SELECT id, subscription_id, amount, currency, paid_at, status, created_at
-		FROM payments WHERE subscription_id = $1 ORDER BY paid_at DESC`
+		INSERT INTO payments (user_id, subscription_id, amount, currency, paid_at, status, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
 
-	rows, err := r.db.Query(ctx, query, subscriptionID)
+	var id int
+	err := r.db.QueryRow(ctx, query,
+		payment.UserID, payment.SubscriptionID, payment.Amount, payment.Currency, payment.PaidAt,
+		payment.Status, payment.Note, payment.CreatedAt,
+	).Scan(&id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get payments: %w", err)
+		return 0, fmt.Errorf("failed to restore payment: %w", err)
 	}
-	defer rows.Close()
 
-	var payments []*models.Payment
-	for rows.Next() {
-		var payment models.Payment
-		err := rows.Scan(
-			&payment.ID, &payment.SubscriptionID, &payment.Amount, &payment.Currency,
-			&payment.PaidAt, &payment.Status, &payment.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan payment: %w", err)
-		}
-		payments = append(payments, &payment)
+	return id, nil
+}
+
+func (r *PaymentRepository) GetByID(ctx context.Context, userID int64, id int) (*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE id = $1 AND user_id = $2`
+
+	payment, err := scanPayment(r.db.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("payment %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// Update saves the amount, date, status and note of the payment
+func (r *PaymentRepository) Update(ctx context.Context, payment *models.Payment) error {
+	query := `
+		UPDATE payments SET amount = $3, paid_at = $4, status = $5, note = $6
+		WHERE id = $1 AND user_id = $2`
+
+	tag, err := r.db.Exec(ctx, query,
+		payment.ID, payment.UserID, payment.Amount, payment.PaidAt, payment.Status, payment.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("payment %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *PaymentRepository) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE user_id = $1 AND subscription_id = $2 ORDER BY paid_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
 	}
+	defer rows.Close()
 
-	return payments, nil
+	return scanPayments(rows)
 }
 
-func (r *PaymentRepository) GetMonthlyExpense(ctx context.Context, month time.Time) ([]models.PaymentSummary, error) {
+func (r *PaymentRepository) GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error) {
 	startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
 	endOfMonth := startOfMonth.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
 
 	query := `
 		SELECT currency, SUM(amount) as total_amount, COUNT(*) as count
-		FROM payments 
-		WHERE paid_at >= $1 AND paid_at <= $2 AND status = 'completed'
+		FROM payments
+		WHERE user_id = $1 AND paid_at >= $2 AND paid_at <= $3 AND status = 'completed'
 		GROUP BY currency`
 
-	rows, err := r.db.Query(ctx, query, startOfMonth, endOfMonth)
+	rows, err := r.db.Query(ctx, query, userID, startOfMonth, endOfMonth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get monthly expense: %w", err)
 	}
@@ -94,16 +147,16 @@ func (r *PaymentRepository) GetMonthlyExpense(ctx context.Context, month time.Ti
 	return summaries, nil
 }
 
-func (r *PaymentRepository) GetCategoryAnalytics(ctx context.Context, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
+func (r *PaymentRepository) GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
 	query := `
 		SELECT s.category, p.currency, SUM(p.amount) as total_amount, COUNT(p.*) as count
 		FROM payments p
 		JOIN subscriptions s ON p.subscription_id = s.id
-		WHERE p.paid_at >= $1 AND p.paid_at <= $2 AND p.status = 'completed'
+		WHERE p.user_id = $1 AND p.paid_at >= $2 AND p.paid_at <= $3 AND p.status = 'completed'
 		GROUP BY s.category, p.currency
 		ORDER BY s.category, p.currency`
 
-	rows, err := r.db.Query(ctx, query, startDate, endDate)
+	rows, err := r.db.Query(ctx, query, userID, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get category analytics: %w", err)
 	}
@@ -123,29 +176,141 @@ func (r *PaymentRepository) GetCategoryAnalytics(ctx context.Context, startDate,
 	return analytics, nil
 }
 
-func (r *PaymentRepository) GetAllPayments(ctx context.Context, limit int) ([]*models.Payment, error) {
+func (r *PaymentRepository) GetAllPayments(ctx context.Context, userID int64, limit int) ([]*models.Payment, error) {
 	query := `
-		SELECT id, subscription_id, amount, currency, paid_at, status, created_at
-		FROM payments ORDER BY paid_at DESC LIMIT $1`
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE user_id = $1 ORDER BY paid_at DESC LIMIT $2`
 
-	rows, err := r.db.Query(ctx, query, limit)
+	rows, err := r.db.Query(ctx, query, userID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all payments: %w", err)
 	}
 	defer rows.Close()
 
+	return scanPayments(rows)
+}
+
+// GetPage returns a page of the payments matching the filter. It pages by
+// (paid_at, id) instead of an offset, so a page costs the same however deep
+// in the history it is.
+func (r *PaymentRepository) GetPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error) {
+	conditions, args := paymentConditions(userID, req.Filter)
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	order := "DESC"
+	if req.Cursor != nil {
+		comparison := "<"
+		if req.Newer {
+			comparison = ">"
+		}
+		conditions = append(conditions,
+			fmt.Sprintf("(paid_at, id) %s (%s, %s)", comparison, arg(req.Cursor.PaidAt), arg(req.Cursor.ID)))
+	}
+	if req.Newer {
+		order = "ASC"
+	}
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY paid_at ` + order + `, id ` + order + `
+		LIMIT ` + arg(req.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments page: %w", err)
+	}
+	defer rows.Close()
+
+	payments, err := scanPayments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaymentPage(payments, req), nil
+}
+
+// GetAll returns every payment matching the filter, oldest first
+func (r *PaymentRepository) GetAll(ctx context.Context, userID int64, filter models.PaymentFilter) ([]*models.Payment, error) {
+	conditions, args := paymentConditions(userID, filter)
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY paid_at ASC, id ASC`
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPayments(rows)
+}
+
+// paymentConditions turns the filter into WHERE conditions with their arguments,
+// the user ID is always the first one
+func paymentConditions(userID int64, filter models.PaymentFilter) ([]string, []any) {
+	args := []any{userID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"user_id = $1"}
+	if filter.SubscriptionID != 0 {
+		conditions = append(conditions, "subscription_id = "+arg(filter.SubscriptionID))
+	}
+	if filter.Category != "" {
+		conditions = append(conditions, "subscription_id IN (SELECT id FROM subscriptions WHERE category = "+arg(filter.Category)+")")
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, `subscription_id IN (
+			SELECT st.subscription_id FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE t.name = `+arg(filter.Tag)+")")
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = "+arg(filter.Currency))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "paid_at >= "+arg(models.DateOnly(filter.From)))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "paid_at < "+arg(models.DateOnly(filter.To).AddDate(0, 0, 1)))
+	}
+
+	return conditions, args
+}
+
+func scanPayment(row pgx.Row) (*models.Payment, error) {
+	var payment models.Payment
+	err := row.Scan(
+		&payment.ID, &payment.UserID, &payment.SubscriptionID, &payment.Amount, &payment.Currency,
+		&payment.PaidAt, &payment.Status, &payment.Note, &payment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func scanPayments(rows pgx.Rows) ([]*models.Payment, error) {
 	var payments []*models.Payment
 	for rows.Next() {
-		var payment models.Payment
-		err := rows.Scan(
-			&payment.ID, &payment.SubscriptionID, &payment.Amount, &payment.Currency,
-			&payment.PaidAt, &payment.Status, &payment.CreatedAt,
-		)
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan payment: %w", err)
 		}
-		payments = append(payments, &payment)
+		payments = append(payments, payment)
 	}
 
-	return payments, nil
+	return payments, rows.Err()
 }
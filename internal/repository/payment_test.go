@@ -0,0 +1,141 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/testdb"
+	"testing"
+	"time"
+)
+
+func TestPaymentCreateIsIdempotent(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+
+	sub, err := repository.NewSubscriptionRepository(db).Create(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Netflix",
+		Cost:        models.NewMoney(1599),
+		Currency:    models.CurrencyUSD,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Category:    models.CategoryEntertainment,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	payments := repository.NewPaymentRepository(db)
+	req := &models.CreatePaymentRequest{
+		UserID:         1,
+		SubscriptionID: sub.ID,
+		Amount:         sub.Cost,
+		Currency:       sub.Currency,
+		Status:         models.PaymentStatusCompleted,
+		IdempotencyKey: models.BillingCycleKey(sub.ID, sub.NextPayment),
+	}
+
+	if _, err := payments.Create(ctx, req); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+	if _, err := payments.Create(ctx, req); !errors.Is(err, repository.ErrDuplicatePayment) {
+		t.Fatalf("Expected repository.ErrDuplicatePayment, got %v", err)
+	}
+
+	// Payments without a key are never deduplicated
+	req.IdempotencyKey = ""
+	for i := 0; i < 2; i++ {
+		if _, err := payments.Create(ctx, req); err != nil {
+			t.Fatalf("Failed to create payment without key: %v", err)
+		}
+	}
+
+	recorded, err := payments.GetBySubscriptionID(ctx, 1, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get payments: %v", err)
+	}
+	if len(recorded) != 3 {
+		t.Errorf("Expected 3 payments, got %d", len(recorded))
+	}
+}
+
+func TestPaymentGetPageWalksTheHistory(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+
+	sub, err := repository.NewSubscriptionRepository(db).Create(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Spotify",
+		Cost:        models.NewMoney(999),
+		Currency:    models.CurrencyEUR,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Category:    models.CategoryEntertainment,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	// Two payments share each date to check the ID tie-breaker
+	payments := repository.NewPaymentRepository(db)
+	for i := 0; i < 7; i++ {
+		paidAt := time.Date(2025, 1, 1+i/2, 12, 0, 0, 0, time.UTC)
+		status := models.PaymentStatusCompleted
+		if i == 3 {
+			status = models.PaymentStatusVoided
+		}
+		_, err := payments.Create(ctx, &models.CreatePaymentRequest{
+			UserID: 1, SubscriptionID: sub.ID, Amount: sub.Cost, Currency: sub.Currency, Status: status, PaidAt: &paidAt,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create payment: %v", err)
+		}
+	}
+
+	req := models.PaymentPageRequest{Limit: 3}
+	var seen []int
+	var last *models.PaymentPage
+	for {
+		last, err = payments.GetPage(ctx, 1, req)
+		if err != nil {
+			t.Fatalf("Failed to get page: %v", err)
+		}
+		for _, payment := range last.Payments {
+			seen = append(seen, payment.ID)
+		}
+		if last.Older == nil {
+			break
+		}
+		req.Cursor = last.Older
+	}
+	if len(seen) != 7 {
+		t.Fatalf("Expected to see 7 payments, got %v", seen)
+	}
+
+	// Going back from the last page returns the previous one
+	page, err := payments.GetPage(ctx, 1, models.PaymentPageRequest{Cursor: last.Newer, Newer: true, Limit: 3})
+	if err != nil {
+		t.Fatalf("Failed to get newer page: %v", err)
+	}
+	if len(page.Payments) != 3 || page.Payments[0].ID != seen[3] || page.Newer == nil {
+		t.Errorf("Unexpected newer page: %+v", page)
+	}
+
+	filtered, err := payments.GetPage(ctx, 1, models.PaymentPageRequest{
+		Filter: models.PaymentFilter{
+			Category: models.CategoryEntertainment,
+			Status:   models.PaymentStatusCompleted,
+			From:     time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+			To:       time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to get filtered page: %v", err)
+	}
+	if len(filtered.Payments) != 3 {
+		t.Errorf("Expected 3 filtered payments, got %d", len(filtered.Payments))
+	}
+}
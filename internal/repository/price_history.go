@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PriceHistoryRepository reads the price periods written by SubscriptionRepository.
+type PriceHistoryRepository struct {
+	db DBTX
+}
+
+func NewPriceHistoryRepository(db *pgxpool.Pool) *PriceHistoryRepository {
+	return &PriceHistoryRepository{db: db}
+}
+
+// Restore inserts a price period from a backup as is
+func (r *PriceHistoryRepository) Restore(ctx context.Context, change *models.PriceChange) error {
+	query := `
+		INSERT INTO subscription_price_history (subscription_id, cost, currency, effective_from, effective_to, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(ctx, query,
+		change.SubscriptionID, change.Cost, change.Currency, change.EffectiveFrom, change.EffectiveTo, change.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore price: %w", err)
+	}
+
+	return nil
+}
+
+// GetBySubscriptionID returns the prices of one subscription, oldest first.
+func (r *PriceHistoryRepository) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.PriceChange, error) {
+	query := `
+		SELECT h.id, h.subscription_id, h.cost, h.currency, h.effective_from, h.effective_to, h.created_at
+		FROM subscription_price_history h
+		JOIN subscriptions s ON s.id = h.subscription_id
+		WHERE s.user_id = $1 AND h.subscription_id = $2
+		ORDER BY h.effective_from ASC, h.id ASC`
+
+	return r.query(ctx, query, userID, subscriptionID)
+}
+
+// GetByUserID returns the prices of all subscriptions of the user,
+// grouped by subscription and oldest first.
+func (r *PriceHistoryRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.PriceChange, error) {
+	query := `
+		SELECT h.id, h.subscription_id, h.cost, h.currency, h.effective_from, h.effective_to, h.created_at
+		FROM subscription_price_history h
+		JOIN subscriptions s ON s.id = h.subscription_id
+		WHERE s.user_id = $1
+		ORDER BY h.subscription_id ASC, h.effective_from ASC, h.id ASC`
+
+	return r.query(ctx, query, userID)
+}
+
+func (r *PriceHistoryRepository) query(ctx context.Context, query string, args ...any) ([]*models.PriceChange, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*models.PriceChange
+	for rows.Next() {
+		var change models.PriceChange
+		err := rows.Scan(
+			&change.ID, &change.SubscriptionID, &change.Cost, &change.Currency,
+			&change.EffectiveFrom, &change.EffectiveTo, &change.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price change: %w", err)
+		}
+		history = append(history, &change)
+	}
+
+	return history, rows.Err()
+}
+
+// recordPrice closes the current price period of the subscription and opens a new one.
+func recordPrice(ctx context.Context, tx pgx.Tx, subscriptionID int, cost models.Money, currency models.Currency, at time.Time) error {
+	closeQuery := `
+		UPDATE subscription_price_history SET effective_to = $2
+		WHERE subscription_id = $1 AND effective_to IS NULL`
+
+	if _, err := tx.Exec(ctx, closeQuery, subscriptionID, at); err != nil {
+		return fmt.Errorf("failed to close price period: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO subscription_price_history (subscription_id, cost, currency, effective_from)
+		VALUES ($1, $2, $3, $4)`
+
+	if _, err := tx.Exec(ctx, insertQuery, subscriptionID, cost, currency, at); err != nil {
+		return fmt.Errorf("failed to record price: %w", err)
+	}
+
+	return nil
+}
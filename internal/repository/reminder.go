@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReminderRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReminderRepository(db *pgxpool.Pool) *ReminderRepository {
+	return &ReminderRepository{db: db}
+}
+
+// Reserve records a reminder for the billing cycle. It returns false when the
+// reminder was already recorded, so each reminder is sent only once per cycle.
+func (r *ReminderRepository) Reserve(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) (bool, error) {
+	query := `
+		INSERT INTO payment_reminders (subscription_id, user_id, due_date, kind)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subscription_id, due_date, kind) DO NOTHING`
+
+	tag, err := r.db.Exec(ctx, query, sub.ID, sub.UserID, models.DateOnly(sub.NextPayment), kind)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve reminder: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// Release removes a reservation whose message could not be delivered,
+// so the reminder is retried on the next run.
+func (r *ReminderRepository) Release(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error {
+	query := `DELETE FROM payment_reminders WHERE subscription_id = $1 AND due_date = $2 AND kind = $3`
+
+	_, err := r.db.Exec(ctx, query, sub.ID, models.DateOnly(sub.NextPayment), kind)
+	if err != nil {
+		return fmt.Errorf("failed to release reminder: %w", err)
+	}
+
+	return nil
+}
+
+// Snooze postpones reminders of the cycle until the given time.
+func (r *ReminderRepository) Snooze(ctx context.Context, userID int64, subscriptionID int, dueDate, until time.Time) error {
+	query := `
+		UPDATE payment_reminders SET snoozed_until = $4
+		WHERE subscription_id = $1 AND user_id = $2 AND due_date = $3`
+
+	tag, err := r.db.Exec(ctx, query, subscriptionID, userID, models.DateOnly(dueDate), until)
+	if err != nil {
+		return fmt.Errorf("failed to snooze reminder: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("reminder not found")
+	}
+
+	return nil
+}
+
+// TakeSnoozed returns subscriptions whose snoozed reminders have expired and
+// clears the snooze, so every snoozed reminder is repeated exactly once.
+func (r *ReminderRepository) TakeSnoozed(ctx context.Context, now time.Time) ([]*models.Subscription, error) {
+	query := `
+		WITH expired AS (
+			UPDATE payment_reminders SET snoozed_until = NULL
+			WHERE snoozed_until IS NOT NULL AND snoozed_until <= $1
+			RETURNING subscription_id, due_date
+		)
+		SELECT DISTINCT s.id, s.user_id, s.name, s.cost, s.currency, s.period_unit, s.period_interval, s.anchor_day, s.next_payment,
+		       s.category, s.auto_renewal, s.active, s.trial_ends_at, s.trial_cost, s.notes, s.url,
+		       ARRAY(SELECT t.name FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+		             WHERE st.subscription_id = s.id ORDER BY t.name),
+		       s.created_at, s.updated_at
+		FROM expired e
+		JOIN subscriptions s ON s.id = e.subscription_id AND s.next_payment = e.due_date
+		WHERE s.active = true`
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snoozed reminders: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
@@ -0,0 +1,898 @@
+// Package repotest is the contract of a storage backend. Every backend runs
+// the same suite, so services behave alike whatever storage they are given.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"testing"
+	"time"
+)
+
+const (
+	userID  = 1
+	otherID = 2
+)
+
+// Run checks the storage backend, newStorage must return an empty storage
+// every time it is called.
+func Run(t *testing.T, newStorage func(t *testing.T) *repository.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s *repository.Storage)
+	}{
+		{"SubscriptionCreateAndGet", testSubscriptionCreateAndGet},
+		{"SubscriptionUpdateRecordsPrice", testSubscriptionUpdateRecordsPrice},
+		{"SubscriptionQueries", testSubscriptionQueries},
+		{"SubscriptionRestore", testSubscriptionRestore},
+		{"SubscriptionTags", testSubscriptionTags},
+		{"PaymentCreateIsIdempotent", testPaymentCreateIsIdempotent},
+		{"PaymentGetAndUpdate", testPaymentGetAndUpdate},
+		{"PaymentAnalytics", testPaymentAnalytics},
+		{"PaymentFilters", testPaymentFilters},
+		{"PaymentPages", testPaymentPages},
+		{"Settings", testSettings},
+		{"Categories", testCategories},
+		{"Budgets", testBudgets},
+		{"ExchangeRates", testExchangeRates},
+		{"Reminders", testReminders},
+		{"Transactions", testTransactions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func createSubscription(t *testing.T, s *repository.Storage, req models.CreateSubscriptionRequest) *models.Subscription {
+	t.Helper()
+
+	if req.UserID == 0 {
+		req.UserID = userID
+	}
+	if req.Name == "" {
+		req.Name = "Netflix"
+	}
+	if req.Cost == 0 {
+		req.Cost = models.NewMoney(1599)
+	}
+	if req.Currency == "" {
+		req.Currency = models.CurrencyUSD
+	}
+	if req.Period.Unit == "" {
+		req.Period = models.Every(1, models.PeriodMonthly)
+	}
+	if req.NextPayment.IsZero() {
+		req.NextPayment = date(2025, 3, 10)
+	}
+	if req.Category == "" {
+		req.Category = models.CategoryEntertainment
+	}
+
+	sub, err := s.Subscriptions.Create(context.Background(), &req)
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	return sub
+}
+
+func createPayment(t *testing.T, s *repository.Storage, sub *models.Subscription, amount models.Money, currency models.Currency, status models.PaymentStatus, paidAt time.Time) *models.Payment {
+	t.Helper()
+
+	payment, err := s.Payments.Create(context.Background(), &models.CreatePaymentRequest{
+		UserID:         sub.UserID,
+		SubscriptionID: sub.ID,
+		Amount:         amount,
+		Currency:       currency,
+		Status:         status,
+		PaidAt:         &paidAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+	return payment
+}
+
+func ids[T any](items []*T, id func(*T) int) []int {
+	result := make([]int, 0, len(items))
+	for _, item := range items {
+		result = append(result, id(item))
+	}
+	return result
+}
+
+func subscriptionIDs(subs []*models.Subscription) []int {
+	return ids(subs, func(s *models.Subscription) int { return s.ID })
+}
+
+func paymentIDs(payments []*models.Payment) []int {
+	return ids(payments, func(p *models.Payment) int { return p.ID })
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testSubscriptionCreateAndGet(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	trialEndsAt := time.Date(2025, 3, 1, 18, 30, 0, 0, time.UTC)
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{
+		Name:        "Kindle",
+		Cost:        models.NewMoney(999),
+		Currency:    models.CurrencyEUR,
+		Period:      models.Every(3, models.PeriodMonthly).AnchoredTo(date(2025, 3, 31)),
+		NextPayment: time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC),
+		Category:    models.CategoryEducation,
+		AutoRenewal: true,
+		TrialEndsAt: &trialEndsAt,
+		TrialCost:   models.NewMoney(100),
+	})
+
+	if sub.ID == 0 || !sub.Active || sub.CreatedAt.IsZero() {
+		t.Errorf("Expected a new active subscription with ID and creation time, got %+v", sub)
+	}
+	// Payment and trial dates are kept without time
+	if !sub.NextPayment.Equal(date(2025, 3, 31)) || !sub.TrialEndsAt.Equal(date(2025, 3, 1)) {
+		t.Errorf("Expected dates without time, got %v and %v", sub.NextPayment, sub.TrialEndsAt)
+	}
+
+	got, err := s.Subscriptions.GetByID(ctx, userID, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if got.Name != "Kindle" || got.Cost != models.NewMoney(999) || got.Currency != models.CurrencyEUR ||
+		got.Period != sub.Period || got.Category != models.CategoryEducation || !got.AutoRenewal ||
+		got.TrialCost != models.NewMoney(100) || !got.NextPayment.Equal(sub.NextPayment) {
+		t.Errorf("Expected %+v, got %+v", sub, got)
+	}
+
+	// Changing a returned subscription doesn't change the stored one
+	got.Name = "Changed"
+	if again, _ := s.Subscriptions.GetByID(ctx, userID, sub.ID); again.Name != "Kindle" {
+		t.Errorf("Expected the stored subscription to stay the same, got %q", again.Name)
+	}
+
+	if _, err := s.Subscriptions.GetByID(ctx, otherID, sub.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another user, got %v", err)
+	}
+	if _, err := s.Subscriptions.GetByID(ctx, userID, sub.ID+100); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing subscription, got %v", err)
+	}
+
+	if count, err := s.Subscriptions.Count(ctx, userID); err != nil || count != 1 {
+		t.Errorf("Expected 1 subscription, got %d (%v)", count, err)
+	}
+	if count, err := s.Subscriptions.Count(ctx, otherID); err != nil || count != 0 {
+		t.Errorf("Expected no subscriptions of another user, got %d (%v)", count, err)
+	}
+
+	// Constraints of the table hold whatever the caller checked
+	_, err = s.Subscriptions.Create(ctx, &models.CreateSubscriptionRequest{
+		UserID: userID, Name: "Broken", Cost: 1, Currency: "usd",
+		Period: models.Every(1, models.PeriodMonthly), NextPayment: date(2025, 3, 1), Category: models.CategoryOther,
+	})
+	if err == nil {
+		t.Error("Expected an invalid currency code to be rejected")
+	}
+}
+
+func testSubscriptionUpdateRecordsPrice(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	history, err := s.PriceHistory.GetBySubscriptionID(ctx, userID, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get price history: %v", err)
+	}
+	if len(history) != 1 || history[0].Cost != sub.Cost || history[0].EffectiveTo != nil {
+		t.Fatalf("Expected the initial price to be recorded, got %+v", history)
+	}
+
+	sub.Name = "Netflix Premium"
+	if err := s.Subscriptions.Update(ctx, sub); err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+	sub.Cost = models.NewMoney(1999)
+	sub.NextPayment = time.Date(2025, 4, 10, 9, 0, 0, 0, time.UTC)
+	if err := s.Subscriptions.Update(ctx, sub); err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+
+	got, _ := s.Subscriptions.GetByID(ctx, userID, sub.ID)
+	if got.Name != "Netflix Premium" || got.Cost != models.NewMoney(1999) || !got.NextPayment.Equal(date(2025, 4, 10)) {
+		t.Errorf("Expected the changes to be saved, got %+v", got)
+	}
+
+	// Only a new price opens a new period
+	history, _ = s.PriceHistory.GetBySubscriptionID(ctx, userID, sub.ID)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 price periods, got %d", len(history))
+	}
+	if history[0].EffectiveTo == nil || history[1].EffectiveTo != nil || history[1].Cost != models.NewMoney(1999) {
+		t.Errorf("Expected the first period to be closed and the new one open, got %+v %+v", history[0], history[1])
+	}
+	if h, _ := s.PriceHistory.GetBySubscriptionID(ctx, otherID, sub.ID); len(h) != 0 {
+		t.Errorf("Expected no price history for another user, got %d periods", len(h))
+	}
+
+	stranger := *sub
+	stranger.UserID = otherID
+	if err := s.Subscriptions.Update(ctx, &stranger); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when updating a subscription of another user, got %v", err)
+	}
+}
+
+func testSubscriptionQueries(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	today := models.DateOnly(time.Now())
+	trialEndsAt := today.AddDate(0, 0, 5)
+
+	later := createSubscription(t, s, models.CreateSubscriptionRequest{Name: "Later", Cost: 500, NextPayment: today.AddDate(0, 1, 0)})
+	due := createSubscription(t, s, models.CreateSubscriptionRequest{Name: "Due", Cost: 900, NextPayment: today.AddDate(0, 0, -2)})
+	trial := createSubscription(t, s, models.CreateSubscriptionRequest{Name: "Trial", Cost: 700, NextPayment: trialEndsAt, TrialEndsAt: &trialEndsAt, Category: models.CategoryWork})
+	cancelled := createSubscription(t, s, models.CreateSubscriptionRequest{Name: "Cancelled", NextPayment: today.AddDate(0, 0, -1)})
+	other := createSubscription(t, s, models.CreateSubscriptionRequest{UserID: otherID, NextPayment: today.AddDate(0, 0, -3)})
+
+	if err := s.Subscriptions.Delete(ctx, userID, cancelled.ID); err != nil {
+		t.Fatalf("Failed to delete subscription: %v", err)
+	}
+	if err := s.Subscriptions.Delete(ctx, userID, other.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting a subscription of another user, got %v", err)
+	}
+
+	active, err := s.Subscriptions.GetAllActive(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get active subscriptions: %v", err)
+	}
+	if expected := []int{due.ID, trial.ID, later.ID}; !equalIDs(subscriptionIDs(active), expected) {
+		t.Errorf("Expected active subscriptions %v by payment date, got %v", expected, subscriptionIDs(active))
+	}
+
+	all, _ := s.Subscriptions.GetAll(ctx, userID)
+	if expected := []int{later.ID, due.ID, trial.ID, cancelled.ID}; !equalIDs(subscriptionIDs(all), expected) {
+		t.Errorf("Expected every subscription %v in the order they were added, got %v", expected, subscriptionIDs(all))
+	}
+	if all[3].Active {
+		t.Error("Expected the deleted subscription to be inactive")
+	}
+
+	entertainment, _ := s.Subscriptions.GetByCategory(ctx, userID, models.CategoryEntertainment)
+	if expected := []int{due.ID, later.ID}; !equalIDs(subscriptionIDs(entertainment), expected) {
+		t.Errorf("Expected active entertainment %v by cost, got %v", expected, subscriptionIDs(entertainment))
+	}
+
+	duePayments, _ := s.Subscriptions.GetDuePayments(ctx, userID)
+	if expected := []int{due.ID}; !equalIDs(subscriptionIDs(duePayments), expected) {
+		t.Errorf("Expected due subscriptions %v, got %v", expected, subscriptionIDs(duePayments))
+	}
+
+	trials, _ := s.Subscriptions.GetActiveTrials(ctx, userID, today)
+	if expected := []int{trial.ID}; !equalIDs(subscriptionIDs(trials), expected) {
+		t.Errorf("Expected trials %v, got %v", expected, subscriptionIDs(trials))
+	}
+	if trials, _ := s.Subscriptions.GetActiveTrials(ctx, userID, trialEndsAt); len(trials) != 0 {
+		t.Errorf("Expected the trial to be over on its last day, got %v", subscriptionIDs(trials))
+	}
+
+	// Background jobs see every owner
+	dueBefore, _ := s.Subscriptions.GetAllDueBefore(ctx, today)
+	if expected := []int{other.ID, due.ID}; !equalIDs(subscriptionIDs(dueBefore), expected) {
+		t.Errorf("Expected subscriptions due before today %v, got %v", expected, subscriptionIDs(dueBefore))
+	}
+}
+
+func testSubscriptionRestore(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	trialEndsAt := date(2024, 12, 1)
+	createdAt := time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC)
+	id, err := s.Subscriptions.Restore(ctx, &models.Subscription{
+		ID: 42, UserID: userID, Name: "Old", Cost: models.NewMoney(300), Currency: models.CurrencyGBP,
+		Period: models.Every(1, models.PeriodYearly), NextPayment: date(2025, 11, 1), Category: models.CategoryHome,
+		TrialEndsAt: &trialEndsAt, Tags: []string{"work"}, Notes: "old plan", CreatedAt: createdAt, UpdatedAt: createdAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to restore subscription: %v", err)
+	}
+
+	// A restored subscription keeps its state and gets a new ID
+	got, err := s.Subscriptions.GetByID(ctx, userID, id)
+	if err != nil {
+		t.Fatalf("Failed to get restored subscription: %v", err)
+	}
+	if got.Active || got.Name != "Old" || !got.CreatedAt.Equal(createdAt) || !got.TrialEndsAt.Equal(trialEndsAt) ||
+		!slices.Equal(got.Tags, []string{"work"}) || got.Notes != "old plan" {
+		t.Errorf("Expected the subscription as it was in the backup, got %+v", got)
+	}
+
+	// Its prices are restored separately
+	effectiveTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, change := range []*models.PriceChange{
+		{SubscriptionID: id, Cost: models.NewMoney(250), Currency: models.CurrencyGBP, EffectiveFrom: createdAt, EffectiveTo: &effectiveTo, CreatedAt: createdAt},
+		{SubscriptionID: id, Cost: models.NewMoney(300), Currency: models.CurrencyGBP, EffectiveFrom: effectiveTo, CreatedAt: effectiveTo},
+	} {
+		if err := s.PriceHistory.Restore(ctx, change); err != nil {
+			t.Fatalf("Failed to restore price: %v", err)
+		}
+	}
+	newer := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	history, err := s.PriceHistory.GetByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get price history: %v", err)
+	}
+	if len(history) != 3 || history[0].SubscriptionID != id || history[0].Cost != models.NewMoney(250) ||
+		history[1].EffectiveTo != nil || history[2].SubscriptionID != newer.ID {
+		t.Errorf("Expected prices grouped by subscription and oldest first, got %d periods", len(history))
+	}
+
+	payment, err := s.Payments.Restore(ctx, &models.Payment{
+		ID: 7, UserID: userID, SubscriptionID: id, Amount: models.NewMoney(300), Currency: models.CurrencyGBP,
+		PaidAt: createdAt, Status: models.PaymentStatusVoided, Note: "refund", CreatedAt: createdAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to restore payment: %v", err)
+	}
+	if got, _ := s.Payments.GetByID(ctx, userID, payment); got == nil || got.Status != models.PaymentStatusVoided || got.Note != "refund" {
+		t.Errorf("Expected the payment as it was in the backup, got %+v", got)
+	}
+}
+
+func testSubscriptionTags(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{
+		Tags:  []string{"family", "cancel soon"},
+		Notes: "shared with Anna",
+		URL:   "https://netflix.com/cancel",
+	})
+	if !slices.Equal(sub.Tags, []string{"cancel soon", "family"}) || sub.Notes != "shared with Anna" || sub.URL != "https://netflix.com/cancel" {
+		t.Fatalf("Expected the tags sorted with the notes and link, got %+v", sub)
+	}
+	other := createSubscription(t, s, models.CreateSubscriptionRequest{UserID: otherID, Tags: []string{"family"}})
+
+	sub.Tags = []string{"family", "work"}
+	sub.Notes = ""
+	if err := s.Subscriptions.Update(ctx, sub); err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+	got, _ := s.Subscriptions.GetByID(ctx, userID, sub.ID)
+	if !slices.Equal(got.Tags, []string{"family", "work"}) || got.Notes != "" || got.URL != "https://netflix.com/cancel" {
+		t.Errorf("Expected the tags replaced and the notes cleared, got %+v", got)
+	}
+
+	// Tags of another user are separate, even with the same name
+	if got, _ := s.Subscriptions.GetByID(ctx, otherID, other.ID); !slices.Equal(got.Tags, []string{"family"}) {
+		t.Errorf("Expected the tags of the other user untouched, got %v", got.Tags)
+	}
+
+	sub.Tags = nil
+	if err := s.Subscriptions.Update(ctx, sub); err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+	all, _ := s.Subscriptions.GetAllActive(ctx, userID)
+	if len(all) != 1 || all[0].Tags != nil {
+		t.Errorf("Expected every tag removed, got %+v", all)
+	}
+}
+
+func testPaymentCreateIsIdempotent(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	req := &models.CreatePaymentRequest{
+		UserID:         userID,
+		SubscriptionID: sub.ID,
+		Amount:         sub.Cost,
+		Currency:       sub.Currency,
+		Status:         models.PaymentStatusCompleted,
+		IdempotencyKey: models.BillingCycleKey(sub.ID, sub.NextPayment),
+	}
+
+	payment, err := s.Payments.Create(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+	if payment.PaidAt.IsZero() || time.Since(payment.PaidAt) > time.Minute {
+		t.Errorf("Expected a payment without a date to be paid now, got %v", payment.PaidAt)
+	}
+	if _, err := s.Payments.Create(ctx, req); !errors.Is(err, repository.ErrDuplicatePayment) {
+		t.Fatalf("Expected ErrDuplicatePayment, got %v", err)
+	}
+
+	// Payments without a key are never deduplicated
+	req.IdempotencyKey = ""
+	for i := 0; i < 2; i++ {
+		if _, err := s.Payments.Create(ctx, req); err != nil {
+			t.Fatalf("Failed to create payment without key: %v", err)
+		}
+	}
+
+	recorded, err := s.Payments.GetBySubscriptionID(ctx, userID, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get payments: %v", err)
+	}
+	if len(recorded) != 3 {
+		t.Errorf("Expected 3 payments, got %d", len(recorded))
+	}
+
+	req.SubscriptionID = sub.ID + 100
+	if _, err := s.Payments.Create(ctx, req); err == nil {
+		t.Error("Expected a payment of a missing subscription to be rejected")
+	}
+}
+
+func testPaymentGetAndUpdate(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	first := createPayment(t, s, sub, models.NewMoney(1599), models.CurrencyUSD, models.PaymentStatusCompleted, date(2025, 1, 10))
+	second := createPayment(t, s, sub, models.NewMoney(1599), models.CurrencyUSD, models.PaymentStatusPending, date(2025, 2, 10))
+
+	got, err := s.Payments.GetByID(ctx, userID, first.ID)
+	if err != nil {
+		t.Fatalf("Failed to get payment: %v", err)
+	}
+	if got.Amount != first.Amount || !got.PaidAt.Equal(date(2025, 1, 10)) || got.Status != models.PaymentStatusCompleted {
+		t.Errorf("Expected %+v, got %+v", first, got)
+	}
+	if _, err := s.Payments.GetByID(ctx, otherID, first.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another user, got %v", err)
+	}
+
+	got.Amount = models.NewMoney(1000)
+	got.PaidAt = date(2025, 1, 11)
+	got.Status = models.PaymentStatusVoided
+	got.Note = "charged twice"
+	if err := s.Payments.Update(ctx, got); err != nil {
+		t.Fatalf("Failed to update payment: %v", err)
+	}
+	updated, _ := s.Payments.GetByID(ctx, userID, first.ID)
+	if updated.Amount != models.NewMoney(1000) || !updated.PaidAt.Equal(date(2025, 1, 11)) ||
+		updated.Status != models.PaymentStatusVoided || updated.Note != "charged twice" {
+		t.Errorf("Expected the changes to be saved, got %+v", updated)
+	}
+
+	missing := *updated
+	missing.ID = second.ID + 100
+	if err := s.Payments.Update(ctx, &missing); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing payment, got %v", err)
+	}
+
+	bySubscription, _ := s.Payments.GetBySubscriptionID(ctx, userID, sub.ID)
+	if expected := []int{second.ID, first.ID}; !equalIDs(paymentIDs(bySubscription), expected) {
+		t.Errorf("Expected payments %v newest first, got %v", expected, paymentIDs(bySubscription))
+	}
+
+	latest, _ := s.Payments.GetAllPayments(ctx, userID, 1)
+	if expected := []int{second.ID}; !equalIDs(paymentIDs(latest), expected) {
+		t.Errorf("Expected the latest payment %v, got %v", expected, paymentIDs(latest))
+	}
+}
+
+func testPaymentAnalytics(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	movies := createSubscription(t, s, models.CreateSubscriptionRequest{Category: models.CategoryEntertainment})
+	course := createSubscription(t, s, models.CreateSubscriptionRequest{Category: models.CategoryEducation, Currency: models.CurrencyEUR})
+	other := createSubscription(t, s, models.CreateSubscriptionRequest{UserID: otherID})
+
+	createPayment(t, s, movies, 1000, models.CurrencyUSD, models.PaymentStatusCompleted, date(2025, 3, 1))
+	createPayment(t, s, movies, 500, models.CurrencyUSD, models.PaymentStatusCompleted, time.Date(2025, 3, 31, 23, 0, 0, 0, time.UTC))
+	createPayment(t, s, course, 700, models.CurrencyEUR, models.PaymentStatusCompleted, date(2025, 3, 15))
+	createPayment(t, s, movies, 9999, models.CurrencyUSD, models.PaymentStatusVoided, date(2025, 3, 2))
+	createPayment(t, s, movies, 9999, models.CurrencyUSD, models.PaymentStatusCompleted, date(2025, 4, 1))
+	createPayment(t, s, other, 9999, models.CurrencyUSD, models.PaymentStatusCompleted, date(2025, 3, 5))
+
+	summaries, err := s.Payments.GetMonthlyExpense(ctx, userID, date(2025, 3, 20))
+	if err != nil {
+		t.Fatalf("Failed to get monthly expense: %v", err)
+	}
+	totals := make(map[models.Currency]models.PaymentSummary)
+	for _, summary := range summaries {
+		totals[summary.Currency] = summary
+	}
+	if len(totals) != 2 || totals[models.CurrencyUSD].TotalAmount != 1500 || totals[models.CurrencyUSD].Count != 2 ||
+		totals[models.CurrencyEUR].TotalAmount != 700 {
+		t.Errorf("Expected completed March payments per currency, got %+v", summaries)
+	}
+
+	analytics, err := s.Payments.GetCategoryAnalytics(ctx, userID, date(2025, 3, 1), time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get category analytics: %v", err)
+	}
+	entertainment := analytics[models.CategoryEntertainment]
+	education := analytics[models.CategoryEducation]
+	if len(analytics) != 2 || len(entertainment) != 1 || entertainment[0].TotalAmount != 1500 ||
+		len(education) != 1 || education[0].Currency != models.CurrencyEUR {
+		t.Errorf("Expected completed March payments per category, got %+v", analytics)
+	}
+}
+
+func testPaymentFilters(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	movies := createSubscription(t, s, models.CreateSubscriptionRequest{Category: models.CategoryEntertainment, Tags: []string{"family"}})
+	course := createSubscription(t, s, models.CreateSubscriptionRequest{Category: models.CategoryEducation})
+
+	first := createPayment(t, s, movies, 100, models.CurrencyUSD, models.PaymentStatusCompleted, time.Date(2025, 1, 31, 23, 59, 0, 0, time.UTC))
+	second := createPayment(t, s, course, 200, models.CurrencyEUR, models.PaymentStatusCompleted, date(2025, 2, 1))
+	third := createPayment(t, s, movies, 300, models.CurrencyUSD, models.PaymentStatusFailed, time.Date(2025, 2, 28, 12, 0, 0, 0, time.UTC))
+	fourth := createPayment(t, s, movies, 400, models.CurrencyUSD, models.PaymentStatusCompleted, date(2025, 3, 1))
+
+	tests := []struct {
+		name     string
+		filter   models.PaymentFilter
+		expected []int
+	}{
+		{"no filter", models.PaymentFilter{}, []int{first.ID, second.ID, third.ID, fourth.ID}},
+		{"subscription", models.PaymentFilter{SubscriptionID: course.ID}, []int{second.ID}},
+		{"category", models.PaymentFilter{Category: models.CategoryEntertainment}, []int{first.ID, third.ID, fourth.ID}},
+		{"tag", models.PaymentFilter{Tag: "family"}, []int{first.ID, third.ID, fourth.ID}},
+		{"unknown tag", models.PaymentFilter{Tag: "work"}, []int{}},
+		{"currency", models.PaymentFilter{Currency: models.CurrencyEUR}, []int{second.ID}},
+		{"status", models.PaymentFilter{Status: models.PaymentStatusFailed}, []int{third.ID}},
+		{"whole days", models.PaymentFilter{From: date(2025, 2, 1), To: date(2025, 2, 28)}, []int{second.ID, third.ID}},
+		{"combined", models.PaymentFilter{Category: models.CategoryEntertainment, Status: models.PaymentStatusCompleted, From: date(2025, 2, 1)}, []int{fourth.ID}},
+	}
+
+	for _, tt := range tests {
+		payments, err := s.Payments.GetAll(ctx, userID, tt.filter)
+		if err != nil {
+			t.Fatalf("Failed to get payments: %v", err)
+		}
+		if !equalIDs(paymentIDs(payments), tt.expected) {
+			t.Errorf("%s: expected %v oldest first, got %v", tt.name, tt.expected, paymentIDs(payments))
+		}
+	}
+
+	if payments, _ := s.Payments.GetAll(ctx, otherID, models.PaymentFilter{}); len(payments) != 0 {
+		t.Errorf("Expected no payments of another user, got %v", paymentIDs(payments))
+	}
+}
+
+func testPaymentPages(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	// Two payments share a date, the ID breaks the tie
+	var created []*models.Payment
+	for _, paidAt := range []time.Time{date(2025, 1, 1), date(2025, 1, 2), date(2025, 1, 2), date(2025, 1, 3), date(2025, 1, 4)} {
+		created = append(created, createPayment(t, s, sub, 100, models.CurrencyUSD, models.PaymentStatusCompleted, paidAt))
+	}
+
+	req := models.PaymentPageRequest{Limit: 2}
+	var pages [][]int
+	var last *models.PaymentPage
+	for {
+		page, err := s.Payments.GetPage(ctx, userID, req)
+		if err != nil {
+			t.Fatalf("Failed to get page: %v", err)
+		}
+		pages = append(pages, paymentIDs(page.Payments))
+		last = page
+		if page.Older == nil {
+			break
+		}
+		req.Cursor, req.Newer = page.Older, false
+	}
+
+	expected := [][]int{{created[4].ID, created[3].ID}, {created[2].ID, created[1].ID}, {created[0].ID}}
+	if len(pages) != len(expected) {
+		t.Fatalf("Expected %d pages, got %v", len(expected), pages)
+	}
+	for i := range expected {
+		if !equalIDs(pages[i], expected[i]) {
+			t.Errorf("Page %d: expected %v, got %v", i, expected[i], pages[i])
+		}
+	}
+
+	// The way back leads through the same pages
+	page, err := s.Payments.GetPage(ctx, userID, models.PaymentPageRequest{Cursor: last.Newer, Newer: true, Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to get newer page: %v", err)
+	}
+	if !equalIDs(paymentIDs(page.Payments), expected[1]) || page.Newer == nil || page.Older == nil {
+		t.Errorf("Expected page %v with both cursors, got %v", expected[1], paymentIDs(page.Payments))
+	}
+}
+
+func testSettings(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	if _, ok, err := s.Settings.GetBaseCurrency(ctx, userID); err != nil || ok {
+		t.Errorf("Expected no base currency, got %v (%v)", ok, err)
+	}
+
+	for _, currency := range []models.Currency{models.CurrencyEUR, models.CurrencyRUB} {
+		if err := s.Settings.SetBaseCurrency(ctx, userID, currency); err != nil {
+			t.Fatalf("Failed to set base currency: %v", err)
+		}
+		if got, ok, err := s.Settings.GetBaseCurrency(ctx, userID); err != nil || !ok || got != currency {
+			t.Errorf("Expected %s, got %s %v (%v)", currency, got, ok, err)
+		}
+	}
+
+	if _, ok, _ := s.Settings.GetBaseCurrency(ctx, otherID); ok {
+		t.Error("Expected settings to be per user")
+	}
+}
+
+func testCategories(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	for _, req := range models.DefaultCategories() {
+		req.UserID = userID
+		if _, err := s.Categories.Create(ctx, &req); err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+	}
+	custom, err := s.Categories.Create(ctx, &models.CreateCategoryRequest{UserID: userID, Key: "custom_1", Name: "Облако", Emoji: "☁️"})
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	if custom.ID == 0 || custom.Archived || custom.CreatedAt.IsZero() {
+		t.Errorf("Unexpected created category: %+v", custom)
+	}
+
+	// Creating a key twice keeps the first category
+	_, err = s.Categories.Create(ctx, &models.CreateCategoryRequest{UserID: userID, Key: models.CategoryWork, Name: "Офис"})
+	if !errors.Is(err, repository.ErrDuplicateCategory) {
+		t.Fatalf("Expected ErrDuplicateCategory, got %v", err)
+	}
+	// Another user has keys of their own
+	if _, err := s.Categories.Create(ctx, &models.CreateCategoryRequest{UserID: otherID, Key: models.CategoryWork, Name: "Работа"}); err != nil {
+		t.Fatalf("Failed to create category of another user: %v", err)
+	}
+
+	categories, err := s.Categories.GetAll(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get categories: %v", err)
+	}
+	if len(categories) != 6 || categories[0].Key != models.CategoryEntertainment || categories[1].Name != "Работа" ||
+		categories[5].Key != "custom_1" || categories[5].Emoji != "☁️" {
+		t.Fatalf("Expected the defaults and the custom category in order, got %d", len(categories))
+	}
+
+	custom.Name = "Хранилище"
+	custom.Emoji = ""
+	custom.Archived = true
+	if err := s.Categories.Update(ctx, custom); err != nil {
+		t.Fatalf("Failed to update category: %v", err)
+	}
+	categories, _ = s.Categories.GetAll(ctx, userID)
+	if got := categories[5]; got.Name != "Хранилище" || got.Emoji != "" || !got.Archived || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Errorf("Unexpected updated category: %+v", got)
+	}
+
+	if err := s.Categories.Update(ctx, &models.UserCategory{UserID: otherID, Key: "custom_1", Name: "Чужая"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a key the user doesn't have, got %v", err)
+	}
+
+	// Restore replaces a category with the same key and adds a new one
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, category := range []*models.UserCategory{
+		{UserID: userID, Key: models.CategoryWork, Name: "Офис", Emoji: "🏢", Archived: true, CreatedAt: createdAt},
+		{UserID: userID, Key: "custom_2", Name: "Спорт", Emoji: "⚽", CreatedAt: createdAt},
+	} {
+		if err := s.Categories.Restore(ctx, category); err != nil {
+			t.Fatalf("Failed to restore category: %v", err)
+		}
+	}
+	categories, _ = s.Categories.GetAll(ctx, userID)
+	if len(categories) != 7 || categories[1].Name != "Офис" || !categories[1].Archived ||
+		categories[6].Key != "custom_2" || !categories[6].CreatedAt.Equal(createdAt) {
+		t.Errorf("Unexpected categories after restore: %d", len(categories))
+	}
+
+	if others, _ := s.Categories.GetAll(ctx, otherID); len(others) != 1 || others[0].Name != "Работа" {
+		t.Errorf("Expected categories to be per user, got %d", len(others))
+	}
+}
+
+func testBudgets(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	for _, budget := range []*models.Budget{
+		{UserID: userID, Category: models.CategoryWork, Currency: models.CurrencyUSD, Limit: 5000},
+		{UserID: userID, Currency: models.CurrencyRUB, Limit: 1000000},
+		{UserID: userID, Category: models.CategoryEntertainment, Currency: models.CurrencyUSD, Limit: 3000},
+		{UserID: otherID, Currency: models.CurrencyRUB, Limit: 500000},
+	} {
+		if err := s.Budgets.Set(ctx, budget); err != nil {
+			t.Fatalf("Failed to set budget: %v", err)
+		}
+		if budget.ID == 0 || budget.CreatedAt.IsZero() {
+			t.Errorf("Expected the budget to get an ID and creation time, got %+v", budget)
+		}
+	}
+
+	// Setting the same category and currency again changes the limit
+	changed := &models.Budget{UserID: userID, Category: models.CategoryWork, Currency: models.CurrencyUSD, Limit: 7000}
+	if err := s.Budgets.Set(ctx, changed); err != nil {
+		t.Fatalf("Failed to change budget: %v", err)
+	}
+
+	budgets, err := s.Budgets.GetAll(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get budgets: %v", err)
+	}
+	if len(budgets) != 3 || !budgets[0].IsOverall() || budgets[1].Category != models.CategoryEntertainment ||
+		budgets[2].Limit != 7000 || budgets[2].ID != changed.ID || budgets[2].UpdatedAt.Before(budgets[2].CreatedAt) {
+		t.Fatalf("Expected the overall budget first and the changed limit, got %d", len(budgets))
+	}
+
+	if err := s.Budgets.Set(ctx, &models.Budget{UserID: userID, Currency: models.CurrencyEUR}); err == nil {
+		t.Error("Expected a zero limit to be rejected")
+	}
+
+	if err := s.Budgets.Delete(ctx, userID, models.CategoryWork, models.CurrencyUSD); err != nil {
+		t.Fatalf("Failed to delete budget: %v", err)
+	}
+	if err := s.Budgets.Delete(ctx, userID, models.CategoryWork, models.CurrencyUSD); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted budget, got %v", err)
+	}
+	if err := s.Budgets.Delete(ctx, otherID, models.CategoryEntertainment, models.CurrencyUSD); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a budget of another user, got %v", err)
+	}
+
+	if budgets, _ := s.Budgets.GetAll(ctx, userID); len(budgets) != 2 {
+		t.Errorf("Expected 2 budgets after delete, got %d", len(budgets))
+	}
+	if others, _ := s.Budgets.GetAll(ctx, otherID); len(others) != 1 || others[0].Limit != 500000 {
+		t.Errorf("Expected budgets to be per user, got %d", len(others))
+	}
+}
+
+func testExchangeRates(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+
+	for _, rate := range []*models.ExchangeRate{
+		{From: models.CurrencyUSD, To: models.CurrencyRUB, Rate: 90, Source: "manual"},
+		{From: models.CurrencyEUR, To: models.CurrencyUSD, Rate: 1.1, Source: "manual"},
+		{From: models.CurrencyUSD, To: models.CurrencyRUB, Rate: 92.5, Source: "fixture"},
+	} {
+		if err := s.ExchangeRates.Save(ctx, rate); err != nil {
+			t.Fatalf("Failed to save rate: %v", err)
+		}
+	}
+
+	rates, err := s.ExchangeRates.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get rates: %v", err)
+	}
+	if len(rates) != 2 || rates[0].From != models.CurrencyEUR || rates[1].Rate != 92.5 || rates[1].Source != "fixture" ||
+		rates[1].UpdatedAt.IsZero() {
+		t.Errorf("Expected the second USD/RUB rate to replace the first, got %+v %+v", rates[0], rates[1])
+	}
+
+	if err := s.ExchangeRates.Save(ctx, &models.ExchangeRate{From: models.CurrencyUSD, To: models.CurrencyEUR, Rate: 0, Source: "manual"}); err == nil {
+		t.Error("Expected a zero rate to be rejected")
+	}
+}
+
+func testReminders(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+
+	reserved, err := s.Reminders.Reserve(ctx, sub, models.ReminderKindUpcoming)
+	if err != nil || !reserved {
+		t.Fatalf("Expected the reminder to be reserved, got %v (%v)", reserved, err)
+	}
+	if reserved, _ := s.Reminders.Reserve(ctx, sub, models.ReminderKindUpcoming); reserved {
+		t.Error("Expected a reminder to be reserved once per cycle")
+	}
+	if reserved, _ := s.Reminders.Reserve(ctx, sub, models.ReminderKindDue); !reserved {
+		t.Error("Expected another kind of reminder to be reserved")
+	}
+
+	if err := s.Reminders.Release(ctx, sub, models.ReminderKindDue); err != nil {
+		t.Fatalf("Failed to release reminder: %v", err)
+	}
+	if reserved, _ := s.Reminders.Reserve(ctx, sub, models.ReminderKindDue); !reserved {
+		t.Error("Expected a released reminder to be reserved again")
+	}
+
+	if err := s.Reminders.Snooze(ctx, otherID, sub.ID, sub.NextPayment, time.Now()); err == nil {
+		t.Error("Expected a reminder of another user not to be snoozed")
+	}
+
+	until := time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC)
+	if err := s.Reminders.Snooze(ctx, userID, sub.ID, sub.NextPayment, until); err != nil {
+		t.Fatalf("Failed to snooze reminder: %v", err)
+	}
+
+	if snoozed, _ := s.Reminders.TakeSnoozed(ctx, until.Add(-time.Minute)); len(snoozed) != 0 {
+		t.Errorf("Expected no reminders before the snooze ends, got %v", subscriptionIDs(snoozed))
+	}
+	snoozed, err := s.Reminders.TakeSnoozed(ctx, until)
+	if err != nil {
+		t.Fatalf("Failed to take snoozed reminders: %v", err)
+	}
+	// Both reminders of the cycle were snoozed, the subscription is returned once
+	if expected := []int{sub.ID}; !equalIDs(subscriptionIDs(snoozed), expected) {
+		t.Errorf("Expected snoozed subscriptions %v, got %v", expected, subscriptionIDs(snoozed))
+	}
+	if snoozed, _ := s.Reminders.TakeSnoozed(ctx, until); len(snoozed) != 0 {
+		t.Errorf("Expected a snoozed reminder to be repeated once, got %v", subscriptionIDs(snoozed))
+	}
+}
+
+func testTransactions(t *testing.T, s *repository.Storage) {
+	ctx := context.Background()
+	sub := createSubscription(t, s, models.CreateSubscriptionRequest{})
+	failure := errors.New("failure")
+
+	err := s.UnitOfWork.Do(ctx, func(tx repository.Stores) error {
+		locked, err := tx.Subscriptions.GetByIDForUpdate(ctx, userID, sub.ID)
+		if err != nil {
+			return err
+		}
+		locked.Cost = models.NewMoney(2999)
+		if err := tx.Subscriptions.Update(ctx, locked); err != nil {
+			return err
+		}
+		if _, err := tx.Subscriptions.Create(ctx, &models.CreateSubscriptionRequest{
+			UserID: userID, Name: "Spotify", Cost: 999, Currency: models.CurrencyEUR,
+			Period: models.Every(1, models.PeriodMonthly), NextPayment: date(2025, 3, 1), Category: models.CategoryOther,
+		}); err != nil {
+			return err
+		}
+		if err := tx.Settings.SetBaseCurrency(ctx, userID, models.CurrencyEUR); err != nil {
+			return err
+		}
+		if _, err := tx.Categories.Create(ctx, &models.CreateCategoryRequest{UserID: userID, Key: "custom_1", Name: "Облако"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the error of fn, got %v", err)
+	}
+
+	// Nothing of a failed transaction is kept
+	if got, _ := s.Subscriptions.GetByID(ctx, userID, sub.ID); got.Cost != sub.Cost {
+		t.Errorf("Expected the cost to be rolled back, got %d", got.Cost)
+	}
+	if count, _ := s.Subscriptions.Count(ctx, userID); count != 1 {
+		t.Errorf("Expected the new subscription to be rolled back, got %d subscriptions", count)
+	}
+	if history, _ := s.PriceHistory.GetBySubscriptionID(ctx, userID, sub.ID); len(history) != 1 {
+		t.Errorf("Expected the price change to be rolled back, got %d periods", len(history))
+	}
+	if _, ok, _ := s.Settings.GetBaseCurrency(ctx, userID); ok {
+		t.Error("Expected the base currency to be rolled back")
+	}
+	if categories, _ := s.Categories.GetAll(ctx, userID); len(categories) != 0 {
+		t.Errorf("Expected the category to be rolled back, got %d", len(categories))
+	}
+
+	err = s.UnitOfWork.Do(ctx, func(tx repository.Stores) error {
+		_, err := tx.Payments.Create(ctx, &models.CreatePaymentRequest{
+			UserID: userID, SubscriptionID: sub.ID, Amount: sub.Cost, Currency: sub.Currency,
+			Status: models.PaymentStatusCompleted, IdempotencyKey: "cycle",
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if payments, _ := s.Payments.GetBySubscriptionID(ctx, userID, sub.ID); len(payments) != 1 {
+		t.Errorf("Expected the payment of a committed transaction, got %d", len(payments))
+	}
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// budgetColumns are read by scanBudget, in the same order
+const budgetColumns = `id, user_id, category, currency, amount, created_at, updated_at`
+
+type budgets struct {
+	db DBTX
+}
+
+// Set saves the budget, replacing the limit of the budget the user has in
+// the same category and currency.
+func (r *budgets) Set(ctx context.Context, budget *models.Budget) error {
+	now := timestamp(time.Now())
+	query := `
+		INSERT INTO budgets (user_id, category, currency, amount, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, category, currency) DO UPDATE
+		SET amount = excluded.amount, updated_at = excluded.updated_at
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		budget.UserID, budget.Category, budget.Currency, budget.Limit, now, now,
+	).Scan(&budget.ID, timestampColumn{&budget.CreatedAt}, timestampColumn{&budget.UpdatedAt})
+	if err != nil {
+		return fmt.Errorf("failed to set budget: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns the budgets of the user, the overall ones first
+func (r *budgets) GetAll(ctx context.Context, userID int64) ([]*models.Budget, error) {
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets WHERE user_id = ?
+		ORDER BY category ASC, currency ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []*models.Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, budget)
+	}
+
+	return budgets, rows.Err()
+}
+
+func (r *budgets) Delete(ctx context.Context, userID int64, category models.Category, currency models.Currency) error {
+	query := `DELETE FROM budgets WHERE user_id = ? AND category = ? AND currency = ?`
+
+	result, err := r.db.ExecContext(ctx, query, userID, category, currency)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return fmt.Errorf("budget %w", repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+func scanBudget(row row) (*models.Budget, error) {
+	var budget models.Budget
+	err := row.Scan(
+		&budget.ID, &budget.UserID, &budget.Category, &budget.Currency, &budget.Limit,
+		timestampColumn{&budget.CreatedAt}, timestampColumn{&budget.UpdatedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
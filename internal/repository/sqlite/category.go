@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// categoryColumns are read by scanCategory, in the same order
+const categoryColumns = `id, user_id, key, name, emoji, archived, created_at, updated_at`
+
+type categories struct {
+	db DBTX
+}
+
+// Create adds a category of the user. A key the user already has is left
+// as is and gets repository.ErrDuplicateCategory.
+func (r *categories) Create(ctx context.Context, req *models.CreateCategoryRequest) (*models.UserCategory, error) {
+	now := timestamp(time.Now())
+	query := `
+		INSERT INTO categories (user_id, key, name, emoji, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(r.db.QueryRowContext(ctx, query, req.UserID, req.Key, req.Name, req.Emoji, now, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrDuplicateCategory
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+
+	return category, nil
+}
+
+// Restore saves a category from a backup, replacing the name, emoji and
+// archived flag of the category with the same key if the user has one.
+func (r *categories) Restore(ctx context.Context, category *models.UserCategory) error {
+	query := `
+		INSERT INTO categories (user_id, key, name, emoji, archived, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET name = excluded.name, emoji = excluded.emoji, archived = excluded.archived, updated_at = excluded.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		category.UserID, category.Key, category.Name, category.Emoji, category.Archived,
+		timestamp(category.CreatedAt), timestamp(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore category: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns the categories of the user, archived ones included, oldest first
+func (r *categories) GetAll(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories WHERE user_id = ?
+		ORDER BY id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*models.UserCategory
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// Update saves the name, emoji and archived flag of the category
+func (r *categories) Update(ctx context.Context, category *models.UserCategory) error {
+	updatedAt := time.Now()
+	query := `
+		UPDATE categories SET name = ?, emoji = ?, archived = ?, updated_at = ?
+		WHERE user_id = ? AND key = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		category.Name, category.Emoji, category.Archived, timestamp(updatedAt), category.UserID, category.Key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return fmt.Errorf("category %w", repository.ErrNotFound)
+	}
+	category.UpdatedAt = time.UnixMicro(timestamp(updatedAt))
+
+	return nil
+}
+
+func scanCategory(row row) (*models.UserCategory, error) {
+	var category models.UserCategory
+	err := row.Scan(
+		&category.ID, &category.UserID, &category.Key, &category.Name, &category.Emoji,
+		&category.Archived, timestampColumn{&category.CreatedAt}, timestampColumn{&category.UpdatedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
@@ -0,0 +1,129 @@
+// Package sqlite stores the data of a single-user deployment in a local
+// SQLite file. It behaves like the PostgreSQL repositories, repotest checks
+// that, so the bot can run as one binary without a database server.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sub-cos-counter/internal/repository"
+
+	_ "modernc.org/sqlite"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so repositories run the
+// same queries on their own or as a part of a unit of work.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Open opens the database file, creating it when missing. The tables are
+// created by the migrations, see package migrate.
+//
+// Transactions take the write lock when they begin, so a transaction that
+// reads a row to change it is never overtaken, like with FOR UPDATE in
+// PostgreSQL. Writers wait for each other instead of failing at once.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(10000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return db, nil
+}
+
+// NewStorage returns the SQLite repositories sharing the database
+func NewStorage(db *sql.DB) *repository.Storage {
+	return &repository.Storage{
+		Stores:        stores(db),
+		ExchangeRates: &exchangeRates{db: db},
+		Reminders:     &reminders{db: db},
+		UnitOfWork:    &unitOfWork{db: db},
+	}
+}
+
+func stores(db DBTX) repository.Stores {
+	return repository.Stores{
+		Subscriptions: &subscriptions{db: db},
+		Payments:      &payments{db: db},
+		PriceHistory:  &priceHistory{db: db},
+		Settings:      &settings{db: db},
+		Categories:    &categories{db: db},
+		Budgets:       &budgets{db: db},
+	}
+}
+
+// unitOfWork runs changes of several repositories in one transaction
+type unitOfWork struct {
+	db *sql.DB
+}
+
+// Do commits the transaction when fn succeeds and rolls it back otherwise.
+// The repositories passed to fn run their queries in the transaction.
+func (u *unitOfWork) Do(ctx context.Context, fn func(tx repository.Stores) error) error {
+	return transact(ctx, u.db, func(tx DBTX) error {
+		return fn(stores(tx))
+	})
+}
+
+// transact runs fn in a transaction of db. Inside a transaction it starts a
+// savepoint instead, like Begin of pgx.Tx.
+func transact(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	switch db := db.(type) {
+	case *sql.DB:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+
+	default:
+		if _, err := db.ExecContext(ctx, `SAVEPOINT nested`); err != nil {
+			return fmt.Errorf("failed to begin savepoint: %w", err)
+		}
+
+		if err := fn(db); err != nil {
+			db.ExecContext(ctx, `ROLLBACK TO nested`)
+			db.ExecContext(ctx, `RELEASE nested`)
+			return err
+		}
+
+		if _, err := db.ExecContext(ctx, `RELEASE nested`); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		return nil
+	}
+}
+
+// rowsAffected returns the number of rows changed by a statement
+func rowsAffected(result sql.Result) int64 {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
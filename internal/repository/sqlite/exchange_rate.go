@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+type exchangeRates struct {
+	db *sql.DB
+}
+
+// Save stores the rate, replacing the previous rate of the same currency pair
+func (r *exchangeRates) Save(ctx context.Context, rate *models.ExchangeRate) error {
+	query := `
+		INSERT INTO exchange_rates (currency_from, currency_to, rate, source, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (currency_from, currency_to) DO UPDATE
+		SET rate = excluded.rate, source = excluded.source, updated_at = excluded.updated_at`
+
+	if _, err := r.db.ExecContext(ctx, query, rate.From, rate.To, rate.Rate, rate.Source, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return nil
+}
+
+func (r *exchangeRates) GetAll(ctx context.Context) ([]*models.ExchangeRate, error) {
+	query := `
+		SELECT currency_from, currency_to, rate, source, updated_at
+		FROM exchange_rates ORDER BY currency_from, currency_to`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []*models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.From, &rate.To, &rate.Rate, &rate.Source, timestampColumn{&rate.UpdatedAt}); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, &rate)
+	}
+
+	return rates, rows.Err()
+}
@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// paymentColumns are read by scanPayment, in the same order
+const paymentColumns = `id, user_id, subscription_id, amount, currency, paid_at, status, note, created_at`
+
+type payments struct {
+	db DBTX
+}
+
+// Create records a payment. A payment with an idempotency key is recorded at
+// most once, repeated requests get repository.ErrDuplicatePayment.
+func (r *payments) Create(ctx context.Context, req *models.CreatePaymentRequest) (*models.Payment, error) {
+	now := time.Now()
+	paidAt := now
+	if req.PaidAt != nil {
+		paidAt = *req.PaidAt
+	}
+
+	query := `
+		INSERT INTO payments (user_id, subscription_id, amount, currency, status, paid_at, idempotency_key, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING ` + paymentColumns
+
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query,
+		req.UserID, req.SubscriptionID, req.Amount, req.Currency, req.Status, timestamp(paidAt),
+		req.IdempotencyKey, req.Note, timestamp(now),
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrDuplicatePayment
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// Restore inserts a payment from a backup as is and returns its new ID
+func (r *payments) Restore(ctx context.Context, payment *models.Payment) (int, error) {
+	query := `
+		INSERT INTO payments (user_id, subscription_id, amount, currency, paid_at, status, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		payment.UserID, payment.SubscriptionID, payment.Amount, payment.Currency, timestamp(payment.PaidAt),
+		payment.Status, payment.Note, timestamp(payment.CreatedAt),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore payment: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *payments) GetByID(ctx context.Context, userID int64, id int) (*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE id = ? AND user_id = ?`
+
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment %w", repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// Update saves the amount, date, status and note of the payment
+func (r *payments) Update(ctx context.Context, payment *models.Payment) error {
+	query := `
+		UPDATE payments SET amount = ?, paid_at = ?, status = ?, note = ?
+		WHERE id = ? AND user_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		payment.Amount, timestamp(payment.PaidAt), payment.Status, payment.Note, payment.ID, payment.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return fmt.Errorf("payment %w", repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *payments) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE user_id = ? AND subscription_id = ? ORDER BY paid_at DESC`
+
+	return r.query(ctx, "failed to get payments", query, userID, subscriptionID)
+}
+
+func (r *payments) GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error) {
+	startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	endOfMonth := startOfMonth.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+
+	query := `
+		SELECT currency, SUM(amount) as total_amount, COUNT(*) as count
+		FROM payments
+		WHERE user_id = ? AND paid_at >= ? AND paid_at <= ? AND status = 'completed'
+		GROUP BY currency`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, timestamp(startOfMonth), timestamp(endOfMonth))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monthly expense: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []models.PaymentSummary
+	for rows.Next() {
+		var summary models.PaymentSummary
+		err := rows.Scan(&summary.Currency, &summary.TotalAmount, &summary.Count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment summary: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+func (r *payments) GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
+	query := `
+		SELECT s.category, p.currency, SUM(p.amount) as total_amount, COUNT(*) as count
+		FROM payments p
+		JOIN subscriptions s ON p.subscription_id = s.id
+		WHERE p.user_id = ? AND p.paid_at >= ? AND p.paid_at <= ? AND p.status = 'completed'
+		GROUP BY s.category, p.currency
+		ORDER BY s.category, p.currency`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, timestamp(startDate), timestamp(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category analytics: %w", err)
+	}
+	defer rows.Close()
+
+	analytics := make(map[models.Category][]models.PaymentSummary)
+	for rows.Next() {
+		var category models.Category
+		var summary models.PaymentSummary
+		err := rows.Scan(&category, &summary.Currency, &summary.TotalAmount, &summary.Count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category analytics: %w", err)
+		}
+		analytics[category] = append(analytics[category], summary)
+	}
+
+	return analytics, rows.Err()
+}
+
+func (r *payments) GetAllPayments(ctx context.Context, userID int64, limit int) ([]*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments WHERE user_id = ? ORDER BY paid_at DESC LIMIT ?`
+
+	return r.query(ctx, "failed to get all payments", query, userID, limit)
+}
+
+// GetPage returns a page of the payments matching the filter. It pages by
+// (paid_at, id) instead of an offset, so a page costs the same however deep
+// in the history it is.
+func (r *payments) GetPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error) {
+	conditions, args := paymentConditions(userID, req.Filter)
+
+	order := "DESC"
+	if req.Cursor != nil {
+		comparison := "<"
+		if req.Newer {
+			comparison = ">"
+		}
+		conditions = append(conditions, "(paid_at, id) "+comparison+" (?, ?)")
+		args = append(args, timestamp(req.Cursor.PaidAt), req.Cursor.ID)
+	}
+	if req.Newer {
+		order = "ASC"
+	}
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY paid_at ` + order + `, id ` + order + `
+		LIMIT ?`
+
+	payments, err := r.query(ctx, "failed to get payments page", query, append(args, req.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaymentPage(payments, req), nil
+}
+
+// GetAll returns every payment matching the filter, oldest first
+func (r *payments) GetAll(ctx context.Context, userID int64, filter models.PaymentFilter) ([]*models.Payment, error) {
+	conditions, args := paymentConditions(userID, filter)
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY paid_at ASC, id ASC`
+
+	return r.query(ctx, "failed to get payments", query, args...)
+}
+
+// paymentConditions turns the filter into WHERE conditions with their arguments,
+// the user ID is always the first one
+func paymentConditions(userID int64, filter models.PaymentFilter) ([]string, []any) {
+	conditions := []string{"user_id = ?"}
+	args := []any{userID}
+	add := func(condition string, value any) {
+		conditions = append(conditions, condition)
+		args = append(args, value)
+	}
+
+	if filter.SubscriptionID != 0 {
+		add("subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Category != "" {
+		add("subscription_id IN (SELECT id FROM subscriptions WHERE category = ?)", filter.Category)
+	}
+	if filter.Tag != "" {
+		add(`subscription_id IN (
+			SELECT st.subscription_id FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE t.name = ?)`, filter.Tag)
+	}
+	if filter.Currency != "" {
+		add("currency = ?", filter.Currency)
+	}
+	if filter.Status != "" {
+		add("status = ?", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		add("paid_at >= ?", timestamp(models.DateOnly(filter.From)))
+	}
+	if !filter.To.IsZero() {
+		add("paid_at < ?", timestamp(models.DateOnly(filter.To).AddDate(0, 0, 1)))
+	}
+
+	return conditions, args
+}
+
+func (r *payments) query(ctx context.Context, failure, query string, args ...any) ([]*models.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", failure, err)
+	}
+	defer rows.Close()
+
+	return scanPayments(rows)
+}
+
+func scanPayment(row row) (*models.Payment, error) {
+	var payment models.Payment
+	err := row.Scan(
+		&payment.ID, &payment.UserID, &payment.SubscriptionID, &payment.Amount, &payment.Currency,
+		timestampColumn{&payment.PaidAt}, &payment.Status, &payment.Note, timestampColumn{&payment.CreatedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func scanPayments(rows *sql.Rows) ([]*models.Payment, error) {
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
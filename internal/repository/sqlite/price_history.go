@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// priceHistory reads the price periods written by subscriptions
+type priceHistory struct {
+	db DBTX
+}
+
+// Restore inserts a price period from a backup as is
+func (r *priceHistory) Restore(ctx context.Context, change *models.PriceChange) error {
+	query := `
+		INSERT INTO subscription_price_history (subscription_id, cost, currency, effective_from, effective_to, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		change.SubscriptionID, change.Cost, change.Currency, timestamp(change.EffectiveFrom),
+		nullTimestamp(change.EffectiveTo), timestamp(change.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore price: %w", err)
+	}
+
+	return nil
+}
+
+// GetBySubscriptionID returns the prices of one subscription, oldest first.
+func (r *priceHistory) GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.PriceChange, error) {
+	query := `
+		SELECT h.id, h.subscription_id, h.cost, h.currency, h.effective_from, h.effective_to, h.created_at
+		FROM subscription_price_history h
+		JOIN subscriptions s ON s.id = h.subscription_id
+		WHERE s.user_id = ? AND h.subscription_id = ?
+		ORDER BY h.effective_from ASC, h.id ASC`
+
+	return r.query(ctx, query, userID, subscriptionID)
+}
+
+// GetByUserID returns the prices of all subscriptions of the user,
+// grouped by subscription and oldest first.
+func (r *priceHistory) GetByUserID(ctx context.Context, userID int64) ([]*models.PriceChange, error) {
+	query := `
+		SELECT h.id, h.subscription_id, h.cost, h.currency, h.effective_from, h.effective_to, h.created_at
+		FROM subscription_price_history h
+		JOIN subscriptions s ON s.id = h.subscription_id
+		WHERE s.user_id = ?
+		ORDER BY h.subscription_id ASC, h.effective_from ASC, h.id ASC`
+
+	return r.query(ctx, query, userID)
+}
+
+func (r *priceHistory) query(ctx context.Context, query string, args ...any) ([]*models.PriceChange, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*models.PriceChange
+	for rows.Next() {
+		var change models.PriceChange
+		err := rows.Scan(
+			&change.ID, &change.SubscriptionID, &change.Cost, &change.Currency,
+			timestampColumn{&change.EffectiveFrom}, nullTimestampColumn{&change.EffectiveTo}, timestampColumn{&change.CreatedAt},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price change: %w", err)
+		}
+		history = append(history, &change)
+	}
+
+	return history, rows.Err()
+}
+
+// recordPrice closes the current price period of the subscription and opens a new one.
+func recordPrice(ctx context.Context, tx DBTX, subscriptionID int, cost models.Money, currency models.Currency, at time.Time) error {
+	closeQuery := `
+		UPDATE subscription_price_history SET effective_to = ?
+		WHERE subscription_id = ? AND effective_to IS NULL`
+
+	if _, err := tx.ExecContext(ctx, closeQuery, timestamp(at), subscriptionID); err != nil {
+		return fmt.Errorf("failed to close price period: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO subscription_price_history (subscription_id, cost, currency, effective_from, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+
+	if _, err := tx.ExecContext(ctx, insertQuery, subscriptionID, cost, currency, timestamp(at), timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to record price: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+type reminders struct {
+	db *sql.DB
+}
+
+// Reserve records a reminder for the billing cycle. It returns false when the
+// reminder was already recorded, so each reminder is sent only once per cycle.
+func (r *reminders) Reserve(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) (bool, error) {
+	query := `
+		INSERT INTO payment_reminders (subscription_id, user_id, due_date, kind, sent_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (subscription_id, due_date, kind) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, sub.ID, sub.UserID, date(sub.NextPayment), kind, timestamp(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve reminder: %w", err)
+	}
+
+	return rowsAffected(result) == 1, nil
+}
+
+// Release removes a reservation whose message could not be delivered,
+// so the reminder is retried on the next run.
+func (r *reminders) Release(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error {
+	query := `DELETE FROM payment_reminders WHERE subscription_id = ? AND due_date = ? AND kind = ?`
+
+	_, err := r.db.ExecContext(ctx, query, sub.ID, date(sub.NextPayment), kind)
+	if err != nil {
+		return fmt.Errorf("failed to release reminder: %w", err)
+	}
+
+	return nil
+}
+
+// Snooze postpones reminders of the cycle until the given time.
+func (r *reminders) Snooze(ctx context.Context, userID int64, subscriptionID int, dueDate, until time.Time) error {
+	query := `
+		UPDATE payment_reminders SET snoozed_until = ?
+		WHERE subscription_id = ? AND user_id = ? AND due_date = ?`
+
+	result, err := r.db.ExecContext(ctx, query, timestamp(until), subscriptionID, userID, date(dueDate))
+	if err != nil {
+		return fmt.Errorf("failed to snooze reminder: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return fmt.Errorf("reminder not found")
+	}
+
+	return nil
+}
+
+// TakeSnoozed returns subscriptions whose snoozed reminders have expired and
+// clears the snooze, so every snoozed reminder is repeated exactly once.
+// SQLite has no UPDATE in WITH, so both steps share a transaction instead.
+func (r *reminders) TakeSnoozed(ctx context.Context, now time.Time) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := transact(ctx, r.db, func(tx DBTX) error {
+		query := `
+			UPDATE payment_reminders SET snoozed_until = NULL
+			WHERE snoozed_until IS NOT NULL AND snoozed_until <= ?
+			RETURNING subscription_id, due_date`
+
+		rows, err := tx.QueryContext(ctx, query, timestamp(now))
+		if err != nil {
+			return fmt.Errorf("failed to get snoozed reminders: %w", err)
+		}
+		defer rows.Close()
+
+		var conditions []string
+		var args []any
+		for rows.Next() {
+			var subscriptionID int
+			var dueDate string
+			if err := rows.Scan(&subscriptionID, &dueDate); err != nil {
+				return fmt.Errorf("failed to scan snoozed reminder: %w", err)
+			}
+			conditions = append(conditions, "(id = ? AND next_payment = ?)")
+			args = append(args, subscriptionID, dueDate)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to get snoozed reminders: %w", err)
+		}
+		if len(conditions) == 0 {
+			return nil
+		}
+
+		query = `
+			SELECT ` + subscriptionColumns + `
+			FROM subscriptions
+			WHERE active = 1 AND (` + strings.Join(conditions, " OR ") + `)
+			ORDER BY id`
+
+		subRows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to get snoozed subscriptions: %w", err)
+		}
+		defer subRows.Close()
+
+		subscriptions, err = scanSubscriptions(subRows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,49 @@
+package sqlite_test
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/repotest"
+	"sub-cos-counter/internal/repository/sqlite"
+	"sub-cos-counter/internal/testdb"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repository.Storage {
+		return sqlite.NewStorage(testdb.NewSQLite(t))
+	})
+}
+
+// Test that concurrent updates of one user are not lost
+func TestStateRepositoryConcurrentUpdates(t *testing.T) {
+	store := sqlite.NewStateRepository(testdb.NewSQLite(t), time.Hour, "idle")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Update(ctx, 1, func(state *models.UserState) {
+				count, _ := state.Data["count"].(int)
+				state.Data["count"] = count + 1
+			})
+			if err != nil {
+				t.Errorf("Failed to update state: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, err := store.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get state: %v", err)
+	}
+	if state.Data["count"] != 20 {
+		t.Errorf("Expected 20 updates, got %v", state.Data["count"])
+	}
+}
@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+// StateRepository stores conversation states in the database file so that
+// unfinished dialogs survive restarts. It implements bot.StateStore.
+type StateRepository struct {
+	db           *sql.DB
+	ttl          time.Duration
+	initialState string
+}
+
+func NewStateRepository(db *sql.DB, ttl time.Duration, initialState string) *StateRepository {
+	return &StateRepository{db: db, ttl: ttl, initialState: initialState}
+}
+
+func (r *StateRepository) Get(ctx context.Context, userID int64) (*models.UserState, error) {
+	return r.load(ctx, r.db, userID)
+}
+
+// Update changes the state in a transaction, which holds the write lock of
+// the database, so updates of the same user never overwrite each other.
+func (r *StateRepository) Update(ctx context.Context, userID int64, fn func(state *models.UserState)) error {
+	return transact(ctx, r.db, func(tx DBTX) error {
+		state, err := r.load(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		fn(state)
+
+		data, err := json.Marshal(state.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode state: %w", err)
+		}
+
+		query := `
+			INSERT INTO conversation_states (user_id, state, data, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE
+			SET state = excluded.state, data = excluded.data, updated_at = excluded.updated_at`
+
+		if _, err := tx.ExecContext(ctx, query, userID, state.State, string(data), timestamp(time.Now())); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *StateRepository) Delete(ctx context.Context, userID int64) error {
+	query := `DELETE FROM conversation_states WHERE user_id = ?`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete state: %w", err)
+	}
+
+	return nil
+}
+
+func (r *StateRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if r.ttl <= 0 {
+		return 0, nil
+	}
+
+	query := `DELETE FROM conversation_states WHERE updated_at < ?`
+
+	result, err := r.db.ExecContext(ctx, query, timestamp(time.Now().Add(-r.ttl)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired states: %w", err)
+	}
+
+	return rowsAffected(result), nil
+}
+
+func (r *StateRepository) load(ctx context.Context, db DBTX, userID int64) (*models.UserState, error) {
+	query := `SELECT state, data, updated_at FROM conversation_states WHERE user_id = ?`
+
+	var state models.UserState
+	var data string
+	err := db.QueryRowContext(ctx, query, userID).Scan(&state.State, &data, timestampColumn{&state.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NewUserState(r.initialState), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+
+	if state.IsExpired(time.Now(), r.ttl) {
+		return models.NewUserState(r.initialState), nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &state.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return &state, nil
+}
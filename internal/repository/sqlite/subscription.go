@@ -0,0 +1,355 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// subscriptionColumns are read by scanSubscription, in the same order
+const subscriptionColumns = `id, user_id, name, cost, currency, period_unit, period_interval, anchor_day, next_payment,
+		category, auto_renewal, active, trial_ends_at, trial_cost, notes, url, ` + tagsColumn + `,
+		created_at, updated_at`
+
+// tagsColumn reads the tags of a subscription as a JSON array, sorted by name
+const tagsColumn = `(SELECT json_group_array(name) FROM (
+		SELECT t.name FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.subscription_id = subscriptions.id ORDER BY t.name))`
+
+type subscriptions struct {
+	db DBTX
+}
+
+func (r *subscriptions) Create(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	var sub *models.Subscription
+	err := transact(ctx, r.db, func(tx DBTX) error {
+		now := time.Now()
+		query := `
+			INSERT INTO subscriptions (user_id, name, cost, currency, period_unit, period_interval, anchor_day,
+			                           next_payment, category, auto_renewal, trial_ends_at, trial_cost,
+			                           notes, url, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING ` + subscriptionColumns
+
+		var err error
+		sub, err = scanSubscription(tx.QueryRowContext(ctx, query,
+			req.UserID, req.Name, req.Cost, req.Currency, req.Period.Unit, req.Period.Interval, req.Period.AnchorDay,
+			date(req.NextPayment), req.Category, req.AutoRenewal, nullDate(req.TrialEndsAt), req.TrialCost,
+			req.Notes, req.URL, timestamp(now), timestamp(now),
+		))
+		if err != nil {
+			return fmt.Errorf("failed to create subscription: %w", err)
+		}
+
+		if err := recordPrice(ctx, tx, sub.ID, sub.Cost, sub.Currency, sub.CreatedAt); err != nil {
+			return err
+		}
+
+		if len(req.Tags) > 0 {
+			sub.Tags, err = setTags(ctx, tx, sub.UserID, sub.ID, req.Tags)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Restore inserts a subscription from a backup as is, with its state, tags
+// and timestamps, and returns its new ID. The price history is restored separately.
+func (r *subscriptions) Restore(ctx context.Context, sub *models.Subscription) (int, error) {
+	var id int
+	err := transact(ctx, r.db, func(tx DBTX) error {
+		query := `
+			INSERT INTO subscriptions (user_id, name, cost, currency, period_unit, period_interval, anchor_day,
+			                           next_payment, category, auto_renewal, active, trial_ends_at, trial_cost,
+			                           notes, url, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`
+
+		err := tx.QueryRowContext(ctx, query,
+			sub.UserID, sub.Name, sub.Cost, sub.Currency, sub.Period.Unit, sub.Period.Interval, sub.Period.AnchorDay,
+			date(sub.NextPayment), sub.Category, sub.AutoRenewal, sub.Active, nullDate(sub.TrialEndsAt), sub.TrialCost,
+			sub.Notes, sub.URL, timestamp(sub.CreatedAt), timestamp(sub.UpdatedAt),
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to restore subscription: %w", err)
+		}
+
+		if len(sub.Tags) > 0 {
+			_, err = setTags(ctx, tx, sub.UserID, id, sub.Tags)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Count returns the number of subscriptions of the user, cancelled ones included
+func (r *subscriptions) Count(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM subscriptions WHERE user_id = ?`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *subscriptions) GetByID(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE id = ? AND user_id = ?`
+
+	sub, err := scanSubscription(r.db.QueryRowContext(ctx, query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("subscription %w", repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+// GetByIDForUpdate needs no lock of its own, every transaction holds the
+// write lock of the database from its start.
+func (r *subscriptions) GetByIDForUpdate(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	return r.GetByID(ctx, userID, id)
+}
+
+func (r *subscriptions) GetAllActive(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = ? AND active = 1 ORDER BY next_payment ASC`
+
+	return r.query(ctx, "failed to get active subscriptions", query, userID)
+}
+
+// GetAll returns every subscription of the user, cancelled ones included, in the order they were added
+func (r *subscriptions) GetAll(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = ? ORDER BY id ASC`
+
+	return r.query(ctx, "failed to get subscriptions", query, userID)
+}
+
+// Update saves the subscription. A new cost or currency is added to the price
+// history, the tags are only written when they changed.
+func (r *subscriptions) Update(ctx context.Context, sub *models.Subscription) error {
+	return transact(ctx, r.db, func(tx DBTX) error {
+		var oldCost models.Money
+		var oldCurrency models.Currency
+		var oldTags []string
+		err := tx.QueryRowContext(ctx, `SELECT cost, currency, `+tagsColumn+` FROM subscriptions WHERE id = ? AND user_id = ?`,
+			sub.ID, sub.UserID,
+		).Scan(&oldCost, &oldCurrency, tagsScanner{&oldTags})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("subscription %w", repository.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get subscription: %w", err)
+		}
+
+		query := `
+			UPDATE subscriptions
+			SET name = ?, cost = ?, currency = ?, period_unit = ?, period_interval = ?, anchor_day = ?,
+			    next_payment = ?, category = ?, auto_renewal = ?, active = ?,
+			    trial_ends_at = ?, trial_cost = ?, notes = ?, url = ?, updated_at = ?
+			WHERE id = ? AND user_id = ?`
+
+		_, err = tx.ExecContext(ctx, query,
+			sub.Name, sub.Cost, sub.Currency, sub.Period.Unit, sub.Period.Interval, sub.Period.AnchorDay,
+			date(sub.NextPayment), sub.Category, sub.AutoRenewal, sub.Active,
+			nullDate(sub.TrialEndsAt), sub.TrialCost, sub.Notes, sub.URL, timestamp(time.Now()),
+			sub.ID, sub.UserID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		if sub.Cost != oldCost || sub.Currency != oldCurrency {
+			if err := recordPrice(ctx, tx, sub.ID, sub.Cost, sub.Currency, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		if !slices.Equal(sub.Tags, oldTags) {
+			_, err = setTags(ctx, tx, sub.UserID, sub.ID, sub.Tags)
+		}
+		return err
+	})
+}
+
+func (r *subscriptions) Delete(ctx context.Context, userID int64, id int) error {
+	query := `UPDATE subscriptions SET active = 0, updated_at = ? WHERE id = ? AND user_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, timestamp(time.Now()), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return fmt.Errorf("subscription %w", repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *subscriptions) GetByCategory(ctx context.Context, userID int64, category models.Category) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = ? AND category = ? AND active = 1 ORDER BY cost DESC`
+
+	return r.query(ctx, "failed to get subscriptions by category", query, userID, category)
+}
+
+func (r *subscriptions) GetDuePayments(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE user_id = ? AND active = 1 AND next_payment <= ?
+		ORDER BY next_payment ASC`
+
+	return r.query(ctx, "failed to get due payments", query, userID, date(time.Now()))
+}
+
+// GetActiveTrials returns active subscriptions whose trial ends after today, the soonest first
+func (r *subscriptions) GetActiveTrials(ctx context.Context, userID int64, today time.Time) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE user_id = ? AND active = 1 AND trial_ends_at > ?
+		ORDER BY trial_ends_at ASC`
+
+	return r.query(ctx, "failed to get trials", query, userID, date(today))
+}
+
+// GetAllDueBefore returns active subscriptions of every owner with a payment
+// date on or before until. It is meant for background jobs, not for handlers.
+func (r *subscriptions) GetAllDueBefore(ctx context.Context, until time.Time) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE active = 1 AND next_payment <= ?
+		ORDER BY next_payment ASC`
+
+	return r.query(ctx, "failed to get due subscriptions", query, date(until))
+}
+
+func (r *subscriptions) query(ctx context.Context, failure, query string, args ...any) ([]*models.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", failure, err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// setTags replaces the tags of the subscription and returns them sorted like
+// tagsColumn reads them. Tags no subscription has anymore are removed.
+func setTags(ctx context.Context, tx DBTX, userID int64, subscriptionID int, tags []string) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_tags WHERE subscription_id = ?`, subscriptionID); err != nil {
+		return nil, fmt.Errorf("failed to remove tags: %w", err)
+	}
+
+	now := timestamp(time.Now())
+	for _, tag := range tags {
+		createQuery := `
+			INSERT INTO tags (user_id, name, created_at) VALUES (?, ?, ?)
+			ON CONFLICT (user_id, name) DO NOTHING`
+
+		if _, err := tx.ExecContext(ctx, createQuery, userID, tag, now); err != nil {
+			return nil, fmt.Errorf("failed to create tag: %w", err)
+		}
+
+		linkQuery := `
+			INSERT OR IGNORE INTO subscription_tags (subscription_id, tag_id)
+			SELECT ?, id FROM tags WHERE user_id = ? AND name = ?`
+
+		if _, err := tx.ExecContext(ctx, linkQuery, subscriptionID, userID, tag); err != nil {
+			return nil, fmt.Errorf("failed to tag subscription: %w", err)
+		}
+	}
+
+	cleanupQuery := `
+		DELETE FROM tags
+		WHERE user_id = ? AND NOT EXISTS (SELECT 1 FROM subscription_tags st WHERE st.tag_id = tags.id)`
+
+	if _, err := tx.ExecContext(ctx, cleanupQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove unused tags: %w", err)
+	}
+
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return slices.Compact(sorted), nil
+}
+
+// tagsScanner reads tagsColumn into a slice, nil when there are no tags
+type tagsScanner struct {
+	dest *[]string
+}
+
+func (c tagsScanner) Scan(src any) error {
+	var text []byte
+	switch src := src.(type) {
+	case string:
+		text = []byte(src)
+	case []byte:
+		text = src
+	default:
+		return fmt.Errorf("unexpected tags %v", src)
+	}
+
+	var tags []string
+	if err := json.Unmarshal(text, &tags); err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	*c.dest = tags
+	return nil
+}
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row row) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := row.Scan(
+		&sub.ID, &sub.UserID, &sub.Name, &sub.Cost, &sub.Currency, &sub.Period.Unit, &sub.Period.Interval, &sub.Period.AnchorDay,
+		dateColumn{&sub.NextPayment}, &sub.Category, &sub.AutoRenewal, &sub.Active, nullDateColumn{&sub.TrialEndsAt},
+		&sub.TrialCost, &sub.Notes, &sub.URL, tagsScanner{&sub.Tags}, timestampColumn{&sub.CreatedAt}, timestampColumn{&sub.UpdatedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func scanSubscriptions(rows *sql.Rows) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	return subscriptions, rows.Err()
+}
@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timestamps are stored as microseconds since the Unix epoch, the precision
+// PostgreSQL keeps, so they compare as numbers. Dates are stored as
+// YYYY-MM-DD text and read back as midnight UTC, like DATE columns of pgx.
+
+func timestamp(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func nullTimestamp(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+func date(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+func nullDate(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return date(*t)
+}
+
+// timestampColumn reads a timestamp column into a time
+type timestampColumn struct {
+	dest *time.Time
+}
+
+func (c timestampColumn) Scan(src any) error {
+	micros, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("unexpected timestamp %v", src)
+	}
+	*c.dest = time.UnixMicro(micros)
+	return nil
+}
+
+// nullTimestampColumn reads a nullable timestamp column into a time pointer
+type nullTimestampColumn struct {
+	dest **time.Time
+}
+
+func (c nullTimestampColumn) Scan(src any) error {
+	if src == nil {
+		*c.dest = nil
+		return nil
+	}
+	var t time.Time
+	if err := (timestampColumn{dest: &t}).Scan(src); err != nil {
+		return err
+	}
+	*c.dest = &t
+	return nil
+}
+
+// dateColumn reads a date column into a time
+type dateColumn struct {
+	dest *time.Time
+}
+
+func (c dateColumn) Scan(src any) error {
+	var text string
+	switch src := src.(type) {
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return fmt.Errorf("unexpected date %v", src)
+	}
+
+	t, err := time.Parse(time.DateOnly, text)
+	if err != nil {
+		return err
+	}
+	*c.dest = t
+	return nil
+}
+
+// nullDateColumn reads a nullable date column into a time pointer
+type nullDateColumn struct {
+	dest **time.Time
+}
+
+func (c nullDateColumn) Scan(src any) error {
+	if src == nil {
+		*c.dest = nil
+		return nil
+	}
+	var t time.Time
+	if err := (dateColumn{dest: &t}).Scan(src); err != nil {
+		return err
+	}
+	*c.dest = &t
+	return nil
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+)
+
+type settings struct {
+	db DBTX
+}
+
+// GetBaseCurrency returns the currency the user wants totals in.
+// The second result is false if the user has not chosen one.
+func (r *settings) GetBaseCurrency(ctx context.Context, userID int64) (models.Currency, bool, error) {
+	query := `SELECT base_currency FROM user_settings WHERE user_id = ?`
+
+	var currency models.Currency
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get base currency: %w", err)
+	}
+
+	return currency, true, nil
+}
+
+func (r *settings) SetBaseCurrency(ctx context.Context, userID int64, currency models.Currency) error {
+	query := `
+		INSERT INTO user_settings (user_id, base_currency, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET base_currency = excluded.base_currency, updated_at = excluded.updated_at`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, currency, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to set base currency: %w", err)
+	}
+
+	return nil
+}
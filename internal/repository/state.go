@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StateRepository stores conversation states in PostgreSQL so that
+// unfinished dialogs survive restarts. It implements bot.StateStore.
+type StateRepository struct {
+	db           *pgxpool.Pool
+	ttl          time.Duration
+	initialState string
+}
+
+func NewStateRepository(db *pgxpool.Pool, ttl time.Duration, initialState string) *StateRepository {
+	return &StateRepository{db: db, ttl: ttl, initialState: initialState}
+}
+
+func (r *StateRepository) Get(ctx context.Context, userID int64) (*models.UserState, error) {
+	return r.load(ctx, r.db, userID)
+}
+
+func (r *StateRepository) Update(ctx context.Context, userID int64, fn func(state *models.UserState)) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin state transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize updates of the same user, even when no row exists yet
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, userID); err != nil {
+		return fmt.Errorf("failed to lock state: %w", err)
+	}
+
+	state, err := r.load(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	fn(state)
+
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	query := `
+		INSERT INTO conversation_states (user_id, state, data, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET state = EXCLUDED.state, data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`
+
+	if _, err := tx.Exec(ctx, query, userID, state.State, data); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *StateRepository) Delete(ctx context.Context, userID int64) error {
+	query := `DELETE FROM conversation_states WHERE user_id = $1`
+
+	if _, err := r.db.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete state: %w", err)
+	}
+
+	return nil
+}
+
+func (r *StateRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if r.ttl <= 0 {
+		return 0, nil
+	}
+
+	query := `DELETE FROM conversation_states WHERE updated_at < $1`
+
+	tag, err := r.db.Exec(ctx, query, time.Now().Add(-r.ttl))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired states: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (r *StateRepository) load(ctx context.Context, q querier, userID int64) (*models.UserState, error) {
+	query := `SELECT state, data, updated_at FROM conversation_states WHERE user_id = $1`
+
+	var state models.UserState
+	var data []byte
+	err := q.QueryRow(ctx, query, userID).Scan(&state.State, &data, &state.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.NewUserState(r.initialState), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+
+	if state.IsExpired(time.Now(), r.ttl) {
+		return models.NewUserState(r.initialState), nil
+	}
+
+	if err := json.Unmarshal(data, &state.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return &state, nil
+}
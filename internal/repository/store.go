@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The interfaces below are what services need from a storage backend. They
+// are implemented by the PostgreSQL repositories of this package and by the
+// in-memory ones of package memory, repotest checks that both behave alike.
+
+type SubscriptionStore interface {
+	Create(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error)
+	Restore(ctx context.Context, sub *models.Subscription) (int, error)
+	Count(ctx context.Context, userID int64) (int, error)
+	GetByID(ctx context.Context, userID int64, id int) (*models.Subscription, error)
+	GetByIDForUpdate(ctx context.Context, userID int64, id int) (*models.Subscription, error)
+	GetAllActive(ctx context.Context, userID int64) ([]*models.Subscription, error)
+	GetAll(ctx context.Context, userID int64) ([]*models.Subscription, error)
+	Update(ctx context.Context, sub *models.Subscription) error
+	Delete(ctx context.Context, userID int64, id int) error
+	GetByCategory(ctx context.Context, userID int64, category models.Category) ([]*models.Subscription, error)
+	GetDuePayments(ctx context.Context, userID int64) ([]*models.Subscription, error)
+	GetActiveTrials(ctx context.Context, userID int64, today time.Time) ([]*models.Subscription, error)
+	GetAllDueBefore(ctx context.Context, until time.Time) ([]*models.Subscription, error)
+}
+
+type PaymentStore interface {
+	Create(ctx context.Context, req *models.CreatePaymentRequest) (*models.Payment, error)
+	Restore(ctx context.Context, payment *models.Payment) (int, error)
+	GetByID(ctx context.Context, userID int64, id int) (*models.Payment, error)
+	Update(ctx context.Context, payment *models.Payment) error
+	GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.Payment, error)
+	GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error)
+	GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error)
+	GetAllPayments(ctx context.Context, userID int64, limit int) ([]*models.Payment, error)
+	GetPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error)
+	GetAll(ctx context.Context, userID int64, filter models.PaymentFilter) ([]*models.Payment, error)
+}
+
+type PriceHistoryStore interface {
+	Restore(ctx context.Context, change *models.PriceChange) error
+	GetBySubscriptionID(ctx context.Context, userID int64, subscriptionID int) ([]*models.PriceChange, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.PriceChange, error)
+}
+
+type SettingsStore interface {
+	GetBaseCurrency(ctx context.Context, userID int64) (models.Currency, bool, error)
+	SetBaseCurrency(ctx context.Context, userID int64, currency models.Currency) error
+}
+
+type CategoryStore interface {
+	Create(ctx context.Context, req *models.CreateCategoryRequest) (*models.UserCategory, error)
+	Restore(ctx context.Context, category *models.UserCategory) error
+	GetAll(ctx context.Context, userID int64) ([]*models.UserCategory, error)
+	Update(ctx context.Context, category *models.UserCategory) error
+}
+
+type BudgetStore interface {
+	Set(ctx context.Context, budget *models.Budget) error
+	GetAll(ctx context.Context, userID int64) ([]*models.Budget, error)
+	Delete(ctx context.Context, userID int64, category models.Category, currency models.Currency) error
+}
+
+type ExchangeRateStore interface {
+	Save(ctx context.Context, rate *models.ExchangeRate) error
+	GetAll(ctx context.Context) ([]*models.ExchangeRate, error)
+}
+
+type ReminderStore interface {
+	Reserve(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) (bool, error)
+	Release(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error
+	Snooze(ctx context.Context, userID int64, subscriptionID int, dueDate, until time.Time) error
+	TakeSnoozed(ctx context.Context, now time.Time) ([]*models.Subscription, error)
+}
+
+// Stores are the repositories that can take part in a transaction
+type Stores struct {
+	Subscriptions SubscriptionStore
+	Payments      PaymentStore
+	PriceHistory  PriceHistoryStore
+	Settings      SettingsStore
+	Categories    CategoryStore
+	Budgets       BudgetStore
+}
+
+// Transactor runs fn in a transaction. It is committed when fn succeeds and
+// rolled back otherwise, the stores passed to fn run their queries in it.
+type Transactor interface {
+	Do(ctx context.Context, fn func(tx Stores) error) error
+}
+
+// Storage is a complete storage backend
+type Storage struct {
+	Stores
+	ExchangeRates ExchangeRateStore
+	Reminders     ReminderStore
+	UnitOfWork    Transactor
+}
+
+// NewStorage returns the PostgreSQL repositories sharing the pool
+func NewStorage(db *pgxpool.Pool) *Storage {
+	return &Storage{
+		Stores: Stores{
+			Subscriptions: NewSubscriptionRepository(db),
+			Payments:      NewPaymentRepository(db),
+			PriceHistory:  NewPriceHistoryRepository(db),
+			Settings:      NewUserSettingsRepository(db),
+			Categories:    NewCategoryRepository(db),
+			Budgets:       NewBudgetRepository(db),
+		},
+		ExchangeRates: NewExchangeRateRepository(db),
+		Reminders:     NewReminderRepository(db),
+		UnitOfWork:    NewUnitOfWork(db),
+	}
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/repotest"
+	"sub-cos-counter/internal/testdb"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repository.Storage {
+		return repository.NewStorage(testdb.New(t))
+	})
+}
@@ -2,15 +2,25 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sub-cos-counter/internal/models"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// subscriptionColumns are read by scanSubscription, in the same order
+const subscriptionColumns = `id, user_id, name, cost, currency, period_unit, period_interval, anchor_day, next_payment,
+		category, auto_renewal, active, trial_ends_at, trial_cost, notes, url,
+		ARRAY(SELECT t.name FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+		      WHERE st.subscription_id = subscriptions.id ORDER BY t.name),
+		created_at, updated_at`
+
 type SubscriptionRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
 func NewSubscriptionRepository(db *pgxpool.Pool) *SubscriptionRepository {
@@ -18,153 +28,360 @@ func NewSubscriptionRepository(db *pgxpool.Pool) *SubscriptionRepository {
 }
 
 func (r *SubscriptionRepository) Create(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
-	query := `
-		INSERT INTO subscriptions (name, cost, currency, period_days, next_payment, category, auto_renewal)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, name, cost, currency, period_days, next_payment, category, auto_renewal, active, created_at, updated_at`
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	var sub models.Subscription
-	err := r.db.QueryRow(ctx, query,
-		req.Name, req.Cost, req.Currency, req.PeriodDays, req.NextPayment, req.Category, req.AutoRenewal,
-	).Scan(
-		&sub.ID, &sub.Name, &sub.Cost, &sub.Currency, &sub.PeriodDays,
-		&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
-	)
+	query := `
+		INSERT INTO subscriptions (user_id, name, cost, currency, period_unit, period_interval, anchor_day,
+		                           next_payment, category, auto_renewal, trial_ends_at, trial_cost, notes, url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING ` + subscriptionColumns
 
+	sub, err := scanSubscription(tx.QueryRow(ctx, query,
+		req.UserID, req.Name, req.Cost, req.Currency, req.Period.Unit, req.Period.Interval, req.Period.AnchorDay,
+		req.NextPayment, req.Category, req.AutoRenewal, req.TrialEndsAt, req.TrialCost, req.Notes, req.URL,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subscription: %w", err)
 	}
 
-	return &sub, nil
+	if err := recordPrice(ctx, tx, sub.ID, sub.Cost, sub.Currency, sub.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if len(req.Tags) > 0 {
+		if sub.Tags, err = setTags(ctx, tx, sub.UserID, sub.ID, req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit subscription: %w", err)
+	}
+
+	return sub, nil
 }
 
-func (r *SubscriptionRepository) GetByID(ctx context.Context, id int) (*models.Subscription, error) {
+// Restore inserts a subscription from a backup as is, with its state, tags
+// and timestamps, and returns its new ID. The price history is restored separately.
+func (r *SubscriptionRepository) Restore(ctx context.Context, sub *models.Subscription) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		SELECT id, name, cost, currency, period_days, next_payment, category, auto_renewal, active, created_at, updated_at
-		FROM subscriptions WHERE id = $1`
+		INSERT INTO subscriptions (user_id, name, cost, currency, period_unit, period_interval, anchor_day,
+		                           next_payment, category, auto_renewal, active, trial_ends_at, trial_cost,
+		                           notes, url, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id`
 
-	var sub models.Subscription
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&sub.ID, &sub.Name, &sub.Cost, &sub.Currency, &sub.PeriodDays,
-		&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
-	)
+	var id int
+	err = tx.QueryRow(ctx, query,
+		sub.UserID, sub.Name, sub.Cost, sub.Currency, sub.Period.Unit, sub.Period.Interval, sub.Period.AnchorDay,
+		sub.NextPayment, sub.Category, sub.AutoRenewal, sub.Active, sub.TrialEndsAt, sub.TrialCost,
+		sub.Notes, sub.URL, sub.CreatedAt, sub.UpdatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore subscription: %w", err)
+	}
 
+	if len(sub.Tags) > 0 {
+		if _, err := setTags(ctx, tx, sub.UserID, id, sub.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit subscription: %w", err)
+	}
+
+	return id, nil
+}
+
+// Count returns the number of subscriptions of the user, cancelled ones included
+func (r *SubscriptionRepository) Count(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM subscriptions WHERE user_id = $1`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *SubscriptionRepository) GetByID(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE id = $1 AND user_id = $2`
+
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("subscription %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	return &sub, nil
+	return sub, nil
+}
+
+// GetByIDForUpdate reads the subscription and locks it until the end of the
+// transaction, so concurrent payments of one subscription run one after another.
+// It must be called on the Stores passed by UnitOfWork.Do, outside a
+// transaction the lock ends with the query.
+func (r *SubscriptionRepository) GetByIDForUpdate(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE id = $1 AND user_id = $2 FOR UPDATE`
+
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("subscription %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	return sub, nil
 }
 
-func (r *SubscriptionRepository) GetAllActive(ctx context.Context) ([]*models.Subscription, error) {
+func (r *SubscriptionRepository) GetAllActive(ctx context.Context, userID int64) ([]*models.Subscription, error) {
 	query := `
-		SELECT id, name, cost, currency, period_days, next_payment, category, auto_renewal, active, created_at, updated_at
-		FROM subscriptions WHERE active = true ORDER BY next_payment ASC`
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = $1 AND active = true ORDER BY next_payment ASC`
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active subscriptions: %w", err)
 	}
 	defer rows.Close()
 
-	var subscriptions []*models.Subscription
-	for rows.Next() {
-		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID, &sub.Name, &sub.Cost, &sub.Currency, &sub.PeriodDays,
-			&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan subscription: %w", err)
-		}
-		subscriptions = append(subscriptions, &sub)
+	return scanSubscriptions(rows)
+}
+
+// GetAll returns every subscription of the user, cancelled ones included, in the order they were added
+func (r *SubscriptionRepository) GetAll(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = $1 ORDER BY id ASC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
 	}
+	defer rows.Close()
 
-	return subscriptions, nil
+	return scanSubscriptions(rows)
 }
 
+// Update saves the subscription. A new cost or currency is added to the price
+// history, the tags are only written when they changed.
 func (r *SubscriptionRepository) Update(ctx context.Context, sub *models.Subscription) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var oldCost models.Money
+	var oldCurrency models.Currency
+	var oldTags []string
+	err = tx.QueryRow(ctx, `
+		SELECT cost, currency, ARRAY(SELECT t.name FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+		                             WHERE st.subscription_id = subscriptions.id ORDER BY t.name)
+		FROM subscriptions WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		sub.ID, sub.UserID,
+	).Scan(&oldCost, &oldCurrency, &oldTags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("subscription %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
 	query := `
-		UPDATE subscriptions 
-		SET name = $2, cost = $3, currency = $4, period_days = $5, next_payment = $6, 
-		    category = $7, auto_renewal = $8, active = $9, updated_at = NOW()
-		WHERE id = $1`
-
-	_, err := r.db.Exec(ctx, query,
-		sub.ID, sub.Name, sub.Cost, sub.Currency, sub.PeriodDays,
-		sub.NextPayment, sub.Category, sub.AutoRenewal, sub.Active,
+		UPDATE subscriptions
+		SET name = $3, cost = $4, currency = $5, period_unit = $6, period_interval = $7, anchor_day = $8,
+		    next_payment = $9, category = $10, auto_renewal = $11, active = $12,
+		    trial_ends_at = $13, trial_cost = $14, notes = $15, url = $16, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2`
+
+	_, err = tx.Exec(ctx, query,
+		sub.ID, sub.UserID, sub.Name, sub.Cost, sub.Currency, sub.Period.Unit, sub.Period.Interval, sub.Period.AnchorDay,
+		sub.NextPayment, sub.Category, sub.AutoRenewal, sub.Active, sub.TrialEndsAt, sub.TrialCost, sub.Notes, sub.URL,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to update subscription: %w", err)
 	}
 
+	if sub.Cost != oldCost || sub.Currency != oldCurrency {
+		if err := recordPrice(ctx, tx, sub.ID, sub.Cost, sub.Currency, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if !slices.Equal(sub.Tags, oldTags) {
+		if _, err := setTags(ctx, tx, sub.UserID, sub.ID, sub.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit subscription: %w", err)
+	}
+
 	return nil
 }
 
-func (r *SubscriptionRepository) Delete(ctx context.Context, id int) error {
-	query := `UPDATE subscriptions SET active = false, updated_at = NOW() WHERE id = $1`
+func (r *SubscriptionRepository) Delete(ctx context.Context, userID int64, id int) error {
+	query := `UPDATE subscriptions SET active = false, updated_at = NOW() WHERE id = $1 AND user_id = $2`
 
-	_, err := r.db.Exec(ctx, query, id)
+	tag, err := r.db.Exec(ctx, query, id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("subscription %w", ErrNotFound)
+	}
 
 	return nil
 }
 
-func (r *SubscriptionRepository) GetByCategory(ctx context.Context, category models.Category) ([]*models.Subscription, error) {
+func (r *SubscriptionRepository) GetByCategory(ctx context.Context, userID int64, category models.Category) ([]*models.Subscription, error) {
 	query := `
-		SELECT id, name, cost, currency, period_days, next_payment, category, auto_renewal, active, created_at, updated_at
-		FROM subscriptions WHERE category = $1 AND active = true ORDER BY cost DESC`
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions WHERE user_id = $1 AND category = $2 AND active = true ORDER BY cost DESC`
 
-	rows, err := r.db.Query(ctx, query, category)
+	rows, err := r.db.Query(ctx, query, userID, category)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subscriptions by category: %w", err)
 	}
 	defer rows.Close()
 
-	var subscriptions []*models.Subscription
-	for rows.Next() {
-		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID, &sub.Name, &sub.Cost, &sub.Currency, &sub.PeriodDays,
-			&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan subscription: %w", err)
-		}
-		subscriptions = append(subscriptions, &sub)
+	return scanSubscriptions(rows)
+}
+
+func (r *SubscriptionRepository) GetDuePayments(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE user_id = $1 AND active = true AND next_payment <= $2
+		ORDER BY next_payment ASC`
+
+	rows, err := r.db.Query(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due payments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// GetActiveTrials returns active subscriptions whose trial ends after today, the soonest first
+func (r *SubscriptionRepository) GetActiveTrials(ctx context.Context, userID int64, today time.Time) ([]*models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE user_id = $1 AND active = true AND trial_ends_at > $2
+		ORDER BY trial_ends_at ASC`
+
+	rows, err := r.db.Query(ctx, query, userID, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trials: %w", err)
 	}
+	defer rows.Close()
 
-	return subscriptions, nil
+	return scanSubscriptions(rows)
 }
 
-func (r *SubscriptionRepository) GetDuePayments(ctx context.Context) ([]*models.Subscription, error) {
+// GetAllDueBefore returns active subscriptions of every owner with a payment
+// date on or before until. It is meant for background jobs, not for handlers.
+func (r *SubscriptionRepository) GetAllDueBefore(ctx context.Context, until time.Time) ([]*models.Subscription, error) {
 	query := `
-		SELECT id, name, cost, currency, period_days, next_payment, category, auto_renewal, active, created_at, updated_at
-		FROM subscriptions 
-		WHERE active = true AND next_payment <= $1 
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE active = true AND next_payment <= $1
 		ORDER BY next_payment ASC`
 
-	rows, err := r.db.Query(ctx, query, time.Now())
+	rows, err := r.db.Query(ctx, query, until)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get due payments: %w", err)
+		return nil, fmt.Errorf("failed to get due subscriptions: %w", err)
 	}
 	defer rows.Close()
 
+	return scanSubscriptions(rows)
+}
+
+// setTags replaces the tags of the subscription and returns them sorted like
+// subscriptionColumns reads them. Tags no subscription has anymore are removed.
+func setTags(ctx context.Context, tx pgx.Tx, userID int64, subscriptionID int, tags []string) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM subscription_tags WHERE subscription_id = $1`, subscriptionID); err != nil {
+		return nil, fmt.Errorf("failed to remove tags: %w", err)
+	}
+
+	if len(tags) > 0 {
+		createQuery := `
+			INSERT INTO tags (user_id, name) SELECT $1, unnest($2::varchar[])
+			ON CONFLICT (user_id, name) DO NOTHING`
+
+		if _, err := tx.Exec(ctx, createQuery, userID, tags); err != nil {
+			return nil, fmt.Errorf("failed to create tags: %w", err)
+		}
+
+		linkQuery := `
+			INSERT INTO subscription_tags (subscription_id, tag_id)
+			SELECT $1, id FROM tags WHERE user_id = $2 AND name = ANY($3)`
+
+		if _, err := tx.Exec(ctx, linkQuery, subscriptionID, userID, tags); err != nil {
+			return nil, fmt.Errorf("failed to tag subscription: %w", err)
+		}
+	}
+
+	cleanupQuery := `
+		DELETE FROM tags t
+		WHERE t.user_id = $1 AND NOT EXISTS (SELECT 1 FROM subscription_tags st WHERE st.tag_id = t.id)`
+
+	if _, err := tx.Exec(ctx, cleanupQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove unused tags: %w", err)
+	}
+
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return slices.Compact(sorted), nil
+}
+
+func scanSubscription(row pgx.Row) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := row.Scan(
+		&sub.ID, &sub.UserID, &sub.Name, &sub.Cost, &sub.Currency, &sub.Period.Unit, &sub.Period.Interval, &sub.Period.AnchorDay,
+		&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.TrialEndsAt, &sub.TrialCost,
+		&sub.Notes, &sub.URL, &sub.Tags, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(sub.Tags) == 0 {
+		sub.Tags = nil
+	}
+	return &sub, nil
+}
+
+func scanSubscriptions(rows pgx.Rows) ([]*models.Subscription, error) {
 	var subscriptions []*models.Subscription
 	for rows.Next() {
-		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID, &sub.Name, &sub.Cost, &sub.Currency, &sub.PeriodDays,
-			&sub.NextPayment, &sub.Category, &sub.AutoRenewal, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
-		)
+		sub, err := scanSubscription(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan subscription: %w", err)
 		}
-		subscriptions = append(subscriptions, &sub)
+		subscriptions = append(subscriptions, sub)
 	}
 
-	return subscriptions, nil
+	return subscriptions, rows.Err()
 }
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when a record doesn't exist or belongs to another user
+var ErrNotFound = errors.New("not found")
+
+// DBTX is implemented by both *pgxpool.Pool and pgx.Tx, so repositories run
+// the same queries on their own or as a part of a unit of work. Begin on a
+// transaction starts a savepoint.
+type DBTX interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// UnitOfWork runs changes of several repositories in one transaction.
+type UnitOfWork struct {
+	db *pgxpool.Pool
+}
+
+func NewUnitOfWork(db *pgxpool.Pool) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Do commits the transaction when fn succeeds and rolls it back otherwise.
+// The repositories passed to fn run their queries in the transaction.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(tx Stores) error) error {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	stores := Stores{
+		Subscriptions: &SubscriptionRepository{db: tx},
+		Payments:      &PaymentRepository{db: tx},
+		PriceHistory:  &PriceHistoryRepository{db: tx},
+		Settings:      &UserSettingsRepository{db: tx},
+		Categories:    &CategoryRepository{db: tx},
+		Budgets:       &BudgetRepository{db: tx},
+	}
+	if err := fn(stores); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserSettingsRepository struct {
+	db DBTX
+}
+
+func NewUserSettingsRepository(db *pgxpool.Pool) *UserSettingsRepository {
+	return &UserSettingsRepository{db: db}
+}
+
+// GetBaseCurrency returns the currency the user wants totals in.
+// The second result is false if the user has not chosen one.
+func (r *UserSettingsRepository) GetBaseCurrency(ctx context.Context, userID int64) (models.Currency, bool, error) {
+	query := `SELECT base_currency FROM user_settings WHERE user_id = $1`
+
+	var currency models.Currency
+	err := r.db.QueryRow(ctx, query, userID).Scan(&currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get base currency: %w", err)
+	}
+
+	return currency, true, nil
+}
+
+func (r *UserSettingsRepository) SetBaseCurrency(ctx context.Context, userID int64, currency models.Currency) error {
+	query := `
+		INSERT INTO user_settings (user_id, base_currency, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET base_currency = EXCLUDED.base_currency, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.Exec(ctx, query, userID, currency); err != nil {
+		return fmt.Errorf("failed to set base currency: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work.
+type Job func(ctx context.Context) error
+
+type task struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// Scheduler runs registered jobs periodically until it is stopped.
+// Every job runs once right after Start and then on its own interval;
+// runs of the same job never overlap.
+type Scheduler struct {
+	tasks  []task
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(name string, interval time.Duration, job Job) {
+	s.tasks = append(s.tasks, task{name: name, interval: interval, job: job})
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, t := range s.tasks {
+		s.wg.Add(1)
+		go s.run(ctx, t)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context, t task) {
+	defer s.wg.Done()
+
+	log.Printf("Scheduler: %s every %s", t.name, t.interval)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.job(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Scheduler: %s failed: %v", t.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
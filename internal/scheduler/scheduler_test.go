@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsJobsPeriodically(t *testing.T) {
+	var runs atomic.Int32
+
+	s := New()
+	s.Add("counter", 10*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.Start(context.Background())
+
+	time.Sleep(55 * time.Millisecond)
+	s.Stop()
+
+	got := runs.Load()
+	if got < 3 {
+		t.Errorf("Expected job to run at least 3 times, got %d", got)
+	}
+
+	// No runs after Stop
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != got {
+		t.Errorf("Job kept running after Stop")
+	}
+}
+
+func TestSchedulerSurvivesFailingJob(t *testing.T) {
+	var runs atomic.Int32
+
+	s := New()
+	s.Add("failing", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return errors.New("boom")
+	})
+	s.Start(context.Background())
+
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+
+	if runs.Load() < 2 {
+		t.Errorf("Expected failing job to be retried, got %d runs", runs.Load())
+	}
+}
+
+func TestSchedulerStopCancelsContext(t *testing.T) {
+	cancelled := make(chan struct{})
+
+	s := New()
+	s.Add("blocking", time.Hour, func(ctx context.Context) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+	s.Start(context.Background())
+	s.Stop()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Job context was not cancelled by Stop")
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	New().Stop()
+}
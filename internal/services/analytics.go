@@ -7,45 +7,122 @@ import (
 	"time"
 )
 
+const maxPaymentPageSize = 100
+
 type AnalyticsService struct {
-	paymentRepo      *repository.PaymentRepository
-	subscriptionRepo *repository.SubscriptionRepository
+	paymentRepo      repository.PaymentStore
+	subscriptionRepo repository.SubscriptionStore
+	priceHistoryRepo repository.PriceHistoryStore
+	exchangeService  *ExchangeService
 }
 
-func NewAnalyticsService(paymentRepo *repository.PaymentRepository, subscriptionRepo *repository.SubscriptionRepository) *AnalyticsService {
+func NewAnalyticsService(paymentRepo repository.PaymentStore, subscriptionRepo repository.SubscriptionStore, priceHistoryRepo repository.PriceHistoryStore, exchangeService *ExchangeService) *AnalyticsService {
 	return &AnalyticsService{
 		paymentRepo:      paymentRepo,
 		subscriptionRepo: subscriptionRepo,
+		priceHistoryRepo: priceHistoryRepo,
+		exchangeService:  exchangeService,
 	}
 }
 
-func (s *AnalyticsService) GetMonthlyExpense(ctx context.Context, month time.Time) ([]models.PaymentSummary, error) {
-	return s.paymentRepo.GetMonthlyExpense(ctx, month)
+func (s *AnalyticsService) GetMonthlyExpense(ctx context.Context, userID int64, month time.Time) ([]models.PaymentSummary, error) {
+	return s.paymentRepo.GetMonthlyExpense(ctx, userID, month)
 }
 
-func (s *AnalyticsService) GetCurrentMonthExpense(ctx context.Context) ([]models.PaymentSummary, error) {
+func (s *AnalyticsService) GetCurrentMonthExpense(ctx context.Context, userID int64) ([]models.PaymentSummary, error) {
 	now := time.Now()
-	return s.GetMonthlyExpense(ctx, now)
+	return s.GetMonthlyExpense(ctx, userID, now)
+}
+
+func (s *AnalyticsService) GetCategoryAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
+	return s.paymentRepo.GetCategoryAnalytics(ctx, userID, startDate, endDate)
 }
 
-func (s *AnalyticsService) GetCategoryAnalytics(ctx context.Context, startDate, endDate time.Time) (map[models.Category][]models.PaymentSummary, error) {
-	return s.paymentRepo.GetCategoryAnalytics(ctx, startDate, endDate)
+func (s *AnalyticsService) GetCurrentMonthCategoryAnalytics(ctx context.Context, userID int64) (map[models.Category][]models.PaymentSummary, error) {
+	startOfMonth, endOfMonth := currentMonth()
+	return s.GetCategoryAnalytics(ctx, userID, startOfMonth, endOfMonth)
 }
 
-func (s *AnalyticsService) GetCurrentMonthCategoryAnalytics(ctx context.Context) (map[models.Category][]models.PaymentSummary, error) {
+// GetCurrentMonthTagAnalytics is GetCurrentMonthCategoryAnalytics of the subscriptions with the tag
+func (s *AnalyticsService) GetCurrentMonthTagAnalytics(ctx context.Context, userID int64, tag string) (map[models.Category][]models.PaymentSummary, error) {
+	startOfMonth, endOfMonth := currentMonth()
+	payments, err := s.paymentRepo.GetAll(ctx, userID, models.PaymentFilter{
+		Tag:    tag,
+		Status: models.PaymentStatusCompleted,
+		From:   startOfMonth,
+		To:     endOfMonth,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	categories := make(map[int]models.Category, len(subscriptions))
+	for _, sub := range subscriptions {
+		categories[sub.ID] = sub.Category
+	}
+
+	analytics := make(map[models.Category][]models.PaymentSummary)
+	for _, payment := range payments {
+		category := categories[payment.SubscriptionID]
+		analytics[category] = addToSummary(analytics[category], payment)
+	}
+
+	return analytics, nil
+}
+
+// currentMonth returns the first and the last moment of this month
+func currentMonth() (time.Time, time.Time) {
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	endOfMonth := startOfMonth.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	return startOfMonth, endOfMonth
+}
 
-	return s.GetCategoryAnalytics(ctx, startOfMonth, endOfMonth)
+func addToSummary(summaries []models.PaymentSummary, payment *models.Payment) []models.PaymentSummary {
+	for i := range summaries {
+		if summaries[i].Currency == payment.Currency {
+			summaries[i].TotalAmount = summaries[i].TotalAmount.Add(payment.Amount)
+			summaries[i].Count++
+			return summaries
+		}
+	}
+	return append(summaries, models.PaymentSummary{Currency: payment.Currency, TotalAmount: payment.Amount, Count: 1})
 }
 
-func (s *AnalyticsService) GetPaymentHistory(ctx context.Context, limit int) ([]*models.Payment, error) {
-	return s.paymentRepo.GetAllPayments(ctx, limit)
+func (s *AnalyticsService) GetPaymentHistory(ctx context.Context, userID int64, limit int) ([]*models.Payment, error) {
+	return s.paymentRepo.GetAllPayments(ctx, userID, limit)
 }
 
-func (s *AnalyticsService) GetUpcomingPayments(ctx context.Context, days int) ([]*models.Subscription, error) {
-	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx)
+// GetPaymentPage returns a page of the payment history, see models.PaymentPageRequest
+func (s *AnalyticsService) GetPaymentPage(ctx context.Context, userID int64, req models.PaymentPageRequest) (*models.PaymentPage, error) {
+	if req.Limit <= 0 || req.Limit > maxPaymentPageSize {
+		return nil, invalidf("page size must be between 1 and %d", maxPaymentPageSize)
+	}
+	filter := req.Filter
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, invalidf("date range ends before it starts")
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, invalidf("unknown payment status: %s", filter.Status)
+	}
+
+	return s.paymentRepo.GetPage(ctx, userID, req)
+}
+
+// GetPayments returns every payment matching the filter, oldest first
+func (s *AnalyticsService) GetPayments(ctx context.Context, userID int64, filter models.PaymentFilter) ([]*models.Payment, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, invalidf("date range ends before it starts")
+	}
+	return s.paymentRepo.GetAll(ctx, userID, filter)
+}
+
+func (s *AnalyticsService) GetUpcomingPayments(ctx context.Context, userID int64, days int) ([]*models.Subscription, error) {
+	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -62,8 +139,8 @@ func (s *AnalyticsService) GetUpcomingPayments(ctx context.Context, days int) ([
 	return upcoming, nil
 }
 
-func (s *AnalyticsService) GetMonthlyRecurringCost(ctx context.Context) (map[models.Currency]models.Money, error) {
-	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx)
+func (s *AnalyticsService) GetMonthlyRecurringCost(ctx context.Context, userID int64) (map[models.Currency]models.Money, error) {
+	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +149,7 @@ func (s *AnalyticsService) GetMonthlyRecurringCost(ctx context.Context) (map[mod
 
 	for _, sub := range subscriptions {
 		// Convert to monthly cost based on period
-		monthlyCost := s.calculateMonthlyCost(sub.Cost, sub.PeriodDays)
+		monthlyCost := sub.Period.MonthlyCost(sub.Cost)
 		if existing, exists := monthlyCosts[sub.Currency]; exists {
 			monthlyCosts[sub.Currency] = existing.Add(monthlyCost)
 		} else {
@@ -83,11 +160,84 @@ func (s *AnalyticsService) GetMonthlyRecurringCost(ctx context.Context) (map[mod
 	return monthlyCosts, nil
 }
 
-func (s *AnalyticsService) calculateMonthlyCost(cost models.Money, periodDays int) models.Money {
-	// Average days in a month is 30.44
-	// To avoid float calculations, multiply by 3044 and divide by 100 * periodDays
-	totalCents := cost.Cents()
-	monthlyCents := (totalCents * 3044) / (100 * periodDays)
+// GetMonthlyRecurringTotal returns the monthly cost of all subscriptions in the user's base currency
+func (s *AnalyticsService) GetMonthlyRecurringTotal(ctx context.Context, userID int64) (models.ConvertedTotal, error) {
+	monthlyCosts, err := s.GetMonthlyRecurringCost(ctx, userID)
+	if err != nil {
+		return models.ConvertedTotal{}, err
+	}
+
+	return s.exchangeService.ConvertTotal(ctx, userID, monthlyCosts)
+}
+
+// GetCurrentMonthExpenseTotal returns this month's payments in the user's base currency
+func (s *AnalyticsService) GetCurrentMonthExpenseTotal(ctx context.Context, userID int64) (models.ConvertedTotal, error) {
+	expenses, err := s.GetCurrentMonthExpense(ctx, userID)
+	if err != nil {
+		return models.ConvertedTotal{}, err
+	}
+
+	return s.exchangeService.ConvertTotal(ctx, userID, summaryAmounts(expenses))
+}
+
+// GetCurrentMonthCategoryTotals returns this month's payments per category in the user's base currency
+func (s *AnalyticsService) GetCurrentMonthCategoryTotals(ctx context.Context, userID int64) (map[models.Category]models.ConvertedTotal, error) {
+	analytics, err := s.GetCurrentMonthCategoryAnalytics(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ConvertCategoryAnalytics(ctx, userID, analytics)
+}
+
+// ConvertCategoryAnalytics returns the payments of each category in the user's base currency
+func (s *AnalyticsService) ConvertCategoryAnalytics(ctx context.Context, userID int64, analytics map[models.Category][]models.PaymentSummary) (map[models.Category]models.ConvertedTotal, error) {
+	totals := make(map[models.Category]models.ConvertedTotal, len(analytics))
+	for category, summaries := range analytics {
+		total, err := s.exchangeService.ConvertTotal(ctx, userID, summaryAmounts(summaries))
+		if err != nil {
+			return nil, err
+		}
+		totals[category] = total
+	}
+
+	return totals, nil
+}
+
+func summaryAmounts(summaries []models.PaymentSummary) map[models.Currency]models.Money {
+	amounts := make(map[models.Currency]models.Money)
+	for _, summary := range summaries {
+		amounts[summary.Currency] = amounts[summary.Currency].Add(summary.TotalAmount)
+	}
+	return amounts
+}
+
+// GetPriceIncreases returns the price increases of the user's subscriptions since the given time
+func (s *AnalyticsService) GetPriceIncreases(ctx context.Context, userID int64, since time.Time) ([]models.PriceIncrease, error) {
+	history, err := s.priceHistoryRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	increases := models.FindPriceIncreases(history, since)
+
+	names := make(map[int]string)
+	for i := range increases {
+		id := increases[i].SubscriptionID
+		if _, ok := names[id]; !ok {
+			sub, err := s.subscriptionRepo.GetByID(ctx, userID, id)
+			if err != nil {
+				return nil, err
+			}
+			names[id] = sub.Name
+		}
+		increases[i].Name = names[id]
+	}
+
+	return increases, nil
+}
 
-	return models.NewMoney(monthlyCents)
+// GetLastYearPriceIncreases returns the price increases of the last 12 months
+func (s *AnalyticsService) GetLastYearPriceIncreases(ctx context.Context, userID int64) ([]models.PriceIncrease, error) {
+	return s.GetPriceIncreases(ctx, userID, time.Now().AddDate(-1, 0, 0))
 }
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestGetPaymentPageValidatesRequest(t *testing.T) {
+	storage := memory.New()
+	analytics := NewAnalyticsService(storage.Payments, storage.Subscriptions, storage.PriceHistory, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  models.PaymentPageRequest
+	}{
+		{"no limit", models.PaymentPageRequest{}},
+		{"limit too large", models.PaymentPageRequest{Limit: maxPaymentPageSize + 1}},
+		{"reversed range", models.PaymentPageRequest{Limit: 10, Filter: models.PaymentFilter{
+			From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		}}},
+		{"unknown status", models.PaymentPageRequest{Limit: 10, Filter: models.PaymentFilter{Status: "lost"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := analytics.GetPaymentPage(ctx, 1, tt.req)
+			var validation *ValidationError
+			if !errors.As(err, &validation) {
+				t.Errorf("Expected a validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetPriceIncreasesNamesSubscriptions(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+	analytics := NewAnalyticsService(storage.Payments, storage.Subscriptions, storage.PriceHistory, nil)
+
+	sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Netflix",
+		Cost:        models.NewMoney(1599),
+		Currency:    models.CurrencyUSD,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Now().AddDate(0, 0, 10),
+		Category:    models.CategoryEntertainment,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	cost := models.NewMoney(1799)
+	if _, err := subscriptions.UpdateSubscription(ctx, 1, sub.ID, &models.UpdateSubscriptionRequest{Cost: &cost}); err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+
+	increases, err := analytics.GetLastYearPriceIncreases(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get price increases: %v", err)
+	}
+	if len(increases) != 1 {
+		t.Fatalf("Expected one price increase, got %d", len(increases))
+	}
+	if increases[0].Name != "Netflix" {
+		t.Errorf("Expected the increase to be named Netflix, got %q", increases[0].Name)
+	}
+
+	// Other users see none of it
+	if increases, _ := analytics.GetLastYearPriceIncreases(ctx, 2); len(increases) != 0 {
+		t.Errorf("Expected no price increases for another user, got %d", len(increases))
+	}
+}
+
+func TestGetCurrentMonthTagAnalytics(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+	analytics := NewAnalyticsService(storage.Payments, storage.Subscriptions, storage.PriceHistory, nil)
+
+	create := func(name string, category models.Category, tags ...string) *models.Subscription {
+		sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+			UserID:      1,
+			Name:        name,
+			Cost:        models.NewMoney(1000),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Now(),
+			Category:    category,
+			Tags:        tags,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+		if _, err := subscriptions.RecordPayment(ctx, &models.CreatePaymentRequest{
+			UserID: 1, SubscriptionID: sub.ID, Amount: sub.Cost, Currency: sub.Currency, Status: models.PaymentStatusCompleted,
+		}, nil); err != nil {
+			t.Fatalf("Failed to record payment: %v", err)
+		}
+		return sub
+	}
+	create("Netflix", models.CategoryEntertainment, "family")
+	create("Spotify", models.CategoryEntertainment, "family")
+	create("Slack", models.CategoryWork, "company")
+
+	result, err := analytics.GetCurrentMonthTagAnalytics(ctx, 1, "family")
+	if err != nil {
+		t.Fatalf("Failed to get analytics: %v", err)
+	}
+	if len(result) != 1 || len(result[models.CategoryEntertainment]) != 1 {
+		t.Fatalf("Expected only entertainment in one currency, got %+v", result)
+	}
+	if summary := result[models.CategoryEntertainment][0]; summary.Count != 2 || summary.TotalAmount != models.NewMoney(2000) {
+		t.Errorf("Expected both family payments, got %+v", summary)
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// ErrAccountNotEmpty is returned by Restore when the user already has data
+var ErrAccountNotEmpty = errors.New("account already has subscriptions")
+
+// BackupService copies all data of a user to a backup and back
+type BackupService struct {
+	uow              repository.Transactor
+	subscriptionRepo repository.SubscriptionStore
+	paymentRepo      repository.PaymentStore
+	priceHistoryRepo repository.PriceHistoryStore
+	settingsRepo     repository.SettingsStore
+	categoryRepo     repository.CategoryStore
+	budgetRepo       repository.BudgetStore
+}
+
+func NewBackupService(uow repository.Transactor, subscriptionRepo repository.SubscriptionStore, paymentRepo repository.PaymentStore, priceHistoryRepo repository.PriceHistoryStore, settingsRepo repository.SettingsStore, categoryRepo repository.CategoryStore, budgetRepo repository.BudgetStore) *BackupService {
+	return &BackupService{
+		uow:              uow,
+		subscriptionRepo: subscriptionRepo,
+		paymentRepo:      paymentRepo,
+		priceHistoryRepo: priceHistoryRepo,
+		settingsRepo:     settingsRepo,
+		categoryRepo:     categoryRepo,
+		budgetRepo:       budgetRepo,
+	}
+}
+
+// Create collects subscriptions, their prices and payments, the categories,
+// budgets and the settings of the user
+func (s *BackupService) Create(ctx context.Context, userID int64) (*models.Backup, error) {
+	backup := &models.Backup{
+		Version:   models.BackupVersion,
+		CreatedAt: time.Now(),
+	}
+
+	var err error
+	if backup.Subscriptions, err = s.subscriptionRepo.GetAll(ctx, userID); err != nil {
+		return nil, err
+	}
+	if backup.PriceHistory, err = s.priceHistoryRepo.GetByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if backup.Payments, err = s.paymentRepo.GetAll(ctx, userID, models.PaymentFilter{}); err != nil {
+		return nil, err
+	}
+	if backup.Categories, err = s.categoryRepo.GetAll(ctx, userID); err != nil {
+		return nil, err
+	}
+	if backup.Budgets, err = s.budgetRepo.GetAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	baseCurrency, ok, err := s.settingsRepo.GetBaseCurrency(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		backup.Settings.BaseCurrency = baseCurrency
+	}
+
+	return backup, nil
+}
+
+// Restore replays a backup into the account of userID, which must have no
+// subscriptions yet. Everything gets new IDs and the references between
+// subscriptions, prices and payments are remapped. Categories of the backup
+// replace the ones with the same key. Either the whole backup is restored or
+// nothing is.
+func (s *BackupService) Restore(ctx context.Context, userID int64, backup *models.Backup) error {
+	if err := backup.CheckReferences(); err != nil {
+		return err
+	}
+	for _, sub := range backup.Subscriptions {
+		if err := validateSubscription(sub); err != nil {
+			return fmt.Errorf("invalid subscription %d: %w", sub.ID, err)
+		}
+	}
+	for _, category := range backup.Categories {
+		if err := validateCategory(category); err != nil {
+			return fmt.Errorf("invalid category %s: %w", category.Key, err)
+		}
+	}
+	for _, budget := range backup.Budgets {
+		if err := validateBudget(budget.Currency, budget.Limit); err != nil {
+			return fmt.Errorf("invalid budget %s %s: %w", budget.Category, budget.Currency, err)
+		}
+	}
+	for _, payment := range backup.Payments {
+		if err := validatePayment(payment.Amount, payment.Currency, payment.Status, payment.PaidAt, payment.Note); err != nil {
+			return fmt.Errorf("invalid payment %d: %w", payment.ID, err)
+		}
+	}
+	if currency := backup.Settings.BaseCurrency; currency != "" && !currency.IsValid() {
+		return fmt.Errorf("unsupported base currency: %s", currency)
+	}
+
+	return s.uow.Do(ctx, func(tx repository.Stores) error {
+		subscriptions := tx.Subscriptions
+
+		count, err := subscriptions.Count(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAccountNotEmpty
+		}
+
+		// Backups of version 1 use the defaults, later ones may change them
+		if err := seedCategories(ctx, tx.Categories, userID); err != nil {
+			return err
+		}
+		for _, category := range backup.Categories {
+			restored := *category
+			restored.UserID = userID
+			if err := tx.Categories.Restore(ctx, &restored); err != nil {
+				return err
+			}
+		}
+
+		for _, budget := range backup.Budgets {
+			restored := *budget
+			restored.UserID = userID
+			if err := tx.Budgets.Set(ctx, &restored); err != nil {
+				return err
+			}
+		}
+
+		// IDs of the backup mapped to the IDs of restored subscriptions
+		ids := make(map[int]int, len(backup.Subscriptions))
+		for _, sub := range backup.Subscriptions {
+			restored := *sub
+			restored.UserID = userID
+			if ids[sub.ID], err = subscriptions.Restore(ctx, &restored); err != nil {
+				return err
+			}
+		}
+
+		prices := tx.PriceHistory
+		for _, change := range backup.PriceHistory {
+			restored := *change
+			restored.SubscriptionID = ids[change.SubscriptionID]
+			if err := prices.Restore(ctx, &restored); err != nil {
+				return err
+			}
+		}
+
+		payments := tx.Payments
+		for _, payment := range backup.Payments {
+			restored := *payment
+			restored.UserID = userID
+			restored.SubscriptionID = ids[payment.SubscriptionID]
+			if _, err := payments.Restore(ctx, &restored); err != nil {
+				return err
+			}
+		}
+
+		if currency := backup.Settings.BaseCurrency; currency != "" {
+			if err := tx.Settings.SetBaseCurrency(ctx, userID, currency); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
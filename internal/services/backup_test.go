@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/testdb"
+	"testing"
+	"time"
+)
+
+func TestBackupRestoreRemapsIDs(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+
+	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db)
+	priceHistoryRepo := repository.NewPriceHistoryRepository(db)
+	settingsRepo := repository.NewUserSettingsRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	budgetRepo := repository.NewBudgetRepository(db)
+	uow := repository.NewUnitOfWork(db)
+
+	categories := NewCategoryService(categoryRepo)
+	subscriptions := NewSubscriptionService(uow, subscriptionRepo, paymentRepo, priceHistoryRepo, categories)
+	backups := NewBackupService(uow, subscriptionRepo, paymentRepo, priceHistoryRepo, settingsRepo, categoryRepo, budgetRepo)
+
+	music, err := categories.CreateCategory(ctx, 1, "Музыка", "🎵")
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	due := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Spotify",
+		Cost:        models.NewMoney(999),
+		Currency:    models.CurrencyEUR,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: due,
+		Category:    music.Key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	if _, err := subscriptions.MarkAsPaid(ctx, 1, sub.ID, due); err != nil {
+		t.Fatalf("Failed to pay: %v", err)
+	}
+	if err := settingsRepo.SetBaseCurrency(ctx, 1, models.CurrencyRUB); err != nil {
+		t.Fatalf("Failed to set base currency: %v", err)
+	}
+	if err := budgetRepo.Set(ctx, &models.Budget{UserID: 1, Category: music.Key, Currency: models.CurrencyEUR, Limit: 2000}); err != nil {
+		t.Fatalf("Failed to set budget: %v", err)
+	}
+
+	backup, err := backups.Create(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	if len(backup.Subscriptions) != 1 || len(backup.Payments) != 1 || len(backup.PriceHistory) != 1 {
+		t.Fatalf("Unexpected backup: %+v", backup)
+	}
+
+	// Restoring into the same account would duplicate everything
+	if err := backups.Restore(ctx, 1, backup); !errors.Is(err, ErrAccountNotEmpty) {
+		t.Fatalf("Expected ErrAccountNotEmpty, got %v", err)
+	}
+
+	if err := backups.Restore(ctx, 2, backup); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+
+	restored, err := backups.Create(ctx, 2)
+	if err != nil {
+		t.Fatalf("Failed to read restored data: %v", err)
+	}
+	restoredSub := restored.Subscriptions[0]
+	if restoredSub.ID == sub.ID || restoredSub.Name != "Spotify" || !restoredSub.NextPayment.Equal(due.AddDate(0, 1, 0)) {
+		t.Errorf("Unexpected restored subscription: %+v", restoredSub)
+	}
+	if len(restored.Payments) != 1 || restored.Payments[0].SubscriptionID != restoredSub.ID {
+		t.Errorf("Expected the payment to point at the new subscription, got %+v", restored.Payments)
+	}
+	if len(restored.PriceHistory) != 1 || restored.PriceHistory[0].SubscriptionID != restoredSub.ID {
+		t.Errorf("Expected the price to point at the new subscription, got %+v", restored.PriceHistory)
+	}
+	if restored.Settings.BaseCurrency != models.CurrencyRUB {
+		t.Errorf("Expected base currency RUB, got %q", restored.Settings.BaseCurrency)
+	}
+	if len(restored.Categories) != 6 || restored.Categories[5].Key != music.Key || restored.Categories[5].Name != "Музыка" {
+		t.Errorf("Expected the defaults and the custom category, got %d categories", len(restored.Categories))
+	}
+	if len(restored.Budgets) != 1 || restored.Budgets[0].Category != music.Key || restored.Budgets[0].Limit != 2000 {
+		t.Errorf("Expected the budget of the custom category, got %+v", restored.Budgets)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+)
+
+// BudgetService keeps the monthly spending limits of users and tells when a
+// payment brings the spending close to a limit or over it. Budgets are per
+// currency, payments in other currencies don't count towards them.
+type BudgetService struct {
+	budgetRepo       repository.BudgetStore
+	subscriptionRepo repository.SubscriptionStore
+	analytics        *AnalyticsService
+	categories       *CategoryService
+}
+
+func NewBudgetService(budgetRepo repository.BudgetStore, subscriptionRepo repository.SubscriptionStore, analytics *AnalyticsService, categories *CategoryService) *BudgetService {
+	return &BudgetService{
+		budgetRepo:       budgetRepo,
+		subscriptionRepo: subscriptionRepo,
+		analytics:        analytics,
+		categories:       categories,
+	}
+}
+
+// GetBudgets returns the budgets of the user, the overall ones first
+func (s *BudgetService) GetBudgets(ctx context.Context, userID int64) ([]*models.Budget, error) {
+	return s.budgetRepo.GetAll(ctx, userID)
+}
+
+// SetBudget limits the monthly spending in the category, or in all of them
+// when the category is empty, replacing the limit set before.
+func (s *BudgetService) SetBudget(ctx context.Context, userID int64, category models.Category, currency models.Currency, limit models.Money) (*models.Budget, error) {
+	if err := validateBudget(currency, limit); err != nil {
+		return nil, err
+	}
+	if category != "" {
+		if err := s.categories.checkCategory(ctx, userID, category); err != nil {
+			return nil, err
+		}
+	}
+
+	budget := &models.Budget{UserID: userID, Category: category, Currency: currency, Limit: limit}
+	if err := s.budgetRepo.Set(ctx, budget); err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (s *BudgetService) DeleteBudget(ctx context.Context, userID int64, category models.Category, currency models.Currency) error {
+	return s.budgetRepo.Delete(ctx, userID, category, currency)
+}
+
+// GetBudgetStatus compares the spending of the current month with every budget of the user
+func (s *BudgetService) GetBudgetStatus(ctx context.Context, userID int64) ([]models.BudgetStatus, error) {
+	budgets, err := s.budgetRepo.GetAll(ctx, userID)
+	if err != nil || len(budgets) == 0 {
+		return nil, err
+	}
+
+	analytics, err := s.analytics.GetCurrentMonthCategoryAnalytics(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category analytics: %w", err)
+	}
+
+	return budgetStatus(budgets, analytics), nil
+}
+
+// CheckPayment returns an alert for every budget whose spending the payment
+// has pushed past one of models.BudgetThresholds, the highest one crossed.
+// Only completed payments of the current month are checked, a payment
+// recorded for a past month can't change how that month went.
+func (s *BudgetService) CheckPayment(ctx context.Context, payment *models.Payment) ([]models.BudgetAlert, error) {
+	return s.CheckPayments(ctx, []*models.Payment{payment})
+}
+
+// CheckPayments is CheckPayment for payments of one subscription made
+// together, like the periods caught up by a renewal. Their sum is checked,
+// so a budget crossed by them is alerted about once.
+func (s *BudgetService) CheckPayments(ctx context.Context, payments []*models.Payment) ([]models.BudgetAlert, error) {
+	startOfMonth, endOfMonth := currentMonth()
+	payments = slices.DeleteFunc(slices.Clone(payments), func(payment *models.Payment) bool {
+		return payment.Status != models.PaymentStatusCompleted || !payment.Amount.IsPositive() ||
+			payment.PaidAt.Before(startOfMonth) || payment.PaidAt.After(endOfMonth)
+	})
+	if len(payments) == 0 {
+		return nil, nil
+	}
+	userID, subscriptionID := payments[0].UserID, payments[0].SubscriptionID
+
+	budgets, err := s.budgetRepo.GetAll(ctx, userID)
+	if err != nil || len(budgets) == 0 {
+		return nil, err
+	}
+
+	sub, err := s.subscriptionRepo.GetByID(ctx, userID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	analytics, err := s.analytics.GetCurrentMonthCategoryAnalytics(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category analytics: %w", err)
+	}
+
+	var alerts []models.BudgetAlert
+	for _, status := range budgetStatus(budgets, analytics) {
+		budget := status.Budget
+		if !budget.IsOverall() && budget.Category != sub.Category {
+			continue
+		}
+
+		var paid models.Money
+		for _, payment := range payments {
+			if payment.Currency == budget.Currency {
+				paid = paid.Add(payment.Amount)
+			}
+		}
+		if !paid.IsPositive() {
+			continue
+		}
+
+		if threshold, ok := crossedThreshold(budget.Limit, status.Spent-paid, status.Spent); ok {
+			alerts = append(alerts, models.BudgetAlert{BudgetStatus: status, Threshold: threshold})
+		}
+	}
+
+	return alerts, nil
+}
+
+func validateBudget(currency models.Currency, limit models.Money) error {
+	if !currency.IsValid() {
+		return invalidf("unsupported currency: %s", currency)
+	}
+	if !limit.IsPositive() {
+		return invalidf("budget limit must be positive")
+	}
+	return nil
+}
+
+// budgetStatus sums the spending of every budget in its currency, over all
+// categories for an overall budget
+func budgetStatus(budgets []*models.Budget, analytics map[models.Category][]models.PaymentSummary) []models.BudgetStatus {
+	statuses := make([]models.BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		status := models.BudgetStatus{Budget: budget}
+		for category, summaries := range analytics {
+			if !budget.IsOverall() && category != budget.Category {
+				continue
+			}
+			status.Spent = status.Spent.Add(summaryAmounts(summaries)[budget.Currency])
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// crossedThreshold returns the highest threshold the spending has reached
+// going from before to after, if any
+func crossedThreshold(limit, before, after models.Money) (int, bool) {
+	for _, threshold := range slices.Backward(models.BudgetThresholds) {
+		reached := int64(limit) * int64(threshold)
+		if int64(before)*100 < reached && int64(after)*100 >= reached {
+			return threshold, true
+		}
+	}
+	return 0, false
+}
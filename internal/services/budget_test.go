@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestBudgetAlertsWhenPaymentCrossesThreshold(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+
+	categories := NewCategoryService(storage.Categories)
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, categories)
+	analytics := NewAnalyticsService(storage.Payments, storage.Subscriptions, storage.PriceHistory, nil)
+	budgets := NewBudgetService(storage.Budgets, storage.Subscriptions, analytics, categories)
+
+	var invalid *ValidationError
+	if _, err := budgets.SetBudget(ctx, 1, "", models.CurrencyRUB, 0); !errors.As(err, &invalid) {
+		t.Errorf("Expected a zero limit to be rejected, got %v", err)
+	}
+	if _, err := budgets.SetBudget(ctx, 1, "custom_9", models.CurrencyRUB, 5000); !errors.As(err, &invalid) {
+		t.Errorf("Expected an unknown category to be rejected, got %v", err)
+	}
+
+	if _, err := budgets.SetBudget(ctx, 1, "", models.CurrencyRUB, 10000); err != nil {
+		t.Fatalf("Failed to set overall budget: %v", err)
+	}
+	if _, err := budgets.SetBudget(ctx, 1, models.CategoryEntertainment, models.CurrencyRUB, 5000); err != nil {
+		t.Fatalf("Failed to set category budget: %v", err)
+	}
+
+	create := func(name string, category models.Category) *models.Subscription {
+		sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+			UserID:      1,
+			Name:        name,
+			Cost:        models.NewMoney(1000),
+			Currency:    models.CurrencyRUB,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Now().AddDate(0, 0, 7),
+			Category:    category,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+		return sub
+	}
+	netflix := create("Netflix", models.CategoryEntertainment)
+	slack := create("Slack", models.CategoryWork)
+
+	// pay records a payment and returns the thresholds of the alerts, keyed by budget category
+	pay := func(sub *models.Subscription, amount models.Money, currency models.Currency, paidAt time.Time) map[models.Category]int {
+		payment, err := subscriptions.RecordPayment(ctx, &models.CreatePaymentRequest{
+			UserID: 1, SubscriptionID: sub.ID, Amount: amount, Currency: currency,
+			Status: models.PaymentStatusCompleted, PaidAt: &paidAt,
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to record payment: %v", err)
+		}
+		alerts, err := budgets.CheckPayment(ctx, payment)
+		if err != nil {
+			t.Fatalf("Failed to check payment: %v", err)
+		}
+		thresholds := make(map[models.Category]int)
+		for _, alert := range alerts {
+			thresholds[alert.Budget.Category] = alert.Threshold
+		}
+		return thresholds
+	}
+
+	now := time.Now()
+	steps := []struct {
+		name     string
+		sub      *models.Subscription
+		amount   models.Money
+		currency models.Currency
+		paidAt   time.Time
+		expected map[models.Category]int
+	}{
+		{"below thresholds", netflix, 3000, models.CurrencyRUB, now, map[models.Category]int{}},
+		{"category reaches 80%", netflix, 1500, models.CurrencyRUB, now, map[models.Category]int{models.CategoryEntertainment: 80}},
+		{"other category counts only overall", slack, 4500, models.CurrencyRUB, now, map[models.Category]int{"": 80}},
+		{"other currency", netflix, 9000, models.CurrencyUSD, now, map[models.Category]int{}},
+		{"previous month", slack, 9000, models.CurrencyRUB, now.AddDate(0, -1, -now.Day()+1), map[models.Category]int{}},
+		{"both exceeded", netflix, 1000, models.CurrencyRUB, now, map[models.Category]int{"": 100, models.CategoryEntertainment: 100}},
+		{"already exceeded", netflix, 1000, models.CurrencyRUB, now, map[models.Category]int{}},
+	}
+	for _, step := range steps {
+		got := pay(step.sub, step.amount, step.currency, step.paidAt)
+		if len(got) != len(step.expected) {
+			t.Errorf("%s: expected alerts %v, got %v", step.name, step.expected, got)
+			continue
+		}
+		for category, threshold := range step.expected {
+			if got[category] != threshold {
+				t.Errorf("%s: expected alerts %v, got %v", step.name, step.expected, got)
+			}
+		}
+	}
+
+	statuses, err := budgets.GetBudgetStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get budget status: %v", err)
+	}
+	if len(statuses) != 2 || !statuses[0].Budget.IsOverall() || statuses[0].Spent != 11000 ||
+		statuses[1].Spent != 6500 || statuses[1].Remaining() != -1500 {
+		t.Errorf("Unexpected budget status: %+v", statuses)
+	}
+}
+
+func TestCrossedThresholdReportsHighest(t *testing.T) {
+	tests := []struct {
+		before, after models.Money
+		threshold     int
+		crossed       bool
+	}{
+		{0, 799, 0, false},
+		{0, 800, 80, true},
+		{790, 999, 80, true},
+		{500, 1200, 100, true},
+		{800, 999, 0, false},
+		{1000, 1500, 0, false},
+	}
+	for _, tt := range tests {
+		threshold, crossed := crossedThreshold(1000, tt.before, tt.after)
+		if threshold != tt.threshold || crossed != tt.crossed {
+			t.Errorf("crossedThreshold(1000, %d, %d) = %d %v, expected %d %v",
+				tt.before, tt.after, threshold, crossed, tt.threshold, tt.crossed)
+		}
+	}
+}
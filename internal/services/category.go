@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"unicode/utf8"
+)
+
+// maxCategories keeps the category keyboard usable
+const maxCategories = 30
+
+// CategoryService keeps the categories users put their subscriptions in
+type CategoryService struct {
+	categoryRepo repository.CategoryStore
+}
+
+func NewCategoryService(categoryRepo repository.CategoryStore) *CategoryService {
+	return &CategoryService{categoryRepo: categoryRepo}
+}
+
+// GetCategories returns the categories of the user, archived ones included,
+// oldest first. A user without categories gets the defaults first.
+func (s *CategoryService) GetCategories(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	categories, err := s.categoryRepo.GetAll(ctx, userID)
+	if err != nil || len(categories) > 0 {
+		return categories, err
+	}
+
+	if err := seedCategories(ctx, s.categoryRepo, userID); err != nil {
+		return nil, err
+	}
+	return s.categoryRepo.GetAll(ctx, userID)
+}
+
+// GetActiveCategories returns the categories new subscriptions can be put in
+func (s *CategoryService) GetActiveCategories(ctx context.Context, userID int64) ([]*models.UserCategory, error) {
+	categories, err := s.GetCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := categories[:0]
+	for _, category := range categories {
+		if !category.Archived {
+			active = append(active, category)
+		}
+	}
+	return active, nil
+}
+
+func (s *CategoryService) GetCategory(ctx context.Context, userID int64, key models.Category) (*models.UserCategory, error) {
+	categories, err := s.GetCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return findCategory(categories, key)
+}
+
+// CreateCategory adds a category with a generated key
+func (s *CategoryService) CreateCategory(ctx context.Context, userID int64, name, emoji string) (*models.UserCategory, error) {
+	categories, err := s.GetCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) >= maxCategories {
+		return nil, invalidf("too many categories, at most %d", maxCategories)
+	}
+
+	req := &models.CreateCategoryRequest{
+		UserID: userID,
+		Name:   strings.TrimSpace(name),
+		Emoji:  strings.TrimSpace(emoji),
+	}
+	if err := validateChange(categories, &models.UserCategory{Name: req.Name, Emoji: req.Emoji}); err != nil {
+		return nil, err
+	}
+
+	// A concurrent create may take the next key, then the one after it is tried
+	for n := len(categories) + 1; ; n++ {
+		req.Key = models.Category(fmt.Sprintf("custom_%d", n))
+		category, err := s.categoryRepo.Create(ctx, req)
+		if !errors.Is(err, repository.ErrDuplicateCategory) {
+			return category, err
+		}
+	}
+}
+
+// UpdateCategory renames, changes the emoji of, archives or unarchives a category
+func (s *CategoryService) UpdateCategory(ctx context.Context, userID int64, key models.Category, req *models.UpdateCategoryRequest) (*models.UserCategory, error) {
+	categories, err := s.GetCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	category, err := findCategory(categories, key)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Apply(category)
+	category.Name = strings.TrimSpace(category.Name)
+	category.Emoji = strings.TrimSpace(category.Emoji)
+	if err := validateChange(categories, category); err != nil {
+		return nil, err
+	}
+
+	if err := s.categoryRepo.Update(ctx, category); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// checkCategory makes sure a subscription can be put in the category
+func (s *CategoryService) checkCategory(ctx context.Context, userID int64, key models.Category) error {
+	category, err := s.GetCategory(ctx, userID, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return invalidf("unknown category: %s", key)
+	}
+	if err != nil {
+		return err
+	}
+	if category.Archived {
+		return invalidf("category is archived: %s", category.Name)
+	}
+	return nil
+}
+
+func validateCategory(category *models.UserCategory) error {
+	if category.Name == "" {
+		return invalidf("category name is required")
+	}
+	if utf8.RuneCountInString(category.Name) > 50 {
+		return invalidf("category name is too long")
+	}
+	if utf8.RuneCountInString(category.Emoji) > 16 {
+		return invalidf("category emoji is too long")
+	}
+	return nil
+}
+
+// validateChange checks a new or changed category against the other categories of the user
+func validateChange(categories []*models.UserCategory, category *models.UserCategory) error {
+	if err := validateCategory(category); err != nil {
+		return err
+	}
+
+	active := 0
+	for _, other := range categories {
+		if other.Key == category.Key {
+			continue
+		}
+		if strings.EqualFold(other.Name, category.Name) {
+			return invalidf("category already exists: %s", other.Name)
+		}
+		if !other.Archived {
+			active++
+		}
+	}
+	if category.Archived && active == 0 {
+		return invalidf("at least one category must stay active")
+	}
+	return nil
+}
+
+// resolveCategory finds an active category by key or name, e.g. from an imported file
+func resolveCategory(categories []*models.UserCategory, s string) (models.Category, error) {
+	for _, category := range categories {
+		if !category.Archived && category.Matches(s) {
+			return category.Key, nil
+		}
+	}
+	return "", invalidf("unknown category: %s", s)
+}
+
+func findCategory(categories []*models.UserCategory, key models.Category) (*models.UserCategory, error) {
+	for _, category := range categories {
+		if category.Key == key {
+			return category, nil
+		}
+	}
+	return nil, fmt.Errorf("category %w", repository.ErrNotFound)
+}
+
+// seedCategories creates the default categories the user doesn't have yet
+func seedCategories(ctx context.Context, categories repository.CategoryStore, userID int64) error {
+	for _, req := range models.DefaultCategories() {
+		req.UserID = userID
+		if _, err := categories.Create(ctx, &req); err != nil && !errors.Is(err, repository.ErrDuplicateCategory) {
+			return fmt.Errorf("failed to create default categories: %w", err)
+		}
+	}
+	return nil
+}
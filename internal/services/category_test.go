@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestCategoriesStartWithDefaults(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	categories := NewCategoryService(storage.Categories)
+
+	all, err := categories.GetCategories(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get categories: %v", err)
+	}
+	if len(all) != 5 || all[0].Key != models.CategoryEntertainment || all[4].Label() != "📦 Другое" {
+		t.Fatalf("Expected the five defaults, got %d", len(all))
+	}
+
+	// The defaults are created once
+	if again, _ := categories.GetCategories(ctx, 1); len(again) != 5 {
+		t.Errorf("Expected the defaults once, got %d", len(again))
+	}
+}
+
+func TestCreateUpdateAndArchiveCategory(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	categories := NewCategoryService(storage.Categories)
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, categories)
+
+	cloud, err := categories.CreateCategory(ctx, 1, "  Облако ", "☁️")
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	if cloud.Key != "custom_6" || cloud.Name != "Облако" {
+		t.Errorf("Unexpected category: %+v", cloud)
+	}
+
+	var invalid *ValidationError
+	if _, err := categories.CreateCategory(ctx, 1, "облако", ""); !errors.As(err, &invalid) {
+		t.Errorf("Expected a duplicate name to be rejected, got %v", err)
+	}
+	if _, err := categories.CreateCategory(ctx, 1, " ", ""); !errors.As(err, &invalid) {
+		t.Errorf("Expected an empty name to be rejected, got %v", err)
+	}
+
+	name := "Хранилище"
+	renamed, err := categories.UpdateCategory(ctx, 1, cloud.Key, &models.UpdateCategoryRequest{Name: &name})
+	if err != nil || renamed.Name != "Хранилище" || renamed.Emoji != "☁️" {
+		t.Fatalf("Failed to rename category: %+v (%v)", renamed, err)
+	}
+	taken := "Работа"
+	if _, err := categories.UpdateCategory(ctx, 1, cloud.Key, &models.UpdateCategoryRequest{Name: &taken}); !errors.As(err, &invalid) {
+		t.Errorf("Expected the name of another category to be rejected, got %v", err)
+	}
+
+	req := &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "iCloud",
+		Cost:        models.NewMoney(99),
+		Currency:    models.CurrencyUSD,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Category:    cloud.Key,
+	}
+	sub, err := subscriptions.CreateSubscription(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	archived := true
+	if _, err := categories.UpdateCategory(ctx, 1, cloud.Key, &models.UpdateCategoryRequest{Archived: &archived}); err != nil {
+		t.Fatalf("Failed to archive category: %v", err)
+	}
+	active, _ := categories.GetActiveCategories(ctx, 1)
+	if len(active) != 5 {
+		t.Errorf("Expected the archived category hidden, got %d active", len(active))
+	}
+
+	// The subscription keeps its category, new ones can't use it
+	newName := "iCloud+"
+	if _, err := subscriptions.UpdateSubscription(ctx, 1, sub.ID, &models.UpdateSubscriptionRequest{Name: &newName}); err != nil {
+		t.Errorf("Expected a subscription of an archived category to stay editable, got %v", err)
+	}
+	if _, err := subscriptions.CreateSubscription(ctx, req); !errors.As(err, &invalid) {
+		t.Errorf("Expected an archived category to be rejected, got %v", err)
+	}
+	req.Category = "custom_42"
+	if _, err := subscriptions.CreateSubscription(ctx, req); !errors.As(err, &invalid) {
+		t.Errorf("Expected an unknown category to be rejected, got %v", err)
+	}
+}
+
+func TestArchiveKeepsOneActiveCategory(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	categories := NewCategoryService(storage.Categories)
+
+	all, err := categories.GetCategories(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get categories: %v", err)
+	}
+
+	archived := true
+	for i, category := range all {
+		_, err := categories.UpdateCategory(ctx, 1, category.Key, &models.UpdateCategoryRequest{Archived: &archived})
+		if last := i == len(all)-1; last != (err != nil) {
+			t.Errorf("Archiving category %d of %d: %v", i+1, len(all), err)
+		}
+	}
+}
+
+func TestImportResolvesCategoryNames(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	categories := NewCategoryService(storage.Categories)
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, categories)
+
+	cloud, err := categories.CreateCategory(ctx, 1, "Облако", "")
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	row := func(line int, category string) models.ImportRow {
+		return models.ImportRow{Line: line, Request: &models.CreateSubscriptionRequest{
+			Name:        "Netflix",
+			Cost:        models.NewMoney(1599),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+			Category:    models.Category(category),
+		}}
+	}
+
+	report, err := subscriptions.ImportSubscriptions(ctx, 1, []models.ImportRow{
+		row(2, "work"), row(3, "облако"), row(4, "спорт"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if len(report.Created) != 2 || report.Created[0].Category != models.CategoryWork || report.Created[1].Category != cloud.Key {
+		t.Errorf("Expected the key and the name resolved, got %+v", report.Created)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Line != 4 {
+		t.Errorf("Expected the unknown category reported, got %+v", report.Failed)
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/rates"
+	"sub-cos-counter/internal/repository"
+)
+
+// ExchangeService keeps exchange rates and converts amounts into the user's base currency
+type ExchangeService struct {
+	rateRepo     repository.ExchangeRateStore
+	settingsRepo repository.SettingsStore
+	provider     rates.Provider
+	defaultBase  models.Currency
+}
+
+// NewExchangeService creates the service. provider may be nil when rates are only entered manually.
+func NewExchangeService(rateRepo repository.ExchangeRateStore, settingsRepo repository.SettingsStore, provider rates.Provider, defaultBase models.Currency) *ExchangeService {
+	return &ExchangeService{
+		rateRepo:     rateRepo,
+		settingsRepo: settingsRepo,
+		provider:     provider,
+		defaultBase:  defaultBase,
+	}
+}
+
+// SetRate stores a manually entered rate: one unit of from costs rate units of to
+func (s *ExchangeService) SetRate(ctx context.Context, from, to models.Currency, rate float64) (*models.ExchangeRate, error) {
+	if !from.IsValid() || !to.IsValid() {
+		return nil, fmt.Errorf("unsupported currency")
+	}
+	if from == to {
+		return nil, fmt.Errorf("currencies must differ")
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+
+	exchangeRate := &models.ExchangeRate{
+		From:   from,
+		To:     to,
+		Rate:   rate,
+		Source: models.RateSourceManual,
+	}
+	if err := s.rateRepo.Save(ctx, exchangeRate); err != nil {
+		return nil, err
+	}
+
+	return exchangeRate, nil
+}
+
+// RefreshRates stores the current rates of the provider and returns how many were saved
+func (s *ExchangeService) RefreshRates(ctx context.Context) (int, error) {
+	if s.provider == nil {
+		return 0, nil
+	}
+
+	fetched, err := s.provider.Rates(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch rates from %s: %w", s.provider.Name(), err)
+	}
+
+	for _, rate := range fetched {
+		if err := s.rateRepo.Save(ctx, rate); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(fetched), nil
+}
+
+func (s *ExchangeService) GetRates(ctx context.Context) ([]*models.ExchangeRate, error) {
+	return s.rateRepo.GetAll(ctx)
+}
+
+// GetBaseCurrency returns the user's base currency, or the configured default
+func (s *ExchangeService) GetBaseCurrency(ctx context.Context, userID int64) (models.Currency, error) {
+	currency, ok, err := s.settingsRepo.GetBaseCurrency(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return s.defaultBase, nil
+	}
+	return currency, nil
+}
+
+func (s *ExchangeService) SetBaseCurrency(ctx context.Context, userID int64, currency models.Currency) error {
+	if !currency.IsValid() {
+		return fmt.Errorf("unsupported currency: %s", currency)
+	}
+	return s.settingsRepo.SetBaseCurrency(ctx, userID, currency)
+}
+
+// ConvertTotal sums amounts in different currencies in the user's base currency
+func (s *ExchangeService) ConvertTotal(ctx context.Context, userID int64, amounts map[models.Currency]models.Money) (models.ConvertedTotal, error) {
+	base, err := s.GetBaseCurrency(ctx, userID)
+	if err != nil {
+		return models.ConvertedTotal{}, err
+	}
+
+	exchangeRates, err := s.rateRepo.GetAll(ctx)
+	if err != nil {
+		return models.ConvertedTotal{}, err
+	}
+
+	return models.NewRateTable(exchangeRates, s.defaultBase).Total(amounts, base), nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// Notifier delivers reminders to subscription owners.
+type Notifier interface {
+	SendPaymentReminder(ctx context.Context, sub *models.Subscription, kind models.ReminderKind) error
+}
+
+type ReminderService struct {
+	subscriptionRepo repository.SubscriptionStore
+	reminderRepo     repository.ReminderStore
+	daysBefore       int
+	trialDaysBefore  int
+	snooze           time.Duration
+}
+
+func NewReminderService(subscriptionRepo repository.SubscriptionStore, reminderRepo repository.ReminderStore, daysBefore, trialDaysBefore int, snooze time.Duration) *ReminderService {
+	return &ReminderService{
+		subscriptionRepo: subscriptionRepo,
+		reminderRepo:     reminderRepo,
+		daysBefore:       daysBefore,
+		trialDaysBefore:  trialDaysBefore,
+		snooze:           snooze,
+	}
+}
+
+// SendReminders notifies owners about upcoming and due payments and repeats
+// snoozed reminders whose snooze has expired. Trials are announced before they
+// convert to paid. Each reminder is sent once per
+// billing cycle, even across restarts.
+func (s *ReminderService) SendReminders(ctx context.Context, notifier Notifier) error {
+	now := time.Now()
+	until := models.DateOnly(now).AddDate(0, 0, max(s.daysBefore, s.trialDaysBefore))
+
+	subscriptions, err := s.subscriptionRepo.GetAllDueBefore(ctx, until)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions for reminders: %w", err)
+	}
+
+	for _, sub := range subscriptions {
+		kind, ok := models.SubscriptionReminderKind(sub, now, s.daysBefore, s.trialDaysBefore)
+		if !ok {
+			continue
+		}
+		// Auto-renewing subscriptions are paid by the renewal job on the due date
+		if kind == models.ReminderKindDue && sub.AutoRenewal {
+			continue
+		}
+
+		reserved, err := s.reminderRepo.Reserve(ctx, sub, kind)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			continue
+		}
+
+		if err := notifier.SendPaymentReminder(ctx, sub, kind); err != nil {
+			log.Printf("Failed to send %s reminder for subscription %d: %v", kind, sub.ID, err)
+			if err := s.reminderRepo.Release(ctx, sub, kind); err != nil {
+				return err
+			}
+		}
+	}
+
+	snoozed, err := s.reminderRepo.TakeSnoozed(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range snoozed {
+		kind, ok := models.SubscriptionReminderKind(sub, now, s.daysBefore, s.trialDaysBefore)
+		if !ok {
+			kind = models.ReminderKindUpcoming
+		}
+		if err := notifier.SendPaymentReminder(ctx, sub, kind); err != nil {
+			log.Printf("Failed to repeat snoozed reminder for subscription %d: %v", sub.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Snooze postpones the reminders of the billing cycle that ends on dueDate.
+// It returns the time the reminder will be repeated.
+func (s *ReminderService) Snooze(ctx context.Context, userID int64, subscriptionID int, dueDate time.Time) (time.Time, error) {
+	until := time.Now().Add(s.snooze)
+	if err := s.reminderRepo.Snooze(ctx, userID, subscriptionID, dueDate, until); err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"time"
+)
+
+// RenewalNotifier informs owners about subscriptions changed by the renewal job.
+type RenewalNotifier interface {
+	SendRenewalNotice(ctx context.Context, sub *models.Subscription, payments []*models.Payment) error
+	SendExpirationNotice(ctx context.Context, sub *models.Subscription) error
+	SendBudgetAlerts(ctx context.Context, userID int64, alerts []models.BudgetAlert) error
+}
+
+type RenewalService struct {
+	uow              repository.Transactor
+	subscriptionRepo repository.SubscriptionStore
+	paymentRepo      repository.PaymentStore
+	budgets          *BudgetService
+	graceDays        int
+}
+
+// NewRenewalService creates the renewal job. Without budgets the payments it
+// records are not checked against them.
+func NewRenewalService(uow repository.Transactor, subscriptionRepo repository.SubscriptionStore, paymentRepo repository.PaymentStore, budgets *BudgetService, graceDays int) *RenewalService {
+	return &RenewalService{
+		uow:              uow,
+		subscriptionRepo: subscriptionRepo,
+		paymentRepo:      paymentRepo,
+		budgets:          budgets,
+		graceDays:        graceDays,
+	}
+}
+
+// ProcessRenewals records payments for auto-renewing subscriptions whose
+// payment date has passed, one per missed period, and deactivates
+// subscriptions without auto-renewal that stayed unpaid for the grace period.
+func (s *RenewalService) ProcessRenewals(ctx context.Context, notifier RenewalNotifier) error {
+	now := time.Now()
+
+	subscriptions, err := s.subscriptionRepo.GetAllDueBefore(ctx, models.DateOnly(now))
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions for renewal: %w", err)
+	}
+
+	for _, sub := range subscriptions {
+		if sub.AutoRenewal {
+			payments, err := s.renew(ctx, sub, now)
+			if err != nil {
+				log.Printf("Failed to renew subscription %d: %v", sub.ID, err)
+				continue
+			}
+			if len(payments) > 0 {
+				if err := notifier.SendRenewalNotice(ctx, sub, payments); err != nil {
+					log.Printf("Failed to send renewal notice for subscription %d: %v", sub.ID, err)
+				}
+				s.checkBudgets(ctx, notifier, payments)
+			}
+			continue
+		}
+
+		if !s.isExpired(sub, now) {
+			continue
+		}
+
+		if err := s.subscriptionRepo.Delete(ctx, sub.UserID, sub.ID); err != nil {
+			log.Printf("Failed to deactivate expired subscription %d: %v", sub.ID, err)
+			continue
+		}
+		if err := notifier.SendExpirationNotice(ctx, sub); err != nil {
+			log.Printf("Failed to send expiration notice for subscription %d: %v", sub.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// renew records a completed payment for every missed period and moves the
+// payment date forward in the same transaction, so a failure never skips or
+// repeats a period. Each period reads the subscription again under the lock:
+// one cancelled, paid by hand or rescheduled since it was listed is left as
+// it is, and an edited one is renewed with its new price.
+func (s *RenewalService) renew(ctx context.Context, sub *models.Subscription, now time.Time) ([]*models.Payment, error) {
+	pending := *sub
+	var payments []*models.Payment
+
+	for _, paidAt := range pending.CatchUp(now) {
+		var current *models.Subscription
+		var payment *models.Payment
+		err := s.uow.Do(ctx, func(tx repository.Stores) error {
+			var err error
+			current, err = tx.Subscriptions.GetByIDForUpdate(ctx, sub.UserID, sub.ID)
+			if err != nil {
+				return err
+			}
+			if !current.Active || !current.AutoRenewal ||
+				!models.DateOnly(current.NextPayment).Equal(models.DateOnly(paidAt)) {
+				current = nil
+				return nil
+			}
+
+			payment, err = tx.Payments.Create(ctx, &models.CreatePaymentRequest{
+				UserID:         current.UserID,
+				SubscriptionID: current.ID,
+				Amount:         current.Cost,
+				Currency:       current.Currency,
+				Status:         models.PaymentStatusCompleted,
+				PaidAt:         &paidAt,
+				IdempotencyKey: models.BillingCycleKey(current.ID, paidAt),
+			})
+			// The cycle was paid by hand, only the date is left to move
+			if errors.Is(err, repository.ErrDuplicatePayment) {
+				payment = nil
+			} else if err != nil {
+				return err
+			}
+
+			current.UpdateNextPayment()
+			if err := tx.Subscriptions.Update(ctx, current); err != nil {
+				return fmt.Errorf("failed to update subscription: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return payments, err
+		}
+		if current == nil {
+			break
+		}
+
+		*sub = *current
+		if payment != nil {
+			payments = append(payments, payment)
+		}
+	}
+
+	return payments, nil
+}
+
+// checkBudgets alerts about the budgets the renewal payments have brought
+// close to the limit or over it. The payments are already saved, so a failed
+// check is only logged.
+func (s *RenewalService) checkBudgets(ctx context.Context, notifier RenewalNotifier, payments []*models.Payment) {
+	if s.budgets == nil {
+		return
+	}
+
+	userID := payments[0].UserID
+	alerts, err := s.budgets.CheckPayments(ctx, payments)
+	if err != nil {
+		log.Printf("Failed to check budgets for user %d: %v", userID, err)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	if err := notifier.SendBudgetAlerts(ctx, userID, alerts); err != nil {
+		log.Printf("Failed to send budget alert to user %d: %v", userID, err)
+	}
+}
+
+func (s *RenewalService) isExpired(sub *models.Subscription, now time.Time) bool {
+	expiresAt := models.DateOnly(sub.NextPayment).AddDate(0, 0, s.graceDays)
+	return models.DateOnly(now).After(expiresAt)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	renewed map[int][]*models.Payment
+	expired []int
+	alerts  []models.BudgetAlert
+}
+
+func (n *recordingNotifier) SendRenewalNotice(ctx context.Context, sub *models.Subscription, payments []*models.Payment) error {
+	n.renewed[sub.ID] = payments
+	return nil
+}
+
+func (n *recordingNotifier) SendExpirationNotice(ctx context.Context, sub *models.Subscription) error {
+	n.expired = append(n.expired, sub.ID)
+	return nil
+}
+
+func (n *recordingNotifier) SendBudgetAlerts(ctx context.Context, userID int64, alerts []models.BudgetAlert) error {
+	n.alerts = append(n.alerts, alerts...)
+	return nil
+}
+
+func TestProcessRenewals(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	today := models.DateOnly(time.Now())
+
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+	renewals := NewRenewalService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, nil, 3)
+
+	create := func(name string, nextPayment time.Time, autoRenewal bool) *models.Subscription {
+		sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+			UserID:      1,
+			Name:        name,
+			Cost:        models.NewMoney(499),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodWeekly),
+			NextPayment: nextPayment,
+			Category:    models.CategoryEntertainment,
+			AutoRenewal: autoRenewal,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+		return sub
+	}
+
+	renewing := create("Renewing", today.AddDate(0, 0, -15), true)
+	expiring := create("Expiring", today.AddDate(0, 0, -4), false)
+	grace := create("Grace", today.AddDate(0, 0, -2), false)
+
+	notifier := &recordingNotifier{renewed: make(map[int][]*models.Payment)}
+	if err := renewals.ProcessRenewals(ctx, notifier); err != nil {
+		t.Fatalf("Failed to process renewals: %v", err)
+	}
+
+	// One payment for every missed week
+	if got := len(notifier.renewed[renewing.ID]); got != 3 {
+		t.Errorf("Expected 3 renewal payments, got %d", got)
+	}
+	renewed, err := subscriptions.GetSubscriptionByID(ctx, 1, renewing.ID)
+	if err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if expected := today.AddDate(0, 0, 6); !renewed.NextPayment.Equal(expected) {
+		t.Errorf("Expected next payment %s, got %s", expected.Format(time.DateOnly), renewed.NextPayment.Format(time.DateOnly))
+	}
+
+	if len(notifier.expired) != 1 || notifier.expired[0] != expiring.ID {
+		t.Errorf("Expected only subscription %d to expire, got %v", expiring.ID, notifier.expired)
+	}
+	if sub, _ := subscriptions.GetSubscriptionByID(ctx, 1, grace.ID); !sub.Active {
+		t.Error("Expected a subscription within the grace period to stay active")
+	}
+
+	// A second run finds nothing left to renew
+	notifier = &recordingNotifier{renewed: make(map[int][]*models.Payment)}
+	if err := renewals.ProcessRenewals(ctx, notifier); err != nil {
+		t.Fatalf("Failed to process renewals: %v", err)
+	}
+	if len(notifier.renewed) != 0 || len(notifier.expired) != 0 {
+		t.Errorf("Expected no changes on the second run, got %d renewed and %d expired", len(notifier.renewed), len(notifier.expired))
+	}
+}
+
+// listedStore runs changed after the renewal job has listed the due
+// subscriptions, like a user acting while the job runs
+type listedStore struct {
+	repository.SubscriptionStore
+	changed func()
+}
+
+func (s *listedStore) GetAllDueBefore(ctx context.Context, until time.Time) ([]*models.Subscription, error) {
+	subscriptions, err := s.SubscriptionStore.GetAllDueBefore(ctx, until)
+	s.changed()
+	return subscriptions, err
+}
+
+// Test that changes made after a subscription was listed for renewal are
+// neither overwritten nor renewed twice
+func TestProcessRenewalsRereadsSubscriptions(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	today := models.DateOnly(time.Now())
+
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+
+	create := func(name string, nextPayment time.Time) *models.Subscription {
+		sub, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+			UserID:      1,
+			Name:        name,
+			Cost:        models.NewMoney(499),
+			Currency:    models.CurrencyUSD,
+			Period:      models.Every(1, models.PeriodWeekly),
+			NextPayment: nextPayment,
+			Category:    models.CategoryEntertainment,
+			AutoRenewal: true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+		return sub
+	}
+
+	edited := create("Edited", today.AddDate(0, 0, -15))
+	deleted := create("Deleted", today.AddDate(0, 0, -8))
+	paid := create("Paid", today.AddDate(0, 0, -1))
+
+	store := &listedStore{SubscriptionStore: storage.Subscriptions, changed: func() {
+		cost := models.NewMoney(599)
+		if _, err := subscriptions.UpdateSubscription(ctx, 1, edited.ID, &models.UpdateSubscriptionRequest{Cost: &cost}); err != nil {
+			t.Fatalf("Failed to update subscription: %v", err)
+		}
+		if err := subscriptions.DeleteSubscription(ctx, 1, deleted.ID); err != nil {
+			t.Fatalf("Failed to delete subscription: %v", err)
+		}
+		if _, err := subscriptions.MarkAsPaid(ctx, 1, paid.ID, paid.NextPayment); err != nil {
+			t.Fatalf("Failed to pay subscription: %v", err)
+		}
+	}}
+	renewals := NewRenewalService(storage.UnitOfWork, store, storage.Payments, nil, 3)
+
+	notifier := &recordingNotifier{renewed: make(map[int][]*models.Payment)}
+	if err := renewals.ProcessRenewals(ctx, notifier); err != nil {
+		t.Fatalf("Failed to process renewals: %v", err)
+	}
+
+	// The edited subscription is renewed at its new price, with no price change of its own
+	payments := notifier.renewed[edited.ID]
+	if len(payments) != 3 || payments[0].Amount != 599 {
+		t.Errorf("Expected 3 renewal payments of 5.99, got %v", payments)
+	}
+	history, err := subscriptions.GetPriceHistory(ctx, 1, edited.ID)
+	if err != nil {
+		t.Fatalf("Failed to get price history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected only the edit in the price history, got %d prices", len(history))
+	}
+
+	sub, err := subscriptions.GetSubscriptionByID(ctx, 1, deleted.ID)
+	if err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if sub.Active || len(notifier.renewed[deleted.ID]) != 0 {
+		t.Errorf("Expected the deleted subscription to stay inactive and unpaid, got active=%v and %d payments",
+			sub.Active, len(notifier.renewed[deleted.ID]))
+	}
+
+	sub, err = subscriptions.GetSubscriptionByID(ctx, 1, paid.ID)
+	if err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if expected := today.AddDate(0, 0, 6); !sub.NextPayment.Equal(expected) || len(notifier.renewed[paid.ID]) != 0 {
+		t.Errorf("Expected the paid subscription moved once to %s, got %s and %d renewal payments",
+			expected.Format(time.DateOnly), sub.NextPayment.Format(time.DateOnly), len(notifier.renewed[paid.ID]))
+	}
+}
+
+// Test that payments recorded by the renewal job are checked against budgets
+func TestProcessRenewalsAlertsBudgets(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	today := models.DateOnly(time.Now())
+
+	categories := NewCategoryService(storage.Categories)
+	subscriptions := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, categories)
+	analytics := NewAnalyticsService(storage.Payments, storage.Subscriptions, storage.PriceHistory, nil)
+	budgets := NewBudgetService(storage.Budgets, storage.Subscriptions, analytics, categories)
+	renewals := NewRenewalService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, budgets, 3)
+
+	if _, err := budgets.SetBudget(ctx, 1, "", models.CurrencyRUB, 10000); err != nil {
+		t.Fatalf("Failed to set budget: %v", err)
+	}
+	if _, err := budgets.SetBudget(ctx, 1, models.CategoryWork, models.CurrencyRUB, 1000); err != nil {
+		t.Fatalf("Failed to set budget: %v", err)
+	}
+	_, err := subscriptions.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Cinema",
+		Cost:        models.NewMoney(8500),
+		Currency:    models.CurrencyRUB,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: today,
+		Category:    models.CategoryEntertainment,
+		AutoRenewal: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	notifier := &recordingNotifier{renewed: make(map[int][]*models.Payment)}
+	if err := renewals.ProcessRenewals(ctx, notifier); err != nil {
+		t.Fatalf("Failed to process renewals: %v", err)
+	}
+
+	if len(notifier.alerts) != 1 || !notifier.alerts[0].Budget.IsOverall() || notifier.alerts[0].Threshold != 80 {
+		t.Errorf("Expected one alert of 80%% of the overall budget, got %+v", notifier.alerts)
+	}
+}
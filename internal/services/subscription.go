@@ -2,82 +2,433 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"sub-cos-counter/internal/models"
 	"sub-cos-counter/internal/repository"
+	"time"
+	"unicode/utf8"
 )
 
+const (
+	maxTags = 10
+	// maxTagLength keeps a tag within the 64 bytes of a Telegram button payload
+	maxTagLength = 40
+)
+
+// ErrAlreadyPaid is returned by MarkAsPaid when the billing cycle has already been paid
+var ErrAlreadyPaid = errors.New("payment for this billing cycle is already recorded")
+
+// ValidationError is returned when a request breaks a rule of the data. The
+// message tells which rule, so it can be shown to the user as is.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason
+}
+
+func invalidf(format string, args ...any) error {
+	return &ValidationError{Reason: fmt.Sprintf(format, args...)}
+}
+
 type SubscriptionService struct {
-	subscriptionRepo *repository.SubscriptionRepository
-	paymentRepo      *repository.PaymentRepository
+	uow              repository.Transactor
+	subscriptionRepo repository.SubscriptionStore
+	paymentRepo      repository.PaymentStore
+	priceHistoryRepo repository.PriceHistoryStore
+	categories       *CategoryService
 }
 
-func NewSubscriptionService(subscriptionRepo *repository.SubscriptionRepository, paymentRepo *repository.PaymentRepository) *SubscriptionService {
+func NewSubscriptionService(uow repository.Transactor, subscriptionRepo repository.SubscriptionStore, paymentRepo repository.PaymentStore, priceHistoryRepo repository.PriceHistoryStore, categories *CategoryService) *SubscriptionService {
 	return &SubscriptionService{
+		uow:              uow,
 		subscriptionRepo: subscriptionRepo,
 		paymentRepo:      paymentRepo,
+		priceHistoryRepo: priceHistoryRepo,
+		categories:       categories,
 	}
 }
 
 func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
-	if req.Name == "" {
-		return nil, fmt.Errorf("subscription name is required")
+	if err := prepareSubscription(req); err != nil {
+		return nil, err
 	}
-	if !req.Cost.IsPositive() {
-		return nil, fmt.Errorf("subscription cost must be positive")
-	}
-	if req.PeriodDays <= 0 {
-		return nil, fmt.Errorf("subscription period must be positive")
+	if err := s.categories.checkCategory(ctx, req.UserID, req.Category); err != nil {
+		return nil, err
 	}
 
 	return s.subscriptionRepo.Create(ctx, req)
 }
 
-func (s *SubscriptionService) GetAllActiveSubscriptions(ctx context.Context) ([]*models.Subscription, error) {
-	return s.subscriptionRepo.GetAllActive(ctx)
+// ImportSubscriptions creates the subscriptions read from a file. Every row is
+// checked like in CreateSubscription, the valid ones are created in one
+// transaction and the rest are listed in the report with the reason. The
+// category of a row is the key or the name of a category of the user.
+func (s *SubscriptionService) ImportSubscriptions(ctx context.Context, userID int64, rows []models.ImportRow) (*models.ImportReport, error) {
+	report := &models.ImportReport{}
+
+	categories, err := s.categories.GetCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var valid []*models.CreateSubscriptionRequest
+	for _, row := range rows {
+		err := row.Err
+		if err == nil {
+			row.Request.UserID = userID
+			row.Request.Category, err = resolveCategory(categories, string(row.Request.Category))
+		}
+		if err == nil {
+			err = prepareSubscription(row.Request)
+		}
+		if err != nil {
+			failed := models.ImportError{Line: row.Line, Reason: err.Error()}
+			if row.Request != nil {
+				failed.Name = row.Request.Name
+			}
+			report.Failed = append(report.Failed, failed)
+			continue
+		}
+		valid = append(valid, row.Request)
+	}
+
+	err = s.uow.Do(ctx, func(tx repository.Stores) error {
+		subscriptions := tx.Subscriptions
+		for _, req := range valid {
+			sub, err := subscriptions.Create(ctx, req)
+			if err != nil {
+				return fmt.Errorf("failed to import %q: %w", req.Name, err)
+			}
+			report.Created = append(report.Created, sub)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// prepareSubscription validates a new subscription and anchors its period
+func prepareSubscription(req *models.CreateSubscriptionRequest) error {
+	if req.UserID == 0 {
+		return invalidf("subscription owner is required")
+	}
+
+	req.Tags = models.NormalizeTags(req.Tags)
+	req.Notes = strings.TrimSpace(req.Notes)
+	req.URL = strings.TrimSpace(req.URL)
+
+	err := validateSubscription(&models.Subscription{
+		Name:        req.Name,
+		Cost:        req.Cost,
+		Currency:    req.Currency,
+		Period:      req.Period,
+		NextPayment: req.NextPayment,
+		Category:    req.Category,
+		TrialEndsAt: req.TrialEndsAt,
+		TrialCost:   req.TrialCost,
+		Tags:        req.Tags,
+		Notes:       req.Notes,
+		URL:         req.URL,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Month based periods keep the billing day of the first payment
+	if req.Period.AnchorDay == 0 {
+		req.Period = req.Period.AnchoredTo(req.NextPayment)
+	}
+
+	return nil
+}
+
+// UpdateSubscription changes the requested fields of an active subscription
+// and validates the result with the same rules as CreateSubscription.
+func (s *SubscriptionService) UpdateSubscription(ctx context.Context, userID int64, id int, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	subscription, err := s.subscriptionRepo.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if !subscription.Active {
+		return nil, invalidf("subscription is not active")
+	}
+
+	req.Apply(subscription)
+	subscription.Tags = models.NormalizeTags(subscription.Tags)
+	subscription.Notes = strings.TrimSpace(subscription.Notes)
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	if err := validateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	// A subscription stays in its category after the category is archived
+	if req.Category != nil {
+		if err := s.categories.checkCategory(ctx, userID, *req.Category); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func validateSubscription(sub *models.Subscription) error {
+	if strings.TrimSpace(sub.Name) == "" {
+		return invalidf("subscription name is required")
+	}
+	if utf8.RuneCountInString(sub.Name) > 255 {
+		return invalidf("subscription name is too long")
+	}
+	if !sub.Cost.IsPositive() {
+		return invalidf("subscription cost must be positive")
+	}
+	if !sub.Currency.IsValid() {
+		return invalidf("unsupported currency: %s", sub.Currency)
+	}
+	if err := sub.Period.Validate(); err != nil {
+		return invalidf("invalid subscription period: %v", err)
+	}
+	if sub.NextPayment.IsZero() {
+		return invalidf("next payment date is required")
+	}
+	if sub.Category == "" {
+		return invalidf("category is required")
+	}
+	if sub.TrialCost < 0 {
+		return invalidf("trial cost must not be negative")
+	}
+	if sub.TrialEndsAt == nil && sub.TrialCost != 0 {
+		return invalidf("trial end date is required")
+	}
+	if len(sub.Tags) > maxTags {
+		return invalidf("a subscription can have at most %d tags", maxTags)
+	}
+	for _, tag := range sub.Tags {
+		if len(tag) > maxTagLength {
+			return invalidf("tag %q is too long", tag)
+		}
+		if strings.Contains(tag, ",") {
+			return invalidf("tag %q must not contain commas", tag)
+		}
+	}
+	if len(sub.Notes) > 1000 {
+		return invalidf("subscription notes are too long")
+	}
+	if sub.URL != "" {
+		if err := validateURL(sub.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateURL accepts absolute http and https links, the ones a chat can open
+func validateURL(link string) error {
+	if len(link) > 2048 {
+		return invalidf("link is too long")
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidf("link must be an http or https address")
+	}
+	return nil
+}
+
+func (s *SubscriptionService) GetAllActiveSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	return s.subscriptionRepo.GetAllActive(ctx, userID)
+}
+
+// GetAllSubscriptions returns active and cancelled subscriptions
+func (s *SubscriptionService) GetAllSubscriptions(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	return s.subscriptionRepo.GetAll(ctx, userID)
+}
+
+// GetActiveTrials returns subscriptions whose trial has not converted to paid yet
+func (s *SubscriptionService) GetActiveTrials(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	return s.subscriptionRepo.GetActiveTrials(ctx, userID, models.DateOnly(time.Now()))
 }
 
-func (s *SubscriptionService) GetSubscriptionByID(ctx context.Context, id int) (*models.Subscription, error) {
-	return s.subscriptionRepo.GetByID(ctx, id)
+func (s *SubscriptionService) GetSubscriptionByID(ctx context.Context, userID int64, id int) (*models.Subscription, error) {
+	return s.subscriptionRepo.GetByID(ctx, userID, id)
 }
 
-func (s *SubscriptionService) DeleteSubscription(ctx context.Context, id int) error {
-	return s.subscriptionRepo.Delete(ctx, id)
+// GetPriceHistory returns every price the subscription has had, oldest first
+func (s *SubscriptionService) GetPriceHistory(ctx context.Context, userID int64, id int) ([]*models.PriceChange, error) {
+	return s.priceHistoryRepo.GetBySubscriptionID(ctx, userID, id)
 }
 
-func (s *SubscriptionService) MarkAsPaid(ctx context.Context, subscriptionID int) error {
-	subscription, err := s.subscriptionRepo.GetByID(ctx, subscriptionID)
+func (s *SubscriptionService) DeleteSubscription(ctx context.Context, userID int64, id int) error {
+	return s.subscriptionRepo.Delete(ctx, userID, id)
+}
+
+// MarkAsPaid records the subscription cost paid now for the billing cycle due on dueDate
+func (s *SubscriptionService) MarkAsPaid(ctx context.Context, userID int64, subscriptionID int, dueDate time.Time) (*models.Payment, error) {
+	subscription, err := s.subscriptionRepo.GetByID(ctx, userID, subscriptionID)
 	if err != nil {
-		return fmt.Errorf("failed to get subscription: %w", err)
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	// Create payment record
-	paymentReq := &models.CreatePaymentRequest{
+	return s.RecordPayment(ctx, &models.CreatePaymentRequest{
+		UserID:         userID,
 		SubscriptionID: subscriptionID,
 		Amount:         subscription.Cost,
 		Currency:       subscription.Currency,
 		Status:         models.PaymentStatusCompleted,
+	}, &dueDate)
+}
+
+// RecordPayment records a payment with any amount, date, status and note.
+// With a dueDate the payment settles that billing cycle: the payment is saved
+// and the subscription moves to the next cycle, both or neither. Paying the
+// same cycle twice, e.g. after a double tap, returns ErrAlreadyPaid and
+// records nothing.
+func (s *SubscriptionService) RecordPayment(ctx context.Context, req *models.CreatePaymentRequest, dueDate *time.Time) (*models.Payment, error) {
+	paidAt := time.Now()
+	if req.PaidAt != nil {
+		paidAt = *req.PaidAt
 	}
+	if err := validatePayment(req.Amount, req.Currency, req.Status, paidAt, req.Note); err != nil {
+		return nil, err
+	}
+	if dueDate != nil && req.Status != models.PaymentStatusCompleted {
+		return nil, invalidf("only completed payments settle a billing cycle")
+	}
+
+	var payment *models.Payment
+	err := s.uow.Do(ctx, func(tx repository.Stores) error {
+		subscriptions := tx.Subscriptions
 
-	_, err = s.paymentRepo.Create(ctx, paymentReq)
+		// The lock makes a concurrent payment of the same subscription wait and see the new date
+		subscription, err := subscriptions.GetByIDForUpdate(ctx, req.UserID, req.SubscriptionID)
+		if err != nil {
+			return err
+		}
+
+		if dueDate != nil {
+			if !models.DateOnly(subscription.NextPayment).Equal(models.DateOnly(*dueDate)) {
+				return ErrAlreadyPaid
+			}
+			req.IdempotencyKey = models.BillingCycleKey(subscription.ID, *dueDate)
+		}
+
+		payment, err = tx.Payments.Create(ctx, req)
+		if errors.Is(err, repository.ErrDuplicatePayment) {
+			return ErrAlreadyPaid
+		}
+		if err != nil || dueDate == nil {
+			return err
+		}
+
+		subscription.UpdateNextPayment()
+		if err := subscriptions.Update(ctx, subscription); err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create payment: %w", err)
+		return nil, err
 	}
 
-	// Update next payment date
-	subscription.UpdateNextPayment()
-	err = s.subscriptionRepo.Update(ctx, subscription)
+	return payment, nil
+}
+
+func (s *SubscriptionService) GetPayment(ctx context.Context, userID int64, id int) (*models.Payment, error) {
+	return s.paymentRepo.GetByID(ctx, userID, id)
+}
+
+// UpdatePayment changes the requested fields of a payment. The subscription
+// payment date is not moved, even when the payment settled a billing cycle.
+func (s *SubscriptionService) UpdatePayment(ctx context.Context, userID int64, id int, req *models.UpdatePaymentRequest) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, userID, id)
 	if err != nil {
-		return fmt.Errorf("failed to update subscription: %w", err)
+		return nil, err
+	}
+
+	req.Apply(payment)
+	if err := validatePayment(payment.Amount, payment.Currency, payment.Status, payment.PaidAt, payment.Note); err != nil {
+		return nil, err
 	}
 
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// VoidPayment marks a payment recorded by mistake. Voided payments stay in
+// the history but are left out of expenses and analytics.
+func (s *SubscriptionService) VoidPayment(ctx context.Context, userID int64, id int) (*models.Payment, error) {
+	status := models.PaymentStatusVoided
+	return s.UpdatePayment(ctx, userID, id, &models.UpdatePaymentRequest{Status: &status})
+}
+
+func validatePayment(amount models.Money, currency models.Currency, status models.PaymentStatus, paidAt time.Time, note string) error {
+	if amount < 0 {
+		return invalidf("payment amount must not be negative")
+	}
+	if !currency.IsValid() {
+		return invalidf("unsupported currency: %s", currency)
+	}
+	if !status.IsValid() {
+		return invalidf("unknown payment status: %s", status)
+	}
+	if status == models.PaymentStatusCompleted && paidAt.After(time.Now()) {
+		return invalidf("completed payment can't be in the future")
+	}
+	if len(note) > 500 {
+		return invalidf("payment note is too long")
+	}
 	return nil
 }
 
-func (s *SubscriptionService) GetDuePayments(ctx context.Context) ([]*models.Subscription, error) {
-	return s.subscriptionRepo.GetDuePayments(ctx)
+func (s *SubscriptionService) GetDuePayments(ctx context.Context, userID int64) ([]*models.Subscription, error) {
+	return s.subscriptionRepo.GetDuePayments(ctx, userID)
+}
+
+func (s *SubscriptionService) GetSubscriptionsByCategory(ctx context.Context, userID int64, category models.Category) ([]*models.Subscription, error) {
+	return s.subscriptionRepo.GetByCategory(ctx, userID, category)
 }
 
-func (s *SubscriptionService) GetSubscriptionsByCategory(ctx context.Context, category models.Category) ([]*models.Subscription, error) {
-	return s.subscriptionRepo.GetByCategory(ctx, category)
+// GetSubscriptionsByTag returns the active subscriptions with the tag
+func (s *SubscriptionService) GetSubscriptionsByTag(ctx context.Context, userID int64, tag string) ([]*models.Subscription, error) {
+	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagged []*models.Subscription
+	for _, sub := range subscriptions {
+		if sub.HasTag(tag) {
+			tagged = append(tagged, sub)
+		}
+	}
+	return tagged, nil
+}
+
+// GetTags returns the tags of the active subscriptions of the user, sorted
+func (s *SubscriptionService) GetTags(ctx context.Context, userID int64) ([]string, error) {
+	subscriptions, err := s.subscriptionRepo.GetAllActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, sub := range subscriptions {
+		tags = append(tags, sub.Tags...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
 }
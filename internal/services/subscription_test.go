@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sub-cos-counter/internal/models"
+	"sub-cos-counter/internal/repository"
+	"sub-cos-counter/internal/repository/memory"
+	"sub-cos-counter/internal/repository/sqlite"
+	"sub-cos-counter/internal/testdb"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMarkAsPaidRecordsOnePaymentPerCycle(t *testing.T) {
+	testMarkAsPaidRecordsOnePaymentPerCycle(t, repository.NewStorage(testdb.New(t)))
+}
+
+func TestMarkAsPaidRecordsOnePaymentPerCycleSQLite(t *testing.T) {
+	testMarkAsPaidRecordsOnePaymentPerCycle(t, sqlite.NewStorage(testdb.NewSQLite(t)))
+}
+
+func testMarkAsPaidRecordsOnePaymentPerCycle(t *testing.T, storage *repository.Storage) {
+	ctx := context.Background()
+
+	subscriptionRepo := storage.Subscriptions
+	paymentRepo := storage.Payments
+	service := NewSubscriptionService(storage.UnitOfWork, subscriptionRepo, paymentRepo, storage.PriceHistory, NewCategoryService(storage.Categories))
+
+	due := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	sub, err := service.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Spotify",
+		Cost:        models.NewMoney(999),
+		Currency:    models.CurrencyEUR,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: due,
+		Category:    models.CategoryEntertainment,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	// A double tap on the pay button
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = service.MarkAsPaid(ctx, 1, sub.ID, due)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrAlreadyPaid):
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one successful payment, got %d", succeeded)
+	}
+
+	payments, err := paymentRepo.GetBySubscriptionID(ctx, 1, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get payments: %v", err)
+	}
+	if len(payments) != 1 {
+		t.Errorf("Expected 1 payment for the cycle, got %d", len(payments))
+	}
+
+	updated, err := subscriptionRepo.GetByID(ctx, 1, sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if expected := time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC); !updated.NextPayment.Equal(expected) {
+		t.Errorf("Expected next payment %v, got %v", expected, updated.NextPayment)
+	}
+
+	// The next cycle can be paid as usual
+	if _, err := service.MarkAsPaid(ctx, 1, sub.ID, updated.NextPayment); err != nil {
+		t.Errorf("Failed to pay the next cycle: %v", err)
+	}
+}
+
+func TestImportSubscriptionsReportsInvalidRows(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+
+	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	service := NewSubscriptionService(repository.NewUnitOfWork(db), subscriptionRepo,
+		repository.NewPaymentRepository(db), repository.NewPriceHistoryRepository(db),
+		NewCategoryService(repository.NewCategoryRepository(db)))
+
+	valid := func(name string, cost models.Money) *models.CreateSubscriptionRequest {
+		return &models.CreateSubscriptionRequest{
+			Name:        name,
+			Cost:        cost,
+			Currency:    models.CurrencyEUR,
+			Period:      models.Every(1, models.PeriodMonthly),
+			NextPayment: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+			Category:    models.CategoryOther,
+		}
+	}
+
+	report, err := service.ImportSubscriptions(ctx, 1, []models.ImportRow{
+		{Line: 2, Request: valid("Netflix", models.NewMoney(1599))},
+		{Line: 3, Request: valid("Free", 0)},
+		{Line: 4, Err: errors.New("invalid cost")},
+		{Line: 5, Request: valid("Spotify", models.NewMoney(999))},
+	})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+
+	if len(report.Created) != 2 || len(report.Failed) != 2 {
+		t.Fatalf("Expected 2 created and 2 failed rows, got %+v", report)
+	}
+	if report.Failed[0].Line != 3 || report.Failed[0].Name != "Free" || report.Failed[1].Line != 4 {
+		t.Errorf("Unexpected failed rows: %+v", report.Failed)
+	}
+
+	// Imported subscriptions are anchored like the ones created in the bot
+	if report.Created[0].Period.AnchorDay != 31 {
+		t.Errorf("Expected anchor day 31, got %d", report.Created[0].Period.AnchorDay)
+	}
+
+	subscriptions, err := subscriptionRepo.GetAllActive(ctx, 1)
+	if err != nil || len(subscriptions) != 2 {
+		t.Errorf("Expected 2 active subscriptions, got %d (%v)", len(subscriptions), err)
+	}
+}
+
+func TestSubscriptionTagsNotesAndLink(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	service := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+
+	sub, err := service.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        "Netflix",
+		Cost:        models.NewMoney(1599),
+		Currency:    models.CurrencyUSD,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Category:    models.CategoryEntertainment,
+		Tags:        []string{" #Family", "cancel soon", "family"},
+		Notes:       "  shared with Anna  ",
+		URL:         "https://netflix.com/cancel",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	if !slices.Equal(sub.Tags, []string{"cancel soon", "family"}) || sub.Notes != "shared with Anna" {
+		t.Errorf("Expected normalized tags and trimmed notes, got %v %q", sub.Tags, sub.Notes)
+	}
+
+	tagged, err := service.GetSubscriptionsByTag(ctx, 1, "family")
+	if err != nil || len(tagged) != 1 {
+		t.Errorf("Expected the subscription with the tag, got %d (%v)", len(tagged), err)
+	}
+	if tags, _ := service.GetTags(ctx, 1); !slices.Equal(tags, []string{"cancel soon", "family"}) {
+		t.Errorf("Expected the tags of the user, got %v", tags)
+	}
+
+	invalid := []*models.UpdateSubscriptionRequest{
+		{URL: ptr("netflix.com")},
+		{URL: ptr("javascript:alert(1)")},
+		{Tags: &[]string{strings.Repeat("x", maxTagLength+1)}},
+		{Notes: ptr(strings.Repeat("x", 1001))},
+	}
+	for _, req := range invalid {
+		_, err := service.UpdateSubscription(ctx, 1, sub.ID, req)
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			t.Errorf("Expected a validation error for %+v, got %v", req, err)
+		}
+	}
+
+	updated, err := service.UpdateSubscription(ctx, 1, sub.ID, &models.UpdateSubscriptionRequest{Tags: &[]string{}, URL: ptr("")})
+	if err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+	if updated.Tags != nil || updated.URL != "" || updated.Notes != "shared with Anna" {
+		t.Errorf("Expected the tags and the link removed, got %+v", updated)
+	}
+}
+
+// Test that the name limit counts characters, a Cyrillic letter takes two bytes
+func TestSubscriptionNameLengthCountsCharacters(t *testing.T) {
+	storage := memory.New()
+	ctx := context.Background()
+	service := NewSubscriptionService(storage.UnitOfWork, storage.Subscriptions, storage.Payments, storage.PriceHistory, NewCategoryService(storage.Categories))
+
+	sub, err := service.CreateSubscription(ctx, &models.CreateSubscriptionRequest{
+		UserID:      1,
+		Name:        strings.Repeat("я", 255),
+		Cost:        models.NewMoney(29900),
+		Currency:    models.CurrencyRUB,
+		Period:      models.Every(1, models.PeriodMonthly),
+		NextPayment: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Category:    models.CategoryEntertainment,
+	})
+	if err != nil {
+		t.Fatalf("Expected a name of 255 characters to be accepted, got %v", err)
+	}
+
+	_, err = service.UpdateSubscription(ctx, 1, sub.ID, &models.UpdateSubscriptionRequest{Name: ptr(strings.Repeat("я", 256))})
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Errorf("Expected a validation error for a name of 256 characters, got %v", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,99 @@
+// Package testdb gives tests an empty database with the current structure,
+// created by the migrations. PostgreSQL tests are skipped unless
+// TEST_DATABASE_URL is set, SQLite tests use a temporary file.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sub-cos-counter/internal/migrate"
+	"sub-cos-counter/internal/repository/sqlite"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// New creates a temporary schema, migrates it and returns a pool bound to it.
+// The schema is dropped when the test finishes.
+func New(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool := Empty(t)
+	runner, err := migrate.Postgres(pool, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := runner.Up(context.Background(), false); err != nil {
+		t.Fatalf("Failed to migrate test schema: %v", err)
+	}
+
+	return pool
+}
+
+// Empty creates a temporary schema without tables, like New
+func Empty(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("Failed to parse TEST_DATABASE_URL: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("Failed to connect to test schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pool.Close()
+		admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	return pool
+}
+
+// NewSQLite creates a migrated database in a temporary file. It is closed
+// when the test finishes.
+func NewSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrate.SQLite(db, t.Logf)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := runner.Up(ctx, false); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	return db
+}
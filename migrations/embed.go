@@ -0,0 +1,36 @@
+// Package migrations embeds the numbered schema migrations of every storage
+// backend, so the binary can bring its database up to date by itself.
+//
+// Each backend has its own directory with NNNN_name.up.sql and
+// NNNN_name.down.sql files, numbered from the schema the backend started
+// with: the original schema.sql for PostgreSQL, whose later changes follow it
+// one by one, and the complete schema of the time for SQLite. The versions of
+// the backends differ, a change of the schema adds the next number to every
+// directory.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Postgres returns the migrations of the PostgreSQL backend
+func Postgres() fs.FS {
+	return sub("postgres")
+}
+
+// SQLite returns the migrations of the SQLite backend
+func SQLite() fs.FS {
+	return sub("sqlite")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_payments_user_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS user_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,15 @@
+-- Add owner columns to subscriptions and payments.
+-- Rows that existed before this migration belong to the owner the bot is
+-- configured with (telegram.allowed_user). Without one the migration fails
+-- while there are such rows, nobody could see them.
+ALTER TABLE subscriptions ADD COLUMN user_id BIGINT;
+ALTER TABLE payments ADD COLUMN user_id BIGINT;
+
+UPDATE subscriptions SET user_id = (SELECT user_id FROM migration_owner);
+UPDATE payments SET user_id = (SELECT user_id FROM migration_owner);
+
+ALTER TABLE subscriptions ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE payments ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX idx_payments_user_id ON payments(user_id);
@@ -0,0 +1,12 @@
+-- Track sent payment reminders so each one is delivered once per billing cycle
+CREATE TABLE payment_reminders (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    due_date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('upcoming', 'due')),
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    snoozed_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (subscription_id, due_date, kind)
+);
+
+CREATE INDEX idx_payment_reminders_snoozed_until ON payment_reminders(snoozed_until) WHERE snoozed_until IS NOT NULL;
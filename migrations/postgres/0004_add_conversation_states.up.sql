@@ -0,0 +1,9 @@
+-- Persist unfinished bot conversations across restarts
+CREATE TABLE conversation_states (
+    user_id BIGINT PRIMARY KEY,
+    state VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_conversation_states_updated_at ON conversation_states(updated_at);
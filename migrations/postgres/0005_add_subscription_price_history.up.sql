@@ -0,0 +1,16 @@
+-- Keep every price a subscription has had so cost changes can be explained later
+CREATE TABLE subscription_price_history (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    cost INTEGER NOT NULL,
+    currency VARCHAR(3) NOT NULL CHECK (currency IN ('USD', 'RUB')),
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    effective_to TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_price_history_subscription_id ON subscription_price_history(subscription_id, effective_from);
+
+-- The current price of existing subscriptions is the first known one
+INSERT INTO subscription_price_history (subscription_id, cost, currency, effective_from)
+SELECT id, cost, currency, created_at FROM subscriptions;
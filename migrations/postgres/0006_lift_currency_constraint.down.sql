@@ -0,0 +1,9 @@
+-- Fails while rows in other currencies than USD and RUB remain
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_currency_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_currency_check CHECK (currency IN ('USD', 'RUB'));
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_currency_check;
+ALTER TABLE payments ADD CONSTRAINT payments_currency_check CHECK (currency IN ('USD', 'RUB'));
+
+ALTER TABLE subscription_price_history DROP CONSTRAINT IF EXISTS subscription_price_history_currency_check;
+ALTER TABLE subscription_price_history ADD CONSTRAINT subscription_price_history_currency_check CHECK (currency IN ('USD', 'RUB'));
@@ -0,0 +1,10 @@
+-- Currencies are validated against the ISO 4217 registry in the application,
+-- the database only checks that the code looks like one
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_currency_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_currency_check;
+ALTER TABLE payments ADD CONSTRAINT payments_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE subscription_price_history DROP CONSTRAINT IF EXISTS subscription_price_history_currency_check;
+ALTER TABLE subscription_price_history ADD CONSTRAINT subscription_price_history_currency_check CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,16 @@
+-- Exchange rates used to report totals in one currency
+CREATE TABLE exchange_rates (
+    currency_from VARCHAR(3) NOT NULL,
+    currency_to VARCHAR(3) NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    source VARCHAR(50) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (currency_from, currency_to)
+);
+
+-- Per-user preferences
+CREATE TABLE user_settings (
+    user_id BIGINT PRIMARY KEY,
+    base_currency VARCHAR(3) NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+-- Calendar periods become their usual length in days
+ALTER TABLE subscriptions ADD COLUMN period_days INTEGER;
+
+UPDATE subscriptions SET period_days = period_interval * CASE period_unit
+    WHEN 'week' THEN 7
+    WHEN 'month' THEN 30
+    WHEN 'quarter' THEN 90
+    WHEN 'year' THEN 365
+    ELSE 1
+END;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN period_days SET NOT NULL,
+    DROP COLUMN period_unit,
+    DROP COLUMN period_interval,
+    DROP COLUMN anchor_day;
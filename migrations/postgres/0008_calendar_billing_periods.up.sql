@@ -0,0 +1,29 @@
+-- Replace fixed periods in days with calendar periods anchored to a day of month
+ALTER TABLE subscriptions
+    ADD COLUMN period_unit VARCHAR(10) NOT NULL DEFAULT 'month'
+        CHECK (period_unit IN ('day', 'week', 'month', 'quarter', 'year')),
+    ADD COLUMN period_interval INTEGER NOT NULL DEFAULT 1 CHECK (period_interval > 0),
+    ADD COLUMN anchor_day SMALLINT NOT NULL DEFAULT 0 CHECK (anchor_day BETWEEN 0 AND 31);
+
+-- Same mapping as models.PeriodFromDays
+UPDATE subscriptions SET
+    period_unit = CASE
+        WHEN period_days BETWEEN 28 AND 31 THEN 'month'
+        WHEN period_days BETWEEN 89 AND 92 THEN 'quarter'
+        WHEN period_days BETWEEN 365 AND 366 THEN 'year'
+        WHEN period_days % 7 = 0 THEN 'week'
+        ELSE 'day'
+    END,
+    period_interval = CASE
+        WHEN period_days BETWEEN 28 AND 31 THEN 1
+        WHEN period_days BETWEEN 89 AND 92 THEN 1
+        WHEN period_days BETWEEN 365 AND 366 THEN 1
+        WHEN period_days % 7 = 0 THEN period_days / 7
+        ELSE period_days
+    END;
+
+-- Month based periods keep the current billing day
+UPDATE subscriptions SET anchor_day = EXTRACT(DAY FROM next_payment)
+WHERE period_unit IN ('month', 'quarter', 'year');
+
+ALTER TABLE subscriptions DROP COLUMN period_days;
@@ -0,0 +1,11 @@
+DELETE FROM payment_reminders WHERE kind = 'trial_ending';
+
+ALTER TABLE payment_reminders DROP CONSTRAINT payment_reminders_kind_check;
+ALTER TABLE payment_reminders ADD CONSTRAINT payment_reminders_kind_check
+    CHECK (kind IN ('upcoming', 'due'));
+
+DROP INDEX IF EXISTS idx_subscriptions_trial_ends_at;
+
+ALTER TABLE subscriptions
+    DROP COLUMN trial_ends_at,
+    DROP COLUMN trial_cost;
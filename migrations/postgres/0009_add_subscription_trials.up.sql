@@ -0,0 +1,11 @@
+-- Free and discounted trials that convert to the paid plan on trial_ends_at
+ALTER TABLE subscriptions
+    ADD COLUMN trial_ends_at DATE,
+    ADD COLUMN trial_cost INTEGER NOT NULL DEFAULT 0 CHECK (trial_cost >= 0);
+
+CREATE INDEX idx_subscriptions_trial_ends_at ON subscriptions(trial_ends_at) WHERE trial_ends_at IS NOT NULL;
+
+-- Reminders before a trial converts to paid
+ALTER TABLE payment_reminders DROP CONSTRAINT payment_reminders_kind_check;
+ALTER TABLE payment_reminders ADD CONSTRAINT payment_reminders_kind_check
+    CHECK (kind IN ('upcoming', 'due', 'trial_ending'));
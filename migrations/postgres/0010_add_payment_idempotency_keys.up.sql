@@ -0,0 +1,2 @@
+-- Each billing cycle is paid at most once, repeated requests reuse the key
+ALTER TABLE payments ADD COLUMN idempotency_key VARCHAR(100) UNIQUE;
@@ -0,0 +1,8 @@
+-- Voided payments are kept as failed ones, which no total counts either
+UPDATE payments SET status = 'failed' WHERE status = 'voided';
+
+ALTER TABLE payments DROP CONSTRAINT payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('completed', 'pending', 'failed'));
+
+ALTER TABLE payments DROP COLUMN IF EXISTS note;
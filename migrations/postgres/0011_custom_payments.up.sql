@@ -0,0 +1,6 @@
+-- Notes on payments and voiding payments recorded by mistake
+ALTER TABLE payments ADD COLUMN note TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE payments DROP CONSTRAINT payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('completed', 'pending', 'failed', 'voided'));
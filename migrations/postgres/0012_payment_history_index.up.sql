@@ -0,0 +1,2 @@
+-- Keyset pagination of the payment history by (paid_at, id), newest first
+CREATE INDEX idx_payments_user_paid_at ON payments(user_id, paid_at DESC, id DESC);
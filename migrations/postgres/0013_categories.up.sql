@@ -0,0 +1,30 @@
+-- Categories of subscriptions, created by each user. Subscriptions refer to a
+-- category by its key within the user, categories are archived, not deleted.
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL, -- Telegram user ID of the owner
+    key VARCHAR(50) NOT NULL, -- stored in subscriptions.category, never changes
+    name VARCHAR(50) NOT NULL,
+    emoji VARCHAR(16) NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, key)
+);
+
+-- The former fixed categories become the defaults of existing users
+INSERT INTO categories (user_id, key, name, emoji)
+SELECT users.user_id, defaults.key, defaults.name, defaults.emoji
+FROM (
+    SELECT user_id FROM subscriptions WHERE user_id IS NOT NULL
+    UNION
+    SELECT user_id FROM user_settings
+) users
+CROSS JOIN (VALUES
+    (1, 'entertainment', 'Развлечения', '🎮'),
+    (2, 'work', 'Работа', '💼'),
+    (3, 'education', 'Обучение', '📚'),
+    (4, 'home', 'Дом', '🏠'),
+    (5, 'other', 'Другое', '📦')
+) AS defaults (position, key, name, emoji)
+ORDER BY users.user_id, defaults.position;
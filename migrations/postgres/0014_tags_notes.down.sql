@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS notes,
+    DROP COLUMN IF EXISTS url;
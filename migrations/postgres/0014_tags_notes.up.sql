@@ -0,0 +1,22 @@
+-- Free-text notes and the link to manage or cancel a subscription
+ALTER TABLE subscriptions
+    ADD COLUMN notes VARCHAR(1000) NOT NULL DEFAULT '',
+    ADD COLUMN url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- Tags of each user, like "семья" or "отменить"
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL, -- Telegram user ID of the owner
+    name VARCHAR(50) NOT NULL, -- lowercase
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+-- Tags of subscriptions, a subscription has any number of tags and a tag any number of subscriptions
+CREATE TABLE subscription_tags (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX idx_subscription_tags_tag_id ON subscription_tags(tag_id);
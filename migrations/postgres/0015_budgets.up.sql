@@ -0,0 +1,12 @@
+-- Monthly spending limits of each user per currency, in one category or,
+-- with an empty category, in all of them
+CREATE TABLE budgets (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL, -- Telegram user ID of the owner
+    category VARCHAR(50) NOT NULL DEFAULT '', -- key of a category, empty for the overall budget
+    currency VARCHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'), -- ISO 4217 code
+    amount INTEGER NOT NULL CHECK (amount > 0), -- stored in cents/kopecks
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, category, currency)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS conversation_states;
+DROP TABLE IF EXISTS payment_reminders;
+DROP TABLE IF EXISTS subscription_price_history;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,97 @@
+-- The tables of the PostgreSQL schema in SQLite. Timestamps are microseconds
+-- since the Unix epoch, dates are YYYY-MM-DD text, booleans are 0 or 1.
+
+-- Subscriptions table
+CREATE TABLE subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER, -- Telegram user ID of the owner
+    name TEXT NOT NULL CHECK (length(name) <= 255),
+    cost INTEGER NOT NULL, -- stored in cents/kopecks
+    currency TEXT NOT NULL CHECK (currency GLOB '[A-Z][A-Z][A-Z]'), -- ISO 4217 code
+    period_unit TEXT NOT NULL DEFAULT 'month' CHECK (period_unit IN ('day', 'week', 'month', 'quarter', 'year')),
+    period_interval INTEGER NOT NULL DEFAULT 1 CHECK (period_interval > 0), -- e.g. every 2 months
+    anchor_day INTEGER NOT NULL DEFAULT 0 CHECK (anchor_day BETWEEN 0 AND 31), -- billing day of month, 0 - not anchored
+    next_payment TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (length(category) <= 50),
+    auto_renewal INTEGER NOT NULL DEFAULT 1,
+    active INTEGER NOT NULL DEFAULT 1,
+    trial_ends_at TEXT, -- the trial converts to paid on this day
+    trial_cost INTEGER NOT NULL DEFAULT 0 CHECK (trial_cost >= 0), -- price of the trial in cents
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+-- Payments table
+CREATE TABLE payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER, -- Telegram user ID of the owner
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL, -- stored in cents/kopecks
+    currency TEXT NOT NULL CHECK (currency GLOB '[A-Z][A-Z][A-Z]'), -- ISO 4217 code
+    paid_at INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'completed' CHECK (status IN ('completed', 'pending', 'failed', 'voided')),
+    idempotency_key TEXT UNIQUE CHECK (length(idempotency_key) <= 100), -- one payment per billing cycle
+    note TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+-- Price periods of subscriptions, the open one (effective_to IS NULL) is current
+CREATE TABLE subscription_price_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    cost INTEGER NOT NULL, -- stored in cents/kopecks
+    currency TEXT NOT NULL CHECK (currency GLOB '[A-Z][A-Z][A-Z]'), -- ISO 4217 code
+    effective_from INTEGER NOT NULL,
+    effective_to INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+-- Sent payment reminders, one row per reminder kind and billing cycle
+CREATE TABLE payment_reminders (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    due_date TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('upcoming', 'due', 'trial_ending')),
+    sent_at INTEGER NOT NULL,
+    snoozed_until INTEGER,
+    PRIMARY KEY (subscription_id, due_date, kind)
+);
+
+-- Unfinished bot conversations, kept across restarts
+CREATE TABLE conversation_states (
+    user_id INTEGER PRIMARY KEY,
+    state TEXT NOT NULL CHECK (length(state) <= 50),
+    data TEXT NOT NULL DEFAULT '{}', -- JSON
+    updated_at INTEGER NOT NULL
+);
+
+-- Exchange rates, one unit of currency_from costs rate units of currency_to
+CREATE TABLE exchange_rates (
+    currency_from TEXT NOT NULL,
+    currency_to TEXT NOT NULL,
+    rate REAL NOT NULL CHECK (rate > 0),
+    source TEXT NOT NULL, -- manual or provider name
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (currency_from, currency_to)
+);
+
+-- Per-user preferences
+CREATE TABLE user_settings (
+    user_id INTEGER PRIMARY KEY,
+    base_currency TEXT NOT NULL CHECK (base_currency GLOB '[A-Z][A-Z][A-Z]'),
+    updated_at INTEGER NOT NULL
+);
+
+-- Indexes for better performance
+CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX idx_subscriptions_active ON subscriptions(active);
+CREATE INDEX idx_subscriptions_next_payment ON subscriptions(next_payment);
+CREATE INDEX idx_subscriptions_category ON subscriptions(category);
+CREATE INDEX idx_subscriptions_trial_ends_at ON subscriptions(trial_ends_at) WHERE trial_ends_at IS NOT NULL;
+CREATE INDEX idx_payments_user_id ON payments(user_id);
+CREATE INDEX idx_payments_subscription_id ON payments(subscription_id);
+CREATE INDEX idx_payments_paid_at ON payments(paid_at);
+CREATE INDEX idx_payments_user_paid_at ON payments(user_id, paid_at DESC, id DESC);
+CREATE INDEX idx_price_history_subscription_id ON subscription_price_history(subscription_id, effective_from);
+CREATE INDEX idx_payment_reminders_snoozed_until ON payment_reminders(snoozed_until) WHERE snoozed_until IS NOT NULL;
+CREATE INDEX idx_conversation_states_updated_at ON conversation_states(updated_at);
@@ -0,0 +1,31 @@
+-- Categories of subscriptions, created by each user. Subscriptions refer to a
+-- category by its key within the user, categories are archived, not deleted.
+CREATE TABLE categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Telegram user ID of the owner
+    key TEXT NOT NULL CHECK (length(key) <= 50), -- stored in subscriptions.category, never changes
+    name TEXT NOT NULL CHECK (length(name) <= 50),
+    emoji TEXT NOT NULL DEFAULT '' CHECK (length(emoji) <= 16),
+    archived INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    UNIQUE (user_id, key)
+);
+
+-- The former fixed categories become the defaults of existing users
+INSERT INTO categories (user_id, key, name, emoji, created_at, updated_at)
+SELECT users.user_id, defaults.column2, defaults.column3, defaults.column4,
+       CAST(unixepoch('subsec') * 1000000 AS INTEGER), CAST(unixepoch('subsec') * 1000000 AS INTEGER)
+FROM (
+    SELECT user_id FROM subscriptions WHERE user_id IS NOT NULL
+    UNION
+    SELECT user_id FROM user_settings
+) users
+CROSS JOIN (VALUES
+    (1, 'entertainment', 'Развлечения', '🎮'),
+    (2, 'work', 'Работа', '💼'),
+    (3, 'education', 'Обучение', '📚'),
+    (4, 'home', 'Дом', '🏠'),
+    (5, 'other', 'Другое', '📦')
+) AS defaults
+ORDER BY users.user_id, defaults.column1;
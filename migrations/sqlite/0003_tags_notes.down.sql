@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
+
+ALTER TABLE subscriptions DROP COLUMN notes;
+ALTER TABLE subscriptions DROP COLUMN url;
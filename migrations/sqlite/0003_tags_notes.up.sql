@@ -0,0 +1,21 @@
+-- Free-text notes and the link to manage or cancel a subscription
+ALTER TABLE subscriptions ADD COLUMN notes TEXT NOT NULL DEFAULT '' CHECK (length(notes) <= 1000);
+ALTER TABLE subscriptions ADD COLUMN url TEXT NOT NULL DEFAULT '' CHECK (length(url) <= 2048);
+
+-- Tags of each user, like "семья" or "отменить"
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Telegram user ID of the owner
+    name TEXT NOT NULL CHECK (length(name) <= 50), -- lowercase
+    created_at INTEGER NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+-- Tags of subscriptions, a subscription has any number of tags and a tag any number of subscriptions
+CREATE TABLE subscription_tags (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX idx_subscription_tags_tag_id ON subscription_tags(tag_id);
@@ -0,0 +1,12 @@
+-- Monthly spending limits of each user per currency, in one category or,
+-- with an empty category, in all of them
+CREATE TABLE budgets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Telegram user ID of the owner
+    category TEXT NOT NULL DEFAULT '' CHECK (length(category) <= 50), -- key of a category, empty for the overall budget
+    currency TEXT NOT NULL CHECK (currency GLOB '[A-Z][A-Z][A-Z]'), -- ISO 4217 code
+    amount INTEGER NOT NULL CHECK (amount > 0), -- stored in cents/kopecks
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    UNIQUE (user_id, category, currency)
+);
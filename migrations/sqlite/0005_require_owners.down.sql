@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS payments_user_id_update;
+DROP TRIGGER IF EXISTS payments_user_id_insert;
+DROP TRIGGER IF EXISTS subscriptions_user_id_update;
+DROP TRIGGER IF EXISTS subscriptions_user_id_insert;
@@ -0,0 +1,30 @@
+-- Rows without an owner belong to the owner the bot is configured with
+-- (telegram.allowed_user), nobody could see them otherwise
+UPDATE subscriptions SET user_id = (SELECT user_id FROM migration_owner) WHERE user_id IS NULL;
+UPDATE payments SET user_id = (SELECT s.user_id FROM subscriptions s WHERE s.id = payments.subscription_id)
+WHERE user_id IS NULL;
+
+-- SQLite can't make an existing column NOT NULL, the triggers refuse NULL instead
+CREATE TRIGGER subscriptions_user_id_insert BEFORE INSERT ON subscriptions WHEN NEW.user_id IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: subscriptions.user_id');
+END;
+
+CREATE TRIGGER subscriptions_user_id_update BEFORE UPDATE OF user_id ON subscriptions WHEN NEW.user_id IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: subscriptions.user_id');
+END;
+
+CREATE TRIGGER payments_user_id_insert BEFORE INSERT ON payments WHEN NEW.user_id IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: payments.user_id');
+END;
+
+CREATE TRIGGER payments_user_id_update BEFORE UPDATE OF user_id ON payments WHEN NEW.user_id IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: payments.user_id');
+END;
+
+-- Rows still without an owner, when none is configured, fail the triggers
+UPDATE subscriptions SET user_id = user_id WHERE user_id IS NULL;
+UPDATE payments SET user_id = user_id WHERE user_id IS NULL;